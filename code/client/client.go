@@ -0,0 +1,317 @@
+// Package client is a typed client of the products API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of a Client. Zero values take the defaults.
+type Config struct {
+	// BaseURL is the url of the server, e.g. "http://localhost:8080"
+	BaseURL string
+	// Token is sent in the Authorization header of every request
+	Token string
+	// HTTPClient is the client the requests are made with (default http.DefaultClient)
+	HTTPClient *http.Client
+	// MaxRetries is the number of retries of a request answered with 429 or 5xx (default 3, -1 disables them)
+	MaxRetries int
+	// MinBackoff is the wait before the first retry, doubled on every retry (default 100ms)
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum wait between retries (default 5s)
+	MaxBackoff time.Duration
+}
+
+// Client is a client of the products API, safe for concurrent use
+type Client struct {
+	cfg Config
+}
+
+// New returns a new Client
+func New(cfg Config) *Client {
+	// default config
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	switch {
+	case cfg.MaxRetries == 0:
+		cfg.MaxRetries = 3
+	case cfg.MaxRetries < 0:
+		cfg.MaxRetries = 0
+	}
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 5 * time.Second
+	}
+
+	return &Client{
+		cfg: cfg,
+	}
+}
+
+// ProductJSON is a product as returned by the API
+type ProductJSON struct {
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Quantity     int    `json:"quantity"`
+	Code_value   string `json:"code_value"`
+	Is_published bool   `json:"is_published"`
+	Expiration   string `json:"expiration"`
+	// Price is in major units of the currency, an ISO 4217 code
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+	// EffectivePrice is the price of a unit once the promotions apply, Promotions the ids of those that lowered it
+	EffectivePrice float64 `json:"effective_price"`
+	Promotions     []int   `json:"promotions,omitempty"`
+	// Reserved is the part of the quantity held by reservations, Available the rest
+	Reserved   int             `json:"reserved"`
+	Available  int             `json:"available"`
+	Attributes []AttributeJSON `json:"attributes,omitempty"`
+	Variants   []VariantJSON   `json:"variants,omitempty"`
+}
+
+// AttributeJSON is a custom property of a product or variant; the value is a string, a number
+// or a boolean, as the type says
+type AttributeJSON struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// VariantJSON is a SKU of a product, with its own code, quantity and price
+type VariantJSON struct {
+	Code_value string          `json:"code_value"`
+	Quantity   int             `json:"quantity"`
+	Price      float64         `json:"price"`
+	Attributes []AttributeJSON `json:"attributes,omitempty"`
+}
+
+// BodyProductJSON is a product as sent to the API to create or replace it
+type BodyProductJSON struct {
+	Name         string  `json:"name"`
+	Quantity     int     `json:"quantity"`
+	Code_value   string  `json:"code_value"`
+	Is_published bool    `json:"is_published"`
+	Expiration   string  `json:"expiration"`
+	Price        float64 `json:"price"`
+	// Currency is the currency of the prices, the default of the API if empty
+	Currency   string          `json:"currency,omitempty"`
+	Attributes []AttributeJSON `json:"attributes,omitempty"`
+	Variants   []VariantJSON   `json:"variants,omitempty"`
+}
+
+// PatchProductJSON is a partial product; nil fields are left unchanged
+type PatchProductJSON struct {
+	Name         *string  `json:"name,omitempty"`
+	Quantity     *int     `json:"quantity,omitempty"`
+	Code_value   *string  `json:"code_value,omitempty"`
+	Is_published *bool    `json:"is_published,omitempty"`
+	Expiration   *string  `json:"expiration,omitempty"`
+	Price        *float64 `json:"price,omitempty"`
+	Currency     *string  `json:"currency,omitempty"`
+	// Attributes and Variants replace those of the product, so a pointer to an empty slice removes them
+	Attributes *[]AttributeJSON `json:"attributes,omitempty"`
+	Variants   *[]VariantJSON   `json:"variants,omitempty"`
+}
+
+// List returns every product
+func (c *Client) List(ctx context.Context) (products []ProductJSON, err error) {
+	err = c.do(ctx, http.MethodGet, "/products", nil, &products)
+	return
+}
+
+// Each streams every product in id order, calling fn as each one arrives so the catalog
+// is never held in memory. It stops at the first error of fn.
+func (c *Client) Each(ctx context.Context, fn func(product ProductJSON) error) (err error) {
+	res, err := c.roundTrip(ctx, http.MethodGet, "/products", nil, "application/x-ndjson")
+	if err != nil {
+		return
+	}
+	if res.StatusCode >= 400 {
+		return c.decode(res, nil)
+	}
+	defer res.Body.Close()
+
+	dec := json.NewDecoder(res.Body)
+	for {
+		var product ProductJSON
+		if err = dec.Decode(&product); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		if err = fn(product); err != nil {
+			return
+		}
+	}
+}
+
+// Get returns a product by id
+func (c *Client) Get(ctx context.Context, id int) (product ProductJSON, err error) {
+	err = c.do(ctx, http.MethodGet, "/products/"+strconv.Itoa(id), nil, &product)
+	return
+}
+
+// Search returns the products whose price is greater than or equal to priceGt USD, converting the other currencies
+func (c *Client) Search(ctx context.Context, priceGt int) (products []ProductJSON, err error) {
+	q := url.Values{"priceGt": {strconv.Itoa(priceGt)}}
+	err = c.do(ctx, http.MethodGet, "/products/search?"+q.Encode(), nil, &products)
+	return
+}
+
+// Create creates a product and returns it with its id
+func (c *Client) Create(ctx context.Context, body BodyProductJSON) (product ProductJSON, err error) {
+	err = c.do(ctx, http.MethodPost, "/products", body, &product)
+	return
+}
+
+// Replace replaces the product with the id, creating it if it does not exist
+func (c *Client) Replace(ctx context.Context, id int, body BodyProductJSON) (product ProductJSON, err error) {
+	err = c.do(ctx, http.MethodPut, "/products/"+strconv.Itoa(id), body, &product)
+	return
+}
+
+// Patch updates the given fields of a product
+func (c *Client) Patch(ctx context.Context, id int, body PatchProductJSON) (product ProductJSON, err error) {
+	err = c.do(ctx, http.MethodPatch, "/products/"+strconv.Itoa(id), body, &product)
+	return
+}
+
+// Delete deletes a product
+func (c *Client) Delete(ctx context.Context, id int) (err error) {
+	err = c.do(ctx, http.MethodDelete, "/products/"+strconv.Itoa(id), nil, nil)
+	return
+}
+
+// do sends a request and decodes the data of the {"message","data"} envelope into out
+func (c *Client) do(ctx context.Context, method, path string, in, out any) (err error) {
+	var body []byte
+	if in != nil {
+		if body, err = json.Marshal(in); err != nil {
+			return
+		}
+	}
+
+	res, err := c.roundTrip(ctx, method, path, body, "application/json")
+	if err != nil {
+		return
+	}
+	return c.decode(res, out)
+}
+
+// roundTrip sends a request, retrying it on 429 and, for idempotent methods, on 5xx and network errors,
+// and returns the last response, whatever its status
+func (c *Client) roundTrip(ctx context.Context, method, path string, body []byte, accept string) (res *http.Response, err error) {
+	idempotent := method != http.MethodPost && method != http.MethodPatch
+
+	for attempt := 0; ; attempt++ {
+		res, err = c.send(ctx, method, path, body, accept)
+
+		var wait time.Duration
+		var retry bool
+		switch {
+		case err != nil:
+			retry = idempotent && ctx.Err() == nil
+		case res.StatusCode == http.StatusTooManyRequests:
+			// the request was rejected before being processed, so it is safe to retry,
+			// unless the server asks to wait longer than the client is willing to (e.g. a daily quota)
+			wait = retryAfter(res)
+			retry = wait <= c.cfg.MaxBackoff
+		case res.StatusCode >= 500:
+			retry = idempotent
+		}
+
+		if !retry || attempt >= c.cfg.MaxRetries {
+			return
+		}
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send makes a single request
+func (c *Client) send(ctx context.Context, method, path string, body []byte, accept string) (res *http.Response, err error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.BaseURL+path, rd)
+	if err != nil {
+		return
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", accept)
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", c.cfg.Token)
+	}
+	return c.cfg.HTTPClient.Do(req)
+}
+
+// decode reads a response, returning an APIError for error statuses
+func (c *Client) decode(res *http.Response, out any) (err error) {
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+
+	if res.StatusCode >= 400 {
+		return newAPIError(res.StatusCode, b)
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return
+	}
+
+	envelope := struct {
+		Data any `json:"data"`
+	}{Data: out}
+	if err = json.Unmarshal(b, &envelope); err != nil {
+		err = fmt.Errorf("client: decoding response: %w", err)
+	}
+	return
+}
+
+// backoff returns the wait before a retry: exponential with jitter, capped at MaxBackoff
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.MinBackoff << attempt
+	if d <= 0 || d > c.cfg.MaxBackoff {
+		d = c.cfg.MaxBackoff
+	}
+	// full jitter over the upper half, so concurrent clients do not retry in lockstep
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter returns the wait requested by the Retry-After header, in seconds, or 0
+func retryAfter(res *http.Response) time.Duration {
+	s, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || s < 0 {
+		return 0
+	}
+	return time.Duration(s) * time.Second
+}
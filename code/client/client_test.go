@@ -0,0 +1,210 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/client"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/auth/middleware"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+// newServer returns a server of the real product handlers, guarded by the token "secret".
+// wrap, if not nil, decorates the router.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+
+	db := map[int]*internal.Product{
+		1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "A1", Is_published: true, Expiration: time.Date(2030, time.January, 2, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 1000, Currency: "USD"}},
+		2: {Id: 2, Name: "Product 2", Quantity: 20, Code_value: "A2", Is_published: true, Expiration: time.Date(2030, time.January, 2, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 20000, Currency: "USD"}},
+	}
+	hd := handler.NewDefaultProducts(service.NewProductDefault(repository.NewProductRepository(db, 2)))
+	au := middleware.NewAuthenticator(auth.NewAuthTokenBasic("secret"))
+
+	rt := chi.NewRouter()
+	rt.Use(au.Auth)
+	rt.Route("/products", func(r chi.Router) {
+		r.Get("/", hd.GetAll())
+		r.Get("/{id}", hd.GetByID())
+		r.Get("/search", hd.Search())
+		r.Post("/", hd.Create())
+		r.Put("/{id}", hd.UpdateOrCreate())
+		r.Patch("/{id}", hd.Update())
+		r.Delete("/{id}", hd.Delete())
+	})
+
+	var h http.Handler = rt
+	if wrap != nil {
+		h = wrap(h)
+	}
+	sv := httptest.NewServer(h)
+	t.Cleanup(sv.Close)
+	return sv
+}
+
+func TestClient_Products(t *testing.T) {
+	sv := newServer(t, nil)
+	cl := client.New(client.Config{BaseURL: sv.URL, Token: "secret", MaxRetries: -1})
+	ctx := context.Background()
+
+	t.Run("list", func(t *testing.T) {
+		products, err := cl.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, products, 2)
+	})
+
+	t.Run("each", func(t *testing.T) {
+		var ids []int
+		err := cl.Each(ctx, func(p client.ProductJSON) error {
+			ids = append(ids, p.Id)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 2}, ids)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		p, err := cl.Get(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, client.ProductJSON{Id: 1, Name: "Product 1", Quantity: 10, Code_value: "A1", Is_published: true, Expiration: "02/01/2030", Price: 10, Currency: "USD", EffectivePrice: 10, Available: 10}, p)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		products, err := cl.Search(ctx, 100)
+		require.NoError(t, err)
+		require.Len(t, products, 1)
+		require.Equal(t, 2, products[0].Id)
+	})
+
+	t.Run("create, replace, patch and delete", func(t *testing.T) {
+		body := client.BodyProductJSON{Name: "Product 3", Quantity: 5, Code_value: "A3", Is_published: true, Expiration: "02/01/2030", Price: 30}
+		p, err := cl.Create(ctx, body)
+		require.NoError(t, err)
+		require.Equal(t, 3, p.Id)
+
+		body.Quantity = 6
+		p, err = cl.Replace(ctx, p.Id, body)
+		require.NoError(t, err)
+		require.Equal(t, 6, p.Quantity)
+
+		name := "Product 3b"
+		p, err = cl.Patch(ctx, p.Id, client.PatchProductJSON{Name: &name})
+		require.NoError(t, err)
+		require.Equal(t, "Product 3b", p.Name)
+		require.Equal(t, 6, p.Quantity)
+
+		require.NoError(t, cl.Delete(ctx, p.Id))
+
+		_, err = cl.Get(ctx, p.Id)
+		require.ErrorIs(t, err, client.ErrProductNotFound)
+	})
+
+	t.Run("typed errors", func(t *testing.T) {
+		_, err := cl.Get(ctx, 99)
+		require.ErrorIs(t, err, client.ErrProductNotFound)
+
+		var apiErr *client.APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+
+		_, err = cl.Create(ctx, client.BodyProductJSON{Name: "missing fields"})
+		require.ErrorIs(t, err, client.ErrBadRequest)
+
+		_, err = client.New(client.Config{BaseURL: sv.URL, Token: "wrong"}).List(ctx)
+		require.ErrorIs(t, err, client.ErrUnauthorized)
+	})
+}
+
+func TestClient_Retry(t *testing.T) {
+	// failing answers the first n requests with status, then passes them through
+	failing := func(n int32, status int, header http.Header) (func(http.Handler) http.Handler, *atomic.Int32) {
+		calls := new(atomic.Int32)
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= n {
+					for k, v := range header {
+						w.Header()[k] = v
+					}
+					http.Error(w, http.StatusText(status), status)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}, calls
+	}
+	cfg := client.Config{Token: "secret", MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	t.Run("retries 5xx of idempotent requests", func(t *testing.T) {
+		wrap, calls := failing(2, http.StatusServiceUnavailable, nil)
+		cfg := cfg
+		cfg.BaseURL = newServer(t, wrap).URL
+
+		_, err := client.New(cfg).Get(context.Background(), 1)
+		require.NoError(t, err)
+		require.EqualValues(t, 3, calls.Load())
+	})
+
+	t.Run("does not retry 5xx of non idempotent requests", func(t *testing.T) {
+		wrap, calls := failing(1, http.StatusInternalServerError, nil)
+		cfg := cfg
+		cfg.BaseURL = newServer(t, wrap).URL
+
+		_, err := client.New(cfg).Create(context.Background(), client.BodyProductJSON{})
+		require.ErrorIs(t, err, client.ErrServer)
+		require.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("retries 429 of any request", func(t *testing.T) {
+		wrap, calls := failing(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}})
+		cfg := cfg
+		cfg.BaseURL = newServer(t, wrap).URL
+
+		_, err := client.New(cfg).Create(context.Background(), client.BodyProductJSON{Name: "Product 3", Quantity: 1, Code_value: "A3", Expiration: "02/01/2030", Price: 1})
+		require.NoError(t, err)
+		require.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("gives up when retry after exceeds the max backoff", func(t *testing.T) {
+		wrap, calls := failing(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}})
+		cfg := cfg
+		cfg.BaseURL = newServer(t, wrap).URL
+
+		_, err := client.New(cfg).List(context.Background())
+		require.ErrorIs(t, err, client.ErrRateLimited)
+		require.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		wrap, calls := failing(10, http.StatusBadGateway, nil)
+		cfg := cfg
+		cfg.BaseURL = newServer(t, wrap).URL
+		cfg.MaxRetries = 2
+
+		_, err := client.New(cfg).List(context.Background())
+		require.ErrorIs(t, err, client.ErrServer)
+		require.EqualValues(t, 3, calls.Load())
+	})
+
+	t.Run("stops on context cancel", func(t *testing.T) {
+		wrap, _ := failing(10, http.StatusServiceUnavailable, nil)
+		cfg := cfg
+		cfg.BaseURL = newServer(t, wrap).URL
+		cfg.MinBackoff, cfg.MaxBackoff = time.Hour, time.Hour
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := client.New(cfg).List(ctx)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrProductNotFound mirrors internal.ErrProductNotFound: the product does not exist
+	ErrProductNotFound = errors.New("product not found")
+	// ErrBadRequest is returned when the server rejects the request as invalid
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnauthorized is returned when the token is missing or invalid
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the principal has no access, e.g. it belongs to no tenant
+	ErrForbidden = errors.New("forbidden")
+	// ErrRateLimited is returned when the rate limit or quota is still exceeded after the retries
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServer is returned when the server fails to process the request
+	ErrServer = errors.New("server error")
+)
+
+// APIError is an error response of the API. It matches the sentinel errors of its status with errors.Is.
+type APIError struct {
+	// StatusCode is the http status code of the response
+	StatusCode int
+	// Message is the message sent by the server
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Unwrap returns the sentinel error of the status code
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrProductNotFound
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrServer
+	case e.StatusCode >= 400:
+		return ErrBadRequest
+	}
+	return nil
+}
+
+// newAPIError builds an APIError from a response body, which is either plain text or a json error
+func newAPIError(statusCode int, body []byte) *APIError {
+	message := strings.TrimSpace(string(body))
+	var v struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &v) == nil && v.Message != "" {
+		message = v.Message
+	}
+	return &APIError{StatusCode: statusCode, Message: message}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rhinosc/web-market/code/internal/auth"
+)
+
+func runKeys(args []string, stdout io.Writer) (err error) {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, "usage: marketctl keys create|revoke|list [flags]")
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("marketctl keys "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	file := fs.String("file", "api_keys.json", "api keys file")
+	principal := fs.String("principal", "", "principal of the new key (create)")
+	id := fs.String("id", "", "id of the key to revoke (revoke)")
+	if err = parse(fs, args[1:]); err != nil {
+		return
+	}
+
+	keys, err := auth.LoadAPIKeys(*file)
+	if errors.Is(err, os.ErrNotExist) {
+		keys, err = nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	switch args[0] {
+	case "create":
+		if *principal == "" {
+			fmt.Fprintln(stdout, "-principal is required")
+			return errUsage
+		}
+		var key string
+		var stored auth.APIKey
+		if key, stored, err = auth.NewAPIKey(*principal); err != nil {
+			return
+		}
+		if err = auth.SaveAPIKeys(*file, append(keys, stored)); err != nil {
+			return
+		}
+		// the key is only ever shown here
+		fmt.Fprintf(stdout, "id:  %s\nkey: %s\n", stored.ID, key)
+
+	case "revoke":
+		if *id == "" {
+			fmt.Fprintln(stdout, "-id is required")
+			return errUsage
+		}
+		kept := make([]auth.APIKey, 0, len(keys))
+		for _, k := range keys {
+			if k.ID != *id {
+				kept = append(kept, k)
+			}
+		}
+		if len(kept) == len(keys) {
+			return fmt.Errorf("no key with id %s", *id)
+		}
+		if err = auth.SaveAPIKeys(*file, kept); err != nil {
+			return
+		}
+		fmt.Fprintf(stdout, "revoked %s\n", *id)
+
+	case "list":
+		for _, k := range keys {
+			fmt.Fprintf(stdout, "%s\t%s\n", k.ID, k.Principal)
+		}
+
+	default:
+		fmt.Fprintf(stdout, "unknown keys command %q\n", args[0])
+		return errUsage
+	}
+	return
+}
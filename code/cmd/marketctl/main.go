@@ -0,0 +1,97 @@
+// Command marketctl maintains the catalog files offline, while the server is stopped.
+//
+// Usage:
+//
+//	marketctl validate -file products1.json
+//	marketctl export   -file products1.json -out products.csv
+//	marketctl import   -file products1.json -in products.csv [-merge]
+//	marketctl renumber -file products1.json [-config server.json]
+//	marketctl repair   -file products1.json [-config server.json]
+//	marketctl dupes    -file products1.json
+//	marketctl compact  -file products1.json
+//	marketctl keys create -file api_keys.json -principal store-a
+//	marketctl keys revoke -file api_keys.json -id 1a2b3c4d
+//	marketctl keys list   -file api_keys.json
+//
+// Files ending in .csv are read and written as csv, any other as the server's json storage.
+// renumber and repair refuse to change the ids while a store of the server, found as the server
+// finds it from -config and the environment, refers to the products by id.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	// errUsage is returned when the command line is malformed; the usage has already been printed
+	errUsage = errors.New("usage")
+	// errFindings is returned when a check ran fine but found problems in the catalog
+	errFindings = errors.New("findings")
+	// errProductStores is returned when the ids of the products cannot change because other stores refer to them
+	errProductStores = errors.New("stores refer to the products by id, move them aside before changing the ids")
+)
+
+// command is a subcommand of marketctl
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdout io.Writer) (err error)
+}
+
+func commands() []command {
+	return []command{
+		{"validate", "check every product against the service validation", runValidate},
+		{"export", "copy the catalog to another file and format", runExport},
+		{"import", "replace or merge the catalog with the products of another file", runImport},
+		{"renumber", "reassign the ids as 1..n in id order", runRenumber},
+		{"repair", "give new ids to products with duplicated or invalid ids", runRepair},
+		{"dupes", "list the code_values shared by several products", runDupes},
+		{"compact", "rewrite the catalog sorted and without duplicated ids", runCompact},
+		{"keys", "create, revoke and list api keys (create|revoke|list)", runKeys},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, c := range commands() {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(args[1:], stdout)
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		case errors.Is(err, errFindings):
+			return 1
+		default:
+			fmt.Fprintf(stderr, "marketctl %s: %v\n", c.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(stderr, "marketctl: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: marketctl <command> [flags]")
+	fmt.Fprintln(w)
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-9s %s\n", c.name, c.usage)
+	}
+}
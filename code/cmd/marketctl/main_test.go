@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCatalog writes a catalog file in a temporary directory and returns its path
+func writeCatalog(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "products.json")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+const catalog = `[{"id":1,"name":"Product 1","quantity":1,"code_value":"S6611","is_published":true,"expiration":"04/11/2099","price":1},
+{"id":1,"name":"Product 2","quantity":2,"code_value":"S6611","is_published":true,"expiration":"04/11/2099","price":2},
+{"id":5,"name":"Product 5","quantity":-1,"code_value":"M1","is_published":false,"expiration":"04/11/2099","price":5}]`
+
+func TestRun(t *testing.T) {
+	t.Run("validate - should report duplicated ids and invalid products", func(t *testing.T) {
+		// arrange
+		file := writeCatalog(t, catalog)
+		var stdout, stderr bytes.Buffer
+
+		// act
+		code := run([]string{"validate", "-file", file}, &stdout, &stderr)
+
+		// assert
+		require.Equal(t, 1, code)
+		require.Equal(t, "id 1: duplicated id\nid 5: validate quality field: quantity\n3 products, 2 invalid\n", stdout.String())
+	})
+
+	t.Run("repair and dupes - should keep every product and report shared code_values", func(t *testing.T) {
+		// arrange
+		file := writeCatalog(t, catalog)
+		var stdout, stderr bytes.Buffer
+
+		// act
+		codeRepair := run([]string{"repair", "-file", file}, &stdout, &stderr)
+		stdout.Reset()
+		codeDupes := run([]string{"dupes", "-file", file}, &stdout, &stderr)
+
+		// assert
+		require.Equal(t, 0, codeRepair)
+		require.Equal(t, 1, codeDupes)
+		require.Equal(t, "S6611: [1 6]\n1 duplicated code_values\n", stdout.String())
+	})
+
+	t.Run("export and import - should round trip through csv", func(t *testing.T) {
+		// arrange
+		file := writeCatalog(t, catalog)
+		csvFile := filepath.Join(t.TempDir(), "products.csv")
+		copyFile := filepath.Join(t.TempDir(), "copy.json")
+		var stdout, stderr bytes.Buffer
+
+		// act
+		codeExport := run([]string{"export", "-file", file, "-out", csvFile}, &stdout, &stderr)
+		codeImport := run([]string{"import", "-file", copyFile, "-in", csvFile}, &stdout, &stderr)
+		codeMerge := run([]string{"import", "-file", copyFile, "-in", csvFile, "-merge"}, &stdout, &stderr)
+		stdout.Reset()
+		codeRenumber := run([]string{"renumber", "-file", copyFile}, &stdout, &stderr)
+
+		// assert
+		require.Equal(t, 0, codeExport)
+		require.Equal(t, 0, codeImport)
+		require.Equal(t, 0, codeMerge)
+		require.Equal(t, 0, codeRenumber)
+		require.Equal(t, "5 -> 2\n6 -> 3\n7 -> 4\nrenumbered 3 of 4 products\n", stdout.String())
+		require.Empty(t, stderr.String())
+	})
+
+	t.Run("renumber and repair - should refuse while the stock ledger refers to the products", func(t *testing.T) {
+		// arrange
+		file := writeCatalog(t, catalog)
+		dir := t.TempDir()
+		stockFile := filepath.Join(dir, "stock.jsonl")
+		require.NoError(t, os.WriteFile(stockFile, []byte(`{"product_id":5,"delta":1}`+"\n"), 0644))
+		configFile := filepath.Join(dir, "server.json")
+		require.NoError(t, os.WriteFile(configFile, []byte(`{"storage":{"stock_file":"`+stockFile+`"}}`), 0644))
+		before, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var stdout, stderr bytes.Buffer
+
+		// act
+		codeRenumber := run([]string{"renumber", "-file", file, "-config", configFile}, &stdout, &stderr)
+		codeRepair := run([]string{"repair", "-file", file, "-config", configFile}, &stdout, &stderr)
+
+		// assert
+		require.Equal(t, 1, codeRenumber)
+		require.Equal(t, 1, codeRepair)
+		require.Contains(t, stderr.String(), "marketctl renumber: stores refer to the products by id")
+		require.Contains(t, stderr.String(), stockFile)
+		after, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.Equal(t, before, after)
+	})
+
+	t.Run("keys - should create a working key and revoke it", func(t *testing.T) {
+		// arrange
+		file := filepath.Join(t.TempDir(), "api_keys.json")
+		var stdout, stderr bytes.Buffer
+
+		// act
+		codeCreate := run([]string{"keys", "create", "-file", file, "-principal", "store-a"}, &stdout, &stderr)
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		id := strings.TrimSpace(strings.TrimPrefix(lines[0], "id:"))
+		key := strings.TrimSpace(strings.TrimPrefix(lines[1], "key:"))
+		keys, err := auth.LoadAPIKeys(file)
+		require.NoError(t, err)
+		principal, errAuth := auth.NewAuthKeys(keys).Auth(key)
+
+		codeRevoke := run([]string{"keys", "revoke", "-file", file, "-id", id}, &stdout, &stderr)
+		keysAfter, err := auth.LoadAPIKeys(file)
+		require.NoError(t, err)
+
+		// assert
+		require.Equal(t, 0, codeCreate)
+		require.NoError(t, errAuth)
+		require.Equal(t, "store-a", principal)
+		require.Equal(t, 0, codeRevoke)
+		require.Empty(t, keysAfter)
+	})
+
+	t.Run("should fail with usage on unknown commands", func(t *testing.T) {
+		// arrange
+		var stdout, stderr bytes.Buffer
+
+		// act
+		code := run([]string{"frobnicate"}, &stdout, &stderr)
+
+		// assert
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr.String(), `unknown command "frobnicate"`)
+	})
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/config"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+)
+
+// catalogFlags are the flags shared by the catalog commands
+type catalogFlags struct {
+	file   string
+	layout string
+}
+
+// newFlagSet returns the flag set of a catalog command
+func newFlagSet(name string, stdout io.Writer) (fs *flag.FlagSet, cf *catalogFlags) {
+	cf = &catalogFlags{}
+	fs = flag.NewFlagSet("marketctl "+name, flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.StringVar(&cf.file, "file", "products1.json", "catalog file")
+	fs.StringVar(&cf.layout, "layout", "02/01/2006", "layout of the dates in the files")
+	return
+}
+
+// parse parses the flags, reporting a malformed command line as errUsage
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		return errUsage
+	}
+	return nil
+}
+
+// storage returns the storage of a file, by its extension
+func storage(filePath, layout string) internal.StorageProduct {
+	if strings.EqualFold(filepath.Ext(filePath), ".csv") {
+		return repository.NewStorageProductCSV(filePath, layout)
+	}
+	return repository.NewStorageProductJSON(filePath, layout)
+}
+
+// sortedIDs returns the ids of the products in order
+func sortedIDs(p map[int]*internal.Product) []int {
+	ids := make([]int, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// checkProductStores fails if any store of the server, as configured by the config file and the environment,
+// refers to the products by id: the audit log, the stock ledger, the lots, the orders, the carts, the
+// categories and the price history and schedules would follow the ids to other products.
+// The reservations are only kept in memory, and the promotions select the products by their fields.
+func checkProductStores(configFile string) (err error) {
+	var args []string
+	if configFile != "" {
+		args = []string{"-config", configFile}
+	}
+	cfg, _, err := config.Load(args, os.Getenv)
+	if err != nil {
+		return
+	}
+
+	s := cfg.Storage
+	var used []string
+	for _, filePath := range []string{s.AuditFile, s.StockFile, s.LotsFile, s.OrdersFile, s.CartsFile, s.CategoriesFile, s.PriceHistoryFile, s.PriceSchedulesFile} {
+		b, err := os.ReadFile(filePath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			continue
+		case err != nil:
+			return err
+		}
+		switch strings.TrimSpace(string(b)) {
+		case "", "[]", "{}", "null":
+			continue
+		}
+		used = append(used, filePath)
+	}
+	if len(used) > 0 {
+		err = fmt.Errorf("%w: %s", errProductStores, strings.Join(used, ", "))
+	}
+	return
+}
+
+func runValidate(args []string, stdout io.Writer) (err error) {
+	fs, cf := newFlagSet("validate", stdout)
+	if err = parse(fs, args); err != nil {
+		return
+	}
+
+	list, err := repository.NewStorageProductJSON(cf.file, cf.layout).ReadList(context.Background())
+	if err != nil {
+		return
+	}
+
+	invalid := 0
+	seen := make(map[int]bool)
+	for _, p := range list {
+		if seen[p.Id] {
+			fmt.Fprintf(stdout, "id %d: duplicated id\n", p.Id)
+			invalid++
+			continue
+		}
+		seen[p.Id] = true
+		if err := service.Validate(p); err != nil {
+			fmt.Fprintf(stdout, "id %d: %v\n", p.Id, err)
+			invalid++
+		}
+	}
+	fmt.Fprintf(stdout, "%d products, %d invalid\n", len(list), invalid)
+	if invalid > 0 {
+		err = errFindings
+	}
+	return
+}
+
+func runExport(args []string, stdout io.Writer) (err error) {
+	fs, cf := newFlagSet("export", stdout)
+	out := fs.String("out", "", "destination file (.csv or .json)")
+	if err = parse(fs, args); err != nil {
+		return
+	}
+	if *out == "" {
+		fmt.Fprintln(stdout, "-out is required")
+		return errUsage
+	}
+
+	ctx := context.Background()
+	p, err := storage(cf.file, cf.layout).ReadAll(ctx)
+	if err != nil {
+		return
+	}
+	if err = storage(*out, cf.layout).WriteAll(ctx, p); err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "exported %d products to %s\n", len(p), *out)
+	return
+}
+
+func runImport(args []string, stdout io.Writer) (err error) {
+	fs, cf := newFlagSet("import", stdout)
+	in := fs.String("in", "", "source file (.csv or .json)")
+	merge := fs.Bool("merge", false, "append the products with new ids instead of replacing the catalog")
+	if err = parse(fs, args); err != nil {
+		return
+	}
+	if *in == "" {
+		fmt.Fprintln(stdout, "-in is required")
+		return errUsage
+	}
+
+	ctx := context.Background()
+	imported, err := storage(*in, cf.layout).ReadAll(ctx)
+	if err != nil {
+		return
+	}
+
+	st := storage(cf.file, cf.layout)
+	p := imported
+	if *merge {
+		if p, err = st.ReadAll(ctx); err != nil {
+			return
+		}
+		lastID := 0
+		for id := range p {
+			lastID = max(lastID, id)
+		}
+		for _, id := range sortedIDs(imported) {
+			lastID++
+			v := *imported[id]
+			v.Id = lastID
+			p[v.Id] = &v
+		}
+	}
+
+	if err = st.WriteAll(ctx, p); err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "imported %d products, catalog has %d\n", len(imported), len(p))
+	return
+}
+
+func runRenumber(args []string, stdout io.Writer) (err error) {
+	fs, cf := newFlagSet("renumber", stdout)
+	configFile := fs.String("config", os.Getenv("MARKET_CONFIG"), "server config file naming the stores that refer to the products by id")
+	if err = parse(fs, args); err != nil {
+		return
+	}
+	if err = checkProductStores(*configFile); err != nil {
+		return
+	}
+
+	ctx := context.Background()
+	st := storage(cf.file, cf.layout)
+	p, err := st.ReadAll(ctx)
+	if err != nil {
+		return
+	}
+
+	renumbered := make(map[int]*internal.Product, len(p))
+	changed := 0
+	for i, id := range sortedIDs(p) {
+		v := p[id]
+		if v.Id != i+1 {
+			fmt.Fprintf(stdout, "%d -> %d\n", v.Id, i+1)
+			changed++
+		}
+		v.Id = i + 1
+		renumbered[v.Id] = v
+	}
+
+	if err = st.WriteAll(ctx, renumbered); err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "renumbered %d of %d products\n", changed, len(p))
+	return
+}
+
+func runRepair(args []string, stdout io.Writer) (err error) {
+	fs, cf := newFlagSet("repair", stdout)
+	configFile := fs.String("config", os.Getenv("MARKET_CONFIG"), "server config file naming the stores that refer to the products by id")
+	if err = parse(fs, args); err != nil {
+		return
+	}
+	if err = checkProductStores(*configFile); err != nil {
+		return
+	}
+
+	// the list keeps the products a map would lose to a duplicated id
+	ctx := context.Background()
+	st := repository.NewStorageProductJSON(cf.file, cf.layout)
+	list, err := st.ReadList(ctx)
+	if err != nil {
+		return
+	}
+
+	lastID := 0
+	for _, v := range list {
+		lastID = max(lastID, v.Id)
+	}
+
+	p := make(map[int]*internal.Product, len(list))
+	repaired := 0
+	for _, v := range list {
+		if _, dup := p[v.Id]; dup || v.Id <= 0 {
+			lastID++
+			fmt.Fprintf(stdout, "%q: %d -> %d\n", v.Name, v.Id, lastID)
+			v.Id = lastID
+			repaired++
+		}
+		p[v.Id] = v
+	}
+
+	if err = st.WriteAll(ctx, p); err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "repaired %d of %d products\n", repaired, len(list))
+	return
+}
+
+func runDupes(args []string, stdout io.Writer) (err error) {
+	fs, cf := newFlagSet("dupes", stdout)
+	if err = parse(fs, args); err != nil {
+		return
+	}
+
+	p, err := storage(cf.file, cf.layout).ReadAll(context.Background())
+	if err != nil {
+		return
+	}
+
+	byCode := make(map[string][]int)
+	for _, id := range sortedIDs(p) {
+		byCode[p[id].Code_value] = append(byCode[p[id].Code_value], id)
+	}
+	codes := make([]string, 0, len(byCode))
+	for code, ids := range byCode {
+		if len(ids) > 1 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		fmt.Fprintf(stdout, "%s: %v\n", code, byCode[code])
+	}
+	fmt.Fprintf(stdout, "%d duplicated code_values\n", len(codes))
+	if len(codes) > 0 {
+		err = errFindings
+	}
+	return
+}
+
+func runCompact(args []string, stdout io.Writer) (err error) {
+	fs, cf := newFlagSet("compact", stdout)
+	if err = parse(fs, args); err != nil {
+		return
+	}
+
+	ctx := context.Background()
+	st := storage(cf.file, cf.layout)
+	p, err := st.ReadAll(ctx)
+	if err != nil {
+		return
+	}
+	if err = st.WriteAll(ctx, p); err != nil {
+		return
+	}
+	fmt.Fprintf(stdout, "compacted %d products\n", len(p))
+	return
+}
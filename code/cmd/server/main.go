@@ -1,12 +1,40 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rhinosc/web-market/code/internal/application"
+	"github.com/rhinosc/web-market/code/internal/config"
+	"github.com/rhinosc/web-market/code/platform/logger"
 )
 
 func main() {
+	// config: defaults < config file < environment < flags
+	cfg, printConfig, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(cfg.Redacted())
+		return
+	}
+
+	// structured logs, enriched with the request attributes of the context
+	slog.SetDefault(slog.New(logger.NewContextHandler(newLogHandler(cfg.Log))))
 
 	// server := application.NewServerChi(":8080")
 
@@ -15,9 +43,25 @@ func main() {
 	// 	return
 	// }
 
-	app := application.NewDefaultHTTP(":8080")
-	if err := app.Run(); err != nil {
-		fmt.Println(err)
-		return
+	// stop gracefully on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app := application.NewDefaultHTTP(cfg)
+	if err := app.Run(ctx); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// newLogHandler returns the slog handler described by the config, which is expected to be validated
+func newLogHandler(cfg config.Log) slog.Handler {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level))
+	opts := &slog.HandlerOptions{Level: level}
+
+	if cfg.Format == "text" {
+		return slog.NewTextHandler(os.Stdout, opts)
 	}
+	return slog.NewJSONHandler(os.Stdout, opts)
 }
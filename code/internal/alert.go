@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// AlertProductExpiring is sent once when a product enters the alert window before its expiration
+	AlertProductExpiring = "product.expiring"
+	// AlertProductExpired is sent when an expired product is unpublished
+	AlertProductExpired = "product.expired"
+	// AlertLotExpired is sent when the units of an expired lot are written off
+	AlertLotExpired = "lot.expired"
+)
+
+var (
+	// ErrAlertNotDelivered is returned when an alert is not accepted by one of its destinations
+	ErrAlertNotDelivered = errors.New("alert not delivered")
+)
+
+// Alert is a notice about the expiration of a product or of one of its lots
+type Alert struct {
+	// Key identifies the alert, the same on every attempt to deliver it, so that duplicates can be dropped
+	Key string
+	// Event is what happened, one of the AlertProduct* or AlertLot* events
+	Event string
+	// Time is when the alert was raised
+	Time time.Time
+	// Tenant is the tenant whose catalog the product belongs to
+	Tenant string
+	// ProductID is the id of the product
+	ProductID int
+	// Name is the name of the product
+	Name string
+	// Lot is the code of the lot, for the lot events
+	Lot string
+	// Quantity is the number of units concerned
+	Quantity int
+	// Expiration is when the product or the lot expires
+	Expiration time.Time
+}
+
+type AlertSender interface {
+	// Delivers an alert to its destinations
+	Send(ctx context.Context, alert Alert) (err error)
+}
@@ -1,60 +1,453 @@
 package application
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/http/pprof"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
 	"github.com/rhinosc/web-market/code/internal/auth"
 	"github.com/rhinosc/web-market/code/internal/auth/middleware"
+	"github.com/rhinosc/web-market/code/internal/config"
 	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/ratelimit"
+	rlMD "github.com/rhinosc/web-market/code/internal/ratelimit/middleware"
 	"github.com/rhinosc/web-market/code/internal/repository"
 	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+	tenantMD "github.com/rhinosc/web-market/code/internal/tenant/middleware"
+	"github.com/rhinosc/web-market/code/internal/webhook"
+	"github.com/rhinosc/web-market/code/platform/metrics"
+	"github.com/rhinosc/web-market/code/platform/web/certs"
+	mw "github.com/rhinosc/web-market/code/platform/web/middleware"
 )
 
 type DefaultHTTP struct {
-	addr string
+	cfg config.Config
 }
 
-func NewDefaultHTTP(addr string) *DefaultHTTP {
+// NewDefaultHTTP returns a new DefaultHTTP. The config is expected to be validated.
+func NewDefaultHTTP(cfg config.Config) *DefaultHTTP {
 	return &DefaultHTTP{
-		addr: addr,
+		cfg: cfg,
 	}
 }
 
-func (d *DefaultHTTP) Run() (err error) {
+// Run serves until ctx is cancelled, then stops accepting connections and waits
+// up to the shutdown timeout for in-flight requests (and so their storage writes) to finish.
+func (d *DefaultHTTP) Run(ctx context.Context) (err error) {
+	rt, err := d.Router(ctx)
+	if err != nil {
+		return
+	}
+
+	//run http server
+	srv := &http.Server{
+		Addr:              d.cfg.Server.Addr,
+		Handler:           rt,
+		ReadHeaderTimeout: time.Duration(d.cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(d.cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(d.cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(d.cfg.Server.IdleTimeout),
+		MaxHeaderBytes:    d.cfg.Server.MaxHeaderBytes,
+	}
+
+	if d.cfg.Server.TLS.Enabled() {
+		srv.TLSConfig, err = d.tlsConfig()
+		if err != nil {
+			return
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			// the certificate is served by the tls config
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-errCh:
+		// the server could not start or failed
+		return
+	case <-ctx.Done():
+	}
+
+	// graceful shutdown: requests in flight are drained before returning
+	shutdownTimeout := time.Duration(d.cfg.Server.ShutdownTimeout)
+	slog.Info("shutting down", "timeout", shutdownTimeout)
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err = srv.Shutdown(ctxShutdown); err != nil {
+		srv.Close()
+		return
+	}
+	if err = <-errCh; errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return
+}
+
+// Router builds the dependencies of the service and returns the router with every route
+func (d *DefaultHTTP) Router(ctx context.Context) (rt chi.Router, err error) {
+	started := time.Now()
+
+	layoutDate := d.cfg.Storage.LayoutDate
+
+	// tenants: without a tenants file every principal shares the default catalog
+	var tenants []tenant.Tenant
+	if d.cfg.Storage.TenantsFile != "" {
+		tenants, err = tenant.LoadTenants(d.cfg.Storage.TenantsFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			err = nil
+		case err != nil:
+			return
+		}
+	}
+	fallback := ""
+	if tenants == nil {
+		tenants = []tenant.Tenant{{ID: "default", Storage: d.cfg.Storage.ProductsFile}}
+		fallback = "default"
+	}
+
+	stAudit := repository.NewAuditJSONL(d.cfg.Storage.AuditFile, layoutDate)
+	svAudit := service.NewAuditDefault(stAudit)
+	hdAudit := handler.NewDefaultAudit(svAudit)
+
+	// readiness checks
+	checks := map[string]handler.HealthCheck{
+		"config": func(ctx context.Context) error { return d.cfg.Validate() },
+		"audit":  stAudit.Check,
+	}
+
+	// the prices are read and searched in other currencies with the exchange rates; without a rates file only in their own
+	rates := internal.ExchangeRates{Base: internal.CurrencyDefault}
+	if d.cfg.Prices.ExchangeRatesFile != "" {
+		rates, err = repository.LoadExchangeRates(d.cfg.Prices.ExchangeRatesFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			rates, err = internal.ExchangeRates{Base: internal.CurrencyDefault}, nil
+		case err != nil:
+			return
+		}
+	}
+	svExchange := service.NewExchangeDefault(rates, internal.Rounding(d.cfg.Prices.Rounding))
+
+	// one repository per tenant, each with its own id sequence
+	svTenants := make(map[string]internal.ProductService)
+	for _, t := range tenants {
+		st := repository.NewStorageProductJSON(t.Storage, layoutDate)
+		checks["storage:"+t.ID] = st.Check
+		var lastID int
+		lastID, err = st.LastID(ctx)
+		if err != nil {
+			return
+		}
+		// rp := repository.NewProductRepository(make(map[int]*internal.Product), 0)
+		rp := repository.NewProductMetrics(repository.NewProductStore(*st, lastID, layoutDate), t.ID)
+		svTenants[t.ID] = service.NewProductAudit(service.NewProductDefault(rp).WithExchange(svExchange), svAudit)
+	}
+	svCatalog := service.NewProductTenant(svTenants)
+
+	// the quantities are derived from the stock ledger, written through the catalog
+	stStock := repository.NewStockJSONL(d.cfg.Storage.StockFile)
+	checks["stock"] = stStock.Check
+	rpReservations := repository.NewReservationMap()
+	stLots := repository.NewLotJSON(d.cfg.Storage.LotsFile)
+	checks["lots"] = stLots.Check
+	svStock := service.NewStockDefault(stStock, rpReservations, svCatalog).WithLots(stLots)
+
+	// the background jobs go over the catalog of every tenant
+	tenantIDs := make([]string, 0, len(tenants))
+	for _, t := range tenants {
+		tenantIDs = append(tenantIDs, t.ID)
+	}
+
+	// every change of price is kept in the price history; the scheduled ones are applied until ctx is done
+	stPriceHistory := repository.NewPriceJSONL(d.cfg.Storage.PriceHistoryFile)
+	checks["price_history"] = stPriceHistory.Check
+	stPriceSchedules := repository.NewPriceScheduleJSON(d.cfg.Storage.PriceSchedulesFile)
+	checks["price_schedules"] = stPriceSchedules.Check
+	// the categories of a product go with it, so that a product created with its id does not inherit them
+	stCategories := repository.NewCategoryJSON(d.cfg.Storage.CategoriesFile)
+	checks["categories"] = stCategories.Check
+	sv := service.NewProductCategories(service.NewProductPrice(service.NewProductStock(svCatalog, svStock), stPriceHistory), stCategories)
+	svPrices := service.NewPriceDefault(stPriceSchedules, stPriceHistory, sv, tenantIDs)
+	go service.Sweeper(ctx, "prices", time.Duration(d.cfg.Prices.SweepInterval), svPrices.Apply)
+
+	// the expired products are unpublished and the expired lots written off until ctx is done, alerting about them
+	svLots := service.NewLotDefault(stLots, svStock)
+	exp := d.cfg.Expiration
+	alerts := webhook.NewSender(exp.Webhooks, exp.WebhookSecret, time.Duration(exp.WebhookTimeout))
+	svExpiration := service.NewExpirationDefault(sv, svStock, stLots, alerts, tenantIDs, time.Duration(exp.AlertWithin))
+	go service.Sweeper(ctx, "expiration", time.Duration(exp.SweepInterval), svExpiration.Sweep)
+
+	// reservations hold units during checkouts; the expired ones are released until ctx is done
+	svReservations := service.NewReservationDefault(rpReservations, svStock, time.Duration(d.cfg.Stock.ReservationTTL), time.Duration(d.cfg.Stock.ReservationMaxTTL))
+	go service.Sweeper(ctx, "reservations", time.Duration(d.cfg.Stock.ReservationSweepInterval), svReservations.Sweep)
+
+	// categories arrange the catalog in a tree, which the product listings filter by
+	svCategories := service.NewCategoryDefault(stCategories, sv)
+	hdCategories := handler.NewDefaultCategories(svCategories)
 
-	st := repository.NewStorageProductJSON("products1.json", "02/01/2006")
-	// rp := repository.NewProductRepository(make(map[int]*internal.Product), 0)
-	rp := repository.NewProductStore(*st, 0, "02/01/2006")
+	// promotions lower the effective prices of the products that meet their conditions
+	stPromotions := repository.NewPromotionJSON(d.cfg.Storage.PromotionsFile)
+	checks["promotions"] = stPromotions.Check
+	svPromotions := service.NewPromotionDefault(stPromotions)
+	svPricing := service.NewPricingDefault(stPromotions, svCategories, internal.Rounding(d.cfg.Prices.Rounding))
+	hdPromotions := handler.NewDefaultPromotions(svPromotions)
 
-	sv := service.NewProductDefault(rp)
+	hd := handler.NewDefaultProducts(sv).WithCategories(svCategories).WithExchange(svExchange).WithPricing(svPricing)
+	hdStock := handler.NewDefaultStock(svStock)
+	hdReservations := handler.NewDefaultReservations(svReservations)
+	hdLots := handler.NewDefaultLots(svLots)
+	hdPrices := handler.NewDefaultPrices(svPrices)
+	hdExpiration := handler.NewDefaultExpiration(svExpiration)
 
-	hd := handler.NewDefaultProducts(sv)
+	stOrders := repository.NewOrderJSON(d.cfg.Storage.OrdersFile)
+	checks["orders"] = stOrders.Check
+	svOrders := service.NewOrderDefault(stOrders, svStock).WithPricing(svPricing)
+	hdOrders := handler.NewDefaultOrders(svOrders)
 
-	rt := chi.NewRouter()
+	// carts follow the catalog prices and the promotions until they are checked out into orders
+	stCarts := repository.NewCartJSON(d.cfg.Storage.CartsFile)
+	checks["carts"] = stCarts.Check
+	svCarts := service.NewCartDefault(stCarts, sv, svOrders, time.Duration(d.cfg.Cart.TTL)).WithPricing(svPricing)
+	go service.Sweeper(ctx, "carts", time.Duration(d.cfg.Cart.SweepInterval), svCarts.Sweep)
+	hdCarts := handler.NewDefaultCarts(svCarts)
+	hdHealth := handler.NewDefaultHealth(checks)
+	hdDebug := handler.NewDefaultDebug(d.cfg, started)
+	hdOpenAPI := handler.NewDefaultOpenAPI()
 
-	au := auth.NewAuthTokenBasic(os.Getenv("TOKEN"))
+	rt = chi.NewRouter()
+
+	// auth: without an api keys file the configured token is the only accepted one
+	var au auth.AuthToken = auth.NewAuthTokenBasic(d.cfg.Auth.Token)
+	if d.cfg.Auth.APIKeysFile != "" {
+		var keys []auth.APIKey
+		keys, err = auth.LoadAPIKeys(d.cfg.Auth.APIKeysFile)
+		switch {
+		case err == nil:
+			au = auth.NewAuthKeys(keys)
+		case errors.Is(err, os.ErrNotExist) && d.cfg.Auth.Token != "":
+			err = nil
+		case errors.Is(err, os.ErrNotExist):
+			// without the keys nor a token the api would be open to any request
+			err = fmt.Errorf("%w: auth.token required, the api keys file %s does not exist", config.ErrConfigInvalid, d.cfg.Auth.APIKeysFile)
+			return
+		default:
+			return
+		}
+	}
 	auMD := middleware.NewAuthenticator(au)
+	if d.cfg.Server.TLS.ClientAuth != "none" {
+		// mTLS: a verified client certificate stands in for the token
+		auMD = middleware.NewAuthenticatorCert(au, auth.NewAuthCertSubject(d.cfg.Auth.ClientCertPrincipal))
+	}
+	tnMD := tenantMD.NewTenancy(tenant.NewDirectory(tenants, fallback))
+
+	// the write quotas counted today survive restarts
+	quota, err := ratelimit.LoadDailyQuota(d.cfg.RateLimit.WriteDailyQuota, d.cfg.Storage.QuotasFile)
+	if err != nil {
+		return
+	}
+
+	rt.Use(mw.RequestID)
+	rt.Use(requestID)
+	rt.Use(mw.Logger)
+	rt.Use(mw.Metrics)
+	rt.Use(mw.MaxBodyBytes(d.cfg.Server.MaxBodyBytes))
+	// conditional wraps compress so each content coding gets its own etag
+	rt.Use(mw.Conditional)
+	rt.Use(mw.Compress(mw.DefaultCompressMinSize, mw.EncodingZstd, mw.EncodingBrotli, mw.EncodingGzip, mw.EncodingDeflate))
+
+	// metrics, probes and the api description are reached without the api token
+	rt.Group(func(rt chi.Router) {
+		rt.Use(mw.CacheControl("no-store"))
+		rt.Get("/metrics", metrics.Default.Handler())
+		rt.Get("/healthz", hdHealth.Liveness())
+		rt.Get("/readyz", hdHealth.Readiness())
+	})
+	rt.With(mw.CacheControl("public, max-age=3600")).Get("/openapi.json", hdOpenAPI.Spec())
+
+	// every other route is authenticated
+	rt.Group(func(rt chi.Router) {
+		rt.Use(auMD.Auth)
+		rt.Use(tnMD.Tenant)
+
+		// rate limits per route group, keyed by principal; the daily quota per api key
+		rl := d.cfg.RateLimit
+		rlRead := rlMD.NewRateLimiter(ratelimit.NewTokenBucket(rl.ReadRate, rl.ReadBurst), rlMD.HeaderPrefixRateLimit)
+		rlWrite := rlMD.NewRateLimiter(ratelimit.NewTokenBucket(rl.WriteRate, rl.WriteBurst), rlMD.HeaderPrefixRateLimit)
+		qtWrite := rlMD.NewRateLimiter(quota, rlMD.HeaderPrefixQuota).WithKey(rlMD.KeyAPIKey)
+
+		rt.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("pong"))
+		})
+
+		rt.Route("/products", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				// cached per principal, revalidated on every use with the etag or last modified
+				r.Use(rlRead.Limit, mw.CacheControl("private, no-cache"))
+				r.Get("/", hd.GetAll())
+				r.Get("/{id}", hd.GetByID())
+				r.Get("/search", hd.Search())
+				r.Get("/expiring", hdExpiration.Expiring())
+				r.Get("/{id}/stock-history", hdStock.History())
+				r.Get("/{id}/categories", hdCategories.ProductCategories())
+				r.Get("/{id}/lots", hdLots.GetAll())
+				r.Get("/{id}/prices", hdPrices.Timeline())
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(rlWrite.Limit, qtWrite.Limit, mw.CacheControl("no-store"))
+				r.Post("/", hd.Create())
+				r.Put("/{id}", hd.UpdateOrCreate())
+				r.Patch("/{id}", hd.Update())
+				r.Delete("/{id}", hd.Delete())
+				r.Post("/{id}/stock-movements", hdStock.Move())
+				r.Post("/{id}/reservations", hdReservations.Reserve())
+				r.Put("/{id}/categories", hdCategories.SetProductCategories())
+				r.Post("/{id}/lots", hdLots.Receive())
+				r.Post("/{id}/prices", hdPrices.Schedule())
+				r.Delete("/{id}/prices/{scheduleID}", hdPrices.Cancel())
+			})
+		})
+
+		rt.Route("/categories", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(rlRead.Limit, mw.CacheControl("private, no-cache"))
+				r.Get("/", hdCategories.GetAll())
+				r.Get("/{id}", hdCategories.GetByID())
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(rlWrite.Limit, qtWrite.Limit, mw.CacheControl("no-store"))
+				r.Post("/", hdCategories.Create())
+				r.Put("/{id}", hdCategories.Update())
+				r.Delete("/{id}", hdCategories.Delete())
+			})
+		})
+
+		rt.Route("/promotions", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(rlRead.Limit, mw.CacheControl("private, no-cache"))
+				r.Get("/", hdPromotions.GetAll())
+				r.Get("/{id}", hdPromotions.GetByID())
+			})
 
-	rt.Use(auMD.Auth)
+			r.Group(func(r chi.Router) {
+				r.Use(rlWrite.Limit, qtWrite.Limit, mw.CacheControl("no-store"))
+				r.Post("/", hdPromotions.Create())
+				r.Put("/{id}", hdPromotions.Update())
+				r.Delete("/{id}", hdPromotions.Delete())
+			})
+		})
 
-	rt.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("pong"))
+		rt.Route("/orders", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(rlRead.Limit, mw.CacheControl("private, no-cache"))
+				r.Get("/", hdOrders.GetAll())
+				r.Get("/{id}", hdOrders.GetByID())
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(rlWrite.Limit, qtWrite.Limit, mw.CacheControl("no-store"))
+				r.Post("/", hdOrders.Create())
+				r.Patch("/{id}", hdOrders.Update())
+			})
+		})
+
+		rt.Route("/carts", func(r chi.Router) {
+			r.Use(mw.CacheControl("no-store"))
+			r.With(rlRead.Limit).Get("/{id}", hdCarts.GetByID())
+			r.Group(func(r chi.Router) {
+				r.Use(rlWrite.Limit, qtWrite.Limit)
+				r.Post("/", hdCarts.Create())
+				r.Delete("/{id}", hdCarts.Delete())
+				r.Put("/{id}/lines/{product_id}", hdCarts.SetLine())
+				r.Delete("/{id}/lines/{product_id}", hdCarts.RemoveLine())
+				r.Post("/{id}/checkout", hdCarts.Checkout())
+			})
+		})
+
+		rt.Route("/reservations", func(r chi.Router) {
+			r.Use(mw.CacheControl("no-store"))
+			r.With(rlRead.Limit).Get("/{id}", hdReservations.GetByID())
+			r.Group(func(r chi.Router) {
+				r.Use(rlWrite.Limit, qtWrite.Limit)
+				r.Post("/{id}/commit", hdReservations.Commit())
+				r.Delete("/{id}", hdReservations.Release())
+			})
+		})
+
+		rt.With(rlRead.Limit, mw.CacheControl("private, no-cache")).Get("/audit", hdAudit.GetAll())
 	})
 
-	rt.Route("/products", func(r chi.Router) {
-		r.Get("/", hd.GetAll())
-		r.Get("/{id}", hd.GetByID())
-		r.Get("/search", hd.Search())
+	// the debug routes are reached by the admins only: the profiles and the command line describe the whole process
+	rt.Group(func(rt chi.Router) {
+		rt.Use(auMD.Auth)
+		rt.Use(middleware.NewAdmins(d.cfg.Auth.AdminPrincipals).Admin)
 
-		r.Post("/", hd.Create())
-		r.Put("/{id}", hd.UpdateOrCreate())
-		r.Patch("/{id}", hd.Update())
-		r.Delete("/{id}", hd.Delete())
+		rt.Route("/debug", func(r chi.Router) {
+			r.Use(mw.CacheControl("no-store"))
+			r.Get("/", hdDebug.Info())
+			r.Get("/pprof/*", pprof.Index)
+			r.Get("/pprof/cmdline", pprof.Cmdline)
+			r.Get("/pprof/profile", pprof.Profile)
+			r.Get("/pprof/symbol", pprof.Symbol)
+			// go tool pprof posts the addresses to symbolize
+			r.Post("/pprof/symbol", pprof.Symbol)
+			r.Get("/pprof/trace", pprof.Trace)
+		})
 	})
+	return
+}
 
-	//run http server
-	err = http.ListenAndServe(d.addr, rt)
+// requestID hands the request id to the services, which stamp it on the audit log, the stock ledger
+// and the price history without depending on the web middlewares
+func requestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(internal.ContextWithRequestID(r.Context(), mw.RequestIDFromContext(r.Context()))))
+	})
+}
+
+// tlsConfig returns the tls configuration of the server, reloading the certificate from disk
+func (d *DefaultHTTP) tlsConfig() (cfg *tls.Config, err error) {
+	c := d.cfg.Server.TLS
+	reloader, err := certs.NewReloader(c.CertFile, c.KeyFile, time.Duration(c.ReloadInterval))
+	if err != nil {
+		return
+	}
+
+	cfg = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch c.ClientAuth {
+	case "optional":
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if cfg.ClientAuth != tls.NoClientCert {
+		cfg.ClientCAs, err = certs.LoadCertPool(c.ClientCAFile)
+		if err != nil {
+			cfg = nil
+			return
+		}
+	}
 	return
 }
@@ -0,0 +1,162 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal/application"
+	"github.com/rhinosc/web-market/code/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// freeAddr returns a local address with a free port
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestDefaultHTTP_Run(t *testing.T) {
+	t.Run("success 01 - should serve until the context is cancelled", func(t *testing.T) {
+		// arrange
+		addr := freeAddr(t)
+		cfg := config.Default()
+		cfg.Server.Addr = addr
+		cfg.Auth.Token = "secret"
+		cfg.Server.ShutdownTimeout = config.Duration(time.Second)
+		app := application.NewDefaultHTTP(cfg)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// act
+		errCh := make(chan error, 1)
+		go func() { errCh <- app.Run(ctx) }()
+
+		require.Eventually(t, func() bool {
+			res, err := http.Get("http://" + addr + "/metrics")
+			if err != nil {
+				return false
+			}
+			res.Body.Close()
+			return res.StatusCode == http.StatusOK
+		}, 2*time.Second, 10*time.Millisecond)
+		cancel()
+
+		// assert
+		select {
+		case err := <-errCh:
+			require.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Run did not return after cancel")
+		}
+		_, err := http.Get("http://" + addr + "/metrics")
+		require.Error(t, err)
+	})
+
+	t.Run("fail 01 - should return the listen error", func(t *testing.T) {
+		// arrange
+		cfg := config.Default()
+		cfg.Server.Addr = "invalid:address:1"
+		cfg.Auth.Token = "secret"
+		app := application.NewDefaultHTTP(cfg)
+
+		// act
+		err := app.Run(context.Background())
+
+		// assert
+		require.Error(t, err)
+	})
+}
+
+// pathParams matches the path parameters of an OpenAPI path and the params and wildcards of a chi pattern
+var pathParams = regexp.MustCompile(`\{[^}]*\}|\*`)
+
+// operation normalizes a route to "METHOD /path/{}" so chi patterns and OpenAPI paths compare equal
+func operation(method, path string) string {
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return strings.ToUpper(method) + " " + pathParams.ReplaceAllString(path, "{}")
+}
+
+func TestDefaultHTTP_Router(t *testing.T) {
+	// newConfig returns the default configuration with its files in a temporary directory
+	newConfig := func(t *testing.T) config.Config {
+		cfg := config.Default()
+		cfg.Storage.ProductsFile = t.TempDir() + "/products.json"
+		cfg.Storage.AuditFile = t.TempDir() + "/audit.jsonl"
+		cfg.Storage.StockFile = t.TempDir() + "/stock.jsonl"
+		cfg.Storage.OrdersFile = t.TempDir() + "/orders.json"
+		cfg.Storage.CartsFile = t.TempDir() + "/carts.json"
+		cfg.Storage.CategoriesFile = t.TempDir() + "/categories.json"
+		cfg.Storage.LotsFile = t.TempDir() + "/lots.json"
+		cfg.Storage.PriceHistoryFile = t.TempDir() + "/prices.jsonl"
+		cfg.Storage.PriceSchedulesFile = t.TempDir() + "/price_schedules.json"
+		cfg.Storage.PromotionsFile = t.TempDir() + "/promotions.json"
+		cfg.Storage.QuotasFile = t.TempDir() + "/quotas.json"
+		cfg.Prices.ExchangeRatesFile = t.TempDir() + "/exchange_rates.json"
+		cfg.Auth.APIKeysFile = t.TempDir() + "/api_keys.json"
+		return cfg
+	}
+
+	t.Run("success 01 - should document every route in the openapi spec", func(t *testing.T) {
+		// arrange
+		cfg := newConfig(t)
+		cfg.Auth.Token = "secret"
+		rt, err := application.NewDefaultHTTP(cfg).Router(context.Background())
+		require.NoError(t, err)
+
+		// act
+		var routed []string
+		err = chi.Walk(rt, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			routed = append(routed, operation(method, route))
+			return nil
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/openapi.json", nil)
+		res := httptest.NewRecorder()
+		rt.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code)
+
+		var spec struct {
+			Paths map[string]map[string]json.RawMessage `json:"paths"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&spec))
+		var documented []string
+		for path, item := range spec.Paths {
+			for method := range item {
+				if method == "parameters" {
+					continue
+				}
+				documented = append(documented, operation(method, path))
+			}
+		}
+
+		// assert
+		sort.Strings(routed)
+		sort.Strings(documented)
+		require.Equal(t, routed, documented, "routes and openapi paths differ: document the new route in internal/handler/openapi.json")
+	})
+
+	t.Run("fail 01 - should refuse to serve without a token nor api keys", func(t *testing.T) {
+		// arrange
+		cfg := newConfig(t)
+		cfg.Auth.Token = ""
+
+		// act
+		_, err := application.NewDefaultHTTP(cfg).Router(context.Background())
+
+		// assert
+		require.ErrorIs(t, err, config.ErrConfigInvalid)
+	})
+}
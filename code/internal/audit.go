@@ -0,0 +1,70 @@
+package internal
+
+import "time"
+
+const (
+	// AuditActionCreate is the action recorded when a product is created
+	AuditActionCreate = "create"
+	// AuditActionUpdateOrCreate is the action recorded when a product is replaced or created
+	AuditActionUpdateOrCreate = "update_or_create"
+	// AuditActionUpdate is the action recorded when a product is partially updated
+	AuditActionUpdate = "update"
+	// AuditActionDelete is the action recorded when a product is deleted
+	AuditActionDelete = "delete"
+	// AuditActionUnpublish is the action recorded when a product is unpublished, e.g. when it expires
+	AuditActionUnpublish = "unpublish"
+)
+
+// AuditEntry is a record of a mutation made on a product
+type AuditEntry struct {
+	// Time is the moment the mutation was made
+	Time time.Time
+	// Tenant is the tenant whose catalog was mutated
+	Tenant string
+	// Principal is who made the mutation, as reported by the auth layer
+	Principal string
+	// RequestID is the id of the request that made the mutation
+	RequestID string
+	// Action is the kind of mutation
+	Action string
+	// ProductID is the id of the mutated product
+	ProductID int
+	// Before is the product before the mutation (nil on creation)
+	Before *Product
+	// After is the product after the mutation (nil on deletion)
+	After *Product
+}
+
+// AuditFilter narrows the audit entries returned by a search. Zero values match everything.
+type AuditFilter struct {
+	// Tenant matches entries of a tenant
+	Tenant string
+	// ProductID matches entries of a product
+	ProductID int
+	// Principal matches entries made by a principal
+	Principal string
+	// From matches entries made at or after this time
+	From time.Time
+	// To matches entries made at or before this time
+	To time.Time
+}
+
+// Match reports whether the entry satisfies the filter
+func (f AuditFilter) Match(e AuditEntry) bool {
+	if f.Tenant != "" && e.Tenant != f.Tenant {
+		return false
+	}
+	if f.ProductID != 0 && e.ProductID != f.ProductID {
+		return false
+	}
+	if f.Principal != "" && e.Principal != f.Principal {
+		return false
+	}
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && e.Time.After(f.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+package internal
+
+import "context"
+
+// AuditRepository is an interface that contains the methods that an audit log must implement.
+// Implementations are append-only: entries are never updated or deleted.
+type AuditRepository interface {
+	// Append adds an entry at the end of the log
+	Append(ctx context.Context, entry AuditEntry) (err error)
+
+	// Find returns the entries that match the filter, in the order they were appended
+	Find(ctx context.Context, filter AuditFilter) (entries []AuditEntry, err error)
+}
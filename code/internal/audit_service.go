@@ -0,0 +1,19 @@
+package internal
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrAuditRecord is an error that returns when an audit entry could not be recorded
+	ErrAuditRecord = errors.New("audit: could not record entry")
+)
+
+type AuditService interface {
+	// Records an entry, filling the principal, request id and time from the context
+	Record(ctx context.Context, entry AuditEntry) (err error)
+
+	// Returns the entries that match the filter
+	Find(ctx context.Context, filter AuditFilter) (entries []AuditEntry, err error)
+}
@@ -18,6 +18,13 @@ var (
 
 // AuthToken is an interface that contains the methods that a authenticator must implement
 type AuthToken interface {
-	// Auth is a method that authenticates
-	Auth(token string) (err error)
+	// Auth is a method that authenticates a token and returns the principal it belongs to
+	Auth(token string) (principal string, err error)
+}
+
+// AuthTokenKey is an AuthToken holding several api keys, which also tells which of them a token is
+type AuthTokenKey interface {
+	AuthToken
+	// AuthKey authenticates a token and returns the api key it matches
+	AuthKey(token string) (key APIKey, err error)
 }
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+const (
+	// CertFieldCN maps a client certificate to the common name of its subject
+	CertFieldCN = "cn"
+	// CertFieldDNS maps a client certificate to its first DNS subject alternative name
+	CertFieldDNS = "dns"
+	// CertFieldURI maps a client certificate to its first URI subject alternative name
+	CertFieldURI = "uri"
+	// CertFieldEmail maps a client certificate to its first email subject alternative name
+	CertFieldEmail = "email"
+)
+
+// AuthCert is an interface that contains the methods that a client certificate authenticator must implement
+type AuthCert interface {
+	// AuthCert is a method that maps a verified client certificate to the principal it belongs to
+	AuthCert(cert *x509.Certificate) (principal string, err error)
+}
+
+// NewAuthCertSubject returns a new AuthCertSubject
+func NewAuthCertSubject(field string) *AuthCertSubject {
+	return &AuthCertSubject{
+		Field: field,
+	}
+}
+
+// AuthCertSubject is an authenticator that takes the principal from a field of the certificate subject or SAN
+type AuthCertSubject struct {
+	// Field is the field the principal is taken from, one of the CertField constants
+	Field string
+}
+
+// AuthCert is a method that maps a verified client certificate to the principal it belongs to
+func (a *AuthCertSubject) AuthCert(cert *x509.Certificate) (principal string, err error) {
+	switch a.Field {
+	case CertFieldCN:
+		principal = cert.Subject.CommonName
+	case CertFieldDNS:
+		if len(cert.DNSNames) > 0 {
+			principal = cert.DNSNames[0]
+		}
+	case CertFieldURI:
+		if len(cert.URIs) > 0 {
+			principal = cert.URIs[0].String()
+		}
+	case CertFieldEmail:
+		if len(cert.EmailAddresses) > 0 {
+			principal = cert.EmailAddresses[0]
+		}
+	default:
+		err = fmt.Errorf("%w: unknown certificate field %s", ErrAuthTokenInternal, a.Field)
+		return
+	}
+	if principal == "" {
+		err = fmt.Errorf("%w: certificate has no %s", ErrAuthTokenInvalid, a.Field)
+	}
+	return
+}
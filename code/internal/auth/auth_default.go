@@ -1,5 +1,10 @@
 package auth
 
+import "crypto/subtle"
+
+// PrincipalBasic is the principal returned by AuthBasic on a successful authentication
+const PrincipalBasic = "basic"
+
 // NewAuthTokenBasic returns a new AuthBasic
 func NewAuthTokenBasic(token string) *AuthBasic {
 	return &AuthBasic{
@@ -13,10 +18,16 @@ type AuthBasic struct {
 	Token string
 }
 
-// Auth is a method that authenticates
-func (a *AuthBasic) Auth(token string) (err error) {
-	if a.Token != token {
-		return ErrAuthTokenInvalid
+// Auth is a method that authenticates. An empty token is never accepted, even if it is the configured one.
+func (a *AuthBasic) Auth(token string) (principal string, err error) {
+	if token == "" {
+		err = ErrAuthTokenNotFound
+		return
+	}
+	if a.Token == "" || subtle.ConstantTimeCompare([]byte(a.Token), []byte(token)) != 1 {
+		err = ErrAuthTokenInvalid
+		return
 	}
-	return nil
+	principal = PrincipalBasic
+	return
 }
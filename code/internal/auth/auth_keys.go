@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// APIKey is a stored API key. Only the SHA-256 of the key is kept, never the key itself.
+type APIKey struct {
+	// ID identifies the key to revoke it, without revealing it
+	ID string `json:"id"`
+	// Principal is the principal the key authenticates as
+	Principal string `json:"principal"`
+	// KeySHA256 is the hex encoded SHA-256 of the key
+	KeySHA256 string `json:"key_sha256"`
+}
+
+// HashKey returns the hex encoded SHA-256 of a key, as stored in APIKey.KeySHA256
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadAPIKeys reads the API keys stored as a JSON array in the file
+func LoadAPIKeys(filePath string) (keys []APIKey, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&keys); err != nil {
+		err = fmt.Errorf("%w: %v", ErrAuthTokenInternal, err)
+		return
+	}
+	return
+}
+
+// NewAuthKeys returns a new AuthKeys
+func NewAuthKeys(keys []APIKey) *AuthKeys {
+	return &AuthKeys{
+		Keys: keys,
+	}
+}
+
+// AuthKeys is an authenticator that accepts any of a set of API keys
+type AuthKeys struct {
+	// Keys is the set of accepted keys
+	Keys []APIKey
+}
+
+// Auth is a method that authenticates
+func (a *AuthKeys) Auth(token string) (principal string, err error) {
+	key, err := a.AuthKey(token)
+	principal = key.Principal
+	return
+}
+
+// AuthKey authenticates a token and returns the api key it matches
+func (a *AuthKeys) AuthKey(token string) (key APIKey, err error) {
+	if token == "" {
+		err = ErrAuthTokenNotFound
+		return
+	}
+
+	hash := []byte(HashKey(token))
+	for _, k := range a.Keys {
+		if subtle.ConstantTimeCompare(hash, []byte(k.KeySHA256)) == 1 {
+			key = k
+			return
+		}
+	}
+	err = ErrAuthTokenInvalid
+	return
+}
+
+// SaveAPIKeys replaces the file with the API keys, as a JSON array
+func SaveAPIKeys(filePath string, keys []APIKey) (err error) {
+	if keys == nil {
+		keys = []APIKey{}
+	}
+	b, err := json.MarshalIndent(keys, "", "\t")
+	if err != nil {
+		return
+	}
+
+	// write to a temporary file that replaces the original, so a failed write keeps the previous keys
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	err = os.Rename(f.Name(), filePath)
+	return
+}
+
+// NewAPIKey generates a random key for the principal. The key is returned once and only its hash is stored.
+func NewAPIKey(principal string) (key string, stored APIKey, err error) {
+	b := make([]byte, 36)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	key = hex.EncodeToString(b[:32])
+	stored = APIKey{
+		ID:        hex.EncodeToString(b[32:]),
+		Principal: principal,
+		KeySHA256: HashKey(key),
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package auth
+
+import "context"
+
+// principalKey is the context key under which the authenticated principal is stored
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the authenticated principal
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal stored in ctx, if any
+func PrincipalFromContext(ctx context.Context) (principal string, ok bool) {
+	principal, ok = ctx.Value(principalKey{}).(string)
+	return
+}
+
+// keyIDKey is the context key under which the id of the api key a request authenticated with is stored
+type keyIDKey struct{}
+
+// ContextWithKeyID returns a copy of ctx carrying the id of the api key the request authenticated with
+func ContextWithKeyID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, keyIDKey{}, id)
+}
+
+// KeyIDFromContext returns the id of the api key stored in ctx, if the request authenticated with one
+func KeyIDFromContext(ctx context.Context) (id string, ok bool) {
+	id, ok = ctx.Value(keyIDKey{}).(string)
+	return
+}
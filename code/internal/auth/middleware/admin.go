@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type Admins struct {
+	// principals are the principals allowed through, none if the routes are disabled
+	principals []string
+}
+
+func NewAdmins(principals []string) *Admins {
+	return &Admins{
+		principals: principals,
+	}
+}
+
+// Admin rejects with 403 Forbidden the requests of any principal but the admins,
+// so it must run after the authenticator
+func (a *Admins) Admin(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// before
+		// get principal
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		if !slices.Contains(a.principals, principal) {
+			slog.WarnContext(r.Context(), "admin route denied", "principal", principal)
+			response.Error(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		// call
+		handler.ServeHTTP(w, r)
+	})
+}
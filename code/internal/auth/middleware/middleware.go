@@ -1,15 +1,24 @@
 package middleware
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/platform/logger"
+	"github.com/rhinosc/web-market/code/platform/metrics"
 	"github.com/rhinosc/web-market/code/platform/web/response"
 )
 
+var authFailures = metrics.Default.NewCounterVec("auth_failures_total",
+	"Number of rejected authentications by reason.", "reason")
+
 type Authenticator struct {
 	// au is the authenticator service.
 	au auth.AuthToken
+	// ac is the client certificate authenticator service, nil when mTLS is disabled.
+	ac auth.AuthCert
 }
 
 func NewAuthenticator(au auth.AuthToken) *Authenticator {
@@ -18,22 +27,69 @@ func NewAuthenticator(au auth.AuthToken) *Authenticator {
 	}
 }
 
+// NewAuthenticatorCert returns an Authenticator that authenticates the requests with a verified
+// client certificate through ac, and the requests without one through au
+func NewAuthenticatorCert(au auth.AuthToken, ac auth.AuthCert) *Authenticator {
+	return &Authenticator{
+		au: au,
+		ac: ac,
+	}
+}
+
 func (a *Authenticator) Auth(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// before
-		// get token
-		token := r.Header.Get("Authorization")
+		var principal, keyID string
+		var err error
+		switch {
+		case a.ac != nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0:
+			// validate client certificate, verified by the tls handshake
+			principal, err = a.ac.AuthCert(r.TLS.VerifiedChains[0][0])
+		default:
+			// get token
+			token := r.Header.Get("Authorization")
 
-		// validate token
-		if err := a.au.Auth(token); err != nil {
+			// validate token, telling which api key it is when there are several
+			if ak, ok := a.au.(auth.AuthTokenKey); ok {
+				var key auth.APIKey
+				key, err = ak.AuthKey(token)
+				principal, keyID = key.Principal, key.ID
+				break
+			}
+			principal, err = a.au.Auth(token)
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), "authentication failed", "error", err)
+			authFailures.Inc(failureReason(err))
 			response.Error(w, http.StatusUnauthorized, "Unauthorized")
 			return
 		}
 
 		// call
+		// - expose the principal to the handlers, services and logs downstream
+		logger.AddAttrs(r.Context(), slog.String("principal", principal))
+		ctx := auth.ContextWithPrincipal(r.Context(), principal)
+		if keyID != "" {
+			ctx = auth.ContextWithKeyID(ctx, keyID)
+		}
+		r = r.WithContext(ctx)
 		handler.ServeHTTP(w, r)
 
 		// after
 		// ...
 	})
 }
+
+// failureReason returns the metric label of an authentication error
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrAuthTokenNotFound):
+		return "token_not_found"
+	case errors.Is(err, auth.ErrAuthTokenInvalid):
+		return "token_invalid"
+	case errors.Is(err, auth.ErrAuthTokenExpired):
+		return "token_expired"
+	default:
+		return "internal"
+	}
+}
@@ -0,0 +1,149 @@
+package middleware_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/auth/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Authenticator.Auth
+func TestAuthenticator_Auth(t *testing.T) {
+	var principal string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = auth.PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("case 1: should take the principal from a verified client certificate", func(t *testing.T) {
+		// arrange
+		principal = ""
+		au := middleware.NewAuthenticatorCert(auth.NewAuthTokenBasic("12345"), auth.NewAuthCertSubject(auth.CertFieldCN))
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "store-a"}}
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+		// act
+		rr := httptest.NewRecorder()
+		au.Auth(next).ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "store-a", principal)
+	})
+
+	t.Run("case 2: should fall back to the token without a client certificate", func(t *testing.T) {
+		// arrange
+		principal = ""
+		au := middleware.NewAuthenticatorCert(auth.NewAuthTokenBasic("12345"), auth.NewAuthCertSubject(auth.CertFieldCN))
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.Header.Set("Authorization", "12345")
+
+		// act
+		rr := httptest.NewRecorder()
+		au.Auth(next).ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, auth.PrincipalBasic, principal)
+	})
+
+	t.Run("case 3: should reject a certificate without the mapped field", func(t *testing.T) {
+		// arrange
+		au := middleware.NewAuthenticatorCert(auth.NewAuthTokenBasic("12345"), auth.NewAuthCertSubject(auth.CertFieldDNS))
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "store-a"}}
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+		// act
+		rr := httptest.NewRecorder()
+		au.Auth(next).ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+		require.Equal(t, `{"status":"Unauthorized","message":"Unauthorized"}`, rr.Body.String())
+	})
+
+	t.Run("case 4: should tell which api key a token is", func(t *testing.T) {
+		// arrange
+		var keyID string
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ = auth.PrincipalFromContext(r.Context())
+			keyID, _ = auth.KeyIDFromContext(r.Context())
+		})
+		au := middleware.NewAuthenticator(auth.NewAuthKeys([]auth.APIKey{
+			{ID: "k1", Principal: "store-a", KeySHA256: auth.HashKey("first")},
+			{ID: "k2", Principal: "store-a", KeySHA256: auth.HashKey("second")},
+		}))
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.Header.Set("Authorization", "second")
+
+		// act
+		rr := httptest.NewRecorder()
+		au.Auth(next).ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "store-a", principal)
+		require.Equal(t, "k2", keyID)
+	})
+
+	t.Run("case 5: should reject a request without token even if no token is configured", func(t *testing.T) {
+		// arrange
+		principal = ""
+		au := middleware.NewAuthenticator(auth.NewAuthTokenBasic(""))
+		req := httptest.NewRequest("GET", "/products", nil)
+
+		// act
+		rr := httptest.NewRecorder()
+		au.Auth(next).ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+		require.Equal(t, "", principal)
+	})
+}
+
+// Tests for Admins.Admin
+func TestAdmins_Admin(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	request := func(principal string) *http.Request {
+		req := httptest.NewRequest("GET", "/debug/pprof/cmdline", nil)
+		return req.WithContext(auth.ContextWithPrincipal(req.Context(), principal))
+	}
+
+	t.Run("case 1: should let through the admin principals only", func(t *testing.T) {
+		// arrange
+		hd := middleware.NewAdmins([]string{"ops"}).Admin(next)
+
+		// act
+		admin := httptest.NewRecorder()
+		hd.ServeHTTP(admin, request("ops"))
+		tenant := httptest.NewRecorder()
+		hd.ServeHTTP(tenant, request("store-a"))
+
+		// assert
+		require.Equal(t, http.StatusOK, admin.Code)
+		require.Equal(t, http.StatusForbidden, tenant.Code)
+	})
+
+	t.Run("case 2: should let no one through without admin principals", func(t *testing.T) {
+		// arrange
+		hd := middleware.NewAdmins(nil).Admin(next)
+
+		// act
+		res := httptest.NewRecorder()
+		hd.ServeHTTP(res, request(auth.PrincipalBasic))
+
+		// assert
+		require.Equal(t, http.StatusForbidden, res.Code)
+	})
+}
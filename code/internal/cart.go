@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrCartNotFound is returned when a cart does not exist, or was checked out or deleted
+	ErrCartNotFound = errors.New("cart not found")
+	// ErrCartExpired is returned when a cart was left untouched for longer than its ttl
+	ErrCartExpired = errors.New("cart expired")
+	// ErrCartInvalid is returned when a line has a wrong quantity or a product that cannot be sold,
+	// or when an empty cart is checked out
+	ErrCartInvalid = errors.New("cart invalid")
+)
+
+// CartLine is a product in a cart, priced at the last time the cart was read
+type CartLine struct {
+	// ProductID is the id of the product
+	ProductID int
+	// Name is the current name of the product
+	Name string
+	// Quantity is the number of units
+	Quantity int
+	// UnitPrice is the current price of the product
+	UnitPrice Money
+	// PreviousUnitPrice is the price the line had before the product changed price, zero if it did not
+	PreviousUnitPrice Money
+	// Total is the current price of the units, with the promotions; zero in the carts saved before
+	// promotions until they are repriced
+	Total Money
+	// Promotions are the ids of the promotions that lowered the price, in the order they applied
+	Promotions []int
+}
+
+// Subtotal returns the price of the line
+func (l CartLine) Subtotal() Money {
+	if l.Total.Currency != "" {
+		return l.Total
+	}
+	return l.UnitPrice.Times(l.Quantity)
+}
+
+// Cart gathers the products a customer intends to order. Unlike an order it takes no stock
+// and follows the prices of the catalog until it is checked out.
+type Cart struct {
+	// ID identifies the cart
+	ID string
+	// Tenant is the tenant whose catalog the products belong to
+	Tenant string
+	// Principal is who created the cart, as reported by the auth layer
+	Principal string
+	// Lines are the products of the cart, one line per product, in the order they were added,
+	// all priced in the same currency
+	Lines []CartLine
+	// Created is when the cart was created
+	Created time.Time
+	// Updated is when the lines last changed
+	Updated time.Time
+	// Expires is when the cart is discarded if its lines do not change
+	Expires time.Time
+}
+
+// Total returns the price of the cart, in the currency of its lines
+func (c Cart) Total() (total Money) {
+	for _, l := range c.Lines {
+		total.Amount += l.Subtotal().Amount
+		total.Currency = l.UnitPrice.Currency
+	}
+	return
+}
+
+// Expired reports whether the cart is discarded at now
+func (c Cart) Expired(now time.Time) bool {
+	return !now.Before(c.Expires)
+}
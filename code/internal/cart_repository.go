@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type CartRepository interface {
+	// Returns a cart of a tenant, expired or not
+	GetByID(ctx context.Context, tenant, id string) (cart Cart, err error)
+
+	// Stores a cart, replacing the one with the same id
+	Save(ctx context.Context, cart Cart) (err error)
+
+	// Removes a cart of a tenant
+	Delete(ctx context.Context, tenant, id string) (err error)
+
+	// Removes and returns the carts of every tenant expired at now
+	DeleteExpired(ctx context.Context, now time.Time) (expired []Cart, err error)
+}
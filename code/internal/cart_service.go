@@ -0,0 +1,26 @@
+package internal
+
+import "context"
+
+type CartService interface {
+	// Creates an empty cart
+	Create(ctx context.Context) (cart Cart, err error)
+
+	// Returns a cart, repriced at the current prices of its products
+	GetByID(ctx context.Context, id string) (cart Cart, err error)
+
+	// Sets the quantity of a product in a cart, adding the line if needed
+	SetLine(ctx context.Context, id string, productID, quantity int) (cart Cart, err error)
+
+	// Removes the line of a product from a cart
+	RemoveLine(ctx context.Context, id string, productID int) (cart Cart, err error)
+
+	// Deletes a cart
+	Delete(ctx context.Context, id string) (err error)
+
+	// Places an order of the lines of a cart at the current prices and deletes the cart
+	Checkout(ctx context.Context, id string) (order Order, err error)
+
+	// Deletes the expired carts of every tenant, returning how many were deleted
+	Sweep(ctx context.Context) (deleted int, err error)
+}
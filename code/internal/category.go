@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"errors"
+	"regexp"
+)
+
+var (
+	// ErrCategoryNotFound is returned when a category does not exist
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryInvalid is returned when a category has no name or a malformed slug
+	ErrCategoryInvalid = errors.New("category invalid")
+	// ErrCategorySlugTaken is returned when another category of the tenant has the slug
+	ErrCategorySlugTaken = errors.New("category slug taken")
+	// ErrCategoryCycle is returned when a category would become its own ancestor
+	ErrCategoryCycle = errors.New("category cycle")
+	// ErrCategoryHasChildren is returned when a category with children is deleted
+	ErrCategoryHasChildren = errors.New("category has children")
+)
+
+// categorySlug is the format of the slugs: lowercase words of letters and digits joined by hyphens
+var categorySlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidCategorySlug reports whether a slug is well formed, e.g. "red-wine"
+func ValidCategorySlug(slug string) bool {
+	return categorySlug.MatchString(slug)
+}
+
+// Category is a node of the taxonomy of the catalog of a tenant. A product can be in any number
+// of categories, and is found under each of them and of their ancestors.
+type Category struct {
+	// ID identifies the category
+	ID int
+	// Tenant is the tenant whose catalog the category belongs to
+	Tenant string
+	// Slug identifies the category in urls, unique within the tenant
+	Slug string
+	// Name is the display name
+	Name string
+	// ParentID is the id of the parent category, zero for a root category
+	ParentID int
+}
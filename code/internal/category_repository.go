@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type CategoryRepository interface {
+	// Returns the categories of a tenant, in id order
+	GetAll(ctx context.Context, tenant string) (categories []Category, err error)
+
+	// Stores a new category, setting its id
+	Create(ctx context.Context, category *Category) (err error)
+
+	// Replaces a category
+	Update(ctx context.Context, category Category) (err error)
+
+	// Removes a category of a tenant and its assignments to the products
+	Delete(ctx context.Context, tenant string, id int) (err error)
+
+	// Returns the ids of the categories of every product of a tenant that has any
+	Assignments(ctx context.Context, tenant string) (assignments map[int][]int, err error)
+
+	// Sets the ids of the categories of a product of a tenant, none to unassign it
+	Assign(ctx context.Context, tenant string, productID int, categoryIDs []int) (err error)
+
+	// Returns the last time a category or an assignment changed, zero if never
+	LastModified(ctx context.Context) (t time.Time, err error)
+}
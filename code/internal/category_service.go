@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type CategoryService interface {
+	// Returns the categories, in id order
+	GetAll(ctx context.Context) (categories []Category, err error)
+
+	// Returns a category
+	GetByID(ctx context.Context, id int) (category Category, err error)
+
+	// Creates a category, under its parent if it has one
+	Create(ctx context.Context, category *Category) (err error)
+
+	// Replaces the slug, name and parent of a category
+	Update(ctx context.Context, category Category) (err error)
+
+	// Deletes a category without children, unassigning it from its products
+	Delete(ctx context.Context, id int) (err error)
+
+	// Returns the path of slugs from the root to a category, e.g. ["wine", "red-wine"]
+	Path(ctx context.Context, id int) (path []string, err error)
+
+	// Returns the categories of a product
+	ProductCategories(ctx context.Context, productID int) (categories []Category, err error)
+
+	// Sets the categories of a product, by id
+	SetProductCategories(ctx context.Context, productID int, categoryIDs []int) (categories []Category, err error)
+
+	// Returns the ids of the products in the category with the slug or in any of its descendants
+	ProductIDs(ctx context.Context, slug string) (ids map[int]bool, err error)
+
+	// Returns the last time a category or the categories of a product changed, zero if never
+	LastModified(ctx context.Context) (t time.Time, err error)
+}
@@ -0,0 +1,373 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+var (
+	// ErrConfigInvalid is an error that returns when the configuration does not pass validation
+	ErrConfigInvalid = errors.New("config: invalid")
+)
+
+// Config is the configuration of the server
+type Config struct {
+	Server     Server     `json:"server"`
+	Storage    Storage    `json:"storage"`
+	Auth       Auth       `json:"auth"`
+	RateLimit  RateLimit  `json:"rate_limit"`
+	Stock      Stock      `json:"stock"`
+	Cart       Cart       `json:"cart"`
+	Expiration Expiration `json:"expiration"`
+	Prices     Prices     `json:"prices"`
+	Log        Log        `json:"log"`
+}
+
+// Server is the configuration of the http server
+type Server struct {
+	// Addr is the address the server listens on
+	Addr string `json:"addr"`
+	// ReadHeaderTimeout is the time allowed to read the request headers
+	ReadHeaderTimeout Duration `json:"read_header_timeout"`
+	// ReadTimeout is the time allowed to read the whole request
+	ReadTimeout Duration `json:"read_timeout"`
+	// WriteTimeout is the time allowed to write the response
+	WriteTimeout Duration `json:"write_timeout"`
+	// IdleTimeout is the time a keep-alive connection is kept open between requests
+	IdleTimeout Duration `json:"idle_timeout"`
+	// ShutdownTimeout is the time in-flight requests are given to finish on shutdown
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	// MaxHeaderBytes is the maximum size of the request headers
+	MaxHeaderBytes int `json:"max_header_bytes"`
+	// MaxBodyBytes is the maximum size of a request body
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// TLS is the configuration of the native TLS serving
+	TLS TLS `json:"tls"`
+}
+
+// TLS is the configuration of the native TLS serving, disabled when there is no certificate
+type TLS struct {
+	// CertFile is the PEM certificate chain of the server
+	CertFile string `json:"cert_file"`
+	// KeyFile is the PEM private key of the server
+	KeyFile string `json:"key_file"`
+	// ReloadInterval is how often the certificate files are checked for changes
+	ReloadInterval Duration `json:"reload_interval"`
+	// ClientAuth is the client certificate policy: none, optional or require
+	ClientAuth string `json:"client_auth"`
+	// ClientCAFile is the PEM bundle of the CAs the client certificates must be signed by
+	ClientCAFile string `json:"client_ca_file"`
+}
+
+// Enabled reports whether the server is served over TLS
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Storage is the configuration of the files the server reads and writes
+type Storage struct {
+	// ProductsFile is the products file of the default tenant, used when there is no tenants file
+	ProductsFile string `json:"products_file"`
+	// LayoutDate is the layout of the dates stored in the products files
+	LayoutDate string `json:"layout_date"`
+	// TenantsFile is the optional file mapping tenants to their products files
+	TenantsFile string `json:"tenants_file"`
+	// AuditFile is the append-only audit log
+	AuditFile string `json:"audit_file"`
+	// StockFile is the append-only ledger of stock movements
+	StockFile string `json:"stock_file"`
+	// OrdersFile is the file of the orders of every tenant
+	OrdersFile string `json:"orders_file"`
+	// CartsFile is the file of the carts of every tenant
+	CartsFile string `json:"carts_file"`
+	// CategoriesFile is the file of the categories of every tenant and of their products
+	CategoriesFile string `json:"categories_file"`
+	// LotsFile is the file of the lots of the products of every tenant
+	LotsFile string `json:"lots_file"`
+	// PriceHistoryFile is the append-only history of the prices of the products
+	PriceHistoryFile string `json:"price_history_file"`
+	// PriceSchedulesFile is the file of the pending scheduled price changes of every tenant
+	PriceSchedulesFile string `json:"price_schedules_file"`
+	// PromotionsFile is the file of the promotions of every tenant
+	PromotionsFile string `json:"promotions_file"`
+	// QuotasFile is the file of the write requests counted against the daily quotas today
+	QuotasFile string `json:"quotas_file"`
+}
+
+// Auth is the configuration of the authentication
+type Auth struct {
+	// Token is the single accepted token, used when there is no api keys file; required then.
+	// It is read from the environment or a file, never from the command line.
+	Token string `json:"token"`
+	// TokenFile is the optional file holding the token, which takes precedence over Token
+	TokenFile string `json:"token_file"`
+	// APIKeysFile is the optional file of accepted api keys
+	APIKeysFile string `json:"api_keys_file"`
+	// ClientCertPrincipal is the client certificate field used as principal in mTLS: cn, dns, uri or email
+	ClientCertPrincipal string `json:"client_cert_principal"`
+	// AdminPrincipals are the principals allowed on /debug, which profiles the process and shows its
+	// configuration; none by default, which leaves it to no one
+	AdminPrincipals []string `json:"admin_principals"`
+}
+
+// RateLimit is the configuration of the rate limits, per route group
+type RateLimit struct {
+	// ReadRate is the number of read requests per second refilled per principal
+	ReadRate float64 `json:"read_rate"`
+	// ReadBurst is the number of read requests a principal can make at once
+	ReadBurst int `json:"read_burst"`
+	// WriteRate is the number of write requests per second refilled per principal
+	WriteRate float64 `json:"write_rate"`
+	// WriteBurst is the number of write requests a principal can make at once
+	WriteBurst int `json:"write_burst"`
+	// WriteDailyQuota is the number of write requests an api key can make per day; the token and the
+	// client certificates are counted per principal. The counts survive restarts through storage.quotas_file,
+	// but they are those of one server: replicas sharing the file do not add up their requests.
+	WriteDailyQuota int `json:"write_daily_quota"`
+}
+
+// Stock is the configuration of the stock reservations
+type Stock struct {
+	// ReservationTTL is the time a reservation holds its units when the client does not ask for one
+	ReservationTTL Duration `json:"reservation_ttl"`
+	// ReservationMaxTTL is the longest time a reservation can hold its units
+	ReservationMaxTTL Duration `json:"reservation_max_ttl"`
+	// ReservationSweepInterval is how often the expired reservations are released
+	ReservationSweepInterval Duration `json:"reservation_sweep_interval"`
+}
+
+// Cart is the configuration of the carts
+type Cart struct {
+	// TTL is the time a cart is kept after its last change
+	TTL Duration `json:"ttl"`
+	// SweepInterval is how often the expired carts are deleted
+	SweepInterval Duration `json:"sweep_interval"`
+}
+
+// Expiration is the configuration of the withdrawal of the expired products and lots, and of its alerts
+type Expiration struct {
+	// SweepInterval is how often the expired products are unpublished and the expired lots written off
+	SweepInterval Duration `json:"sweep_interval"`
+	// AlertWithin is how long before their expiration the published products are alerted
+	AlertWithin Duration `json:"alert_within"`
+	// Webhooks are the urls the alerts are posted to; without any the alerts are only logged
+	Webhooks []string `json:"webhooks"`
+	// WebhookSecret is the key the alerts are signed with, unsigned if empty
+	WebhookSecret string `json:"webhook_secret"`
+	// WebhookTimeout is the time a webhook is given to answer
+	WebhookTimeout Duration `json:"webhook_timeout"`
+}
+
+// Prices is the configuration of the scheduled price changes and of the currency conversions
+type Prices struct {
+	// SweepInterval is how often the scheduled price changes that became effective are applied
+	SweepInterval Duration `json:"sweep_interval"`
+	// ExchangeRatesFile is the optional table of exchange rates the prices are converted with
+	ExchangeRatesFile string `json:"exchange_rates_file"`
+	// Rounding is how the converted and discounted prices are rounded to the minor unit: half_up, half_even, down or up
+	Rounding string `json:"rounding"`
+}
+
+// Log is the configuration of the logs
+type Log struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string `json:"level"`
+	// Format is the format of the records: json or text
+	Format string `json:"format"`
+}
+
+// Default returns the default configuration, suitable for development
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8080",
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			ReadTimeout:       Duration(15 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(60 * time.Second),
+			ShutdownTimeout:   Duration(20 * time.Second),
+			MaxHeaderBytes:    64 << 10,
+			MaxBodyBytes:      1 << 20,
+			TLS: TLS{
+				ReloadInterval: Duration(time.Minute),
+				ClientAuth:     "none",
+			},
+		},
+		Storage: Storage{
+			ProductsFile:       "products1.json",
+			LayoutDate:         "02/01/2006",
+			TenantsFile:        "tenants.json",
+			AuditFile:          "audit.jsonl",
+			StockFile:          "stock.jsonl",
+			OrdersFile:         "orders.json",
+			CartsFile:          "carts.json",
+			CategoriesFile:     "categories.json",
+			LotsFile:           "lots.json",
+			PriceHistoryFile:   "prices.jsonl",
+			PriceSchedulesFile: "price_schedules.json",
+			PromotionsFile:     "promotions.json",
+			QuotasFile:         "quotas.json",
+		},
+		Auth: Auth{
+			APIKeysFile:         "api_keys.json",
+			ClientCertPrincipal: "cn",
+		},
+		RateLimit: RateLimit{
+			ReadRate:        20,
+			ReadBurst:       40,
+			WriteRate:       2,
+			WriteBurst:      10,
+			WriteDailyQuota: 1000,
+		},
+		Stock: Stock{
+			ReservationTTL:           Duration(15 * time.Minute),
+			ReservationMaxTTL:        Duration(time.Hour),
+			ReservationSweepInterval: Duration(time.Minute),
+		},
+		Cart: Cart{
+			TTL:           Duration(24 * time.Hour),
+			SweepInterval: Duration(10 * time.Minute),
+		},
+		Expiration: Expiration{
+			SweepInterval:  Duration(time.Hour),
+			AlertWithin:    Duration(7 * 24 * time.Hour),
+			WebhookTimeout: Duration(5 * time.Second),
+		},
+		Prices: Prices{
+			SweepInterval:     Duration(time.Minute),
+			ExchangeRatesFile: "exchange_rates.json",
+			Rounding:          "half_even",
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// Validate checks that the configuration is usable
+func (c Config) Validate() (err error) {
+	var errs []error
+	check := func(ok bool, field, reason string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %s %s", ErrConfigInvalid, field, reason))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr", "required")
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout", "must be positive")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout", "must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout", "must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout", "must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes", "must be positive")
+	check(c.Server.MaxBodyBytes > 0, "server.max_body_bytes", "must be positive")
+
+	tls := c.Server.TLS
+	check(tls.Enabled() == (tls.KeyFile != ""), "server.tls", "requires both cert_file and key_file")
+	check(tls.ReloadInterval > 0, "server.tls.reload_interval", "must be positive")
+	check(tls.ClientAuth == "none" || tls.ClientAuth == "optional" || tls.ClientAuth == "require", "server.tls.client_auth", "must be none, optional or require")
+	if tls.ClientAuth != "none" {
+		check(tls.Enabled(), "server.tls.client_auth", "requires cert_file and key_file")
+		check(tls.ClientCAFile != "", "server.tls.client_ca_file", "required by client_auth")
+	}
+
+	check(c.Storage.ProductsFile != "", "storage.products_file", "required")
+	check(validLayout(c.Storage.LayoutDate), "storage.layout_date", "must be a date layout")
+	check(c.Storage.AuditFile != "", "storage.audit_file", "required")
+	check(c.Storage.StockFile != "", "storage.stock_file", "required")
+	check(c.Storage.OrdersFile != "", "storage.orders_file", "required")
+	check(c.Storage.CartsFile != "", "storage.carts_file", "required")
+	check(c.Storage.CategoriesFile != "", "storage.categories_file", "required")
+	check(c.Storage.LotsFile != "", "storage.lots_file", "required")
+	check(c.Storage.PriceHistoryFile != "", "storage.price_history_file", "required")
+	check(c.Storage.PriceSchedulesFile != "", "storage.price_schedules_file", "required")
+	check(c.Storage.PromotionsFile != "", "storage.promotions_file", "required")
+	check(c.Storage.QuotasFile != "", "storage.quotas_file", "required")
+
+	check(c.RateLimit.ReadRate > 0, "rate_limit.read_rate", "must be positive")
+	check(c.RateLimit.ReadBurst > 0, "rate_limit.read_burst", "must be positive")
+	check(c.RateLimit.WriteRate > 0, "rate_limit.write_rate", "must be positive")
+	check(c.RateLimit.WriteBurst > 0, "rate_limit.write_burst", "must be positive")
+	check(c.RateLimit.WriteDailyQuota > 0, "rate_limit.write_daily_quota", "must be positive")
+
+	check(c.Stock.ReservationTTL > 0, "stock.reservation_ttl", "must be positive")
+	check(c.Stock.ReservationMaxTTL >= c.Stock.ReservationTTL, "stock.reservation_max_ttl", "must be at least stock.reservation_ttl")
+	check(c.Stock.ReservationSweepInterval > 0, "stock.reservation_sweep_interval", "must be positive")
+	check(c.Cart.TTL > 0, "cart.ttl", "must be positive")
+	check(c.Cart.SweepInterval > 0, "cart.sweep_interval", "must be positive")
+	check(c.Expiration.SweepInterval > 0, "expiration.sweep_interval", "must be positive")
+	check(c.Expiration.AlertWithin >= 0, "expiration.alert_within", "must not be negative")
+	check(c.Expiration.WebhookTimeout > 0, "expiration.webhook_timeout", "must be positive")
+	check(c.Prices.SweepInterval > 0, "prices.sweep_interval", "must be positive")
+	switch c.Prices.Rounding {
+	case "half_up", "half_even", "down", "up":
+	default:
+		check(false, "prices.rounding", "must be half_up, half_even, down or up")
+	}
+	for _, v := range c.Expiration.Webhooks {
+		u, err := url.Parse(v)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "expiration.webhooks", "must be http or https urls")
+	}
+
+	// without a token nor an api keys file no request could authenticate
+	check(c.Auth.Token != "" || c.Auth.APIKeysFile != "", "auth.token", "required without auth.api_keys_file")
+	switch c.Auth.ClientCertPrincipal {
+	case "cn", "dns", "uri", "email":
+	default:
+		check(false, "auth.client_cert_principal", "must be cn, dns, uri or email")
+	}
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "must be debug, info, warn or error")
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format", "must be json or text")
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the configuration with its secrets masked, safe to print or log
+func (c Config) Redacted() Config {
+	if c.Auth.Token != "" {
+		c.Auth.Token = "REDACTED"
+	}
+	if c.Expiration.WebhookSecret != "" {
+		c.Expiration.WebhookSecret = "REDACTED"
+	}
+	return c
+}
+
+// validLayout reports whether layout formats a date that it can parse back
+func validLayout(layout string) bool {
+	if layout == "" {
+		return false
+	}
+	date := time.Date(2006, time.January, 2, 0, 0, 0, 0, time.UTC)
+	parsed, err := time.Parse(layout, date.Format(layout))
+	return err == nil && parsed.Equal(date)
+}
+
+// Duration is a time.Duration read and written as a string such as "15s"
+type Duration time.Duration
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON reads the duration from a string
+func (d *Duration) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	if err = json.Unmarshal(b, &s); err != nil {
+		return
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return
+	}
+	*d = Duration(v)
+	return
+}
@@ -0,0 +1,120 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Load
+func TestLoad(t *testing.T) {
+	t.Run("case 1: should apply file, environment and flags in increasing precedence", func(t *testing.T) {
+		// arrange
+		file := filepath.Join(t.TempDir(), "config.json")
+		err := os.WriteFile(file, []byte(`{"server":{"addr":":7000","read_timeout":"3s"},"storage":{"products_file":"file.json"},"log":{"level":"debug"}}`), 0644)
+		require.NoError(t, err)
+		env := map[string]string{
+			"MARKET_CONFIG":        file,
+			"MARKET_ADDR":          ":7001",
+			"MARKET_PRODUCTS_FILE": "env.json",
+			"TOKEN":                "secret",
+		}
+
+		// act
+		cfg, printConfig, err := config.Load([]string{"-addr", ":7002"}, func(k string) string { return env[k] })
+
+		// assert
+		require.NoError(t, err)
+		require.False(t, printConfig)
+		require.Equal(t, ":7002", cfg.Server.Addr)
+		require.Equal(t, "env.json", cfg.Storage.ProductsFile)
+		require.Equal(t, config.Duration(3*time.Second), cfg.Server.ReadTimeout)
+		require.Equal(t, "debug", cfg.Log.Level)
+		require.Equal(t, config.Default().Server.WriteTimeout, cfg.Server.WriteTimeout)
+		require.Equal(t, "secret", cfg.Auth.Token)
+		require.Equal(t, "REDACTED", cfg.Redacted().Auth.Token)
+	})
+
+	t.Run("case 2: should report -print-config", func(t *testing.T) {
+		// act
+		_, printConfig, err := config.Load([]string{"-print-config"}, func(string) string { return "" })
+
+		// assert
+		require.NoError(t, err)
+		require.True(t, printConfig)
+	})
+
+	t.Run("case 3: should fail validation", func(t *testing.T) {
+		// act
+		_, _, err := config.Load([]string{"-layout-date", "nope", "-write-burst", "0", "-price-rounding", "nearest", "-api-keys-file", ""}, func(string) string { return "" })
+
+		// assert
+		require.ErrorIs(t, err, config.ErrConfigInvalid)
+		require.ErrorContains(t, err, "storage.layout_date")
+		require.ErrorContains(t, err, "rate_limit.write_burst")
+		require.ErrorContains(t, err, "prices.rounding")
+		require.ErrorContains(t, err, "auth.token")
+	})
+
+	t.Run("case 4: should reject malformed values", func(t *testing.T) {
+		// act
+		_, _, errFlag := config.Load([]string{"-read-timeout", "soon"}, func(string) string { return "" })
+		_, _, errEnv := config.Load(nil, func(k string) string {
+			if k == "MARKET_READ_BURST" {
+				return "many"
+			}
+			return ""
+		})
+
+		// assert
+		require.Error(t, errFlag)
+		require.ErrorIs(t, errEnv, config.ErrConfigInvalid)
+	})
+
+	t.Run("case 5: should read the webhooks as a comma-separated list", func(t *testing.T) {
+		// arrange
+		env := map[string]string{
+			"MARKET_EXPIRATION_WEBHOOKS":       "https://a.example/hook, ,http://b.example/hook",
+			"MARKET_EXPIRATION_WEBHOOK_SECRET": "secret",
+		}
+
+		// act
+		cfg, _, err := config.Load(nil, func(k string) string { return env[k] })
+		_, _, errURL := config.Load([]string{"-expiration-webhooks", "ftp://c.example"}, func(string) string { return "" })
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, []string{"https://a.example/hook", "http://b.example/hook"}, cfg.Expiration.Webhooks)
+		require.Equal(t, "REDACTED", cfg.Redacted().Expiration.WebhookSecret)
+		require.ErrorContains(t, errURL, "expiration.webhooks")
+	})
+
+	t.Run("case 6: should read the token from a file and never from the command line", func(t *testing.T) {
+		// arrange
+		file := filepath.Join(t.TempDir(), "token")
+		err := os.WriteFile(file, []byte("from-file\n"), 0600)
+		require.NoError(t, err)
+
+		// act
+		cfg, _, err := config.Load([]string{"-token-file", file}, func(k string) string {
+			if k == "TOKEN" {
+				return "from-env"
+			}
+			return ""
+		})
+		_, _, errFlag := config.Load([]string{"-token", "secret"}, func(string) string { return "" })
+		_, _, errSecret := config.Load([]string{"-expiration-webhook-secret", "secret"}, func(string) string { return "" })
+		_, _, errMissing := config.Load([]string{"-token-file", filepath.Join(t.TempDir(), "missing")}, func(string) string { return "" })
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, "from-file", cfg.Auth.Token)
+		require.ErrorContains(t, errFlag, "flag provided but not defined: -token")
+		require.ErrorContains(t, errSecret, "flag provided but not defined")
+		require.ErrorIs(t, errMissing, config.ErrConfigInvalid)
+	})
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binding ties a configuration field to its command line flag and environment variable.
+// The secrets have no flag: the command line is visible to every user of the host.
+type binding struct {
+	flag  string
+	env   string
+	usage string
+	field func(c *Config) any
+}
+
+// bindings lists every field that can be set from the command line or the environment
+var bindings = []binding{
+	{"addr", "MARKET_ADDR", "address the server listens on", func(c *Config) any { return &c.Server.Addr }},
+	{"read-header-timeout", "MARKET_READ_HEADER_TIMEOUT", "time allowed to read the request headers", func(c *Config) any { return &c.Server.ReadHeaderTimeout }},
+	{"read-timeout", "MARKET_READ_TIMEOUT", "time allowed to read the whole request", func(c *Config) any { return &c.Server.ReadTimeout }},
+	{"write-timeout", "MARKET_WRITE_TIMEOUT", "time allowed to write the response", func(c *Config) any { return &c.Server.WriteTimeout }},
+	{"idle-timeout", "MARKET_IDLE_TIMEOUT", "time a keep-alive connection is kept open", func(c *Config) any { return &c.Server.IdleTimeout }},
+	{"shutdown-timeout", "MARKET_SHUTDOWN_TIMEOUT", "time in-flight requests are given on shutdown", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"max-header-bytes", "MARKET_MAX_HEADER_BYTES", "maximum size of the request headers", func(c *Config) any { return &c.Server.MaxHeaderBytes }},
+	{"max-body-bytes", "MARKET_MAX_BODY_BYTES", "maximum size of a request body", func(c *Config) any { return &c.Server.MaxBodyBytes }},
+	{"tls-cert-file", "MARKET_TLS_CERT_FILE", "PEM certificate chain; enables TLS", func(c *Config) any { return &c.Server.TLS.CertFile }},
+	{"tls-key-file", "MARKET_TLS_KEY_FILE", "PEM private key", func(c *Config) any { return &c.Server.TLS.KeyFile }},
+	{"tls-reload-interval", "MARKET_TLS_RELOAD_INTERVAL", "how often the certificate files are checked for changes", func(c *Config) any { return &c.Server.TLS.ReloadInterval }},
+	{"tls-client-auth", "MARKET_TLS_CLIENT_AUTH", "client certificate policy: none, optional or require", func(c *Config) any { return &c.Server.TLS.ClientAuth }},
+	{"tls-client-ca-file", "MARKET_TLS_CLIENT_CA_FILE", "PEM bundle of the client certificate CAs", func(c *Config) any { return &c.Server.TLS.ClientCAFile }},
+	{"products-file", "MARKET_PRODUCTS_FILE", "products file of the default tenant", func(c *Config) any { return &c.Storage.ProductsFile }},
+	{"layout-date", "MARKET_LAYOUT_DATE", "layout of the dates in the products files", func(c *Config) any { return &c.Storage.LayoutDate }},
+	{"tenants-file", "MARKET_TENANTS_FILE", "file mapping tenants to their products files", func(c *Config) any { return &c.Storage.TenantsFile }},
+	{"audit-file", "MARKET_AUDIT_FILE", "append-only audit log", func(c *Config) any { return &c.Storage.AuditFile }},
+	{"stock-file", "MARKET_STOCK_FILE", "append-only stock ledger", func(c *Config) any { return &c.Storage.StockFile }},
+	{"orders-file", "MARKET_ORDERS_FILE", "file of the orders", func(c *Config) any { return &c.Storage.OrdersFile }},
+	{"carts-file", "MARKET_CARTS_FILE", "file of the carts", func(c *Config) any { return &c.Storage.CartsFile }},
+	{"categories-file", "MARKET_CATEGORIES_FILE", "file of the categories", func(c *Config) any { return &c.Storage.CategoriesFile }},
+	{"lots-file", "MARKET_LOTS_FILE", "file of the lots", func(c *Config) any { return &c.Storage.LotsFile }},
+	{"price-history-file", "MARKET_PRICE_HISTORY_FILE", "append-only price history", func(c *Config) any { return &c.Storage.PriceHistoryFile }},
+	{"price-schedules-file", "MARKET_PRICE_SCHEDULES_FILE", "file of the scheduled price changes", func(c *Config) any { return &c.Storage.PriceSchedulesFile }},
+	{"promotions-file", "MARKET_PROMOTIONS_FILE", "file of the promotions", func(c *Config) any { return &c.Storage.PromotionsFile }},
+	{"quotas-file", "MARKET_QUOTAS_FILE", "file of the daily write quotas counted today", func(c *Config) any { return &c.Storage.QuotasFile }},
+	{"", "TOKEN", "single accepted token when there is no api keys file", func(c *Config) any { return &c.Auth.Token }},
+	{"token-file", "MARKET_TOKEN_FILE", "file holding the single accepted token", func(c *Config) any { return &c.Auth.TokenFile }},
+	{"api-keys-file", "MARKET_API_KEYS_FILE", "file of accepted api keys", func(c *Config) any { return &c.Auth.APIKeysFile }},
+	{"admin-principals", "MARKET_ADMIN_PRINCIPALS", "comma-separated principals allowed on /debug", func(c *Config) any { return &c.Auth.AdminPrincipals }},
+	{"client-cert-principal", "MARKET_CLIENT_CERT_PRINCIPAL", "client certificate field used as principal: cn, dns, uri or email", func(c *Config) any { return &c.Auth.ClientCertPrincipal }},
+	{"read-rate", "MARKET_READ_RATE", "read requests per second per principal", func(c *Config) any { return &c.RateLimit.ReadRate }},
+	{"read-burst", "MARKET_READ_BURST", "read requests a principal can make at once", func(c *Config) any { return &c.RateLimit.ReadBurst }},
+	{"write-rate", "MARKET_WRITE_RATE", "write requests per second per principal", func(c *Config) any { return &c.RateLimit.WriteRate }},
+	{"write-burst", "MARKET_WRITE_BURST", "write requests a principal can make at once", func(c *Config) any { return &c.RateLimit.WriteBurst }},
+	{"write-daily-quota", "MARKET_WRITE_DAILY_QUOTA", "write requests an api key can make per day", func(c *Config) any { return &c.RateLimit.WriteDailyQuota }},
+	{"reservation-ttl", "MARKET_RESERVATION_TTL", "time a reservation holds its units by default", func(c *Config) any { return &c.Stock.ReservationTTL }},
+	{"reservation-max-ttl", "MARKET_RESERVATION_MAX_TTL", "longest time a reservation can hold its units", func(c *Config) any { return &c.Stock.ReservationMaxTTL }},
+	{"reservation-sweep-interval", "MARKET_RESERVATION_SWEEP_INTERVAL", "how often the expired reservations are released", func(c *Config) any { return &c.Stock.ReservationSweepInterval }},
+	{"cart-ttl", "MARKET_CART_TTL", "time a cart is kept after its last change", func(c *Config) any { return &c.Cart.TTL }},
+	{"cart-sweep-interval", "MARKET_CART_SWEEP_INTERVAL", "how often the expired carts are deleted", func(c *Config) any { return &c.Cart.SweepInterval }},
+	{"expiration-sweep-interval", "MARKET_EXPIRATION_SWEEP_INTERVAL", "how often the expired products and lots are withdrawn", func(c *Config) any { return &c.Expiration.SweepInterval }},
+	{"expiration-alert-within", "MARKET_EXPIRATION_ALERT_WITHIN", "how long before their expiration the products are alerted", func(c *Config) any { return &c.Expiration.AlertWithin }},
+	{"expiration-webhooks", "MARKET_EXPIRATION_WEBHOOKS", "comma-separated urls the expiration alerts are posted to", func(c *Config) any { return &c.Expiration.Webhooks }},
+	{"", "MARKET_EXPIRATION_WEBHOOK_SECRET", "key the expiration alerts are signed with", func(c *Config) any { return &c.Expiration.WebhookSecret }},
+	{"expiration-webhook-timeout", "MARKET_EXPIRATION_WEBHOOK_TIMEOUT", "time a webhook is given to answer", func(c *Config) any { return &c.Expiration.WebhookTimeout }},
+	{"price-sweep-interval", "MARKET_PRICE_SWEEP_INTERVAL", "how often the effective scheduled price changes are applied", func(c *Config) any { return &c.Prices.SweepInterval }},
+	{"exchange-rates-file", "MARKET_EXCHANGE_RATES_FILE", "table of exchange rates the prices are converted with", func(c *Config) any { return &c.Prices.ExchangeRatesFile }},
+	{"price-rounding", "MARKET_PRICE_ROUNDING", "rounding of the converted and discounted prices: half_up, half_even, down or up", func(c *Config) any { return &c.Prices.Rounding }},
+	{"log-level", "MARKET_LOG_LEVEL", "minimum level logged: debug, info, warn or error", func(c *Config) any { return &c.Log.Level }},
+	{"log-format", "MARKET_LOG_FORMAT", "format of the logs: json or text", func(c *Config) any { return &c.Log.Format }},
+}
+
+// Load builds the configuration from, in increasing order of precedence: the defaults,
+// the config file (-config or MARKET_CONFIG), the environment variables and the command line flags.
+// The result is validated. printConfig reports whether -print-config was given.
+func Load(args []string, getenv func(string) string) (cfg Config, printConfig bool, err error) {
+	// flags
+	// - values are kept aside so they can be applied last, whatever the config file says
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags := make(map[string]string)
+	defaults := Default()
+	for _, b := range bindings {
+		b := b
+		if b.flag == "" {
+			continue
+		}
+		usage := fmt.Sprintf("%s (env %s, default %s)", b.usage, b.env, format(b.field(&defaults)))
+		fs.Func(b.flag, usage, func(s string) error {
+			// reject malformed values at parse time
+			var scratch Config
+			if err := set(b.field(&scratch), s); err != nil {
+				return err
+			}
+			flags[b.flag] = s
+			return nil
+		})
+	}
+	configFile := fs.String("config", getenv("MARKET_CONFIG"), "optional JSON config file (env MARKET_CONFIG)")
+	fs.BoolVar(&printConfig, "print-config", false, "print the resulting configuration, secrets redacted, and exit")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	// defaults
+	cfg = defaults
+
+	// file
+	if *configFile != "" {
+		if err = loadFile(*configFile, &cfg); err != nil {
+			return
+		}
+	}
+
+	// environment
+	for _, b := range bindings {
+		if v := getenv(b.env); v != "" {
+			if err = set(b.field(&cfg), v); err != nil {
+				err = fmt.Errorf("%w: %s: %v", ErrConfigInvalid, b.env, err)
+				return
+			}
+		}
+	}
+
+	// flags
+	for _, b := range bindings {
+		if v, ok := flags[b.flag]; ok {
+			set(b.field(&cfg), v)
+		}
+	}
+
+	// secrets kept in files
+	if cfg.Auth.TokenFile != "" {
+		var b []byte
+		if b, err = os.ReadFile(cfg.Auth.TokenFile); err != nil {
+			err = fmt.Errorf("%w: auth.token_file: %v", ErrConfigInvalid, err)
+			return
+		}
+		cfg.Auth.Token = strings.TrimSpace(string(b))
+	}
+
+	err = cfg.Validate()
+	return
+}
+
+// loadFile overlays the fields present in a JSON config file on cfg
+func loadFile(filePath string, cfg *Config) (err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(cfg); err != nil {
+		err = fmt.Errorf("%w: %s: %v", ErrConfigInvalid, filePath, err)
+		return
+	}
+	return
+}
+
+// set parses s into the field pointed by ptr
+func set(ptr any, s string) (err error) {
+	switch p := ptr.(type) {
+	case *string:
+		*p = s
+	case *int:
+		*p, err = strconv.Atoi(s)
+	case *int64:
+		*p, err = strconv.ParseInt(s, 10, 64)
+	case *float64:
+		*p, err = strconv.ParseFloat(s, 64)
+	case *Duration:
+		var d time.Duration
+		d, err = time.ParseDuration(s)
+		*p = Duration(d)
+	case *[]string:
+		// a comma-separated list, the empty items left out
+		*p = nil
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				*p = append(*p, v)
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported field type %T", ptr)
+	}
+	return
+}
+
+// format returns the value of the field pointed by ptr as a flag would take it
+func format(ptr any) string {
+	switch p := ptr.(type) {
+	case *string:
+		return strconv.Quote(*p)
+	case *int:
+		return strconv.Itoa(*p)
+	case *int64:
+		return strconv.FormatInt(*p, 10)
+	case *float64:
+		return strconv.FormatFloat(*p, 'g', -1, 64)
+	case *Duration:
+		return time.Duration(*p).String()
+	case *[]string:
+		return strconv.Quote(strings.Join(*p, ","))
+	}
+	return ""
+}
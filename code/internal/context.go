@@ -0,0 +1,18 @@
+package internal
+
+import "context"
+
+// requestIDKey is the context key under which the request id is stored for the services
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the id of the request being served,
+// which the services stamp on what they record
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id stored in ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) (id string) {
+	id, _ = ctx.Value(requestIDKey{}).(string)
+	return
+}
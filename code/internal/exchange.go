@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+var (
+	// ErrExchangeRateMissing is returned when there is no rate to convert between two currencies
+	ErrExchangeRateMissing = errors.New("exchange rate missing")
+	// ErrExchangeRatesInvalid is returned when a table of exchange rates has an unknown currency or a rate that is not positive
+	ErrExchangeRatesInvalid = errors.New("exchange rates invalid")
+)
+
+// ExchangeRates is a table of the value of currencies against a base currency
+type ExchangeRates struct {
+	// Base is the currency the rates are quoted against
+	Base string
+	// Rates are the units of each currency one unit of the base buys, e.g. {EUR: 0.92} for a USD base
+	Rates map[string]*big.Rat
+}
+
+// Rate returns the units of the currency to one unit of another currency
+func (e ExchangeRates) Rate(from, to string) (rate *big.Rat, ok bool) {
+	if from == to {
+		return big.NewRat(1, 1), true
+	}
+	rateFrom, ok := e.rate(from)
+	if !ok {
+		return
+	}
+	rateTo, ok := e.rate(to)
+	if !ok {
+		return
+	}
+	rate = new(big.Rat).Quo(rateTo, rateFrom)
+	return
+}
+
+// rate returns the units of a currency one unit of the base buys
+func (e ExchangeRates) rate(currency string) (rate *big.Rat, ok bool) {
+	if currency == e.Base {
+		return big.NewRat(1, 1), true
+	}
+	rate, ok = e.Rates[currency]
+	return
+}
+
+// ExchangeService converts amounts between currencies
+type ExchangeService interface {
+	// Convert returns the amount in a currency, rounded to its minor unit as configured
+	Convert(ctx context.Context, m Money, currency string) (converted Money, err error)
+}
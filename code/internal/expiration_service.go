@@ -0,0 +1,15 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type ExpirationService interface {
+	// Returns the products not expired yet that expire within the duration, first expiring first
+	Expiring(ctx context.Context, within time.Duration) (products []Product, err error)
+
+	// Unpublishes the expired products and writes off the expired lots of every tenant, alerting about them
+	// and about the products about to expire, returning the number of products unpublished and lots written off
+	Sweep(ctx context.Context) (n int, err error)
+}
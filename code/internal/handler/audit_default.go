@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultAudit struct {
+	sv internal.AuditService
+}
+
+func NewDefaultAudit(sv internal.AuditService) *DefaultAudit {
+	return &DefaultAudit{
+		sv: sv,
+	}
+}
+
+type AuditEntryJSON struct {
+	Time      string       `json:"time"`
+	Principal string       `json:"principal"`
+	RequestID string       `json:"request_id"`
+	Action    string       `json:"action"`
+	ProductID int          `json:"product_id"`
+	Before    *ProductJSON `json:"before"`
+	After     *ProductJSON `json:"after"`
+}
+
+// GetAll returns the audit entries filtered by product_id, principal and a from/to time range (RFC 3339)
+func (a *DefaultAudit) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		//get filters from query params
+		var filter internal.AuditFilter
+		var err error
+		query := r.URL.Query()
+		if v := query.Get("product_id"); v != "" {
+			filter.ProductID, err = strconv.Atoi(v)
+			if err != nil {
+				response.Text(w, http.StatusBadRequest, "invalid product_id")
+				return
+			}
+		}
+		filter.Principal = query.Get("principal")
+		if v := query.Get("from"); v != "" {
+			filter.From, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				response.Text(w, http.StatusBadRequest, "invalid from")
+				return
+			}
+		}
+		if v := query.Get("to"); v != "" {
+			filter.To, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				response.Text(w, http.StatusBadRequest, "invalid to")
+				return
+			}
+		}
+
+		//process
+		entries, err := a.sv.Find(r.Context(), filter)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "find audit entries", "error", err)
+			response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		//response
+		// serialize entries to json
+		data := make([]AuditEntryJSON, 0, len(entries))
+		for _, e := range entries {
+			data = append(data, AuditEntryJSON{
+				Time:      e.Time.Format(time.RFC3339Nano),
+				Principal: e.Principal,
+				RequestID: e.RequestID,
+				Action:    e.Action,
+				ProductID: e.ProductID,
+				Before:    snapshotJSON(e.Before),
+				After:     snapshotJSON(e.After),
+			})
+		}
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    data,
+		})
+	}
+}
+
+// snapshotJSON serializes a product snapshot, keeping nil as nil
+func snapshotJSON(p *internal.Product) *ProductJSON {
+	if p == nil {
+		return nil
+	}
+	return &ProductJSON{
+		Id:           p.Id,
+		Name:         p.Name,
+		Quantity:     p.Quantity,
+		Code_value:   p.Code_value,
+		Is_published: p.Is_published,
+		Expiration:   p.Expiration.Format("02/01/2006"),
+		Price:        p.Price.Major(),
+		Currency:     p.Price.Currency,
+		Attributes:   attributesJSON(p.Attributes),
+		Variants:     variantsJSON(p.Variants),
+	}
+}
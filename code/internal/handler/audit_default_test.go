@@ -0,0 +1,118 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditDefault_GetAll(t *testing.T) {
+	t.Run("success 01 - should record a delete and filter by product and principal", func(t *testing.T) {
+		// arrange
+		db := make(map[int]*internal.Product)
+		db[1] = &internal.Product{
+			Id:           1,
+			Name:         "Product 1",
+			Quantity:     10,
+			Code_value:   "S6611",
+			Is_published: true,
+			Expiration:   time.Date(2006, time.February, 1, 0, 0, 0, 0, time.UTC),
+			Price:        internal.Money{Amount: 1000, Currency: "USD"},
+		}
+		db[2] = &internal.Product{Id: 2, Name: "Product 2"}
+
+		rp := repository.NewProductRepository(db, 2)
+		rpAudit := repository.NewAuditJSONL(filepath.Join(t.TempDir(), "audit.jsonl"), "02/01/2006")
+		svAudit := service.NewAuditDefault(rpAudit)
+		sv := service.NewProductAudit(service.NewProductDefault(rp), svAudit)
+		hd := handler.NewDefaultProducts(sv)
+		hdAudit := handler.NewDefaultAudit(svAudit)
+
+		deleteProduct := func(id, principal string) {
+			req := httptest.NewRequest("DELETE", "/products/"+id, nil)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("id", id)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx)
+			ctx = auth.ContextWithPrincipal(ctx, principal)
+			ctx = internal.ContextWithRequestID(ctx, "req-"+id)
+			res := httptest.NewRecorder()
+			hd.Delete()(res, req.WithContext(ctx))
+			require.Equal(t, http.StatusNoContent, res.Code)
+		}
+		deleteProduct("1", "alice")
+		deleteProduct("2", "bob")
+
+		// act
+		req := httptest.NewRequest("GET", "/audit?product_id=1&principal=alice", nil)
+		res := httptest.NewRecorder()
+		hdAudit.GetAll()(res, req)
+
+		type Response struct {
+			Data    []handler.AuditEntryJSON `json:"data"`
+			Message string                   `json:"message"`
+		}
+
+		var response Response
+		json.NewDecoder(res.Body).Decode(&response)
+
+		// assert
+		expectedCode := http.StatusOK
+		expectedBefore := &handler.ProductJSON{Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S6611", Is_published: true, Expiration: "01/02/2006", Price: 10, Currency: "USD"}
+
+		require.Equal(t, expectedCode, res.Code)
+		require.Len(t, response.Data, 1)
+		require.Equal(t, internal.AuditActionDelete, response.Data[0].Action)
+		require.Equal(t, "alice", response.Data[0].Principal)
+		require.Equal(t, "req-1", response.Data[0].RequestID)
+		require.Equal(t, expectedBefore, response.Data[0].Before)
+		require.Nil(t, response.Data[0].After)
+	})
+
+	t.Run("success 02 - should keep the change when the audit log cannot be written", func(t *testing.T) {
+		// arrange
+		db := make(map[int]*internal.Product)
+		rp := repository.NewProductRepository(db, 0)
+		// the directory of the audit log does not exist, so no entry can be appended
+		rpAudit := repository.NewAuditJSONL(filepath.Join(t.TempDir(), "missing", "audit.jsonl"), "02/01/2006")
+		sv := service.NewProductAudit(service.NewProductDefault(rp), service.NewAuditDefault(rpAudit))
+		hd := handler.NewDefaultProducts(sv)
+
+		// act
+		req := httptest.NewRequest("POST", "/products", strings.NewReader(`{"name":"Product 1","quantity":10,"code_value":"S6611","is_published":true,"expiration":"01/02/2099","price":10}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		hd.Create()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Len(t, db, 1)
+	})
+
+	t.Run("fail 01 - should return bad request when the time range is invalid", func(t *testing.T) {
+		// arrange
+		rpAudit := repository.NewAuditJSONL(filepath.Join(t.TempDir(), "audit.jsonl"), "02/01/2006")
+		hdAudit := handler.NewDefaultAudit(service.NewAuditDefault(rpAudit))
+
+		// act
+		req := httptest.NewRequest("GET", "/audit?from=yesterday", nil)
+		res := httptest.NewRecorder()
+		hdAudit.GetAll()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, "invalid from", res.Body.String())
+	})
+}
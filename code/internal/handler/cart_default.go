@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultCarts struct {
+	sv internal.CartService
+}
+
+func NewDefaultCarts(sv internal.CartService) *DefaultCarts {
+	return &DefaultCarts{
+		sv: sv,
+	}
+}
+
+type CartLineJSON struct {
+	ProductID int     `json:"product_id"`
+	Name      string  `json:"name"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	// PreviousUnitPrice is the price before the product changed price, omitted if it did not
+	PreviousUnitPrice float64 `json:"previous_unit_price,omitempty"`
+	// Subtotal is the price of the units once the promotions apply;
+	// Promotions are the ids of the promotions that lowered it, in the order they applied
+	Subtotal   float64 `json:"subtotal"`
+	Promotions []int   `json:"promotions,omitempty"`
+}
+
+type CartJSON struct {
+	ID        string         `json:"id"`
+	Principal string         `json:"principal"`
+	Lines     []CartLineJSON `json:"lines"`
+	Total     float64        `json:"total"`
+	// Currency is the currency of the prices of the lines and of the total, omitted while the cart is empty
+	Currency string `json:"currency,omitempty"`
+	Created  string `json:"created"`
+	Updated  string `json:"updated"`
+	Expires  string `json:"expires"`
+}
+
+type BodyCartLineJSON struct {
+	Quantity int `json:"quantity"`
+}
+
+// Create creates an empty cart
+func (h *DefaultCarts) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		cart, err := h.sv.Create(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "create cart", "error", err)
+			response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    cartJSON(cart),
+		})
+	}
+}
+
+// GetByID returns a cart at the current prices
+func (h *DefaultCarts) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		cart, err := h.sv.GetByID(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			cartError(w, r, "get cart", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    cartJSON(cart),
+		})
+	}
+}
+
+// SetLine sets the quantity of a product in a cart
+func (h *DefaultCarts) SetLine() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		productID, err := strconv.Atoi(chi.URLParam(r, "product_id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid product id")
+			return
+		}
+
+		var body BodyCartLineJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyCartLine}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+
+		//process
+		cart, err := h.sv.SetLine(r.Context(), chi.URLParam(r, "id"), productID, body.Quantity)
+		if err != nil {
+			cartError(w, r, "set cart line", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    cartJSON(cart),
+		})
+	}
+}
+
+// RemoveLine removes a product from a cart
+func (h *DefaultCarts) RemoveLine() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		productID, err := strconv.Atoi(chi.URLParam(r, "product_id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid product id")
+			return
+		}
+
+		//process
+		cart, err := h.sv.RemoveLine(r.Context(), chi.URLParam(r, "id"), productID)
+		if err != nil {
+			cartError(w, r, "remove cart line", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    cartJSON(cart),
+		})
+	}
+}
+
+// Delete deletes a cart
+func (h *DefaultCarts) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		if err := h.sv.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+			cartError(w, r, "delete cart", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusNoContent, map[string]any{
+			"message": "success",
+			"data":    nil,
+		})
+	}
+}
+
+// Checkout places an order of a cart and deletes the cart
+func (h *DefaultCarts) Checkout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		order, err := h.sv.Checkout(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrOrderInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid order")
+			default:
+				cartError(w, r, "checkout cart", err)
+			}
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    orderJSON(order),
+		})
+	}
+}
+
+// cartError writes the response of a failed cart operation
+func cartError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	switch {
+	case errors.Is(err, internal.ErrCartNotFound):
+		response.Text(w, http.StatusNotFound, "Cart not found")
+	case errors.Is(err, internal.ErrCartExpired):
+		response.Text(w, http.StatusGone, "Cart expired")
+	case errors.Is(err, internal.ErrCartInvalid):
+		response.Text(w, http.StatusBadRequest, "Invalid cart")
+	case errors.Is(err, internal.ErrStockInsufficient):
+		response.Text(w, http.StatusConflict, "Insufficient stock")
+	default:
+		slog.ErrorContext(r.Context(), msg, "error", err)
+		response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// cartJSON serializes a cart
+func cartJSON(v internal.Cart) CartJSON {
+	data := CartJSON{
+		ID:        v.ID,
+		Principal: v.Principal,
+		Lines:     make([]CartLineJSON, 0, len(v.Lines)),
+		Total:     v.Total().Major(),
+		Currency:  v.Total().Currency,
+		Created:   v.Created.Format(time.RFC3339),
+		Updated:   v.Updated.Format(time.RFC3339),
+		Expires:   v.Expires.Format(time.RFC3339),
+	}
+	for _, l := range v.Lines {
+		data.Lines = append(data.Lines, CartLineJSON{
+			ProductID:         l.ProductID,
+			Name:              l.Name,
+			Quantity:          l.Quantity,
+			UnitPrice:         l.UnitPrice.Major(),
+			PreviousUnitPrice: l.PreviousUnitPrice.Major(),
+			Subtotal:          l.Subtotal().Major(),
+			Promotions:        l.Promotions,
+		})
+	}
+	return data
+}
@@ -0,0 +1,231 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCarts(t *testing.T) {
+	// newHandlers returns the product and cart handlers over a catalog with the products 1 and 2, published,
+	// and the product 3, not published, keeping the carts for ttl and pricing them with the promotions
+	newHandlers := func(t *testing.T, ttl time.Duration, promotions ...internal.Promotion) (*handler.DefaultProducts, *handler.DefaultCarts) {
+		expiration := time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S1", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 1000, Currency: "USD"}},
+			2: {Id: 2, Name: "Product 2", Quantity: 5, Code_value: "S2", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 250, Currency: "USD"}},
+			3: {Id: 3, Name: "Product 3", Quantity: 5, Code_value: "S3", Is_published: false, Expiration: expiration, Price: internal.Money{Amount: 100, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 3))
+		st := service.NewStockDefault(repository.NewStockJSONL(t.TempDir()+"/stock.jsonl"), repository.NewReservationMap(), sv)
+		svProducts := service.NewProductStock(sv, st)
+		stPromotions := repository.NewPromotionJSON(t.TempDir() + "/promotions.json")
+		for _, v := range promotions {
+			require.NoError(t, stPromotions.Create(context.Background(), &v))
+		}
+		svPricing := service.NewPricingDefault(stPromotions, nil, internal.RoundHalfEven)
+		svOrders := service.NewOrderDefault(repository.NewOrderJSON(t.TempDir()+"/orders.json"), st).WithPricing(svPricing)
+		svCarts := service.NewCartDefault(repository.NewCartJSON(t.TempDir()+"/carts.json"), svProducts, svOrders, ttl).WithPricing(svPricing)
+		return handler.NewDefaultProducts(svProducts), handler.NewDefaultCarts(svCarts)
+	}
+	// send sends a request to a handler with the url params, decoding the data of the response into out
+	send := func(h http.HandlerFunc, method, body string, out any, params ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/carts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		chiCtx := chi.NewRouteContext()
+		for i := 0; i < len(params); i += 2 {
+			chiCtx.URLParams.Add(params[i], params[i+1])
+		}
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h(res, req)
+		if out != nil {
+			json.Unmarshal(res.Body.Bytes(), &struct {
+				Data any `json:"data"`
+			}{Data: out})
+		}
+		return res
+	}
+	// create creates a cart and returns its id
+	create := func(t *testing.T, hd *handler.DefaultCarts) string {
+		var cart handler.CartJSON
+		res := send(hd.Create(), "POST", "", &cart)
+		require.Equal(t, http.StatusCreated, res.Code)
+		return cart.ID
+	}
+
+	t.Run("success 01 - should add, change and remove lines", func(t *testing.T) {
+		// arrange
+		_, hd := newHandlers(t, time.Hour)
+		id := create(t, hd)
+
+		// act
+		send(hd.SetLine(), "PUT", `{"quantity":2}`, nil, "id", id, "product_id", "1")
+		send(hd.SetLine(), "PUT", `{"quantity":4}`, nil, "id", id, "product_id", "2")
+		send(hd.SetLine(), "PUT", `{"quantity":3}`, nil, "id", id, "product_id", "1")
+		var added handler.CartJSON
+		send(hd.GetByID(), "GET", "", &added, "id", id)
+		var removed handler.CartJSON
+		res := send(hd.RemoveLine(), "DELETE", "", &removed, "id", id, "product_id", "2")
+
+		// assert
+		require.Equal(t, []handler.CartLineJSON{
+			{ProductID: 1, Name: "Product 1", Quantity: 3, UnitPrice: 10, Subtotal: 30},
+			{ProductID: 2, Name: "Product 2", Quantity: 4, UnitPrice: 2.5, Subtotal: 10},
+		}, added.Lines)
+		require.Equal(t, 40.0, added.Total)
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Len(t, removed.Lines, 1)
+		require.Equal(t, 30.0, removed.Total)
+	})
+
+	t.Run("success 02 - should reprice the lines when the price of a product changes", func(t *testing.T) {
+		// arrange
+		hdProducts, hd := newHandlers(t, time.Hour)
+		id := create(t, hd)
+		send(hd.SetLine(), "PUT", `{"quantity":2}`, nil, "id", id, "product_id", "1")
+
+		// act
+		patch := send(hdProducts.Update(), "PATCH", `{"price":12}`, nil, "id", "1")
+		var cart handler.CartJSON
+		send(hd.GetByID(), "GET", "", &cart, "id", id)
+
+		// assert
+		require.Equal(t, http.StatusOK, patch.Code)
+		require.Equal(t, 12.0, cart.Lines[0].UnitPrice)
+		require.Equal(t, 10.0, cart.Lines[0].PreviousUnitPrice)
+		require.Equal(t, 24.0, cart.Total)
+	})
+
+	t.Run("success 03 - should check a cart out into an order", func(t *testing.T) {
+		// arrange
+		hdProducts, hd := newHandlers(t, time.Hour)
+		id := create(t, hd)
+		send(hd.SetLine(), "PUT", `{"quantity":2}`, nil, "id", id, "product_id", "1")
+		send(hdProducts.Update(), "PATCH", `{"price":12}`, nil, "id", "1")
+
+		// act
+		var order handler.OrderJSON
+		res := send(hd.Checkout(), "POST", "", &order, "id", id)
+		got := send(hd.GetByID(), "GET", "", nil, "id", id)
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, internal.OrderStatePending, order.State)
+		require.Equal(t, 24.0, order.Total)
+		require.Equal(t, http.StatusNotFound, got.Code)
+	})
+
+	t.Run("success 04 - should total the lines exactly, in the currency of the products", func(t *testing.T) {
+		// arrange
+		hdProducts, hd := newHandlers(t, time.Hour)
+		send(hdProducts.Update(), "PATCH", `{"price":0.2}`, nil, "id", "1")
+		send(hdProducts.Update(), "PATCH", `{"price":0.1}`, nil, "id", "2")
+		id := create(t, hd)
+
+		// act
+		send(hd.SetLine(), "PUT", `{"quantity":1}`, nil, "id", id, "product_id", "1")
+		var cart handler.CartJSON
+		send(hd.SetLine(), "PUT", `{"quantity":3}`, &cart, "id", id, "product_id", "2")
+
+		// assert
+		require.Equal(t, 0.3, cart.Lines[1].Subtotal)
+		require.Equal(t, 0.5, cart.Total)
+		require.Equal(t, "USD", cart.Currency)
+	})
+
+	t.Run("success 05 - should price the lines and the order checked out with the active promotions", func(t *testing.T) {
+		// arrange
+		_, hd := newHandlers(t, time.Hour,
+			internal.Promotion{Name: "20% off S1", Kind: internal.PromotionPercent, Percent: 20,
+				Conditions: []internal.PromotionCondition{{Field: internal.PromotionFieldCodeValue, Operator: internal.PromotionEq, Value: "S1"}}},
+			internal.Promotion{Name: "buy 2 get 1 S2", Kind: internal.PromotionBuyGet, Buy: 2, Get: 1,
+				Conditions: []internal.PromotionCondition{{Field: internal.PromotionFieldCodeValue, Operator: internal.PromotionEq, Value: "S2"}}},
+		)
+		id := create(t, hd)
+		send(hd.SetLine(), "PUT", `{"quantity":2}`, nil, "id", id, "product_id", "1")
+		send(hd.SetLine(), "PUT", `{"quantity":3}`, nil, "id", id, "product_id", "2")
+
+		// act
+		var cart handler.CartJSON
+		send(hd.GetByID(), "GET", "", &cart, "id", id)
+		var order handler.OrderJSON
+		res := send(hd.Checkout(), "POST", "", &order, "id", id)
+
+		// assert
+		require.Equal(t, []handler.CartLineJSON{
+			{ProductID: 1, Name: "Product 1", Quantity: 2, UnitPrice: 10, Subtotal: 16, Promotions: []int{1}},
+			{ProductID: 2, Name: "Product 2", Quantity: 3, UnitPrice: 2.5, Subtotal: 5, Promotions: []int{2}},
+		}, cart.Lines)
+		require.Equal(t, 21.0, cart.Total)
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, []handler.OrderLineJSON{
+			{ProductID: 1, Name: "Product 1", Quantity: 2, UnitPrice: 10, Subtotal: 16, Promotions: []int{1}},
+			{ProductID: 2, Name: "Product 2", Quantity: 3, UnitPrice: 2.5, Subtotal: 5, Promotions: []int{2}},
+		}, order.Lines)
+		require.Equal(t, 21.0, order.Total)
+	})
+
+	t.Run("fail 01 - should reject lines that cannot be sold", func(t *testing.T) {
+		// arrange
+		_, hd := newHandlers(t, time.Hour)
+		id := create(t, hd)
+
+		// act
+		unpublished := send(hd.SetLine(), "PUT", `{"quantity":1}`, nil, "id", id, "product_id", "3")
+		missing := send(hd.SetLine(), "PUT", `{"quantity":1}`, nil, "id", id, "product_id", "9")
+		insufficient := send(hd.SetLine(), "PUT", `{"quantity":11}`, nil, "id", id, "product_id", "1")
+		zero := send(hd.SetLine(), "PUT", `{"quantity":0}`, nil, "id", id, "product_id", "1")
+		empty := send(hd.Checkout(), "POST", "", nil, "id", id)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, unpublished.Code)
+		require.Equal(t, "Invalid cart", unpublished.Body.String())
+		require.Equal(t, http.StatusBadRequest, missing.Code)
+		require.Equal(t, http.StatusConflict, insufficient.Code)
+		require.Equal(t, http.StatusBadRequest, zero.Code)
+		require.Equal(t, http.StatusBadRequest, empty.Code)
+	})
+
+	t.Run("fail 02 - should reject an expired cart", func(t *testing.T) {
+		// arrange
+		_, hd := newHandlers(t, time.Millisecond)
+		id := create(t, hd)
+		time.Sleep(5 * time.Millisecond)
+
+		// act
+		got := send(hd.GetByID(), "GET", "", nil, "id", id)
+		missing := send(hd.GetByID(), "GET", "", nil, "id", "unknown")
+
+		// assert
+		require.Equal(t, http.StatusGone, got.Code)
+		require.Equal(t, http.StatusNotFound, missing.Code)
+	})
+
+	t.Run("fail 03 - should reject products priced in another currency than the cart", func(t *testing.T) {
+		// arrange
+		hdProducts, hd := newHandlers(t, time.Hour)
+		patch := send(hdProducts.Update(), "PATCH", `{"price":2.5,"currency":"EUR"}`, nil, "id", "2")
+		id := create(t, hd)
+		send(hd.SetLine(), "PUT", `{"quantity":1}`, nil, "id", id, "product_id", "1")
+
+		// act
+		res := send(hd.SetLine(), "PUT", `{"quantity":1}`, nil, "id", id, "product_id", "2")
+
+		// assert
+		require.Equal(t, http.StatusOK, patch.Code)
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, "Invalid cart", res.Body.String())
+	})
+}
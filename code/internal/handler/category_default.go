@@ -0,0 +1,302 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultCategories struct {
+	sv internal.CategoryService
+}
+
+func NewDefaultCategories(sv internal.CategoryService) *DefaultCategories {
+	return &DefaultCategories{
+		sv: sv,
+	}
+}
+
+type CategoryJSON struct {
+	ID       int    `json:"id"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	ParentID int    `json:"parent_id"`
+	// Path is the slugs from the root to the category
+	Path []string `json:"path"`
+}
+
+type BodyCategoryJSON struct {
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	ParentID int    `json:"parent_id"`
+}
+
+type BodyProductCategoriesJSON struct {
+	CategoryIDs []int `json:"category_ids"`
+}
+
+// GetAll returns the categories
+func (h *DefaultCategories) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		categories, err := h.sv.GetAll(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get all categories", "error", err)
+			response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    categoriesJSON(categories, categories),
+		})
+	}
+}
+
+// GetByID returns a category
+func (h *DefaultCategories) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		category, err := h.sv.GetByID(r.Context(), id)
+		if err != nil {
+			categoryError(w, r, "get category", err)
+			return
+		}
+		path, err := h.sv.Path(r.Context(), id)
+		if err != nil {
+			categoryError(w, r, "get category path", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    categoryJSON(category, path),
+		})
+	}
+}
+
+// Create creates a category
+func (h *DefaultCategories) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		var body BodyCategoryJSON
+		if err := request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyCategory}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+
+		//process
+		category := internal.Category{Slug: body.Slug, Name: body.Name, ParentID: body.ParentID}
+		if err := h.sv.Create(r.Context(), &category); err != nil {
+			categoryError(w, r, "create category", err)
+			return
+		}
+		path, err := h.sv.Path(r.Context(), category.ID)
+		if err != nil {
+			categoryError(w, r, "get category path", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    categoryJSON(category, path),
+		})
+	}
+}
+
+// Update replaces the slug, name and parent of a category
+func (h *DefaultCategories) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var body BodyCategoryJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyCategory}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+
+		//process
+		category := internal.Category{ID: id, Slug: body.Slug, Name: body.Name, ParentID: body.ParentID}
+		if err = h.sv.Update(r.Context(), category); err != nil {
+			categoryError(w, r, "update category", err)
+			return
+		}
+		path, err := h.sv.Path(r.Context(), id)
+		if err != nil {
+			categoryError(w, r, "get category path", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    categoryJSON(category, path),
+		})
+	}
+}
+
+// Delete deletes a category without children
+func (h *DefaultCategories) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		if err = h.sv.Delete(r.Context(), id); err != nil {
+			categoryError(w, r, "delete category", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusNoContent, map[string]any{
+			"message": "success",
+			"data":    nil,
+		})
+	}
+}
+
+// ProductCategories returns the categories of a product
+func (h *DefaultCategories) ProductCategories() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		categories, err := h.sv.ProductCategories(r.Context(), id)
+		if err != nil {
+			categoryError(w, r, "get product categories", err)
+			return
+		}
+		all, err := h.sv.GetAll(r.Context())
+		if err != nil {
+			categoryError(w, r, "get all categories", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    categoriesJSON(categories, all),
+		})
+	}
+}
+
+// SetProductCategories replaces the categories of a product
+func (h *DefaultCategories) SetProductCategories() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var body BodyProductCategoriesJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyProductCategories}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+
+		//process
+		categories, err := h.sv.SetProductCategories(r.Context(), id, body.CategoryIDs)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrCategoryNotFound):
+				// the product exists: the missing category is a fault of the body
+				response.Text(w, http.StatusBadRequest, "Invalid category")
+			default:
+				categoryError(w, r, "set product categories", err)
+			}
+			return
+		}
+		all, err := h.sv.GetAll(r.Context())
+		if err != nil {
+			categoryError(w, r, "get all categories", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    categoriesJSON(categories, all),
+		})
+	}
+}
+
+// categoryError writes the response of a failed category operation
+func categoryError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	switch {
+	case errors.Is(err, internal.ErrCategoryNotFound):
+		response.Text(w, http.StatusNotFound, "Category not found")
+	case errors.Is(err, internal.ErrProductNotFound):
+		response.Text(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, internal.ErrCategoryInvalid):
+		response.Text(w, http.StatusBadRequest, "Invalid category")
+	case errors.Is(err, internal.ErrCategorySlugTaken):
+		response.Text(w, http.StatusConflict, "Category slug taken")
+	case errors.Is(err, internal.ErrCategoryCycle):
+		response.Text(w, http.StatusConflict, "Category cannot be its own ancestor")
+	case errors.Is(err, internal.ErrCategoryHasChildren):
+		response.Text(w, http.StatusConflict, "Category has children")
+	default:
+		slog.ErrorContext(r.Context(), msg, "error", err)
+		response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// categoryJSON serializes a category with its path
+func categoryJSON(v internal.Category, path []string) CategoryJSON {
+	return CategoryJSON{
+		ID:       v.ID,
+		Slug:     v.Slug,
+		Name:     v.Name,
+		ParentID: v.ParentID,
+		Path:     path,
+	}
+}
+
+// categoriesJSON serializes categories with their paths, found in all the categories of the tenant
+func categoriesJSON(categories, all []internal.Category) []CategoryJSON {
+	byID := make(map[int]internal.Category, len(all))
+	for _, v := range all {
+		byID[v.ID] = v
+	}
+	data := make([]CategoryJSON, 0, len(categories))
+	for _, v := range categories {
+		var path []string
+		for id := v.ID; id != 0; id = byID[id].ParentID {
+			path = append([]string{byID[id].Slug}, path...)
+		}
+		data = append(data, categoryJSON(v, path))
+	}
+	return data
+}
@@ -0,0 +1,211 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCategories(t *testing.T) {
+	// newHandlers returns the product and category handlers over a catalog with the products 1, 2 and 3
+	newHandlers := func(t *testing.T) (*handler.DefaultProducts, *handler.DefaultCategories) {
+		expiration := time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S1", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 1000, Currency: "USD"}},
+			2: {Id: 2, Name: "Product 2", Quantity: 5, Code_value: "S2", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 2000, Currency: "USD"}},
+			3: {Id: 3, Name: "Product 3", Quantity: 5, Code_value: "S3", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 3000, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 3))
+		svCategories := service.NewCategoryDefault(repository.NewCategoryJSON(t.TempDir()+"/categories.json"), sv)
+		return handler.NewDefaultProducts(sv).WithCategories(svCategories), handler.NewDefaultCategories(svCategories)
+	}
+	// send sends a request to a handler with the url params, decoding the data of the response into out
+	send := func(h http.HandlerFunc, method, target, body string, out any, params ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		chiCtx := chi.NewRouteContext()
+		for i := 0; i < len(params); i += 2 {
+			chiCtx.URLParams.Add(params[i], params[i+1])
+		}
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h(res, req)
+		if out != nil {
+			json.Unmarshal(res.Body.Bytes(), &struct {
+				Data any `json:"data"`
+			}{Data: out})
+		}
+		return res
+	}
+	// ids returns the ids of products
+	ids := func(products []handler.ProductJSON) (ids []int) {
+		for _, p := range products {
+			ids = append(ids, p.Id)
+		}
+		return
+	}
+
+	t.Run("success 01 - should build a tree of categories with their paths", func(t *testing.T) {
+		// arrange
+		_, hd := newHandlers(t)
+
+		// act
+		var wine, red handler.CategoryJSON
+		res := send(hd.Create(), "POST", "/categories", `{"slug":"wine","name":"Wine"}`, &wine)
+		send(hd.Create(), "POST", "/categories", `{"slug":"red-wine","name":"Red wine","parent_id":1}`, &red)
+		var all []handler.CategoryJSON
+		send(hd.GetAll(), "GET", "/categories", "", &all)
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, handler.CategoryJSON{ID: 1, Slug: "wine", Name: "Wine", Path: []string{"wine"}}, wine)
+		require.Equal(t, handler.CategoryJSON{ID: 2, Slug: "red-wine", Name: "Red wine", ParentID: 1, Path: []string{"wine", "red-wine"}}, red)
+		require.Equal(t, []handler.CategoryJSON{wine, red}, all)
+	})
+
+	t.Run("success 02 - should filter the products by category and its descendants", func(t *testing.T) {
+		// arrange
+		hdProducts, hd := newHandlers(t)
+		send(hd.Create(), "POST", "/categories", `{"slug":"wine","name":"Wine"}`, nil)
+		send(hd.Create(), "POST", "/categories", `{"slug":"red-wine","name":"Red wine","parent_id":1}`, nil)
+		send(hd.Create(), "POST", "/categories", `{"slug":"beer","name":"Beer"}`, nil)
+		send(hd.SetProductCategories(), "PUT", "/products/1/categories", `{"category_ids":[1]}`, nil, "id", "1")
+		send(hd.SetProductCategories(), "PUT", "/products/2/categories", `{"category_ids":[2,3,2]}`, nil, "id", "2")
+		send(hd.SetProductCategories(), "PUT", "/products/3/categories", `{"category_ids":[3]}`, nil, "id", "3")
+
+		// act
+		var wine, red, search []handler.ProductJSON
+		res := send(hdProducts.GetAll(), "GET", "/products?category=wine", "", &wine)
+		send(hdProducts.GetAll(), "GET", "/products?category=red-wine", "", &red)
+		send(hdProducts.Search(), "GET", "/products/search?category=beer&priceGt=25", "", &search)
+		var categories []handler.CategoryJSON
+		send(hd.ProductCategories(), "GET", "/products/2/categories", "", &categories, "id", "2")
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		// the assignments move the Last-Modified of the filtered listings
+		require.NotEmpty(t, res.Header().Get("Last-Modified"))
+		require.ElementsMatch(t, []int{1, 2}, ids(wine))
+		require.Equal(t, []int{2}, ids(red))
+		require.Equal(t, []int{3}, ids(search))
+		require.Equal(t, []handler.CategoryJSON{
+			{ID: 2, Slug: "red-wine", Name: "Red wine", ParentID: 1, Path: []string{"wine", "red-wine"}},
+			{ID: 3, Slug: "beer", Name: "Beer", Path: []string{"beer"}},
+		}, categories)
+	})
+
+	t.Run("success 03 - should delete a leaf category, unassigning its products", func(t *testing.T) {
+		// arrange
+		_, hd := newHandlers(t)
+		send(hd.Create(), "POST", "/categories", `{"slug":"wine","name":"Wine"}`, nil)
+		send(hd.Create(), "POST", "/categories", `{"slug":"red-wine","name":"Red wine","parent_id":1}`, nil)
+		send(hd.SetProductCategories(), "PUT", "/products/1/categories", `{"category_ids":[1,2]}`, nil, "id", "1")
+
+		// act
+		res := send(hd.Delete(), "DELETE", "/categories/2", "", nil, "id", "2")
+		var categories []handler.CategoryJSON
+		send(hd.ProductCategories(), "GET", "/products/1/categories", "", &categories, "id", "1")
+		got := send(hd.GetByID(), "GET", "/categories/2", "", nil, "id", "2")
+
+		// assert
+		require.Equal(t, http.StatusNoContent, res.Code)
+		require.Len(t, categories, 1)
+		require.Equal(t, "wine", categories[0].Slug)
+		require.Equal(t, http.StatusNotFound, got.Code)
+	})
+
+	t.Run("success 04 - should not let a product inherit the categories of a deleted one with its id", func(t *testing.T) {
+		// arrange
+		expiration := time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S1", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 1000, Currency: "USD"}},
+		}
+		stCategories := repository.NewCategoryJSON(t.TempDir() + "/categories.json")
+		sv := service.NewProductCategories(service.NewProductDefault(repository.NewProductRepository(db, 1)), stCategories)
+		svCategories := service.NewCategoryDefault(stCategories, sv)
+		hdProducts, hd := handler.NewDefaultProducts(sv).WithCategories(svCategories), handler.NewDefaultCategories(svCategories)
+		send(hd.Create(), "POST", "/categories", `{"slug":"wine","name":"Wine"}`, nil)
+		send(hd.SetProductCategories(), "PUT", "/products/1/categories", `{"category_ids":[1]}`, nil, "id", "1")
+		// the product 2 was deleted while the server was stopped, leaving its categories behind
+		require.NoError(t, stCategories.Assign(context.Background(), "", 2, []int{1}))
+		product := `{"name":"Product 2","quantity":1,"code_value":"S2","is_published":true,"expiration":"01/01/2099","price":10}`
+
+		// act
+		deleted := send(hdProducts.Delete(), "DELETE", "/products/1", "", nil, "id", "1")
+		recreated := send(hdProducts.UpdateOrCreate(), "PUT", "/products/1", strings.Replace(product, "S2", "S1", 1), nil, "id", "1")
+		created := send(hdProducts.Create(), "POST", "/products", product, nil)
+		var wine []handler.ProductJSON
+		send(hdProducts.GetAll(), "GET", "/products?category=wine", "", &wine)
+		var categories []handler.CategoryJSON
+		send(hd.ProductCategories(), "GET", "/products/2/categories", "", &categories, "id", "2")
+
+		// assert
+		require.Equal(t, http.StatusNoContent, deleted.Code)
+		require.Equal(t, http.StatusOK, recreated.Code)
+		require.Equal(t, http.StatusCreated, created.Code)
+		require.Empty(t, wine)
+		require.Empty(t, categories)
+	})
+
+	t.Run("fail 01 - should reject cycles, taken slugs and parents with children", func(t *testing.T) {
+		// arrange
+		_, hd := newHandlers(t)
+		send(hd.Create(), "POST", "/categories", `{"slug":"wine","name":"Wine"}`, nil)
+		send(hd.Create(), "POST", "/categories", `{"slug":"red-wine","name":"Red wine","parent_id":1}`, nil)
+		send(hd.Create(), "POST", "/categories", `{"slug":"rioja","name":"Rioja","parent_id":2}`, nil)
+
+		// act
+		cycle := send(hd.Update(), "PUT", "/categories/1", `{"slug":"wine","name":"Wine","parent_id":3}`, nil, "id", "1")
+		self := send(hd.Update(), "PUT", "/categories/2", `{"slug":"red-wine","name":"Red wine","parent_id":2}`, nil, "id", "2")
+		taken := send(hd.Create(), "POST", "/categories", `{"slug":"wine","name":"Other wine"}`, nil)
+		children := send(hd.Delete(), "DELETE", "/categories/1", "", nil, "id", "1")
+		var moved handler.CategoryJSON
+		move := send(hd.Update(), "PUT", "/categories/3", `{"slug":"rioja","name":"Rioja","parent_id":1}`, &moved, "id", "3")
+
+		// assert
+		require.Equal(t, http.StatusConflict, cycle.Code)
+		require.Equal(t, "Category cannot be its own ancestor", cycle.Body.String())
+		require.Equal(t, http.StatusConflict, self.Code)
+		require.Equal(t, http.StatusConflict, taken.Code)
+		require.Equal(t, "Category slug taken", taken.Body.String())
+		require.Equal(t, http.StatusConflict, children.Code)
+		require.Equal(t, http.StatusOK, move.Code)
+		require.Equal(t, []string{"wine", "rioja"}, moved.Path)
+	})
+
+	t.Run("fail 02 - should reject invalid categories and filters", func(t *testing.T) {
+		// arrange
+		hdProducts, hd := newHandlers(t)
+		send(hd.Create(), "POST", "/categories", `{"slug":"wine","name":"Wine"}`, nil)
+
+		// act
+		slug := send(hd.Create(), "POST", "/categories", `{"slug":"Red Wine","name":"Red wine"}`, nil)
+		parent := send(hd.Create(), "POST", "/categories", `{"slug":"beer","name":"Beer","parent_id":9}`, nil)
+		assign := send(hd.SetProductCategories(), "PUT", "/products/1/categories", `{"category_ids":[9]}`, nil, "id", "1")
+		product := send(hd.SetProductCategories(), "PUT", "/products/9/categories", `{"category_ids":[1]}`, nil, "id", "9")
+		filter := send(hdProducts.GetAll(), "GET", "/products?category=beer", "", nil)
+		price := send(hdProducts.Search(), "GET", "/products/search", "", nil)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, slug.Code)
+		require.Equal(t, http.StatusBadRequest, parent.Code)
+		require.Equal(t, "Invalid category", parent.Body.String())
+		require.Equal(t, http.StatusBadRequest, assign.Code)
+		require.Equal(t, http.StatusNotFound, product.Code)
+		require.Equal(t, "Product not found", product.Body.String())
+		require.Equal(t, http.StatusBadRequest, filter.Code)
+		require.Equal(t, "invalid category", filter.Body.String())
+		require.Equal(t, http.StatusBadRequest, price.Code)
+	})
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal/config"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultDebug struct {
+	cfg config.Config
+	// started is when the server started, to compute the uptime
+	started time.Time
+}
+
+func NewDefaultDebug(cfg config.Config, started time.Time) *DefaultDebug {
+	return &DefaultDebug{
+		cfg:     cfg,
+		started: started,
+	}
+}
+
+type BuildInfoJSON struct {
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path"`
+	Version   string            `json:"version"`
+	Settings  map[string]string `json:"settings"`
+}
+
+// Info returns the build info, uptime, runtime stats and the configuration with its secrets redacted
+func (d *DefaultDebug) Info() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		build := BuildInfoJSON{GoVersion: runtime.Version(), Settings: make(map[string]string)}
+		if info, ok := debug.ReadBuildInfo(); ok {
+			build.Path = info.Main.Path
+			build.Version = info.Main.Version
+			for _, s := range info.Settings {
+				build.Settings[s.Key] = s.Value
+			}
+		}
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data": map[string]any{
+				"build":      build,
+				"started":    d.started.UTC().Format(time.RFC3339),
+				"uptime":     time.Since(d.started).Round(time.Second).String(),
+				"goroutines": runtime.NumGoroutine(),
+				"heap_alloc": mem.HeapAlloc,
+				"config":     d.cfg.Redacted(),
+			},
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+// maxExpiringDays is the longest window the expiring products can be listed for
+const maxExpiringDays = 3650
+
+type DefaultExpiration struct {
+	sv internal.ExpirationService
+}
+
+func NewDefaultExpiration(sv internal.ExpirationService) *DefaultExpiration {
+	return &DefaultExpiration{
+		sv: sv,
+	}
+}
+
+// Expiring returns the products not expired yet that expire within the days given, first expiring first
+func (e *DefaultExpiration) Expiring() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		days, err := strconv.Atoi(r.URL.Query().Get("days"))
+		if err != nil || days < 0 || days > maxExpiringDays {
+			response.Text(w, http.StatusBadRequest, "invalid days")
+			return
+		}
+
+		//process
+		products, err := e.sv.Expiring(r.Context(), time.Duration(days)*24*time.Hour)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "expiring products", "error", err)
+			response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		//response
+		data := make([]ProductJSON, 0, len(products))
+		for _, product := range products {
+			data = append(data, ProductJSON{
+				Id:           product.Id,
+				Name:         product.Name,
+				Quantity:     product.Quantity,
+				Code_value:   product.Code_value,
+				Is_published: product.Is_published,
+				Expiration:   product.Expiration.Format("02/01/2006"),
+				Price:        product.Price.Major(),
+				Currency:     product.Price.Currency,
+				Reserved:     product.Reserved,
+				Available:    product.Available(),
+				Attributes:   attributesJSON(product.Attributes),
+				Variants:     variantsJSON(product.Variants),
+			})
+		}
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    data,
+		})
+	}
+}
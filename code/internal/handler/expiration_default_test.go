@@ -0,0 +1,228 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+// alertsRecorder is an internal.AlertSender keeping the events of the alerts sent
+type alertsRecorder struct {
+	events []string
+}
+
+func (a *alertsRecorder) Send(ctx context.Context, alert internal.Alert) (err error) {
+	a.events = append(a.events, alert.Event)
+	return
+}
+
+// lotsFailing is a repository.LotJSON whose writes fail while fail is set
+type lotsFailing struct {
+	*repository.LotJSON
+	fail bool
+}
+
+func (l *lotsFailing) Replace(ctx context.Context, tenant string, productID int, lots []internal.Lot) (err error) {
+	if l.fail {
+		return errors.New("disk full")
+	}
+	return l.LotJSON.Replace(ctx, tenant, productID, lots)
+}
+
+func TestDefaultExpiration(t *testing.T) {
+	now := time.Now()
+	// fixture holds the services and handlers over a catalog with the products:
+	// 1 expiring in 3 days, 2 in 30 days, 3 expired yesterday and 4 in 2099, all published
+	type fixture struct {
+		hd       *handler.DefaultExpiration
+		products *handler.DefaultProducts
+		sv       *service.ExpirationDefault
+		lots     *repository.LotJSON
+		failing  *lotsFailing
+		alerts   *alertsRecorder
+	}
+	newFixture := func(t *testing.T) fixture {
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Milk", Quantity: 10, Code_value: "M1", Is_published: true, Expiration: now.AddDate(0, 0, 3), Price: internal.Money{Amount: 200, Currency: "USD"}},
+			2: {Id: 2, Name: "Cheese", Quantity: 10, Code_value: "M2", Is_published: true, Expiration: now.AddDate(0, 0, 30), Price: internal.Money{Amount: 800, Currency: "USD"}},
+			3: {Id: 3, Name: "Yogurt", Quantity: 10, Code_value: "M3", Is_published: true, Expiration: now.AddDate(0, 0, -1), Price: internal.Money{Amount: 100, Currency: "USD"}},
+			4: {Id: 4, Name: "Salt", Quantity: 10, Code_value: "M4", Is_published: true, Expiration: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 100, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 4))
+		lots := repository.NewLotJSON(t.TempDir() + "/lots.json")
+		failing := &lotsFailing{LotJSON: lots}
+		st := service.NewStockDefault(repository.NewStockJSONL(t.TempDir()+"/stock.jsonl"), repository.NewReservationMap(), sv).WithLots(failing)
+		products := service.NewProductStock(sv, st)
+		alerts := &alertsRecorder{}
+		svExpiration := service.NewExpirationDefault(products, st, lots, alerts, []string{""}, 7*24*time.Hour)
+		return fixture{
+			hd:       handler.NewDefaultExpiration(svExpiration),
+			products: handler.NewDefaultProducts(products),
+			sv:       svExpiration,
+			lots:     lots,
+			failing:  failing,
+			alerts:   alerts,
+		}
+	}
+	// get sends a get request to a handler with the url param id, decoding the data of the response into out
+	get := func(h http.HandlerFunc, target, id string, out any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h(res, req)
+		if out != nil {
+			json.Unmarshal(res.Body.Bytes(), &struct {
+				Data any `json:"data"`
+			}{Data: out})
+		}
+		return res
+	}
+	// ids returns the ids of products
+	ids := func(products []handler.ProductJSON) (ids []int) {
+		for _, p := range products {
+			ids = append(ids, p.Id)
+		}
+		return
+	}
+
+	t.Run("success 01 - should list the products expiring within the days, first expiring first", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+
+		// act
+		var week, month []handler.ProductJSON
+		res := get(f.hd.Expiring(), "/products/expiring?days=7", "", &week)
+		get(f.hd.Expiring(), "/products/expiring?days=40", "", &month)
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, []int{1}, ids(week))
+		require.Equal(t, []int{1, 2}, ids(month))
+	})
+
+	t.Run("success 02 - should unpublish the expired products and alert once", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+
+		// act
+		n, err := f.sv.Sweep(context.Background())
+		again, errAgain := f.sv.Sweep(context.Background())
+		var product handler.ProductJSON
+		get(f.products.GetByID(), "/products/3", "3", &product)
+
+		// assert
+		require.NoError(t, err)
+		require.NoError(t, errAgain)
+		require.Equal(t, 1, n)
+		require.Equal(t, 0, again)
+		require.False(t, product.Is_published)
+		require.ElementsMatch(t, []string{internal.AlertProductExpiring, internal.AlertProductExpired}, f.alerts.events)
+	})
+
+	t.Run("success 03 - should write off the expired lots of the products", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+		err := f.lots.Replace(context.Background(), "", 4, []internal.Lot{
+			{Code: "L1", Quantity: 3, Expiration: now.AddDate(0, 0, -2)},
+			{Code: "L2", Quantity: 4, Expiration: now.AddDate(0, 0, 20)},
+		})
+		require.NoError(t, err)
+
+		// act
+		n, err := f.sv.Sweep(context.Background())
+		var product handler.ProductJSON
+		get(f.products.GetByID(), "/products/4", "4", &product)
+		lots, errLots := f.lots.Find(context.Background(), "", 4)
+
+		// assert
+		require.NoError(t, err)
+		require.NoError(t, errLots)
+		require.Equal(t, 2, n)
+		require.Equal(t, 7, product.Quantity)
+		require.Len(t, lots, 1)
+		require.Equal(t, "L2", lots[0].Code)
+		require.Contains(t, f.alerts.events, internal.AlertLotExpired)
+	})
+
+	t.Run("success 04 - should write off an expired lot once even if dropping it failed before", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+		err := f.lots.Replace(context.Background(), "", 4, []internal.Lot{
+			{Code: "L1", Quantity: 3, Expiration: now.AddDate(0, 0, -2)},
+		})
+		require.NoError(t, err)
+		f.failing.fail = true
+		_, errFailed := f.sv.Sweep(context.Background())
+		var failed handler.ProductJSON
+		get(f.products.GetByID(), "/products/4", "4", &failed)
+		f.failing.fail = false
+
+		// act
+		_, err = f.sv.Sweep(context.Background())
+		_, errAgain := f.sv.Sweep(context.Background())
+		var product handler.ProductJSON
+		get(f.products.GetByID(), "/products/4", "4", &product)
+
+		// assert
+		require.Error(t, errFailed)
+		require.Equal(t, 10, failed.Quantity)
+		require.NoError(t, err)
+		require.NoError(t, errAgain)
+		require.Equal(t, 7, product.Quantity)
+	})
+
+	t.Run("success 05 - should write off the expired lots of an expired product once it is unpublished", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+		err := f.lots.Replace(context.Background(), "", 3, []internal.Lot{
+			{Code: "L3", Quantity: 4, Expiration: now.AddDate(0, 0, -1)},
+		})
+		require.NoError(t, err)
+
+		// act
+		n, err := f.sv.Sweep(context.Background())
+		again, errAgain := f.sv.Sweep(context.Background())
+		var product handler.ProductJSON
+		get(f.products.GetByID(), "/products/3", "3", &product)
+		lots, errLots := f.lots.Find(context.Background(), "", 3)
+
+		// assert
+		require.NoError(t, err)
+		require.NoError(t, errAgain)
+		require.NoError(t, errLots)
+		require.Equal(t, 2, n)
+		require.Equal(t, 0, again)
+		require.False(t, product.Is_published)
+		require.Equal(t, 6, product.Quantity)
+		require.Empty(t, lots)
+	})
+
+	t.Run("fail 01 - should reject a missing or out of range number of days", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+
+		// act
+		missing := get(f.hd.Expiring(), "/products/expiring", "", nil)
+		negative := get(f.hd.Expiring(), "/products/expiring?days=-1", "", nil)
+		text := get(f.hd.Expiring(), "/products/expiring?days=week", "", nil)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, missing.Code)
+		require.Equal(t, "invalid days", missing.Body.String())
+		require.Equal(t, http.StatusBadRequest, negative.Code)
+		require.Equal(t, http.StatusBadRequest, text.Code)
+	})
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+// HealthCheck verifies that a dependency of the service is usable
+type HealthCheck func(ctx context.Context) (err error)
+
+type DefaultHealth struct {
+	// checks are the readiness checks by name
+	checks map[string]HealthCheck
+}
+
+func NewDefaultHealth(checks map[string]HealthCheck) *DefaultHealth {
+	return &DefaultHealth{
+		checks: checks,
+	}
+}
+
+// Liveness reports that the process is up and serving
+func (h *DefaultHealth) Liveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.JSON(w, http.StatusOK, map[string]any{
+			"status": "ok",
+		})
+	}
+}
+
+// Readiness runs every check and reports 503 Service Unavailable if any of them fails.
+// The probe is not authenticated, so it only tells pass or fail: the failures are logged.
+func (h *DefaultHealth) Readiness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		names := make([]string, 0, len(h.checks))
+		for name := range h.checks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		code, status := http.StatusOK, "ok"
+		for _, name := range names {
+			if err := h.checks[name](r.Context()); err != nil {
+				slog.WarnContext(r.Context(), "readiness check failed", "check", name, "error", err)
+				code, status = http.StatusServiceUnavailable, "fail"
+			}
+		}
+
+		//response
+		response.JSON(w, code, map[string]any{
+			"status": status,
+		})
+	}
+}
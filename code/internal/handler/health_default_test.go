@@ -0,0 +1,57 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultHealth_Readiness(t *testing.T) {
+	t.Run("success 01 - should be ready when the storage is readable and writable", func(t *testing.T) {
+		// arrange
+		file := filepath.Join(t.TempDir(), "products.json")
+		require.NoError(t, os.WriteFile(file, []byte(`[]`), 0644))
+		st := repository.NewStorageProductJSON(file, "02/01/2006")
+		hd := handler.NewDefaultHealth(map[string]handler.HealthCheck{"storage:default": st.Check})
+
+		// act
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		res := httptest.NewRecorder()
+		hd.Readiness()(res, req)
+
+		// assert
+		expectedCode := http.StatusOK
+		expectedBody := `{"status":"ok"}`
+		require.Equal(t, expectedCode, res.Code)
+		require.JSONEq(t, expectedBody, res.Body.String())
+	})
+
+	t.Run("fail 01 - should not be ready when the storage cannot be decoded", func(t *testing.T) {
+		// arrange
+		file := filepath.Join(t.TempDir(), "products.json")
+		require.NoError(t, os.WriteFile(file, []byte(`{not json`), 0644))
+		st := repository.NewStorageProductJSON(file, "02/01/2006")
+		hd := handler.NewDefaultHealth(map[string]handler.HealthCheck{
+			"config":          func(ctx context.Context) error { return nil },
+			"storage:default": st.Check,
+		})
+
+		// act
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		res := httptest.NewRecorder()
+		hd.Readiness()(res, req)
+
+		// assert
+		expectedCode := http.StatusServiceUnavailable
+		require.Equal(t, expectedCode, res.Code)
+		// the checks and their errors, which name the files, are only logged
+		require.JSONEq(t, `{"status":"fail"}`, res.Body.String())
+	})
+}
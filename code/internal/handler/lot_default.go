@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultLots struct {
+	sv internal.LotService
+}
+
+func NewDefaultLots(sv internal.LotService) *DefaultLots {
+	return &DefaultLots{
+		sv: sv,
+	}
+}
+
+type LotJSON struct {
+	Code       string `json:"code"`
+	Quantity   int    `json:"quantity"`
+	Expiration string `json:"expiration"`
+	Received   string `json:"received"`
+}
+
+type BodyLotJSON struct {
+	Code       string `json:"code"`
+	Quantity   int    `json:"quantity"`
+	Expiration string `json:"expiration"`
+}
+
+// GetAll returns the lots of a product with units left, first expiring first
+func (l *DefaultLots) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		lots, err := l.sv.GetAll(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductNotFound):
+				response.Text(w, http.StatusNotFound, "Product not found")
+			default:
+				slog.ErrorContext(r.Context(), "get lots", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		data := make([]LotJSON, 0, len(lots))
+		for _, v := range lots {
+			data = append(data, lotJSON(v))
+		}
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    data,
+		})
+	}
+}
+
+// Receive receives units of a product in a lot, recording them as a receipt
+func (l *DefaultLots) Receive() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var body BodyLotJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyLot}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+		expiration, err := time.Parse("02/01/2006", body.Expiration)
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid expiration")
+			return
+		}
+
+		//process
+		lot, err := l.sv.Receive(r.Context(), id, internal.Lot{
+			Code:       body.Code,
+			Quantity:   body.Quantity,
+			Expiration: expiration,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductNotFound):
+				response.Text(w, http.StatusNotFound, "Product not found")
+			case errors.Is(err, internal.ErrLotInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid lot")
+			default:
+				slog.ErrorContext(r.Context(), "receive lot", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    lotJSON(lot),
+		})
+	}
+}
+
+// lotJSON serializes a lot
+func lotJSON(v internal.Lot) LotJSON {
+	return LotJSON{
+		Code:       v.Code,
+		Quantity:   v.Quantity,
+		Expiration: v.Expiration.Format("02/01/2006"),
+		Received:   v.Received.Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,120 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultLots(t *testing.T) {
+	// newHandlers returns the stock and lot handlers over a catalog with the product 1,
+	// which has 10 units not in any lot that expire in 2099
+	newHandlers := func(t *testing.T) (*handler.DefaultStock, *handler.DefaultLots) {
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Milk", Quantity: 10, Code_value: "M100", Is_published: true, Expiration: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 200, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 1))
+		lots := repository.NewLotJSON(t.TempDir() + "/lots.json")
+		st := service.NewStockDefault(repository.NewStockJSONL(t.TempDir()+"/stock.jsonl"), repository.NewReservationMap(), sv).WithLots(lots)
+		return handler.NewDefaultStock(st), handler.NewDefaultLots(service.NewLotDefault(lots, st))
+	}
+	// send sends a request to a handler for the product id, decoding the data of the response into out
+	send := func(h http.HandlerFunc, method, id, body string, out any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/products/"+id+"/lots", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h(res, req)
+		if out != nil {
+			json.Unmarshal(res.Body.Bytes(), &struct {
+				Data any `json:"data"`
+			}{Data: out})
+		}
+		return res
+	}
+	// in returns the date days from now, as the bodies take it
+	in := func(days int) string {
+		return time.Now().AddDate(0, 0, days).Format("02/01/2006")
+	}
+
+	t.Run("success 01 - should take the units of the lots that expire first", func(t *testing.T) {
+		// arrange
+		hdStock, hd := newHandlers(t)
+		send(hd.Receive(), "POST", "1", `{"code":"L2","quantity":8,"expiration":"`+in(60)+`"}`, nil)
+		send(hd.Receive(), "POST", "1", `{"code":"L1","quantity":5,"expiration":"`+in(30)+`"}`, nil)
+
+		// act
+		var before, after []handler.LotJSON
+		send(hd.GetAll(), "GET", "1", "", &before)
+		res := send(hdStock.Move(), "POST", "1", `{"type":"sale","quantity":7,"reason":"order 1"}`, nil)
+		send(hd.GetAll(), "GET", "1", "", &after)
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Len(t, before, 2)
+		require.Equal(t, "L1", before[0].Code)
+		require.Equal(t, "L2", before[1].Code)
+		require.Len(t, after, 1)
+		require.Equal(t, "L2", after[0].Code)
+		require.Equal(t, 6, after[0].Quantity)
+		require.Equal(t, in(60), after[0].Expiration)
+	})
+
+	t.Run("success 02 - should add the units received again in a lot and record them as receipts", func(t *testing.T) {
+		// arrange
+		hdStock, hd := newHandlers(t)
+		send(hd.Receive(), "POST", "1", `{"code":"L1","quantity":5,"expiration":"`+in(30)+`"}`, nil)
+
+		// act
+		var lot handler.LotJSON
+		res := send(hd.Receive(), "POST", "1", `{"code":"L1","quantity":3,"expiration":"`+in(30)+`"}`, &lot)
+		var movements []handler.StockMovementJSON
+		send(hdStock.History(), "GET", "1", "", &movements)
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, "L1", lot.Code)
+		require.Equal(t, 8, lot.Quantity)
+		require.Len(t, movements, 3)
+		require.Equal(t, "receipt", movements[2].Type)
+		require.Equal(t, "lot L1", movements[2].Reason)
+		require.Equal(t, 18, movements[2].Balance)
+	})
+
+	t.Run("fail 01 - should reject invalid lots and unknown products", func(t *testing.T) {
+		// arrange
+		_, hd := newHandlers(t)
+		send(hd.Receive(), "POST", "1", `{"code":"L1","quantity":5,"expiration":"`+in(30)+`"}`, nil)
+
+		// act
+		other := send(hd.Receive(), "POST", "1", `{"code":"L1","quantity":5,"expiration":"`+in(40)+`"}`, nil)
+		past := send(hd.Receive(), "POST", "1", `{"code":"L2","quantity":5,"expiration":"`+in(-1)+`"}`, nil)
+		empty := send(hd.Receive(), "POST", "1", `{"code":"L2","quantity":0,"expiration":"`+in(30)+`"}`, nil)
+		date := send(hd.Receive(), "POST", "1", `{"code":"L2","quantity":5,"expiration":"2030-01-01"}`, nil)
+		product := send(hd.Receive(), "POST", "9", `{"code":"L2","quantity":5,"expiration":"`+in(30)+`"}`, nil)
+		list := send(hd.GetAll(), "GET", "9", "", nil)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, other.Code)
+		require.Equal(t, "Invalid lot", other.Body.String())
+		require.Equal(t, http.StatusBadRequest, past.Code)
+		require.Equal(t, "Invalid lot", past.Body.String())
+		require.Equal(t, http.StatusBadRequest, empty.Code)
+		require.Equal(t, http.StatusBadRequest, date.Code)
+		require.Equal(t, http.StatusNotFound, product.Code)
+		require.Equal(t, http.StatusNotFound, list.Code)
+	})
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/rhinosc/web-market/code/platform/web/request"
+)
+
+// openapi is the OpenAPI 3 description of every route of the service.
+// It must be updated with the routes: the application tests fail on an undocumented route.
+//
+//go:embed openapi.json
+var openapi []byte
+
+// the request bodies are validated against the schemas of the api description
+var (
+	schemaBodyProduct  = mustSchema("#/components/schemas/BodyProductJSON")
+	schemaPatchProduct = mustSchema("#/components/schemas/ProductPatch")
+
+	schemaBodyStockMovement = mustSchema("#/components/schemas/BodyStockMovementJSON")
+	schemaBodyReservation   = mustSchema("#/components/schemas/BodyReservationJSON")
+
+	schemaBodyOrder      = mustSchema("#/components/schemas/BodyOrderJSON")
+	schemaBodyOrderState = mustSchema("#/components/schemas/BodyOrderStateJSON")
+	schemaBodyCartLine   = mustSchema("#/components/schemas/BodyCartLineJSON")
+
+	schemaBodyCategory          = mustSchema("#/components/schemas/BodyCategoryJSON")
+	schemaBodyProductCategories = mustSchema("#/components/schemas/BodyProductCategoriesJSON")
+
+	schemaBodyLot           = mustSchema("#/components/schemas/BodyLotJSON")
+	schemaBodyPriceSchedule = mustSchema("#/components/schemas/BodyPriceScheduleJSON")
+	schemaBodyPromotion     = mustSchema("#/components/schemas/BodyPromotionJSON")
+)
+
+// mustSchema compiles a schema of the api description, which is embedded so it can only fail on a broken build
+func mustSchema(ref string) *request.Schema {
+	s, err := request.NewSchema(openapi, ref)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+type DefaultOpenAPI struct {
+	spec []byte
+}
+
+func NewDefaultOpenAPI() *DefaultOpenAPI {
+	return &DefaultOpenAPI{
+		spec: openapi,
+	}
+}
+
+// Spec returns the OpenAPI document of the service
+func (o *DefaultOpenAPI) Spec() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(o.spec)
+	}
+}
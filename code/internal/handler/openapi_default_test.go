@@ -0,0 +1,90 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonFields returns the json names of the fields of a struct
+func jsonFields(v any) (names []string) {
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func TestDefaultOpenAPI_Spec(t *testing.T) {
+	t.Run("success 01 - should describe the json models of the handlers", func(t *testing.T) {
+		// arrange
+		hd := handler.NewDefaultOpenAPI()
+		req := httptest.NewRequest("GET", "/openapi.json", nil)
+		res := httptest.NewRecorder()
+
+		// act
+		hd.Spec()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "application/json", res.Header().Get("Content-Type"))
+
+		var spec struct {
+			OpenAPI    string `json:"openapi"`
+			Components struct {
+				Schemas map[string]struct {
+					Properties map[string]any `json:"properties"`
+				} `json:"schemas"`
+			} `json:"components"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&spec))
+		require.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+
+		models := map[string]any{
+			"ProductJSON":               handler.ProductJSON{},
+			"BodyProductJSON":           handler.BodyProductJSON{},
+			"StockMovementJSON":         handler.StockMovementJSON{},
+			"BodyStockMovementJSON":     handler.BodyStockMovementJSON{},
+			"LotJSON":                   handler.LotJSON{},
+			"BodyLotJSON":               handler.BodyLotJSON{},
+			"PriceChangeJSON":           handler.PriceChangeJSON{},
+			"PriceScheduleJSON":         handler.PriceScheduleJSON{},
+			"PriceTimelineJSON":         handler.PriceTimelineJSON{},
+			"BodyPriceScheduleJSON":     handler.BodyPriceScheduleJSON{},
+			"ReservationJSON":           handler.ReservationJSON{},
+			"BodyReservationJSON":       handler.BodyReservationJSON{},
+			"OrderJSON":                 handler.OrderJSON{},
+			"OrderLineJSON":             handler.OrderLineJSON{},
+			"BodyOrderJSON":             handler.BodyOrderJSON{},
+			"BodyOrderLineJSON":         handler.BodyOrderLineJSON{},
+			"BodyOrderStateJSON":        handler.BodyOrderStateJSON{},
+			"CartJSON":                  handler.CartJSON{},
+			"CartLineJSON":              handler.CartLineJSON{},
+			"BodyCartLineJSON":          handler.BodyCartLineJSON{},
+			"CategoryJSON":              handler.CategoryJSON{},
+			"BodyCategoryJSON":          handler.BodyCategoryJSON{},
+			"BodyProductCategoriesJSON": handler.BodyProductCategoriesJSON{},
+			"AuditEntryJSON":            handler.AuditEntryJSON{},
+		}
+		for name, model := range models {
+			schema, ok := spec.Components.Schemas[name]
+			require.True(t, ok, "schema %s is missing", name)
+
+			var properties []string
+			for p := range schema.Properties {
+				properties = append(properties, p)
+			}
+			sort.Strings(properties)
+			require.Equal(t, jsonFields(model), properties, "schema %s", name)
+		}
+	})
+}
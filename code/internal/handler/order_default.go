@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultOrders struct {
+	sv internal.OrderService
+}
+
+func NewDefaultOrders(sv internal.OrderService) *DefaultOrders {
+	return &DefaultOrders{
+		sv: sv,
+	}
+}
+
+type OrderLineJSON struct {
+	ProductID int     `json:"product_id"`
+	Name      string  `json:"name"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	// Subtotal is the price charged for the units, once the promotions apply;
+	// Promotions are the ids of the promotions that lowered it, in the order they applied
+	Subtotal   float64 `json:"subtotal"`
+	Promotions []int   `json:"promotions,omitempty"`
+}
+
+type OrderJSON struct {
+	ID        int             `json:"id"`
+	State     string          `json:"state"`
+	Principal string          `json:"principal"`
+	Lines     []OrderLineJSON `json:"lines"`
+	Total     float64         `json:"total"`
+	// Currency is the currency of the prices of the lines and of the total
+	Currency string `json:"currency"`
+	Created  string `json:"created"`
+	Updated  string `json:"updated"`
+}
+
+type BodyOrderLineJSON struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+type BodyOrderJSON struct {
+	Lines []BodyOrderLineJSON `json:"lines"`
+}
+
+type BodyOrderStateJSON struct {
+	State string `json:"state"`
+}
+
+// GetAll returns the orders
+func (h *DefaultOrders) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		orders, err := h.sv.GetAll(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get all orders", "error", err)
+			response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		//response
+		data := make([]OrderJSON, 0, len(orders))
+		for _, v := range orders {
+			data = append(data, orderJSON(v))
+		}
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    data,
+		})
+	}
+}
+
+// GetByID returns an order
+func (h *DefaultOrders) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		order, err := h.sv.GetByID(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrOrderNotFound):
+				response.Text(w, http.StatusNotFound, "Order not found")
+			default:
+				slog.ErrorContext(r.Context(), "get order", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    orderJSON(order),
+		})
+	}
+}
+
+// Create places an order, taking its units from the stock
+func (h *DefaultOrders) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		var body BodyOrderJSON
+		if err := request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyOrder}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+
+		//process
+		lines := make([]internal.OrderLine, 0, len(body.Lines))
+		for _, l := range body.Lines {
+			lines = append(lines, internal.OrderLine{ProductID: l.ProductID, Quantity: l.Quantity})
+		}
+		order, err := h.sv.Create(r.Context(), lines)
+		if err != nil {
+			orderError(w, r, "create order", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    orderJSON(order),
+		})
+	}
+}
+
+// Update moves an order to another state
+func (h *DefaultOrders) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var body BodyOrderStateJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyOrderState}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+
+		//process
+		order, err := h.sv.Transition(r.Context(), id, body.State)
+		if err != nil {
+			orderError(w, r, "update order", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    orderJSON(order),
+		})
+	}
+}
+
+// orderError writes the response of a failed order write
+func orderError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	switch {
+	case errors.Is(err, internal.ErrOrderNotFound):
+		response.Text(w, http.StatusNotFound, "Order not found")
+	case errors.Is(err, internal.ErrOrderInvalid):
+		response.Text(w, http.StatusBadRequest, "Invalid order")
+	case errors.Is(err, internal.ErrOrderTransition):
+		response.Text(w, http.StatusConflict, "Invalid order state transition")
+	case errors.Is(err, internal.ErrStockInsufficient):
+		response.Text(w, http.StatusConflict, "Insufficient stock")
+	default:
+		slog.ErrorContext(r.Context(), msg, "error", err)
+		response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// orderJSON serializes an order
+func orderJSON(v internal.Order) OrderJSON {
+	data := OrderJSON{
+		ID:        v.ID,
+		State:     v.State,
+		Principal: v.Principal,
+		Lines:     make([]OrderLineJSON, 0, len(v.Lines)),
+		Total:     v.Total().Major(),
+		Currency:  v.Total().Currency,
+		Created:   v.Created.Format(time.RFC3339),
+		Updated:   v.Updated.Format(time.RFC3339),
+	}
+	for _, l := range v.Lines {
+		data.Lines = append(data.Lines, OrderLineJSON{
+			ProductID:  l.ProductID,
+			Name:       l.Name,
+			Quantity:   l.Quantity,
+			UnitPrice:  l.UnitPrice.Major(),
+			Subtotal:   l.Subtotal().Major(),
+			Promotions: l.Promotions,
+		})
+	}
+	return data
+}
@@ -0,0 +1,149 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultOrders(t *testing.T) {
+	// newHandlers returns the product and order handlers over a catalog with the products 1 and 2, published,
+	// and the product 3, not published
+	newHandlers := func(t *testing.T) (*handler.DefaultProducts, *handler.DefaultOrders) {
+		expiration := time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S1", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 1000, Currency: "USD"}},
+			2: {Id: 2, Name: "Product 2", Quantity: 5, Code_value: "S2", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 250, Currency: "USD"}},
+			3: {Id: 3, Name: "Product 3", Quantity: 5, Code_value: "S3", Is_published: false, Expiration: expiration, Price: internal.Money{Amount: 100, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 3))
+		st := service.NewStockDefault(repository.NewStockJSONL(t.TempDir()+"/stock.jsonl"), repository.NewReservationMap(), sv)
+		svOrders := service.NewOrderDefault(repository.NewOrderJSON(t.TempDir()+"/orders.json"), st)
+		return handler.NewDefaultProducts(service.NewProductStock(sv, st)), handler.NewDefaultOrders(svOrders)
+	}
+	// withID sets the chi url param id
+	withID := func(req *http.Request, id string) *http.Request {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+	// send sends a json body to a handler and decodes the order of the response
+	send := func(h http.HandlerFunc, method, id, body string) (res *httptest.ResponseRecorder, order handler.OrderJSON) {
+		req := httptest.NewRequest(method, "/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res = httptest.NewRecorder()
+		h(res, withID(req, id))
+		var data struct {
+			Data handler.OrderJSON `json:"data"`
+		}
+		json.Unmarshal(res.Body.Bytes(), &data)
+		return res, data.Data
+	}
+	// quantity returns the quantity of a product
+	quantity := func(hd *handler.DefaultProducts, id string) int {
+		res := httptest.NewRecorder()
+		hd.GetByID()(res, withID(httptest.NewRequest("GET", "/products/"+id, nil), id))
+		var data struct {
+			Data handler.ProductJSON `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&data)
+		return data.Data.Quantity
+	}
+
+	t.Run("success 01 - should place an order at the current prices and take its units", func(t *testing.T) {
+		// arrange
+		hdProducts, hdOrders := newHandlers(t)
+
+		// act
+		res, order := send(hdOrders.Create(), "POST", "", `{"lines":[{"product_id":1,"quantity":2},{"product_id":2,"quantity":4},{"product_id":1,"quantity":1}]}`)
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, 1, order.ID)
+		require.Equal(t, internal.OrderStatePending, order.State)
+		require.Equal(t, []handler.OrderLineJSON{
+			{ProductID: 1, Name: "Product 1", Quantity: 3, UnitPrice: 10, Subtotal: 30},
+			{ProductID: 2, Name: "Product 2", Quantity: 4, UnitPrice: 2.5, Subtotal: 10},
+		}, order.Lines)
+		require.Equal(t, 40.0, order.Total)
+		require.Equal(t, 7, quantity(hdProducts, "1"))
+		require.Equal(t, 1, quantity(hdProducts, "2"))
+	})
+
+	t.Run("success 02 - should move an order through its states and restock it when cancelled", func(t *testing.T) {
+		// arrange
+		hdProducts, hdOrders := newHandlers(t)
+		send(hdOrders.Create(), "POST", "", `{"lines":[{"product_id":1,"quantity":2}]}`)
+		send(hdOrders.Create(), "POST", "", `{"lines":[{"product_id":1,"quantity":3}]}`)
+
+		// act
+		paid, order1 := send(hdOrders.Update(), "PATCH", "1", `{"state":"paid"}`)
+		shipped, _ := send(hdOrders.Update(), "PATCH", "1", `{"state":"shipped"}`)
+		cancelled, order2 := send(hdOrders.Update(), "PATCH", "2", `{"state":"cancelled"}`)
+		res := httptest.NewRecorder()
+		hdOrders.GetAll()(res, httptest.NewRequest("GET", "/orders", nil))
+		var list struct {
+			Data []handler.OrderJSON `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&list)
+
+		// assert
+		require.Equal(t, http.StatusOK, paid.Code)
+		require.Equal(t, internal.OrderStatePaid, order1.State)
+		require.Equal(t, http.StatusOK, shipped.Code)
+		require.Equal(t, http.StatusOK, cancelled.Code)
+		require.Equal(t, internal.OrderStateCancelled, order2.State)
+		require.Equal(t, 8, quantity(hdProducts, "1"))
+		require.Len(t, list.Data, 2)
+		require.Equal(t, internal.OrderStateShipped, list.Data[0].State)
+	})
+
+	t.Run("fail 01 - should reject an order that cannot be sold", func(t *testing.T) {
+		// arrange
+		hdProducts, hdOrders := newHandlers(t)
+
+		// act
+		empty, _ := send(hdOrders.Create(), "POST", "", `{"lines":[]}`)
+		unpublished, _ := send(hdOrders.Create(), "POST", "", `{"lines":[{"product_id":3,"quantity":1}]}`)
+		missing, _ := send(hdOrders.Create(), "POST", "", `{"lines":[{"product_id":9,"quantity":1}]}`)
+		insufficient, _ := send(hdOrders.Create(), "POST", "", `{"lines":[{"product_id":1,"quantity":1},{"product_id":2,"quantity":6}]}`)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, empty.Code)
+		require.Equal(t, http.StatusBadRequest, unpublished.Code)
+		require.Equal(t, "Invalid order", unpublished.Body.String())
+		require.Equal(t, http.StatusBadRequest, missing.Code)
+		require.Equal(t, http.StatusConflict, insufficient.Code)
+		require.Equal(t, "Insufficient stock", insufficient.Body.String())
+		// no unit of the other lines is taken
+		require.Equal(t, 10, quantity(hdProducts, "1"))
+	})
+
+	t.Run("fail 02 - should reject a transition the state does not allow", func(t *testing.T) {
+		// arrange
+		_, hdOrders := newHandlers(t)
+		send(hdOrders.Create(), "POST", "", `{"lines":[{"product_id":1,"quantity":2}]}`)
+
+		// act
+		shipped, _ := send(hdOrders.Update(), "PATCH", "1", `{"state":"shipped"}`)
+		unknown, _ := send(hdOrders.Update(), "PATCH", "1", `{"state":"lost"}`)
+		missing, _ := send(hdOrders.Update(), "PATCH", "2", `{"state":"paid"}`)
+
+		// assert
+		require.Equal(t, http.StatusConflict, shipped.Code)
+		require.Equal(t, "Invalid order state transition", shipped.Body.String())
+		require.Equal(t, http.StatusBadRequest, unknown.Code)
+		require.Equal(t, http.StatusNotFound, missing.Code)
+	})
+}
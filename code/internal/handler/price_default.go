@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultPrices struct {
+	sv internal.PriceService
+}
+
+func NewDefaultPrices(sv internal.PriceService) *DefaultPrices {
+	return &DefaultPrices{
+		sv: sv,
+	}
+}
+
+type PriceChangeJSON struct {
+	Time string  `json:"time"`
+	Old  float64 `json:"old_price"`
+	New  float64 `json:"new_price"`
+	// Currency is the currency of the new price, as well as of the old one unless OldCurrency says otherwise
+	Currency    string `json:"currency"`
+	OldCurrency string `json:"old_currency,omitempty"`
+	Principal   string `json:"principal"`
+	RequestID   string `json:"request_id"`
+	ScheduleID  string `json:"schedule_id,omitempty"`
+}
+
+type PriceScheduleJSON struct {
+	ID        string  `json:"id"`
+	Price     float64 `json:"price"`
+	Currency  string  `json:"currency"`
+	Effective string  `json:"effective"`
+	Principal string  `json:"principal"`
+	Created   string  `json:"created"`
+}
+
+type PriceTimelineJSON struct {
+	History   []PriceChangeJSON   `json:"history"`
+	Scheduled []PriceScheduleJSON `json:"scheduled"`
+}
+
+type BodyPriceScheduleJSON struct {
+	Price     float64 `json:"price"`
+	Effective string  `json:"effective"`
+}
+
+// Timeline returns the price changes of a product, oldest first, followed by its pending scheduled changes
+func (p *DefaultPrices) Timeline() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		changes, err := p.sv.History(r.Context(), id)
+		var schedules []internal.PriceSchedule
+		if err == nil {
+			schedules, err = p.sv.Scheduled(r.Context(), id)
+		}
+		if err != nil {
+			priceError(w, r, "price timeline", err)
+			return
+		}
+
+		//response
+		data := PriceTimelineJSON{
+			History:   make([]PriceChangeJSON, 0, len(changes)),
+			Scheduled: make([]PriceScheduleJSON, 0, len(schedules)),
+		}
+		for _, v := range changes {
+			change := PriceChangeJSON{
+				Time:       v.Time.Format(time.RFC3339Nano),
+				Old:        v.Old.Major(),
+				New:        v.New.Major(),
+				Currency:   v.New.Currency,
+				Principal:  v.Principal,
+				RequestID:  v.RequestID,
+				ScheduleID: v.ScheduleID,
+			}
+			if v.Old.Currency != v.New.Currency {
+				change.OldCurrency = v.Old.Currency
+			}
+			data.History = append(data.History, change)
+		}
+		for _, v := range schedules {
+			data.Scheduled = append(data.Scheduled, priceScheduleJSON(v))
+		}
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    data,
+		})
+	}
+}
+
+// Schedule schedules a change of the price of a product, applied once effective
+func (p *DefaultPrices) Schedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var body BodyPriceScheduleJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyPriceSchedule}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+		effective, err := time.Parse(time.RFC3339, body.Effective)
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid effective")
+			return
+		}
+
+		//process
+		schedule, err := p.sv.Schedule(r.Context(), id, body.Price, effective)
+		if err != nil {
+			priceError(w, r, "schedule price", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    priceScheduleJSON(schedule),
+		})
+	}
+}
+
+// Cancel cancels a pending change of the price of a product
+func (p *DefaultPrices) Cancel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		if err = p.sv.Cancel(r.Context(), id, chi.URLParam(r, "scheduleID")); err != nil {
+			priceError(w, r, "cancel price schedule", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusNoContent, map[string]any{
+			"message": "success",
+			"data":    nil,
+		})
+	}
+}
+
+// priceError writes the response of a failed price operation
+func priceError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	switch {
+	case errors.Is(err, internal.ErrProductNotFound):
+		response.Text(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, internal.ErrPriceScheduleNotFound):
+		response.Text(w, http.StatusNotFound, "Price schedule not found")
+	case errors.Is(err, internal.ErrPriceScheduleInvalid):
+		response.Text(w, http.StatusBadRequest, "Invalid price schedule")
+	default:
+		slog.ErrorContext(r.Context(), msg, "error", err)
+		response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// priceScheduleJSON serializes a scheduled price change
+func priceScheduleJSON(v internal.PriceSchedule) PriceScheduleJSON {
+	return PriceScheduleJSON{
+		ID:        v.ID,
+		Price:     v.Price.Major(),
+		Currency:  v.Price.Currency,
+		Effective: v.Effective.Format(time.RFC3339),
+		Principal: v.Principal,
+		Created:   v.Created.Format(time.RFC3339Nano),
+	}
+}
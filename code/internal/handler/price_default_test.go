@@ -0,0 +1,189 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPrices(t *testing.T) {
+	// fixture holds the handlers and services over a catalog with the product 1, priced 10
+	type fixture struct {
+		products  *handler.DefaultProducts
+		hd        *handler.DefaultPrices
+		sv        *service.PriceDefault
+		schedules *repository.PriceScheduleJSON
+	}
+	newFixture := func(t *testing.T) fixture {
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Wine", Quantity: 10, Code_value: "W100", Is_published: true, Expiration: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 1000, Currency: "USD"}},
+		}
+		history := repository.NewPriceJSONL(t.TempDir() + "/prices.jsonl")
+		schedules := repository.NewPriceScheduleJSON(t.TempDir() + "/price_schedules.json")
+		sv := service.NewProductPrice(service.NewProductDefault(repository.NewProductRepository(db, 1)), history)
+		svPrices := service.NewPriceDefault(schedules, history, sv, []string{""})
+		return fixture{
+			products:  handler.NewDefaultProducts(sv),
+			hd:        handler.NewDefaultPrices(svPrices),
+			sv:        svPrices,
+			schedules: schedules,
+		}
+	}
+	// send sends a request by alice to a handler with the url params, decoding the data of the response into out
+	send := func(h http.HandlerFunc, method, body string, out any, params ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/products", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		chiCtx := chi.NewRouteContext()
+		for i := 0; i < len(params); i += 2 {
+			chiCtx.URLParams.Add(params[i], params[i+1])
+		}
+		ctx := auth.ContextWithPrincipal(req.Context(), "alice")
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h(res, req)
+		if out != nil {
+			json.Unmarshal(res.Body.Bytes(), &struct {
+				Data any `json:"data"`
+			}{Data: out})
+		}
+		return res
+	}
+
+	t.Run("success 01 - should record who changed the price, and from what to what", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+		send(f.products.Create(), "POST", `{"name":"Beer","quantity":5,"code_value":"B100","is_published":true,"expiration":"01/01/2099","price":20}`, nil)
+		send(f.products.Update(), "PATCH", `{"price":12}`, nil, "id", "1")
+		send(f.products.Update(), "PATCH", `{"name":"Red wine"}`, nil, "id", "1")
+		send(f.products.UpdateOrCreate(), "PUT", `{"name":"Red wine","quantity":10,"code_value":"W100","is_published":true,"expiration":"01/01/2099","price":15}`, nil, "id", "1")
+
+		// act
+		var timeline, created handler.PriceTimelineJSON
+		res := send(f.hd.Timeline(), "GET", "", &timeline, "id", "1")
+		send(f.hd.Timeline(), "GET", "", &created, "id", "2")
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Len(t, timeline.History, 2)
+		require.Equal(t, 10.0, timeline.History[0].Old)
+		require.Equal(t, 12.0, timeline.History[0].New)
+		require.Equal(t, "alice", timeline.History[0].Principal)
+		require.Equal(t, 12.0, timeline.History[1].Old)
+		require.Equal(t, 15.0, timeline.History[1].New)
+		require.Empty(t, timeline.Scheduled)
+		require.Len(t, created.History, 1)
+		require.Equal(t, 0.0, created.History[0].Old)
+		require.Equal(t, 20.0, created.History[0].New)
+	})
+
+	t.Run("success 02 - should schedule a price change and cancel it", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+		effective := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+		// act
+		var schedule handler.PriceScheduleJSON
+		res := send(f.hd.Schedule(), "POST", `{"price":8,"effective":"`+effective.Format(time.RFC3339)+`"}`, &schedule, "id", "1")
+		var pending, cancelled handler.PriceTimelineJSON
+		send(f.hd.Timeline(), "GET", "", &pending, "id", "1")
+		resCancel := send(f.hd.Cancel(), "DELETE", "", nil, "id", "1", "scheduleID", schedule.ID)
+		send(f.hd.Timeline(), "GET", "", &cancelled, "id", "1")
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, 8.0, schedule.Price)
+		require.Equal(t, effective.Format(time.RFC3339), schedule.Effective)
+		require.Equal(t, "alice", schedule.Principal)
+		require.Equal(t, []handler.PriceScheduleJSON{schedule}, pending.Scheduled)
+		require.Equal(t, http.StatusNoContent, resCancel.Code)
+		require.Empty(t, cancelled.Scheduled)
+	})
+
+	t.Run("success 03 - should apply the effective scheduled changes on behalf of who scheduled them", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+		now := time.Now()
+		err := f.schedules.Create(context.Background(), internal.PriceSchedule{ID: "s1", Principal: "bob", ProductID: 1, Price: internal.Money{Amount: 900, Currency: "USD"}, Effective: now.Add(-time.Minute), Created: now.Add(-time.Hour)})
+		require.NoError(t, err)
+		err = f.schedules.Create(context.Background(), internal.PriceSchedule{ID: "s2", Principal: "bob", ProductID: 1, Price: internal.Money{Amount: 700, Currency: "USD"}, Effective: now.Add(time.Hour), Created: now.Add(-time.Hour)})
+		require.NoError(t, err)
+
+		// act
+		n, err := f.sv.Apply(context.Background())
+		var product handler.ProductJSON
+		send(f.products.GetByID(), "GET", "", &product, "id", "1")
+		var timeline handler.PriceTimelineJSON
+		send(f.hd.Timeline(), "GET", "", &timeline, "id", "1")
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.Equal(t, 9.0, product.Price)
+		require.Len(t, timeline.History, 1)
+		require.Equal(t, "bob", timeline.History[0].Principal)
+		require.Equal(t, "s1", timeline.History[0].ScheduleID)
+		require.Len(t, timeline.Scheduled, 1)
+		require.Equal(t, "s2", timeline.Scheduled[0].ID)
+	})
+
+	t.Run("success 04 - should drop a scheduled change once the product changed currency", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+		now := time.Now()
+		err := f.schedules.Create(context.Background(), internal.PriceSchedule{ID: "s1", Principal: "bob", ProductID: 1, Price: internal.Money{Amount: 900, Currency: "USD"}, Effective: now.Add(-time.Minute), Created: now.Add(-time.Hour)})
+		require.NoError(t, err)
+		patch := send(f.products.Update(), "PATCH", `{"price":1500,"currency":"JPY"}`, nil, "id", "1")
+
+		// act
+		n, err := f.sv.Apply(context.Background())
+		var product handler.ProductJSON
+		send(f.products.GetByID(), "GET", "", &product, "id", "1")
+		var timeline handler.PriceTimelineJSON
+		send(f.hd.Timeline(), "GET", "", &timeline, "id", "1")
+
+		// assert
+		require.Equal(t, http.StatusOK, patch.Code)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.Equal(t, 1500.0, product.Price)
+		require.Equal(t, "JPY", product.Currency)
+		require.Empty(t, timeline.Scheduled)
+	})
+
+	t.Run("fail 01 - should reject invalid schedules, unknown products and unknown schedules", func(t *testing.T) {
+		// arrange
+		f := newFixture(t)
+		tomorrow := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+
+		// act
+		past := send(f.hd.Schedule(), "POST", `{"price":8,"effective":"2001-01-01T00:00:00Z"}`, nil, "id", "1")
+		format := send(f.hd.Schedule(), "POST", `{"price":8,"effective":"01/01/2099"}`, nil, "id", "1")
+		negative := send(f.hd.Schedule(), "POST", `{"price":-1,"effective":"`+tomorrow+`"}`, nil, "id", "1")
+		product := send(f.hd.Schedule(), "POST", `{"price":8,"effective":"`+tomorrow+`"}`, nil, "id", "9")
+		timeline := send(f.hd.Timeline(), "GET", "", nil, "id", "9")
+		schedule := send(f.hd.Cancel(), "DELETE", "", nil, "id", "1", "scheduleID", "nope")
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, past.Code)
+		require.Equal(t, "Invalid price schedule", past.Body.String())
+		require.Equal(t, http.StatusBadRequest, format.Code)
+		require.Equal(t, "invalid effective", format.Body.String())
+		require.Equal(t, http.StatusBadRequest, negative.Code)
+		require.Equal(t, http.StatusNotFound, product.Code)
+		require.Equal(t, http.StatusNotFound, timeline.Code)
+		require.Equal(t, http.StatusNotFound, schedule.Code)
+		require.Equal(t, "Price schedule not found", schedule.Body.String())
+	})
+}
@@ -0,0 +1,204 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductCurrencies(t *testing.T) {
+	// rates quote a dollar at 0.92 euros and 151.37 yens
+	rates := internal.ExchangeRates{Base: "USD", Rates: map[string]*big.Rat{
+		"EUR": big.NewRat(92, 100),
+		"JPY": big.NewRat(15137, 100),
+	}}
+	// newHandler returns a product handler over an empty products file, converting with the rates and rounding
+	newHandler := func(t *testing.T, rates internal.ExchangeRates, rounding internal.Rounding) *handler.DefaultProducts {
+		st := repository.NewStorageProductJSON(t.TempDir()+"/products.json", "02/01/2006")
+		ex := service.NewExchangeDefault(rates, rounding)
+		sv := service.NewProductDefault(repository.NewProductStore(*st, 0, "02/01/2006")).WithExchange(ex)
+		return handler.NewDefaultProducts(sv).WithExchange(ex)
+	}
+	// send sends a request to a handler with the url param id, decoding the data of the response into out
+	send := func(h http.HandlerFunc, method, target, body string, out any, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h(res, req)
+		if out != nil {
+			json.Unmarshal(res.Body.Bytes(), &struct {
+				Data any `json:"data"`
+			}{Data: out})
+		}
+		return res
+	}
+	// create creates a product with a variant, both priced in a currency
+	create := func(t *testing.T, hd *handler.DefaultProducts, price, currency string) {
+		res := send(hd.Create(), "POST", "/products", `{"name":"Wine","quantity":1,"code_value":"W100","is_published":true,"expiration":"01/01/2099","price":`+price+`,"currency":"`+currency+`",
+			"variants":[{"code_value":"W100M","quantity":1,"price":`+price+`}]}`, nil, "")
+		require.Equal(t, http.StatusCreated, res.Code)
+	}
+
+	t.Run("success 01 - should read the prices in their currency or in the currency requested", func(t *testing.T) {
+		// arrange
+		hd := newHandler(t, rates, internal.RoundHalfEven)
+		create(t, hd, "10.99", "EUR")
+
+		// act
+		var own, dollars, yens handler.ProductJSON
+		res := send(hd.GetByID(), "GET", "/products/1", "", &own, "1")
+		send(hd.GetByID(), "GET", "/products/1?currency=USD", "", &dollars, "1")
+		send(hd.GetByID(), "GET", "/products/1?currency=JPY", "", &yens, "1")
+		var listed []handler.ProductJSON
+		send(hd.GetAll(), "GET", "/products?currency=USD", "", &listed, "")
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, 10.99, own.Price)
+		require.Equal(t, "EUR", own.Currency)
+		// 10.99 / 0.92 = 11.9456...
+		require.Equal(t, 11.95, dollars.Price)
+		require.Equal(t, "USD", dollars.Currency)
+		require.Equal(t, 11.95, dollars.Variants[0].Price)
+		// 10.99 / 0.92 * 151.37 = 1808.21..., in yens without minor unit
+		require.Equal(t, 1808.0, yens.Price)
+		require.Equal(t, "JPY", yens.Currency)
+		require.Len(t, listed, 1)
+		require.Equal(t, dollars, listed[0])
+	})
+
+	t.Run("success 02 - should round the converted prices as configured", func(t *testing.T) {
+		// arrange
+		// a dollar at 0.925 euros puts 1.00, 0.30 and 0.10 dollars at 0.925, 0.2775 and 0.0925 euros
+		rates := internal.ExchangeRates{Base: "USD", Rates: map[string]*big.Rat{"EUR": big.NewRat(925, 1000)}}
+		cases := map[internal.Rounding][]float64{
+			internal.RoundHalfEven: {0.92, 0.28, 0.09},
+			internal.RoundHalfUp:   {0.93, 0.28, 0.09},
+			internal.RoundDown:     {0.92, 0.27, 0.09},
+			internal.RoundUp:       {0.93, 0.28, 0.1},
+		}
+
+		for rounding, expected := range cases {
+			hd := newHandler(t, rates, rounding)
+			var got []float64
+			for i, price := range []string{"1", "0.3", "0.1"} {
+				res := send(hd.Create(), "POST", "/products", `{"name":"Wine","quantity":1,"code_value":"W10`+string(rune('0'+i))+`","is_published":true,"expiration":"01/01/2099","price":`+price+`}`, nil, "")
+				require.Equal(t, http.StatusCreated, res.Code)
+				var product handler.ProductJSON
+				send(hd.GetByID(), "GET", "/products/1?currency=EUR", "", &product, string(rune('1'+i)))
+				got = append(got, product.Price)
+			}
+
+			// assert
+			require.Equal(t, expected, got, rounding)
+		}
+	})
+
+	t.Run("success 03 - should keep the prices in minor units of the currency", func(t *testing.T) {
+		// arrange
+		hd := newHandler(t, rates, internal.RoundHalfEven)
+
+		// act
+		var cents, yens handler.ProductJSON
+		send(hd.Create(), "POST", "/products", `{"name":"Wine","quantity":1,"code_value":"W100","is_published":true,"expiration":"01/01/2099","price":0.29}`, &cents, "")
+		send(hd.Create(), "POST", "/products", `{"name":"Sake","quantity":1,"code_value":"S100","is_published":true,"expiration":"01/01/2099","price":99.5,"currency":"JPY"}`, &yens, "")
+		var patched handler.ProductJSON
+		send(hd.Update(), "PATCH", "/products/2", `{"price":100,"currency":"EUR"}`, &patched, "2")
+
+		// assert
+		require.Equal(t, 0.29, cents.Price)
+		require.Equal(t, "USD", cents.Currency)
+		require.Equal(t, 100.0, yens.Price)
+		require.Equal(t, 100.0, patched.Price)
+		require.Equal(t, "EUR", patched.Currency)
+	})
+
+	t.Run("success 04 - should search the prices in the currency requested", func(t *testing.T) {
+		// arrange
+		hd := newHandler(t, rates, internal.RoundHalfEven)
+		create(t, hd, "10.99", "EUR")
+		res := send(hd.Create(), "POST", "/products", `{"name":"Beer","quantity":1,"code_value":"B200","is_published":true,"expiration":"01/01/2099","price":11.5,"currency":"USD"}`, nil, "")
+		require.Equal(t, http.StatusCreated, res.Code)
+
+		// act
+		var dollars, euros []handler.ProductJSON
+		resDollars := send(hd.Search(), "GET", "/products/search?priceGt=11", "", &dollars, "")
+		resEuros := send(hd.Search(), "GET", "/products/search?priceGt=11&currency=EUR", "", &euros, "")
+
+		// assert
+		// 10.99 EUR is 11.95 USD, and 11.50 USD is 10.58 EUR
+		require.Equal(t, http.StatusOK, resDollars.Code)
+		require.Len(t, dollars, 2)
+		require.Equal(t, http.StatusNotFound, resEuros.Code)
+	})
+
+	t.Run("fail 01 - should reject unsupported currencies and missing rates", func(t *testing.T) {
+		// arrange
+		hd := newHandler(t, internal.ExchangeRates{Base: "USD"}, internal.RoundHalfEven)
+		create(t, hd, "10", "USD")
+
+		// act
+		unknown := send(hd.GetByID(), "GET", "/products/1?currency=XYZ", "", nil, "1")
+		missing := send(hd.GetByID(), "GET", "/products/1?currency=EUR", "", nil, "1")
+		listed := send(hd.GetAll(), "GET", "/products?currency=EUR", "", nil, "")
+		created := send(hd.Create(), "POST", "/products", `{"name":"Wine","quantity":1,"code_value":"W200","is_published":true,"expiration":"01/01/2099","price":1,"currency":"XYZ"}`, nil, "")
+		without := send(handler.NewDefaultProducts(service.NewProductDefault(repository.NewProductRepository(map[int]*internal.Product{}, 0))).GetByID(), "GET", "/products/1?currency=USD", "", nil, "1")
+		send(hd.Create(), "POST", "/products", `{"name":"Cava","quantity":1,"code_value":"C300","is_published":true,"expiration":"01/01/2099","price":12,"currency":"EUR"}`, nil, "")
+		var searched []handler.ProductJSON
+		search := send(hd.Search(), "GET", "/products/search?priceGt=1", "", &searched, "")
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, unknown.Code)
+		require.Equal(t, "invalid currency", unknown.Body.String())
+		require.Equal(t, http.StatusBadRequest, missing.Code)
+		require.Equal(t, "invalid currency", missing.Body.String())
+		require.Equal(t, http.StatusBadRequest, listed.Code)
+		require.Equal(t, http.StatusBadRequest, created.Code)
+		require.Equal(t, "Invalid currency", created.Body.String())
+		require.Equal(t, http.StatusBadRequest, without.Code)
+		// the product without a rate is left out of the search, not the whole search
+		require.Equal(t, http.StatusOK, search.Code)
+		require.Len(t, searched, 1)
+		require.Equal(t, "USD", searched[0].Currency)
+	})
+
+	t.Run("fail 02 - should reject a new currency without the prices in it", func(t *testing.T) {
+		// arrange
+		hd := newHandler(t, rates, internal.RoundHalfEven)
+		create(t, hd, "19.99", "USD")
+
+		// act
+		bare := send(hd.Update(), "PATCH", "/products/1", `{"currency":"JPY"}`, nil, "1")
+		priced := send(hd.Update(), "PATCH", "/products/1", `{"price":3000,"currency":"JPY"}`, nil, "1")
+		var unchanged handler.ProductJSON
+		send(hd.GetByID(), "GET", "/products/1", "", &unchanged, "1")
+		var patched handler.ProductJSON
+		res := send(hd.Update(), "PATCH", "/products/1", `{"price":3000,"currency":"JPY","variants":[{"code_value":"W100M","quantity":1,"price":3200}]}`, &patched, "1")
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, bare.Code)
+		require.Equal(t, "Price required with a new currency", bare.Body.String())
+		require.Equal(t, http.StatusBadRequest, priced.Code)
+		require.Equal(t, "Variants required with a new currency", priced.Body.String())
+		require.Equal(t, 19.99, unchanged.Price)
+		require.Equal(t, "USD", unchanged.Currency)
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, 3000.0, patched.Price)
+		require.Equal(t, "JPY", patched.Currency)
+		require.Equal(t, 3200.0, patched.Variants[0].Price)
+	})
+}
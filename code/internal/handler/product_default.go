@@ -1,11 +1,12 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
-	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
@@ -17,6 +18,12 @@ import (
 
 type DefaultProducts struct {
 	sv internal.ProductService
+	// ct resolves the ?category= filter, nil if the products are not categorized
+	ct internal.CategoryService
+	// ex converts the prices to the ?currency= of the reads, nil if they are only in the currency of each product
+	ex internal.ExchangeService
+	// pr quotes the effective prices with the promotions, nil if the products are sold at their prices
+	pr internal.PricingService
 }
 
 func NewDefaultProducts(sv internal.ProductService) *DefaultProducts {
@@ -25,14 +32,43 @@ func NewDefaultProducts(sv internal.ProductService) *DefaultProducts {
 	}
 }
 
+// WithCategories enables the ?category= filter of the product listings
+func (p *DefaultProducts) WithCategories(ct internal.CategoryService) *DefaultProducts {
+	p.ct = ct
+	return p
+}
+
+// WithExchange enables the ?currency= conversion of the product reads
+func (p *DefaultProducts) WithExchange(ex internal.ExchangeService) *DefaultProducts {
+	p.ex = ex
+	return p
+}
+
+// WithPricing enables the promotions in the effective prices of the products
+func (p *DefaultProducts) WithPricing(pr internal.PricingService) *DefaultProducts {
+	p.pr = pr
+	return p
+}
+
 type ProductJSON struct {
-	Id           int     `json:"id"`
-	Name         string  `json:"name"`
-	Quantity     int     `json:"quantity"`
-	Code_value   string  `json:"code_value"`
-	Is_published bool    `json:"is_published"`
-	Expiration   string  `json:"expiration"`
-	Price        float64 `json:"price"`
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Quantity     int    `json:"quantity"`
+	Code_value   string `json:"code_value"`
+	Is_published bool   `json:"is_published"`
+	Expiration   string `json:"expiration"`
+	// Price is in major units of the currency, e.g. 19.99 USD
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+	// EffectivePrice is the price of a unit once the promotions apply, in the same currency;
+	// Promotions are the ids of the promotions that lowered it, in the order they applied
+	EffectivePrice float64 `json:"effective_price"`
+	Promotions     []int   `json:"promotions,omitempty"`
+	// Reserved is the part of the quantity held by reservations, Available the rest
+	Reserved   int             `json:"reserved"`
+	Available  int             `json:"available"`
+	Attributes []AttributeJSON `json:"attributes,omitempty"`
+	Variants   []VariantJSON   `json:"variants,omitempty"`
 }
 
 type BodyProductJSON struct {
@@ -42,20 +78,61 @@ type BodyProductJSON struct {
 	Is_published bool    `json:"is_published"`
 	Expiration   string  `json:"expiration"`
 	Price        float64 `json:"price"`
+	// Currency is the ISO 4217 code of the price and of the prices of the variants, the default one if left out
+	Currency string `json:"currency,omitempty"`
+	// Attributes and Variants replace those of the product; left out, it has none
+	Attributes []AttributeJSON `json:"attributes,omitempty"`
+	Variants   []VariantJSON   `json:"variants,omitempty"`
 }
 
-// GetAll returns all products
+type AttributeJSON struct {
+	Name string `json:"name"`
+	// Type is string, number or boolean, which the value must be
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type VariantJSON struct {
+	Code_value string          `json:"code_value"`
+	Quantity   int             `json:"quantity"`
+	Price      float64         `json:"price"`
+	Attributes []AttributeJSON `json:"attributes,omitempty"`
+}
+
+// GetAll returns all products in id order, streamed one per line if the client accepts application/x-ndjson
 func (p *DefaultProducts) GetAll() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		//request
+		category, ok := p.category(w, r)
+		if !ok {
+			return
+		}
+		currency, ok := p.currency(w, r)
+		if !ok {
+			return
+		}
+		quantity, ok := p.quantity(w, r)
+		if !ok {
+			return
+		}
+		pricer, ok := p.pricer(w, r)
+		if !ok {
+			return
+		}
+		if request.Accepts(r, response.ContentTypeNDJSON) {
+			p.stream(w, r, category, pricer, quantity, currency)
+			return
+		}
 
 		//process
-		products, err := p.sv.GetAll()
+		p.lastModified(w, r)
+		products, err := p.sv.GetAll(r.Context())
 		if err != nil {
 			switch {
 			case errors.Is(err, internal.ErrProductNotFound):
 				response.Text(w, http.StatusNotFound, "Product not found")
 			default:
+				slog.ErrorContext(r.Context(), "get all products", "error", err)
 				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
 			}
 			return
@@ -64,7 +141,10 @@ func (p *DefaultProducts) GetAll() http.HandlerFunc {
 		//response
 		// serialize products to json
 		var data []ProductJSON
-		for _, products := range products {
+		for _, products := range byID(products) {
+			if category != nil && !category[products.Id] {
+				continue
+			}
 			pJSON := ProductJSON{
 				Id:           products.Id,
 				Name:         products.Name,
@@ -72,7 +152,16 @@ func (p *DefaultProducts) GetAll() http.HandlerFunc {
 				Code_value:   products.Code_value,
 				Is_published: products.Is_published,
 				Expiration:   products.Expiration.Format("02/01/2006"),
-				Price:        products.Price,
+				Price:        products.Price.Major(),
+				Currency:     products.Price.Currency,
+				Reserved:     products.Reserved,
+				Available:    products.Available(),
+				Attributes:   attributesJSON(products.Attributes),
+				Variants:     variantsJSON(products.Variants),
+			}
+			if err = p.prices(r.Context(), &pJSON, products, pricer, quantity, currency); err != nil {
+				pricesError(w, r, err)
+				return
 			}
 			data = append(data, pJSON)
 		}
@@ -83,6 +172,55 @@ func (p *DefaultProducts) GetAll() http.HandlerFunc {
 	}
 }
 
+// ndjsonFlushEvery is how many products are streamed between flushes
+const ndjsonFlushEvery = 100
+
+// stream writes the products in id order as newline delimited json, without holding them in memory,
+// only those in category if it is not nil, priced as prices does.
+// Once the first product is sent the status is committed, so a later failure ends the stream early.
+func (p *DefaultProducts) stream(w http.ResponseWriter, r *http.Request, category map[int]bool, pricer internal.Pricer, quantity int, currency string) {
+	//process
+	p.lastModified(w, r)
+	nd := response.NewNDJSON(w, ndjsonFlushEvery)
+	err := p.sv.Each(r.Context(), func(product *internal.Product) error {
+		if category != nil && !category[product.Id] {
+			return nil
+		}
+		data := ProductJSON{
+			Id:           product.Id,
+			Name:         product.Name,
+			Quantity:     product.Quantity,
+			Code_value:   product.Code_value,
+			Is_published: product.Is_published,
+			Expiration:   product.Expiration.Format("02/01/2006"),
+			Price:        product.Price.Major(),
+			Currency:     product.Price.Currency,
+			Reserved:     product.Reserved,
+			Available:    product.Available(),
+			Attributes:   attributesJSON(product.Attributes),
+			Variants:     variantsJSON(product.Variants),
+		}
+		if err := p.prices(r.Context(), &data, product, pricer, quantity, currency); err != nil {
+			return err
+		}
+		return nd.Encode(data)
+	})
+
+	//response
+	switch {
+	case err == nil:
+		nd.Close()
+	case nd.Count() == 0 && errors.Is(err, internal.ErrExchangeRateMissing):
+		pricesError(w, r, err)
+	case nd.Count() == 0:
+		slog.ErrorContext(r.Context(), "stream products", "error", err)
+		response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+	default:
+		// the client went away or the storage failed mid-stream: the truncated response is all it gets
+		slog.WarnContext(r.Context(), "stream products interrupted", "sent", nd.Count(), "error", err)
+	}
+}
+
 // GetByID returns a product by id
 func (p *DefaultProducts) GetByID() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -95,13 +233,28 @@ func (p *DefaultProducts) GetByID() http.HandlerFunc {
 			return
 		}
 
+		currency, ok := p.currency(w, r)
+		if !ok {
+			return
+		}
+		quantity, ok := p.quantity(w, r)
+		if !ok {
+			return
+		}
+		pricer, ok := p.pricer(w, r)
+		if !ok {
+			return
+		}
+
 		//process
-		product, err := p.sv.GetByID(id)
+		p.lastModified(w, r)
+		product, err := p.sv.GetByID(r.Context(), id)
 		if err != nil {
 			switch {
 			case errors.Is(err, internal.ErrProductNotFound):
 				response.Text(w, http.StatusNotFound, "Product not found")
 			default:
+				slog.ErrorContext(r.Context(), "get product", "error", err)
 				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
 			}
 			return
@@ -116,7 +269,16 @@ func (p *DefaultProducts) GetByID() http.HandlerFunc {
 			Code_value:   product.Code_value,
 			Is_published: product.Is_published,
 			Expiration:   product.Expiration.Format("02/01/2006"),
-			Price:        product.Price,
+			Price:        product.Price.Major(),
+			Currency:     product.Price.Currency,
+			Reserved:     product.Reserved,
+			Available:    product.Available(),
+			Attributes:   attributesJSON(product.Attributes),
+			Variants:     variantsJSON(product.Variants),
+		}
+		if err = p.prices(r.Context(), &data, product, pricer, quantity, currency); err != nil {
+			pricesError(w, r, err)
+			return
 		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
@@ -126,25 +288,59 @@ func (p *DefaultProducts) GetByID() http.HandlerFunc {
 	}
 }
 
-// Search returns a filtered map of products where are greater than the given price
+// Search returns the products, in id order, priced at or above the given price, in the ?currency= or the default one,
+// and in the given category if any; the price may be left out when the category is given
 func (p *DefaultProducts) Search() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		//request
-
-		//get price from urlparams with chi
-		price, err := strconv.ParseInt(r.URL.Query().Get("priceGt"), 10, 64)
-		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid price")
+		category, ok := p.category(w, r)
+		if !ok {
+			return
+		}
+		currency, ok := p.currency(w, r)
+		if !ok {
+			return
+		}
+		quantity, ok := p.quantity(w, r)
+		if !ok {
+			return
+		}
+		pricer, ok := p.pricer(w, r)
+		if !ok {
 			return
 		}
 
+		//get price from urlparams with chi, in the currency the prices are read in
+		var price *internal.Money
+		if q := r.URL.Query().Get("priceGt"); q != "" || category == nil {
+			major, err := strconv.ParseInt(q, 10, 64)
+			if err != nil {
+				response.Text(w, http.StatusBadRequest, "invalid price")
+				return
+			}
+			m, err := money(float64(major), currency)
+			if err != nil {
+				response.Text(w, http.StatusBadRequest, "invalid price")
+				return
+			}
+			price = &m
+		}
+
 		//process
-		products, err := p.sv.SearchByPrice(float64(price))
+		p.lastModified(w, r)
+		var products map[int]*internal.Product
+		var err error
+		if price != nil {
+			products, err = p.sv.SearchByPrice(r.Context(), *price)
+		} else {
+			products, err = p.sv.GetAll(r.Context())
+		}
 		if err != nil {
 			switch {
 			case errors.Is(err, internal.ErrProductNotFound):
 				response.Text(w, http.StatusNotFound, "Product not found")
 			default:
+				slog.ErrorContext(r.Context(), "search products", "error", err)
 				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
 			}
 			return
@@ -153,7 +349,10 @@ func (p *DefaultProducts) Search() http.HandlerFunc {
 		//response
 		// serialize products to json
 		var data []ProductJSON
-		for _, products := range products {
+		for _, products := range byID(products) {
+			if category != nil && !category[products.Id] {
+				continue
+			}
 			pJSON := ProductJSON{
 				Id:           products.Id,
 				Name:         products.Name,
@@ -161,7 +360,16 @@ func (p *DefaultProducts) Search() http.HandlerFunc {
 				Code_value:   products.Code_value,
 				Is_published: products.Is_published,
 				Expiration:   products.Expiration.Format("02/01/2006"),
-				Price:        products.Price,
+				Price:        products.Price.Major(),
+				Currency:     products.Price.Currency,
+				Reserved:     products.Reserved,
+				Available:    products.Available(),
+				Attributes:   attributesJSON(products.Attributes),
+				Variants:     variantsJSON(products.Variants),
+			}
+			if err = p.prices(r.Context(), &pJSON, products, pricer, quantity, currency); err != nil {
+				pricesError(w, r, err)
+				return
 			}
 			data = append(data, pJSON)
 		}
@@ -185,9 +393,9 @@ func (p *DefaultProducts) Create() http.HandlerFunc {
 
 		//decode body to json
 		var body BodyProductJSON
-		err := json.NewDecoder(r.Body).Decode(&body)
+		err := request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyProduct})
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
+			bodyError(w, r, err)
 			return
 		}
 
@@ -202,23 +410,42 @@ func (p *DefaultProducts) Create() http.HandlerFunc {
 			return
 		}
 
+		price, err := money(body.Price, body.Currency)
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "Invalid currency")
+			return
+		}
+
+		// the promotions are read before the product changes, which a failure to read them must not follow
+		pricer, ok := p.pricer(w, r)
+		if !ok {
+			return
+		}
+
 		product := internal.Product{
 			Name:         body.Name,
 			Quantity:     body.Quantity,
 			Code_value:   body.Code_value,
 			Is_published: body.Is_published,
 			Expiration:   exp,
-			Price:        body.Price,
+			Price:        price,
+			Attributes:   attributes(body.Attributes),
+			Variants:     variants(body.Variants, price.Currency),
 		}
 
-		err = p.sv.Create(&product)
+		err = p.sv.Create(r.Context(), &product)
 		if err != nil {
 			switch {
+			case errors.Is(err, internal.ErrProductVariantInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid variant")
+			case errors.Is(err, internal.ErrProductAttributeInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid attribute")
 			case errors.Is(err, internal.ErrFieldRequired):
 				response.Text(w, http.StatusBadRequest, "Field required")
 			case errors.Is(err, internal.ErrValidateQualityField):
 				response.Text(w, http.StatusBadRequest, "Invalid expiration")
 			default:
+				slog.ErrorContext(r.Context(), "create product", "error", err)
 				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
 			}
 			return
@@ -231,7 +458,16 @@ func (p *DefaultProducts) Create() http.HandlerFunc {
 			Code_value:   product.Code_value,
 			Is_published: product.Is_published,
 			Expiration:   product.Expiration.Format("02/01/2006"),
-			Price:        product.Price,
+			Price:        product.Price.Major(),
+			Currency:     product.Price.Currency,
+			Reserved:     product.Reserved,
+			Available:    product.Available(),
+			Attributes:   attributesJSON(product.Attributes),
+			Variants:     variantsJSON(product.Variants),
+		}
+		if err = p.prices(r.Context(), &data, &product, pricer, 1, ""); err != nil {
+			pricesError(w, r, err)
+			return
 		}
 
 		//response
@@ -254,27 +490,10 @@ func (p *DefaultProducts) UpdateOrCreate() http.HandlerFunc {
 			return
 		}
 
-		bytes, err := io.ReadAll(r.Body)
-		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
-			return
-		}
-
-		var BodyMap map[string]any
-		if err = json.Unmarshal(bytes, &BodyMap); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
-			return
-		}
-
-		if err = ValidateKeyExistance(BodyMap, "name", "quantity", "code_value", "is_published", "expiration", "price"); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
-			return
-		}
-
-		//decode body to json
+		//decode body to json: every field is required
 		var body BodyProductJSON
-		if err = json.Unmarshal(bytes, &body); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyProduct}); err != nil {
+			bodyError(w, r, err)
 			return
 		}
 
@@ -290,6 +509,18 @@ func (p *DefaultProducts) UpdateOrCreate() http.HandlerFunc {
 			return
 		}
 
+		price, err := money(body.Price, body.Currency)
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "Invalid currency")
+			return
+		}
+
+		// the promotions are read before the product changes, which a failure to read them must not follow
+		pricer, ok := p.pricer(w, r)
+		if !ok {
+			return
+		}
+
 		product := internal.Product{
 			Id:           id,
 			Name:         body.Name,
@@ -297,16 +528,25 @@ func (p *DefaultProducts) UpdateOrCreate() http.HandlerFunc {
 			Code_value:   body.Code_value,
 			Is_published: body.Is_published,
 			Expiration:   exp,
-			Price:        body.Price,
+			Price:        price,
+			Attributes:   attributes(body.Attributes),
+			Variants:     variants(body.Variants, price.Currency),
 		}
-		prod, err := p.sv.UpdateOrCreate(&product)
+		prod, err := p.sv.UpdateOrCreate(r.Context(), &product)
 		if err != nil {
 			switch {
+			case errors.Is(err, internal.ErrProductVariantInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid variant")
+			case errors.Is(err, internal.ErrProductAttributeInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid attribute")
 			case errors.Is(err, internal.ErrFieldRequired):
 				response.Text(w, http.StatusBadRequest, "Field required")
 			case errors.Is(err, internal.ErrValidateQualityField):
 				response.Text(w, http.StatusBadRequest, "Invalid expiration")
+			case errors.Is(err, internal.ErrStockQuantityDerived):
+				response.Text(w, http.StatusConflict, "Quantity is changed through stock movements")
 			default:
+				slog.ErrorContext(r.Context(), "update or create product", "error", err)
 				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
 			}
 			return
@@ -319,7 +559,16 @@ func (p *DefaultProducts) UpdateOrCreate() http.HandlerFunc {
 			Code_value:   prod.Code_value,
 			Is_published: prod.Is_published,
 			Expiration:   prod.Expiration.Format("02/01/2006"),
-			Price:        prod.Price,
+			Price:        prod.Price.Major(),
+			Currency:     prod.Price.Currency,
+			Reserved:     prod.Reserved,
+			Available:    prod.Available(),
+			Attributes:   attributesJSON(prod.Attributes),
+			Variants:     variantsJSON(prod.Variants),
+		}
+		if err = p.prices(r.Context(), &data, &prod, pricer, 1, ""); err != nil {
+			pricesError(w, r, err)
+			return
 		}
 
 		//response
@@ -342,12 +591,13 @@ func (p *DefaultProducts) Update() http.HandlerFunc {
 		}
 
 		//get product from database
-		product, err := p.sv.GetByID(id)
+		product, err := p.sv.GetByID(r.Context(), id)
 		if err != nil {
 			switch {
 			case errors.Is(err, internal.ErrProductNotFound):
 				response.Text(w, http.StatusNotFound, "Product not found")
 			default:
+				slog.ErrorContext(r.Context(), "update product", "error", err)
 				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
 			}
 			return
@@ -356,29 +606,63 @@ func (p *DefaultProducts) Update() http.HandlerFunc {
 		//process
 
 		//serialize product to json
+		// - the price starts as NaN, which no body decodes to, to tell whether the body sets it
 		reqBody := BodyProductJSON{
 			Name:         product.Name,
 			Quantity:     product.Quantity,
 			Code_value:   product.Code_value,
 			Is_published: product.Is_published,
 			Expiration:   product.Expiration.Format("02/01/2006"),
-			Price:        product.Price,
+			Price:        math.NaN(),
+			Currency:     product.Price.Currency,
 		}
 
 		//get body
+		// - the attributes and variants start empty: decoding into the current ones would merge
+		//   the new elements into the old, so the current ones are kept only if the body leaves them out
 
-		if err = request.JSON(r, &reqBody); err != nil {
-			fmt.Println("aqui")
-			response.Text(w, http.StatusBadRequest, "invalid body")
+		if err = request.JSONWith(r, &reqBody, request.JSONOptions{Schema: schemaPatchProduct}); err != nil {
+			bodyError(w, r, err)
 			return
 		}
 
+		// the amounts of the product are in its currency: a new currency needs them in the new one
+		if reqBody.Currency != product.Price.Currency {
+			if math.IsNaN(reqBody.Price) {
+				response.Text(w, http.StatusBadRequest, "Price required with a new currency")
+				return
+			}
+			if reqBody.Variants == nil && len(product.Variants) > 0 {
+				response.Text(w, http.StatusBadRequest, "Variants required with a new currency")
+				return
+			}
+		}
+		if math.IsNaN(reqBody.Price) {
+			reqBody.Price = product.Price.Major()
+		}
+
 		//update product
 		expiration, err := time.Parse("02/01/2006", reqBody.Expiration)
 		if err != nil {
 			response.Text(w, http.StatusBadRequest, "Invalid expiration")
 			return
 		}
+		price, err := money(reqBody.Price, reqBody.Currency)
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "Invalid currency")
+			return
+		}
+
+		// the promotions are read before the product changes, which a failure to read them must not follow
+		pricer, ok := p.pricer(w, r)
+		if !ok {
+			return
+		}
+		// the variants kept are only kept in the same currency
+		vars := reqBody.Variants
+		if vars == nil {
+			vars = variantsJSON(product.Variants)
+		}
 
 		product = &internal.Product{
 			Id:           id,
@@ -387,11 +671,27 @@ func (p *DefaultProducts) Update() http.HandlerFunc {
 			Code_value:   reqBody.Code_value,
 			Is_published: reqBody.Is_published,
 			Expiration:   expiration,
-			Price:        reqBody.Price,
+			Price:        price,
+			Attributes:   product.Attributes,
+			Variants:     variants(vars, price.Currency),
+			Reserved:     product.Reserved,
+		}
+		if reqBody.Attributes != nil {
+			product.Attributes = attributes(reqBody.Attributes)
 		}
 
-		if err = p.sv.Update(product); err != nil {
-			response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+		if err = p.sv.Update(r.Context(), product); err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductVariantInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid variant")
+			case errors.Is(err, internal.ErrProductAttributeInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid attribute")
+			case errors.Is(err, internal.ErrStockQuantityDerived):
+				response.Text(w, http.StatusConflict, "Quantity is changed through stock movements")
+			default:
+				slog.ErrorContext(r.Context(), "update product", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
 			return
 		}
 
@@ -404,7 +704,16 @@ func (p *DefaultProducts) Update() http.HandlerFunc {
 			Code_value:   product.Code_value,
 			Is_published: product.Is_published,
 			Expiration:   product.Expiration.Format("02/01/2006"),
-			Price:        product.Price,
+			Price:        product.Price.Major(),
+			Currency:     product.Price.Currency,
+			Reserved:     product.Reserved,
+			Available:    product.Available(),
+			Attributes:   attributesJSON(product.Attributes),
+			Variants:     variantsJSON(product.Variants),
+		}
+		if err = p.prices(r.Context(), &data, product, pricer, 1, ""); err != nil {
+			pricesError(w, r, err)
+			return
 		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
@@ -427,12 +736,13 @@ func (p *DefaultProducts) Delete() http.HandlerFunc {
 		}
 
 		//process
-		err = p.sv.Delete(id)
+		err = p.sv.Delete(r.Context(), id)
 		if err != nil {
 			switch {
 			case errors.Is(err, internal.ErrProductNotFound):
 				response.Text(w, http.StatusNotFound, "Product not found")
 			default:
+				slog.ErrorContext(r.Context(), "delete product", "error", err)
 				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
 			}
 			return
@@ -446,11 +756,227 @@ func (p *DefaultProducts) Delete() http.HandlerFunc {
 	}
 }
 
-func ValidateKeyExistance(m map[string]any, keys ...string) (err error) {
-	for _, key := range keys {
-		if _, ok := m[key]; !ok {
-			return fmt.Errorf("key %s not found", key)
+// category returns the ids of the products in the category of the ?category= query, nil if there is none.
+// It responds and reports false if the category is unknown.
+func (p *DefaultProducts) category(w http.ResponseWriter, r *http.Request) (ids map[int]bool, ok bool) {
+	slug := r.URL.Query().Get("category")
+	if slug == "" {
+		return nil, true
+	}
+	if p.ct == nil {
+		response.Text(w, http.StatusBadRequest, "invalid category")
+		return
+	}
+	ids, err := p.ct.ProductIDs(r.Context(), slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, internal.ErrCategoryNotFound):
+			response.Text(w, http.StatusBadRequest, "invalid category")
+		default:
+			slog.ErrorContext(r.Context(), "category products", "error", err)
+			response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+		}
+		return
+	}
+	return ids, true
+}
+
+// currency returns the currency of the ?currency= query to convert the prices to, empty if there is none.
+// It responds and reports false if the currency is not supported or the prices cannot be converted.
+func (p *DefaultProducts) currency(w http.ResponseWriter, r *http.Request) (currency string, ok bool) {
+	currency = r.URL.Query().Get("currency")
+	if currency == "" {
+		return "", true
+	}
+	if _, err := internal.Digits(currency); err != nil || p.ex == nil {
+		response.Text(w, http.StatusBadRequest, "invalid currency")
+		return
+	}
+	return currency, true
+}
+
+// quantity returns the units of the ?quantity= query the effective prices are for, one if there is none.
+// It responds and reports false if it is not a positive number.
+func (p *DefaultProducts) quantity(w http.ResponseWriter, r *http.Request) (quantity int, ok bool) {
+	q := r.URL.Query().Get("quantity")
+	if q == "" {
+		return 1, true
+	}
+	quantity, err := strconv.Atoi(q)
+	if err != nil || quantity < 1 {
+		response.Text(w, http.StatusBadRequest, "invalid quantity")
+		return
+	}
+	return quantity, true
+}
+
+// pricer returns the pricer of the products with the promotions active now, nil if there are no promotions.
+// It responds and reports false if the promotions cannot be read.
+func (p *DefaultProducts) pricer(w http.ResponseWriter, r *http.Request) (pricer internal.Pricer, ok bool) {
+	if p.pr == nil {
+		return nil, true
+	}
+	pricer, err := p.pr.Pricer(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "product pricer", "error", err)
+		response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	return pricer, true
+}
+
+// prices sets the effective price of a unit of a serialized product when buying quantity units, with the
+// promotions of pricer unless it is nil, and converts all its prices to currency unless it is empty
+func (p *DefaultProducts) prices(ctx context.Context, data *ProductJSON, product *internal.Product, pricer internal.Pricer, quantity int, currency string) (err error) {
+	effective := product.Price
+	if pricer != nil {
+		var quote internal.Quote
+		if quote, err = pricer.Quote(*product, quantity); err != nil {
+			return
+		}
+		effective, data.Promotions = quote.Effective, quote.Promotions
+	}
+	data.EffectivePrice = effective.Major()
+	if currency == "" {
+		return
+	}
+
+	price, err := p.ex.Convert(ctx, product.Price, currency)
+	if err != nil {
+		return
+	}
+	data.Price, data.Currency = price.Major(), price.Currency
+	if effective, err = p.ex.Convert(ctx, effective, currency); err != nil {
+		return
+	}
+	data.EffectivePrice = effective.Major()
+	for i, v := range product.Variants {
+		if price, err = p.ex.Convert(ctx, v.Price, currency); err != nil {
+			return
+		}
+		data.Variants[i].Price = price.Major()
+	}
+	return
+}
+
+// pricesError writes the response of a failed pricing or conversion of the prices
+func pricesError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, internal.ErrExchangeRateMissing):
+		response.Text(w, http.StatusBadRequest, "invalid currency")
+	default:
+		slog.ErrorContext(r.Context(), "price products", "error", err)
+		response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// lastModified sets the Last-Modified header to the latest change of what the listings are derived from, if known:
+// the products with their stock, the categories they are filtered by and the promotions of the effective prices.
+// It is read before the products, so it is never newer than the response.
+func (p *DefaultProducts) lastModified(w http.ResponseWriter, r *http.Request) {
+	t, err := p.sv.LastModified(r.Context())
+	if err != nil {
+		slog.DebugContext(r.Context(), "last modified", "error", err)
+		return
+	}
+	for _, lm := range []func(ctx context.Context) (time.Time, error){p.categoriesModified, p.pricesModified} {
+		var other time.Time
+		if other, err = lm(r.Context()); err != nil {
+			slog.DebugContext(r.Context(), "last modified", "error", err)
+			return
+		}
+		if other.After(t) {
+			t = other
 		}
 	}
+	if !t.IsZero() {
+		w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// categoriesModified returns the last change of the categories, zero if the products are not categorized
+func (p *DefaultProducts) categoriesModified(ctx context.Context) (t time.Time, err error) {
+	if p.ct == nil {
+		return
+	}
+	return p.ct.LastModified(ctx)
+}
+
+// pricesModified returns the last change of the promotions, zero if the products are sold at their prices
+func (p *DefaultProducts) pricesModified(ctx context.Context) (t time.Time, err error) {
+	if p.pr == nil {
+		return
+	}
+	return p.pr.LastModified(ctx)
+}
+
+// attributesJSON serializes attributes, nil if there are none
+func attributesJSON(attributes []internal.Attribute) (data []AttributeJSON) {
+	for _, a := range attributes {
+		data = append(data, AttributeJSON{Name: a.Name, Type: string(a.Type), Value: a.Value})
+	}
 	return
 }
+
+// attributes deserializes the attributes of a body, nil if there are none
+func attributes(data []AttributeJSON) (attributes []internal.Attribute) {
+	for _, a := range data {
+		attributes = append(attributes, internal.Attribute{Name: a.Name, Type: internal.AttributeType(a.Type), Value: a.Value})
+	}
+	return
+}
+
+// variantsJSON serializes variants, nil if there are none
+func variantsJSON(variants []internal.Variant) (data []VariantJSON) {
+	for _, v := range variants {
+		data = append(data, VariantJSON{Code_value: v.Code_value, Quantity: v.Quantity, Price: v.Price.Major(), Attributes: attributesJSON(v.Attributes)})
+	}
+	return
+}
+
+// variants deserializes the variants of a body, priced in a currency already checked, nil if there are none
+func variants(data []VariantJSON, currency string) (variants []internal.Variant) {
+	for _, v := range data {
+		price, _ := money(v.Price, currency)
+		variants = append(variants, internal.Variant{Code_value: v.Code_value, Quantity: v.Quantity, Price: price, Attributes: attributes(v.Attributes)})
+	}
+	return
+}
+
+// byID returns the products in id order, as the stream lists them
+func byID(products map[int]*internal.Product) (sorted []*internal.Product) {
+	ids := make([]int, 0, len(products))
+	for id := range products {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		sorted = append(sorted, products[id])
+	}
+	return
+}
+
+// money deserializes a price of a body, in major units of a currency or of the default one if it is empty
+func money(price float64, currency string) (internal.Money, error) {
+	if currency == "" {
+		currency = internal.CurrencyDefault
+	}
+	return internal.NewMoney(price, currency)
+}
+
+// bodyError responds to a body that request.JSON could not decode
+func bodyError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.DebugContext(r.Context(), "invalid body", "error", err)
+
+	var schemaErr *request.SchemaError
+	switch {
+	case errors.Is(err, request.ErrRequestContentTypeNotJSON):
+		response.Text(w, http.StatusUnsupportedMediaType, "content type must be application/json")
+	case errors.Is(err, request.ErrRequestBodyTooLarge):
+		response.Text(w, http.StatusRequestEntityTooLarge, "body too large")
+	case errors.As(err, &schemaErr):
+		response.Text(w, http.StatusBadRequest, "invalid body: "+schemaErr.Path+": "+schemaErr.Reason)
+	default:
+		response.Text(w, http.StatusBadRequest, "invalid body")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -29,7 +30,7 @@ func TestProductDefault_GetAll(t *testing.T) {
 			Code_value:   "123456",
 			Is_published: true,
 			Expiration:   time.Date(2006, time.February, 1, 0, 0, 0, 0, time.UTC),
-			Price:        10.0,
+			Price:        internal.Money{Amount: 1000, Currency: "USD"},
 		}
 
 		db[2] = &internal.Product{
@@ -39,7 +40,7 @@ func TestProductDefault_GetAll(t *testing.T) {
 			Code_value:   "123456",
 			Is_published: true,
 			Expiration:   time.Date(2006, time.February, 1, 0, 0, 0, 0, time.UTC),
-			Price:        20.0,
+			Price:        internal.Money{Amount: 2000, Currency: "USD"},
 		}
 
 		rp := repository.NewProductRepository(db, 0)
@@ -71,7 +72,7 @@ func TestProductDefault_GetAll(t *testing.T) {
 		// assert
 
 		expectedCode := http.StatusOK
-		expectedBody := `[{"id":1,"name":"Product 1","quantity":10,"code_value":"123456","is_published":true,"expiration":"01/02/2006","price":10},{"id":2,"name":"Product 2","quantity":20,"code_value":"123456","is_published":true,"expiration":"01/02/2006","price":20}]`
+		expectedBody := `[{"id":1,"name":"Product 1","quantity":10,"code_value":"123456","is_published":true,"expiration":"01/02/2006","price":10,"currency":"USD","effective_price":10,"reserved":0,"available":10},{"id":2,"name":"Product 2","quantity":20,"code_value":"123456","is_published":true,"expiration":"01/02/2006","price":20,"currency":"USD","effective_price":20,"reserved":0,"available":20}]`
 		expectedHeader := "application/json"
 
 		require.Equal(t, expectedCode, res.Code)
@@ -124,7 +125,7 @@ func TestProductDefault_GetAll(t *testing.T) {
 			Code_value:   "123456",
 			Is_published: true,
 			Expiration:   time.Date(2006, time.February, 1, 0, 0, 0, 0, time.UTC),
-			Price:        10.0,
+			Price:        internal.Money{Amount: 1000, Currency: "USD"},
 		}
 
 		rp := repository.NewProductRepository(db, 0)
@@ -159,7 +160,7 @@ func TestProductDefault_GetAll(t *testing.T) {
 		// assert
 
 		expectedCode := http.StatusOK
-		expectedBody := `{"id":1,"name":"Product 1","quantity":10,"code_value":"123456","is_published":true,"expiration":"01/02/2006","price":10}`
+		expectedBody := `{"id":1,"name":"Product 1","quantity":10,"code_value":"123456","is_published":true,"expiration":"01/02/2006","price":10,"currency":"USD","effective_price":10,"reserved":0,"available":10}`
 		expectedHeader := "application/json"
 
 		require.Equal(t, expectedCode, res.Code)
@@ -176,12 +177,13 @@ func TestProductDefault_GetAll(t *testing.T) {
 
 		hdFunc := hd.Create()
 
-		product := `{"name":"Product 1","quantity":10,"code_value":"S6611","is_published":true,"expiration":"01/12/2024","price":10}`
+		product := `{"name":"Product 1","quantity":10,"code_value":"S6611","is_published":true,"expiration":"01/12/2099","price":10}`
 
 		// act
 
 		req := httptest.NewRequest("POST", "/products", strings.NewReader(product))
 		req.Header.Set("Authorization", "12345")
+		req.Header.Set("Content-Type", "application/json")
 		res := httptest.NewRecorder()
 
 		hdFunc(res, req)
@@ -202,7 +204,7 @@ func TestProductDefault_GetAll(t *testing.T) {
 		// assert
 
 		expectedCode := http.StatusCreated
-		expectedBody := `{"id":1,"name":"Product 1","quantity":10,"code_value":"S6611","is_published":true,"expiration":"01/12/2024","price":10}`
+		expectedBody := `{"id":1,"name":"Product 1","quantity":10,"code_value":"S6611","is_published":true,"expiration":"01/12/2099","price":10,"currency":"USD","effective_price":10,"reserved":0,"available":10}`
 		expectedHeader := "application/json"
 
 		require.Equal(t, expectedCode, res.Code)
@@ -220,7 +222,7 @@ func TestProductDefault_GetAll(t *testing.T) {
 			Code_value:   "123456",
 			Is_published: true,
 			Expiration:   time.Date(2006, time.February, 1, 0, 0, 0, 0, time.UTC),
-			Price:        10.0,
+			Price:        internal.Money{Amount: 1000, Currency: "USD"},
 		}
 
 		rp := repository.NewProductRepository(db, 0)
@@ -363,3 +365,172 @@ func TestProductDefault_GetAll(t *testing.T) {
 		require.Equal(t, `invalid id`, response)
 	})
 }
+
+func TestProductDefault_Body(t *testing.T) {
+	// newHandler returns the handlers over a catalog with the product 1
+	newHandler := func() *handler.DefaultProducts {
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S6611", Is_published: true, Expiration: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 1000, Currency: "USD"}},
+		}
+		return handler.NewDefaultProducts(service.NewProductDefault(repository.NewProductRepository(db, 1)))
+	}
+	// withID sets the chi url param id
+	withID := func(req *http.Request, id string) *http.Request {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	t.Run("success 01 - should accept media type parameters", func(t *testing.T) {
+		// arrange
+		product := `{"name":"Product 2","quantity":10,"code_value":"S6612","is_published":true,"expiration":"01/12/2099","price":10}`
+		req := httptest.NewRequest("POST", "/products", strings.NewReader(product))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		res := httptest.NewRecorder()
+
+		// act
+		newHandler().Create()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+	})
+
+	t.Run("fail 01 - should reject a body that is not json", func(t *testing.T) {
+		// arrange
+		req := httptest.NewRequest("POST", "/products", strings.NewReader(`name=Product 2`))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res := httptest.NewRecorder()
+
+		// act
+		newHandler().Create()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusUnsupportedMediaType, res.Code)
+	})
+
+	t.Run("fail 02 - should require every field to replace a product", func(t *testing.T) {
+		// arrange
+		product := `{"name":"Product 1","quantity":10,"code_value":"S6611","is_published":true,"expiration":"01/12/2099"}`
+		req := httptest.NewRequest("PUT", "/products/1", strings.NewReader(product))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+
+		// act
+		newHandler().UpdateOrCreate()(res, withID(req, "1"))
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, `invalid body: /: property "price" is required`, res.Body.String())
+	})
+
+	t.Run("fail 03 - should reject a field of the wrong type", func(t *testing.T) {
+		// arrange
+		req := httptest.NewRequest("PATCH", "/products/1", strings.NewReader(`{"quantity":"ten"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+
+		// act
+		newHandler().Update()(res, withID(req, "1"))
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, `invalid body: /quantity: must be of type integer`, res.Body.String())
+	})
+
+	t.Run("fail 04 - should reject unknown fields", func(t *testing.T) {
+		// arrange
+		req := httptest.NewRequest("PATCH", "/products/1", strings.NewReader(`{"colour":"red"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+
+		// act
+		newHandler().Update()(res, withID(req, "1"))
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, `invalid body`, res.Body.String())
+	})
+}
+
+func TestProductDefault_Stream(t *testing.T) {
+	expiration := time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expected := "" +
+		`{"id":1,"name":"Product 1","quantity":10,"code_value":"S1","is_published":true,"expiration":"01/01/2099","price":10,"currency":"USD","effective_price":10,"reserved":0,"available":10}` + "\n" +
+		`{"id":2,"name":"Product 2","quantity":20,"code_value":"S2","is_published":false,"expiration":"01/01/2099","price":20,"currency":"USD","effective_price":20,"reserved":0,"available":20}` + "\n" +
+		`{"id":3,"name":"Product 3","quantity":30,"code_value":"S3","is_published":true,"expiration":"01/01/2099","price":30,"currency":"USD","effective_price":30,"reserved":0,"available":30}` + "\n"
+	db := func() map[int]*internal.Product {
+		return map[int]*internal.Product{
+			3: {Id: 3, Name: "Product 3", Quantity: 30, Code_value: "S3", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 3000, Currency: "USD"}},
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S1", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 1000, Currency: "USD"}},
+			2: {Id: 2, Name: "Product 2", Quantity: 20, Code_value: "S2", Is_published: false, Expiration: expiration, Price: internal.Money{Amount: 2000, Currency: "USD"}},
+		}
+	}
+
+	t.Run("success 01 - should stream the products in id order as ndjson", func(t *testing.T) {
+		// arrange
+		hd := handler.NewDefaultProducts(service.NewProductDefault(repository.NewProductRepository(db(), 3)))
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+		res := httptest.NewRecorder()
+
+		// act
+		hd.GetAll()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "application/x-ndjson", res.Header().Get("Content-Type"))
+		require.Equal(t, expected, res.Body.String())
+	})
+
+	t.Run("success 02 - should stream the products from the file", func(t *testing.T) {
+		// arrange
+		st := repository.NewStorageProductJSON(t.TempDir()+"/products.json", "02/01/2006")
+		require.NoError(t, st.WriteAll(context.Background(), db()))
+		hd := handler.NewDefaultProducts(service.NewProductDefault(repository.NewProductStore(*st, 3, "02/01/2006")))
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.Header.Set("Accept", "application/x-ndjson, application/json;q=0.5")
+		res := httptest.NewRecorder()
+
+		// act
+		hd.GetAll()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, expected, res.Body.String())
+		require.NotEmpty(t, res.Header().Get("Last-Modified"))
+	})
+
+	t.Run("success 03 - should stream an empty catalog", func(t *testing.T) {
+		// arrange
+		st := repository.NewStorageProductJSON(t.TempDir()+"/products.json", "02/01/2006")
+		hd := handler.NewDefaultProducts(service.NewProductDefault(repository.NewProductStore(*st, 0, "02/01/2006")))
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+		res := httptest.NewRecorder()
+
+		// act
+		hd.GetAll()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "application/x-ndjson", res.Header().Get("Content-Type"))
+		require.Empty(t, res.Body.String())
+	})
+
+	t.Run("fail 01 - should fail before streaming when the file is corrupt", func(t *testing.T) {
+		// arrange
+		path := t.TempDir() + "/products.json"
+		require.NoError(t, os.WriteFile(path, []byte(`{"id":1}`), 0644))
+		st := repository.NewStorageProductJSON(path, "02/01/2006")
+		hd := handler.NewDefaultProducts(service.NewProductDefault(repository.NewProductStore(*st, 0, "02/01/2006")))
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+		res := httptest.NewRecorder()
+
+		// act
+		hd.GetAll()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusInternalServerError, res.Code)
+	})
+}
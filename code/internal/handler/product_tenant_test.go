@@ -0,0 +1,78 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductTenant(t *testing.T) {
+	t.Run("success 01 - tenants should only see their own products and id sequences", func(t *testing.T) {
+		// arrange
+		sv := service.NewProductTenant(map[string]internal.ProductService{
+			"store-a": service.NewProductDefault(repository.NewProductRepository(make(map[int]*internal.Product), 0)),
+			"store-b": service.NewProductDefault(repository.NewProductRepository(make(map[int]*internal.Product), 0)),
+		})
+		hd := handler.NewDefaultProducts(sv)
+
+		withTenant := func(req *http.Request, tenantID string) *http.Request {
+			return req.WithContext(tenant.ContextWithTenant(req.Context(), tenantID))
+		}
+
+		// act
+		product := `{"name":"Product 1","quantity":10,"code_value":"S6611","is_published":true,"expiration":"01/12/2099","price":10}`
+		created := httptest.NewRecorder()
+		reqCreate := httptest.NewRequest("POST", "/products", strings.NewReader(product))
+		reqCreate.Header.Set("Content-Type", "application/json")
+		hd.Create()(created, withTenant(reqCreate, "store-a"))
+
+		getA := httptest.NewRecorder()
+		reqA := httptest.NewRequest("GET", "/products/1", nil)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		reqA = reqA.WithContext(context.WithValue(reqA.Context(), chi.RouteCtxKey, chiCtx))
+		hd.GetByID()(getA, withTenant(reqA, "store-a"))
+
+		getB := httptest.NewRecorder()
+		hd.GetByID()(getB, withTenant(reqA, "store-b"))
+
+		type Response struct {
+			Data    handler.ProductJSON `json:"data"`
+			Message string              `json:"message"`
+		}
+		var response Response
+		json.NewDecoder(created.Body).Decode(&response)
+
+		// assert
+		require.Equal(t, http.StatusCreated, created.Code)
+		require.Equal(t, 1, response.Data.Id)
+		require.Equal(t, http.StatusOK, getA.Code)
+		require.Equal(t, http.StatusNotFound, getB.Code)
+	})
+
+	t.Run("fail 01 - should fail when the tenant has no catalog", func(t *testing.T) {
+		// arrange
+		sv := service.NewProductTenant(map[string]internal.ProductService{})
+		hd := handler.NewDefaultProducts(sv)
+
+		// act
+		req := httptest.NewRequest("GET", "/products", nil)
+		req = req.WithContext(tenant.ContextWithTenant(req.Context(), "unknown"))
+		res := httptest.NewRecorder()
+		hd.GetAll()(res, req)
+
+		// assert
+		require.Equal(t, http.StatusInternalServerError, res.Code)
+	})
+}
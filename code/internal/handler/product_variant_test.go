@@ -0,0 +1,111 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductVariants(t *testing.T) {
+	// newHandler returns a product handler over an empty products file
+	newHandler := func(t *testing.T) *handler.DefaultProducts {
+		st := repository.NewStorageProductJSON(t.TempDir()+"/products.json", "02/01/2006")
+		return handler.NewDefaultProducts(service.NewProductDefault(repository.NewProductStore(*st, 0, "02/01/2006")))
+	}
+	// send sends a request to a handler with the url params, decoding the data of the response into out
+	send := func(h http.HandlerFunc, method, body string, out any, params ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/products", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		chiCtx := chi.NewRouteContext()
+		for i := 0; i < len(params); i += 2 {
+			chiCtx.URLParams.Add(params[i], params[i+1])
+		}
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h(res, req)
+		if out != nil {
+			json.Unmarshal(res.Body.Bytes(), &struct {
+				Data any `json:"data"`
+			}{Data: out})
+		}
+		return res
+	}
+	product := `{"name":"T-shirt","quantity":0,"code_value":"T100","is_published":true,"expiration":"01/12/2099","price":15,
+		"attributes":[{"name":"brand","type":"string","value":"Acme"},{"name":"organic","type":"boolean","value":true}],
+		"variants":[
+			{"code_value":"T100S","quantity":4,"price":15,"attributes":[{"name":"size","type":"string","value":"S"}]},
+			{"code_value":"T100L","quantity":2,"price":17.5,"attributes":[{"name":"size","type":"string","value":"L"},{"name":"weight","type":"number","value":0.2}]}
+		]}`
+
+	t.Run("success 01 - should store the attributes and variants of a product", func(t *testing.T) {
+		// arrange
+		hd := newHandler(t)
+
+		// act
+		var created, got handler.ProductJSON
+		res := send(hd.Create(), "POST", product, &created)
+		send(hd.GetByID(), "GET", "", &got, "id", "1")
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, created, got)
+		require.Equal(t, []handler.AttributeJSON{
+			{Name: "brand", Type: "string", Value: "Acme"},
+			{Name: "organic", Type: "boolean", Value: true},
+		}, got.Attributes)
+		require.Equal(t, []handler.VariantJSON{
+			{Code_value: "T100S", Quantity: 4, Price: 15, Attributes: []handler.AttributeJSON{{Name: "size", Type: "string", Value: "S"}}},
+			{Code_value: "T100L", Quantity: 2, Price: 17.5, Attributes: []handler.AttributeJSON{{Name: "size", Type: "string", Value: "L"}, {Name: "weight", Type: "number", Value: 0.2}}},
+		}, got.Variants)
+	})
+
+	t.Run("success 02 - should replace the variants on patch and keep or clear the attributes", func(t *testing.T) {
+		// arrange
+		hd := newHandler(t)
+		send(hd.Create(), "POST", product, nil)
+
+		// act
+		var patched, cleared handler.ProductJSON
+		res := send(hd.Update(), "PATCH", `{"variants":[{"code_value":"T100M","quantity":3,"price":16}]}`, &patched, "id", "1")
+		send(hd.Update(), "PATCH", `{"attributes":[]}`, &cleared, "id", "1")
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Len(t, patched.Attributes, 2)
+		require.Equal(t, []handler.VariantJSON{{Code_value: "T100M", Quantity: 3, Price: 16}}, patched.Variants)
+		require.Empty(t, cleared.Attributes)
+		require.Equal(t, patched.Variants, cleared.Variants)
+	})
+
+	t.Run("fail 01 - should reject attributes and variants that do not validate", func(t *testing.T) {
+		// arrange
+		hd := newHandler(t)
+		base := `{"name":"T-shirt","quantity":0,"code_value":"T100","is_published":true,"expiration":"01/12/2099","price":15,`
+
+		// act
+		mistyped := send(hd.Create(), "POST", base+`"attributes":[{"name":"organic","type":"boolean","value":"yes"}]}`, nil)
+		repeated := send(hd.Create(), "POST", base+`"attributes":[{"name":"brand","type":"string","value":"A"},{"name":"brand","type":"string","value":"B"}]}`, nil)
+		sku := send(hd.Create(), "POST", base+`"variants":[{"code_value":"T100","quantity":1,"price":15}]}`, nil)
+		code := send(hd.Create(), "POST", base+`"variants":[{"code_value":"small","quantity":1,"price":15}]}`, nil)
+		negative := send(hd.Create(), "POST", base+`"variants":[{"code_value":"T100S","quantity":-1,"price":15}]}`, nil)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, mistyped.Code)
+		require.Equal(t, "Invalid attribute", mistyped.Body.String())
+		require.Equal(t, http.StatusBadRequest, repeated.Code)
+		require.Equal(t, "Invalid attribute", repeated.Body.String())
+		require.Equal(t, http.StatusBadRequest, sku.Code)
+		require.Equal(t, "Invalid variant", sku.Body.String())
+		require.Equal(t, http.StatusBadRequest, code.Code)
+		require.Equal(t, http.StatusBadRequest, negative.Code)
+	})
+}
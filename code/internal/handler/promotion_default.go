@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultPromotions struct {
+	sv internal.PromotionService
+}
+
+func NewDefaultPromotions(sv internal.PromotionService) *DefaultPromotions {
+	return &DefaultPromotions{
+		sv: sv,
+	}
+}
+
+type PromotionJSON struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Kind is percent, amount or buy_get, which the fields below configure
+	Kind    string  `json:"kind"`
+	Percent float64 `json:"percent,omitempty"`
+	// Amount is in major units of the currency, the only one the promotion applies to
+	Amount     float64                  `json:"amount,omitempty"`
+	Currency   string                   `json:"currency,omitempty"`
+	Buy        int                      `json:"buy,omitempty"`
+	Get        int                      `json:"get,omitempty"`
+	Conditions []PromotionConditionJSON `json:"conditions"`
+	// Starts and Ends bound when the promotion is active, empty if it is not bounded
+	Starts    string `json:"starts,omitempty"`
+	Ends      string `json:"ends,omitempty"`
+	Priority  int    `json:"priority"`
+	Exclusive bool   `json:"exclusive"`
+}
+
+type BodyPromotionJSON struct {
+	Name       string                   `json:"name"`
+	Kind       string                   `json:"kind"`
+	Percent    float64                  `json:"percent"`
+	Amount     float64                  `json:"amount"`
+	Currency   string                   `json:"currency"`
+	Buy        int                      `json:"buy"`
+	Get        int                      `json:"get"`
+	Conditions []PromotionConditionJSON `json:"conditions"`
+	Starts     string                   `json:"starts"`
+	Ends       string                   `json:"ends"`
+	Priority   int                      `json:"priority"`
+	Exclusive  bool                     `json:"exclusive"`
+}
+
+type PromotionConditionJSON struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    any    `json:"value"`
+	Currency string `json:"currency,omitempty"`
+}
+
+// GetAll returns the promotions
+func (h *DefaultPromotions) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//process
+		promotions, err := h.sv.GetAll(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get all promotions", "error", err)
+			response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		//response
+		data := make([]PromotionJSON, 0, len(promotions))
+		for _, v := range promotions {
+			data = append(data, promotionJSON(v))
+		}
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    data,
+		})
+	}
+}
+
+// GetByID returns a promotion
+func (h *DefaultPromotions) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		promotion, err := h.sv.GetByID(r.Context(), id)
+		if err != nil {
+			promotionError(w, r, "get promotion", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    promotionJSON(promotion),
+		})
+	}
+}
+
+// Create creates a promotion
+func (h *DefaultPromotions) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		var body BodyPromotionJSON
+		if err := request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyPromotion}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+		promotion, err := promotion(body)
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "Invalid promotion")
+			return
+		}
+
+		//process
+		if err = h.sv.Create(r.Context(), &promotion); err != nil {
+			promotionError(w, r, "create promotion", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    promotionJSON(promotion),
+		})
+	}
+}
+
+// Update replaces a promotion
+func (h *DefaultPromotions) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var body BodyPromotionJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyPromotion}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+		promotion, err := promotion(body)
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "Invalid promotion")
+			return
+		}
+		promotion.ID = id
+
+		//process
+		if err = h.sv.Update(r.Context(), promotion); err != nil {
+			promotionError(w, r, "update promotion", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    promotionJSON(promotion),
+		})
+	}
+}
+
+// Delete deletes a promotion
+func (h *DefaultPromotions) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		if err = h.sv.Delete(r.Context(), id); err != nil {
+			promotionError(w, r, "delete promotion", err)
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusNoContent, map[string]any{
+			"message": "success",
+			"data":    nil,
+		})
+	}
+}
+
+// promotionError writes the response of a failed promotion operation
+func promotionError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	switch {
+	case errors.Is(err, internal.ErrPromotionNotFound):
+		response.Text(w, http.StatusNotFound, "Promotion not found")
+	case errors.Is(err, internal.ErrPromotionInvalid):
+		response.Text(w, http.StatusBadRequest, "Invalid promotion")
+	default:
+		slog.ErrorContext(r.Context(), msg, "error", err)
+		response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// promotion deserializes the promotion of a body, failing on a malformed date or amount
+func promotion(body BodyPromotionJSON) (promotion internal.Promotion, err error) {
+	promotion = internal.Promotion{
+		Name:      body.Name,
+		Kind:      internal.PromotionKind(body.Kind),
+		Percent:   body.Percent,
+		Buy:       body.Buy,
+		Get:       body.Get,
+		Priority:  body.Priority,
+		Exclusive: body.Exclusive,
+	}
+	if body.Amount != 0 {
+		if promotion.Amount, err = money(body.Amount, body.Currency); err != nil {
+			return
+		}
+	}
+	for _, c := range body.Conditions {
+		condition := internal.PromotionCondition{Field: c.Field, Operator: internal.PromotionOperator(c.Operator), Value: c.Value, Currency: c.Currency}
+		// a price is in the default currency unless it says otherwise
+		if c.Field == internal.PromotionFieldPrice && c.Currency == "" {
+			condition.Currency = internal.CurrencyDefault
+		}
+		promotion.Conditions = append(promotion.Conditions, condition)
+	}
+	if body.Starts != "" {
+		if promotion.Starts, err = time.Parse(time.RFC3339, body.Starts); err != nil {
+			return
+		}
+	}
+	if body.Ends != "" {
+		if promotion.Ends, err = time.Parse(time.RFC3339, body.Ends); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// promotionJSON serializes a promotion
+func promotionJSON(v internal.Promotion) PromotionJSON {
+	data := PromotionJSON{
+		ID:         v.ID,
+		Name:       v.Name,
+		Kind:       string(v.Kind),
+		Percent:    v.Percent,
+		Buy:        v.Buy,
+		Get:        v.Get,
+		Conditions: []PromotionConditionJSON{},
+		Priority:   v.Priority,
+		Exclusive:  v.Exclusive,
+	}
+	if v.Amount != (internal.Money{}) {
+		data.Amount, data.Currency = v.Amount.Major(), v.Amount.Currency
+	}
+	for _, c := range v.Conditions {
+		data.Conditions = append(data.Conditions, PromotionConditionJSON{Field: c.Field, Operator: string(c.Operator), Value: c.Value, Currency: c.Currency})
+	}
+	if !v.Starts.IsZero() {
+		data.Starts = v.Starts.Format(time.RFC3339)
+	}
+	if !v.Ends.IsZero() {
+		data.Ends = v.Ends.Format(time.RFC3339)
+	}
+	return data
+}
@@ -0,0 +1,222 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPromotions(t *testing.T) {
+	// newHandlers returns the product, category and promotion handlers over a catalog with the products 1 to 4,
+	// the second with a premium brand and the fourth priced in euros
+	newHandlers := func(t *testing.T) (*handler.DefaultProducts, *handler.DefaultCategories, *handler.DefaultPromotions) {
+		expiration := time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC)
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S1", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 15000, Currency: "USD"}},
+			2: {Id: 2, Name: "Product 2", Quantity: 10, Code_value: "S2", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 25000, Currency: "USD"},
+				Attributes: []internal.Attribute{{Name: "brand", Type: internal.AttributeString, Value: "premium"}}},
+			3: {Id: 3, Name: "Product 3", Quantity: 10, Code_value: "S3", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 999, Currency: "USD"}},
+			4: {Id: 4, Name: "Product 4", Quantity: 10, Code_value: "S4", Is_published: true, Expiration: expiration, Price: internal.Money{Amount: 30000, Currency: "EUR"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 4))
+		svCategories := service.NewCategoryDefault(repository.NewCategoryJSON(t.TempDir()+"/categories.json"), sv)
+		stPromotions := repository.NewPromotionJSON(t.TempDir() + "/promotions.json")
+		svPricing := service.NewPricingDefault(stPromotions, svCategories, internal.RoundHalfEven)
+		svExchange := service.NewExchangeDefault(internal.ExchangeRates{Base: "USD", Rates: map[string]*big.Rat{"EUR": big.NewRat(1, 2)}}, internal.RoundHalfEven)
+		hd := handler.NewDefaultProducts(sv).WithCategories(svCategories).WithExchange(svExchange).WithPricing(svPricing)
+		return hd, handler.NewDefaultCategories(svCategories), handler.NewDefaultPromotions(service.NewPromotionDefault(stPromotions))
+	}
+	// send sends a request to a handler with the url params, decoding the data of the response into out
+	send := func(h http.HandlerFunc, method, target, body string, out any, params ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		chiCtx := chi.NewRouteContext()
+		for i := 0; i < len(params); i += 2 {
+			chiCtx.URLParams.Add(params[i], params[i+1])
+		}
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h(res, req)
+		if out != nil {
+			json.Unmarshal(res.Body.Bytes(), &struct {
+				Data any `json:"data"`
+			}{Data: out})
+		}
+		return res
+	}
+	// effective returns the effective prices of products by id
+	effective := func(products []handler.ProductJSON) map[int]float64 {
+		prices := make(map[int]float64)
+		for _, p := range products {
+			prices[p.Id] = p.EffectivePrice
+		}
+		return prices
+	}
+
+	t.Run("success 01 - should create, read, replace and delete a promotion", func(t *testing.T) {
+		// arrange
+		_, _, hd := newHandlers(t)
+
+		// act
+		var created, got, replaced handler.PromotionJSON
+		res := send(hd.Create(), "POST", "/promotions", `{"name":"10% off above 200","kind":"percent","percent":10,
+			"conditions":[{"field":"price","operator":"gt","value":200}],"starts":"2020-01-01T00:00:00Z","priority":5}`, &created)
+		send(hd.GetByID(), "GET", "/promotions/1", "", &got, "id", "1")
+		resPut := send(hd.Update(), "PUT", "/promotions/1", `{"name":"5 off","kind":"amount","amount":5,"currency":"EUR","exclusive":true}`, &replaced, "id", "1")
+		var all []handler.PromotionJSON
+		send(hd.GetAll(), "GET", "/promotions", "", &all)
+		resDelete := send(hd.Delete(), "DELETE", "/promotions/1", "", nil, "id", "1")
+		resGone := send(hd.GetByID(), "GET", "/promotions/1", "", nil, "id", "1")
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, handler.PromotionJSON{ID: 1, Name: "10% off above 200", Kind: "percent", Percent: 10,
+			Conditions: []handler.PromotionConditionJSON{{Field: "price", Operator: "gt", Value: 200.0, Currency: "USD"}}, Starts: "2020-01-01T00:00:00Z", Priority: 5}, created)
+		require.Equal(t, created, got)
+		require.Equal(t, http.StatusOK, resPut.Code)
+		require.Equal(t, handler.PromotionJSON{ID: 1, Name: "5 off", Kind: "amount", Amount: 5, Currency: "EUR", Conditions: []handler.PromotionConditionJSON{}, Exclusive: true}, replaced)
+		require.Equal(t, []handler.PromotionJSON{replaced}, all)
+		require.Equal(t, http.StatusNoContent, resDelete.Code)
+		require.Equal(t, http.StatusNotFound, resGone.Code)
+	})
+
+	t.Run("success 02 - should return the effective prices with the promotions the products meet", func(t *testing.T) {
+		// arrange
+		hdProducts, hdCategories, hd := newHandlers(t)
+		send(hdCategories.Create(), "POST", "/categories", `{"slug":"wine","name":"Wine"}`, nil)
+		send(hdCategories.SetProductCategories(), "PUT", "/products/3/categories", `{"category_ids":[1]}`, nil, "id", "3")
+		send(hd.Create(), "POST", "/promotions", `{"name":"10% off above 200","kind":"percent","percent":10,"conditions":[{"field":"price","operator":"gt","value":200}]}`, nil)
+		send(hd.Create(), "POST", "/promotions", `{"name":"premium","kind":"amount","amount":20,"conditions":[{"field":"attributes.brand","operator":"in","value":["premium","reserve"]}]}`, nil)
+		send(hd.Create(), "POST", "/promotions", `{"name":"wine week","kind":"percent","percent":33,"conditions":[{"field":"category","operator":"eq","value":"wine"}]}`, nil)
+		send(hd.Create(), "POST", "/promotions", `{"name":"expired","kind":"percent","percent":50,"ends":"2020-01-01T00:00:00Z"}`, nil)
+		send(hd.Create(), "POST", "/promotions", `{"name":"upcoming","kind":"percent","percent":50,"starts":"2099-01-01T00:00:00Z"}`, nil)
+		send(hd.Create(), "POST", "/promotions", `{"name":"10% off above 280 EUR","kind":"percent","percent":10,"conditions":[{"field":"price","operator":"gt","value":280,"currency":"EUR"}]}`, nil)
+
+		// act
+		var products []handler.ProductJSON
+		res := send(hdProducts.GetAll(), "GET", "/products", "", &products)
+		var premium handler.ProductJSON
+		send(hdProducts.GetByID(), "GET", "/products/2", "", &premium, "id", "2")
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		// the promotions just written move the Last-Modified of the effective prices
+		modified, err := http.ParseTime(res.Header().Get("Last-Modified"))
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), modified, 2*time.Second)
+		// 250 - 10% = 225, then - 20 = 205; 9.99 - 33% = 6.6933 rounds to 6.69;
+		// 300 EUR is compared with the condition in euros, not with the one in dollars: 300 - 10% = 270
+		require.Equal(t, map[int]float64{1: 150, 2: 205, 3: 6.69, 4: 270}, effective(products))
+		require.Equal(t, 250.0, premium.Price)
+		require.Equal(t, 205.0, premium.EffectivePrice)
+		require.Equal(t, []int{1, 2}, premium.Promotions)
+	})
+
+	t.Run("success 03 - should apply the promotions by priority until an exclusive one", func(t *testing.T) {
+		// arrange
+		hdProducts, _, hd := newHandlers(t)
+		send(hd.Create(), "POST", "/promotions", `{"name":"10% off","kind":"percent","percent":10}`, nil)
+		send(hd.Create(), "POST", "/promotions", `{"name":"half price","kind":"percent","percent":50,"priority":10,"exclusive":true,
+			"conditions":[{"field":"code_value","operator":"eq","value":"S1"}]}`, nil)
+		send(hd.Create(), "POST", "/promotions", `{"name":"5 off","kind":"amount","amount":5,"priority":5}`, nil)
+
+		// act
+		var products []handler.ProductJSON
+		send(hdProducts.GetAll(), "GET", "/products", "", &products)
+		var first, second handler.ProductJSON
+		send(hdProducts.GetByID(), "GET", "/products/1", "", &first, "id", "1")
+		send(hdProducts.GetByID(), "GET", "/products/2", "", &second, "id", "2")
+
+		// assert
+		// the amount only applies to the products priced in dollars
+		require.Equal(t, map[int]float64{1: 75, 2: 220.5, 3: 4.49, 4: 270}, effective(products))
+		require.Equal(t, []int{2}, first.Promotions)
+		require.Equal(t, []int{3, 1}, second.Promotions)
+	})
+
+	t.Run("success 04 - should spread buy 2 get 1 over the quantity quoted", func(t *testing.T) {
+		// arrange
+		hdProducts, _, hd := newHandlers(t)
+		send(hd.Create(), "POST", "/promotions", `{"name":"buy 2 get 1","kind":"buy_get","buy":2,"get":1,"conditions":[{"field":"code_value","operator":"eq","value":"S3"}]}`, nil)
+
+		// act
+		var one, three, seven handler.ProductJSON
+		send(hdProducts.GetByID(), "GET", "/products/3", "", &one, "id", "3")
+		send(hdProducts.GetByID(), "GET", "/products/3?quantity=3", "", &three, "id", "3")
+		send(hdProducts.GetByID(), "GET", "/products/3?quantity=7", "", &seven, "id", "3")
+
+		// assert
+		require.Equal(t, 9.99, one.EffectivePrice)
+		require.Empty(t, one.Promotions)
+		// 2 of 3 units paid: 19.98 / 3
+		require.Equal(t, 6.66, three.EffectivePrice)
+		require.Equal(t, []int{1}, three.Promotions)
+		// 5 of 7 units paid: 49.95 / 7 = 7.1357...
+		require.Equal(t, 7.14, seven.EffectivePrice)
+	})
+
+	t.Run("success 05 - should convert the effective price with the others", func(t *testing.T) {
+		// arrange
+		hdProducts, _, hd := newHandlers(t)
+		send(hd.Create(), "POST", "/promotions", `{"name":"10% off","kind":"percent","percent":10}`, nil)
+
+		// act
+		var product handler.ProductJSON
+		send(hdProducts.GetByID(), "GET", "/products/1?currency=EUR", "", &product, "id", "1")
+
+		// assert
+		require.Equal(t, "EUR", product.Currency)
+		require.Equal(t, 75.0, product.Price)
+		require.Equal(t, 67.5, product.EffectivePrice)
+	})
+
+	t.Run("fail 01 - should reject malformed promotions", func(t *testing.T) {
+		// arrange
+		hdProducts, _, hd := newHandlers(t)
+
+		// act
+		bodies := []string{
+			`{"name":" ","kind":"percent","percent":10}`,
+			`{"name":"no percent","kind":"percent"}`,
+			`{"name":"too much","kind":"percent","percent":150}`,
+			`{"name":"no amount","kind":"amount"}`,
+			`{"name":"no get","kind":"buy_get","buy":2}`,
+			`{"name":"unknown kind","kind":"free"}`,
+			`{"name":"unknown field","kind":"percent","percent":10,"conditions":[{"field":"color","operator":"eq","value":"red"}]}`,
+			`{"name":"string order","kind":"percent","percent":10,"conditions":[{"field":"name","operator":"gt","value":"A"}]}`,
+			`{"name":"category order","kind":"percent","percent":10,"conditions":[{"field":"category","operator":"gt","value":"wine"}]}`,
+			`{"name":"empty list","kind":"percent","percent":10,"conditions":[{"field":"name","operator":"in","value":[]}]}`,
+			`{"name":"bad date","kind":"percent","percent":10,"starts":"tomorrow"}`,
+			`{"name":"backwards","kind":"percent","percent":10,"starts":"2030-01-01T00:00:00Z","ends":"2029-01-01T00:00:00Z"}`,
+			`{"name":"bad currency","kind":"amount","amount":5,"currency":"XYZ"}`,
+			`{"name":"price currency","kind":"percent","percent":10,"conditions":[{"field":"price","operator":"gt","value":200,"currency":"XYZ"}]}`,
+			`{"name":"name currency","kind":"percent","percent":10,"conditions":[{"field":"name","operator":"eq","value":"A","currency":"USD"}]}`,
+		}
+		for _, body := range bodies {
+			res := send(hd.Create(), "POST", "/promotions", body, nil)
+
+			// assert
+			require.Equal(t, http.StatusBadRequest, res.Code, body)
+		}
+		var all []handler.PromotionJSON
+		send(hd.GetAll(), "GET", "/promotions", "", &all)
+		require.Empty(t, all)
+		res := send(hdProducts.GetByID(), "GET", "/products/1?quantity=0", "", nil, "id", "1")
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, "invalid quantity", res.Body.String())
+		res = send(hd.Update(), "PUT", "/promotions/9", `{"name":"missing","kind":"percent","percent":10}`, nil, "id", "9")
+		require.Equal(t, http.StatusNotFound, res.Code)
+	})
+}
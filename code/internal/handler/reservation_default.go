@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultReservations struct {
+	sv internal.ReservationService
+}
+
+func NewDefaultReservations(sv internal.ReservationService) *DefaultReservations {
+	return &DefaultReservations{
+		sv: sv,
+	}
+}
+
+type ReservationJSON struct {
+	ID        string `json:"id"`
+	ProductID int    `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	Principal string `json:"principal"`
+	Created   string `json:"created"`
+	Expires   string `json:"expires"`
+}
+
+type BodyReservationJSON struct {
+	Quantity int `json:"quantity"`
+	// TTLSeconds is how long the units are held, the configured default when omitted
+	TTLSeconds int `json:"ttl_seconds"`
+}
+
+// Reserve holds units of a product until the reservation is committed, released or expires
+func (h *DefaultReservations) Reserve() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var body BodyReservationJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyReservation}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+
+		//process
+		reservation, err := h.sv.Reserve(r.Context(), id, body.Quantity, time.Duration(body.TTLSeconds)*time.Second)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductNotFound):
+				response.Text(w, http.StatusNotFound, "Product not found")
+			case errors.Is(err, internal.ErrReservationInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid reservation")
+			case errors.Is(err, internal.ErrStockInsufficient):
+				response.Text(w, http.StatusConflict, "Insufficient stock")
+			default:
+				slog.ErrorContext(r.Context(), "reserve stock", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    reservationJSON(reservation),
+		})
+	}
+}
+
+// GetByID returns a reservation
+func (h *DefaultReservations) GetByID() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id := chi.URLParam(r, "id")
+
+		//process
+		reservation, err := h.sv.GetByID(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrReservationNotFound):
+				response.Text(w, http.StatusNotFound, "Reservation not found")
+			default:
+				slog.ErrorContext(r.Context(), "get reservation", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    reservationJSON(reservation),
+		})
+	}
+}
+
+// Commit turns a reservation into a sale of its units
+func (h *DefaultReservations) Commit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id := chi.URLParam(r, "id")
+
+		//process
+		movement, err := h.sv.Commit(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrReservationNotFound):
+				response.Text(w, http.StatusNotFound, "Reservation not found")
+			case errors.Is(err, internal.ErrProductNotFound):
+				response.Text(w, http.StatusNotFound, "Product not found")
+			case errors.Is(err, internal.ErrReservationExpired):
+				response.Text(w, http.StatusGone, "Reservation expired")
+			case errors.Is(err, internal.ErrStockInsufficient):
+				response.Text(w, http.StatusConflict, "Insufficient stock")
+			default:
+				slog.ErrorContext(r.Context(), "commit reservation", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    stockMovementJSON(movement),
+		})
+	}
+}
+
+// Release gives the units of a reservation back
+func (h *DefaultReservations) Release() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id := chi.URLParam(r, "id")
+
+		//process
+		if err := h.sv.Release(r.Context(), id); err != nil {
+			switch {
+			case errors.Is(err, internal.ErrReservationNotFound):
+				response.Text(w, http.StatusNotFound, "Reservation not found")
+			default:
+				slog.ErrorContext(r.Context(), "release reservation", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusNoContent, map[string]any{
+			"message": "success",
+			"data":    nil,
+		})
+	}
+}
+
+// reservationJSON serializes a reservation
+func reservationJSON(v internal.Reservation) ReservationJSON {
+	return ReservationJSON{
+		ID:        v.ID,
+		ProductID: v.ProductID,
+		Quantity:  v.Quantity,
+		Principal: v.Principal,
+		Created:   v.Created.Format(time.RFC3339),
+		Expires:   v.Expires.Format(time.RFC3339),
+	}
+}
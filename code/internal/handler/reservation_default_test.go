@@ -0,0 +1,184 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultReservations(t *testing.T) {
+	type handlers struct {
+		products     *handler.DefaultProducts
+		stock        *handler.DefaultStock
+		reservations *handler.DefaultReservations
+		sv           *service.ReservationDefault
+		catalog      *service.ProductStock
+	}
+	// newHandlers returns the handlers over a catalog with 10 units of the product 1,
+	// holding the reservations for ttl by default
+	newHandlers := func(t *testing.T, ttl time.Duration) handlers {
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S6611", Is_published: true, Expiration: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 1000, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 1))
+		rp := repository.NewReservationMap()
+		st := service.NewStockDefault(repository.NewStockJSONL(t.TempDir()+"/stock.jsonl"), rp, sv)
+		svReservations := service.NewReservationDefault(rp, st, ttl, time.Hour)
+		catalog := service.NewProductStock(sv, st)
+		return handlers{
+			products:     handler.NewDefaultProducts(catalog),
+			stock:        handler.NewDefaultStock(st),
+			reservations: handler.NewDefaultReservations(svReservations),
+			sv:           svReservations,
+			catalog:      catalog,
+		}
+	}
+	// withID sets the chi url param id
+	withID := func(req *http.Request, id string) *http.Request {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+	// post sends a json body to a handler
+	post := func(h http.HandlerFunc, id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		h(res, withID(req, id))
+		return res
+	}
+	// call sends a request without body to a handler
+	call := func(h http.HandlerFunc, method, id string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		h(res, withID(httptest.NewRequest(method, "/", nil), id))
+		return res
+	}
+	// reserve holds units of the product 1 and returns the reservation
+	reserve := func(t *testing.T, hd handlers, body string) handler.ReservationJSON {
+		res := post(hd.reservations.Reserve(), "1", body)
+		require.Equal(t, http.StatusCreated, res.Code)
+		var data struct {
+			Data handler.ReservationJSON `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&data))
+		return data.Data
+	}
+	// product returns the product 1
+	product := func(hd handlers) handler.ProductJSON {
+		var data struct {
+			Data handler.ProductJSON `json:"data"`
+		}
+		json.NewDecoder(call(hd.products.GetByID(), "GET", "1").Body).Decode(&data)
+		return data.Data
+	}
+
+	t.Run("success 01 - should hold the units until the reservation is committed", func(t *testing.T) {
+		// arrange
+		hd := newHandlers(t, time.Minute)
+
+		// act
+		reservation := reserve(t, hd, `{"quantity":4}`)
+		held := product(hd)
+		overbooked := post(hd.reservations.Reserve(), "1", `{"quantity":7}`)
+		oversold := post(hd.stock.Move(), "1", `{"type":"sale","quantity":7,"reason":"order 12"}`)
+		committed := call(hd.reservations.Commit(), "POST", reservation.ID)
+		sold := product(hd)
+		again := call(hd.reservations.Commit(), "POST", reservation.ID)
+
+		// assert
+		require.Equal(t, 1, reservation.ProductID)
+		require.Equal(t, 4, reservation.Quantity)
+		require.Equal(t, 10, held.Quantity)
+		require.Equal(t, 4, held.Reserved)
+		require.Equal(t, 6, held.Available)
+		require.Equal(t, http.StatusConflict, overbooked.Code)
+		require.Equal(t, http.StatusConflict, oversold.Code)
+		require.Equal(t, http.StatusCreated, committed.Code)
+		require.Equal(t, 6, sold.Quantity)
+		require.Equal(t, 0, sold.Reserved)
+		require.Equal(t, 6, sold.Available)
+		require.Equal(t, http.StatusNotFound, again.Code)
+	})
+
+	t.Run("success 02 - should give the units back when the reservation is released", func(t *testing.T) {
+		// arrange
+		hd := newHandlers(t, time.Minute)
+		reservation := reserve(t, hd, `{"quantity":10,"ttl_seconds":60}`)
+
+		// act
+		released := call(hd.reservations.Release(), "DELETE", reservation.ID)
+		got := call(hd.reservations.GetByID(), "GET", reservation.ID)
+
+		// assert
+		require.Equal(t, http.StatusNoContent, released.Code)
+		require.Equal(t, http.StatusNotFound, got.Code)
+		require.Equal(t, 10, product(hd).Available)
+	})
+
+	t.Run("success 03 - should release the expired reservations", func(t *testing.T) {
+		// arrange
+		hd := newHandlers(t, time.Millisecond)
+		reservation := reserve(t, hd, `{"quantity":10}`)
+		time.Sleep(5 * time.Millisecond)
+
+		// act
+		expired := call(hd.reservations.Commit(), "POST", reservation.ID)
+		available := product(hd).Available
+		released, err := hd.sv.Sweep(context.Background())
+		got := call(hd.reservations.GetByID(), "GET", reservation.ID)
+
+		// assert
+		require.Equal(t, http.StatusGone, expired.Code)
+		require.Equal(t, 10, available)
+		require.NoError(t, err)
+		require.Equal(t, 1, released)
+		require.Equal(t, http.StatusNotFound, got.Code)
+	})
+
+	t.Run("success 04 - should move the last modification of the products when units are reserved", func(t *testing.T) {
+		// arrange
+		hd := newHandlers(t, time.Minute)
+		before, err := hd.catalog.LastModified(context.Background())
+		require.NoError(t, err)
+
+		// act
+		reservation := reserve(t, hd, `{"quantity":4}`)
+		reserved, errReserved := hd.catalog.LastModified(context.Background())
+		released := call(hd.reservations.Release(), "DELETE", reservation.ID)
+		unreserved, errUnreserved := hd.catalog.LastModified(context.Background())
+
+		// assert
+		require.NoError(t, errReserved)
+		require.NoError(t, errUnreserved)
+		require.Equal(t, http.StatusNoContent, released.Code)
+		require.True(t, reserved.After(before))
+		require.True(t, unreserved.After(reserved))
+	})
+
+	t.Run("fail 01 - should reject an invalid reservation", func(t *testing.T) {
+		// arrange
+		hd := newHandlers(t, time.Minute)
+
+		// act
+		zero := post(hd.reservations.Reserve(), "1", `{"quantity":0}`)
+		long := post(hd.reservations.Reserve(), "1", `{"quantity":1,"ttl_seconds":86400}`)
+		missing := post(hd.reservations.Reserve(), "2", `{"quantity":1}`)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, zero.Code)
+		require.Equal(t, http.StatusBadRequest, long.Code)
+		require.Equal(t, "Invalid reservation", long.Body.String())
+		require.Equal(t, http.StatusNotFound, missing.Code)
+	})
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type DefaultStock struct {
+	sv internal.StockService
+}
+
+func NewDefaultStock(sv internal.StockService) *DefaultStock {
+	return &DefaultStock{
+		sv: sv,
+	}
+}
+
+type StockMovementJSON struct {
+	ID        string `json:"id"`
+	Time      string `json:"time"`
+	Type      string `json:"type"`
+	Delta     int    `json:"delta"`
+	Balance   int    `json:"balance"`
+	Reason    string `json:"reason"`
+	Principal string `json:"principal"`
+	RequestID string `json:"request_id"`
+}
+
+type BodyStockMovementJSON struct {
+	Type     string `json:"type"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+}
+
+// Move records a stock movement of a product and applies it to its quantity
+func (s *DefaultStock) Move() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var body BodyStockMovementJSON
+		if err = request.JSONWith(r, &body, request.JSONOptions{Schema: schemaBodyStockMovement}); err != nil {
+			bodyError(w, r, err)
+			return
+		}
+
+		//process
+		movement, err := s.sv.Move(r.Context(), id, body.Type, body.Quantity, body.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductNotFound):
+				response.Text(w, http.StatusNotFound, "Product not found")
+			case errors.Is(err, internal.ErrStockMovementInvalid):
+				response.Text(w, http.StatusBadRequest, "Invalid stock movement")
+			case errors.Is(err, internal.ErrStockInsufficient):
+				response.Text(w, http.StatusConflict, "Insufficient stock")
+			default:
+				slog.ErrorContext(r.Context(), "move stock", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		response.JSON(w, http.StatusCreated, map[string]any{
+			"message": "success",
+			"data":    stockMovementJSON(movement),
+		})
+	}
+}
+
+// History returns the stock movements of a product, oldest first
+func (s *DefaultStock) History() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			response.Text(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		//process
+		movements, err := s.sv.History(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, internal.ErrProductNotFound):
+				response.Text(w, http.StatusNotFound, "Product not found")
+			default:
+				slog.ErrorContext(r.Context(), "stock history", "error", err)
+				response.Text(w, http.StatusInternalServerError, "Internal Server Error")
+			}
+			return
+		}
+
+		//response
+		data := make([]StockMovementJSON, 0, len(movements))
+		for _, m := range movements {
+			data = append(data, stockMovementJSON(m))
+		}
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    data,
+		})
+	}
+}
+
+// stockMovementJSON serializes a stock movement
+func stockMovementJSON(m internal.StockMovement) StockMovementJSON {
+	return StockMovementJSON{
+		ID:        m.ID,
+		Time:      m.Time.Format(time.RFC3339Nano),
+		Type:      m.Type,
+		Delta:     m.Delta,
+		Balance:   m.Balance,
+		Reason:    m.Reason,
+		Principal: m.Principal,
+		RequestID: m.RequestID,
+	}
+}
@@ -0,0 +1,216 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/handler"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultStock(t *testing.T) {
+	// newHandlers returns the product and stock handlers over a catalog with the product 1,
+	// which predates the ledger with 10 units
+	newHandlers := func(t *testing.T) (*handler.DefaultProducts, *handler.DefaultStock) {
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S6611", Is_published: true, Expiration: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 1000, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 1))
+		st := service.NewStockDefault(repository.NewStockJSONL(t.TempDir()+"/stock.jsonl"), repository.NewReservationMap(), sv)
+		return handler.NewDefaultProducts(service.NewProductStock(sv, st)), handler.NewDefaultStock(st)
+	}
+	// withID sets the chi url param id
+	withID := func(req *http.Request, id string) *http.Request {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+	// move posts a stock movement of the product id
+	move := func(hd *handler.DefaultStock, id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/products/"+id+"/stock-movements", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		hd.Move()(res, withID(req, id))
+		return res
+	}
+	// history returns the stock history of the product id
+	history := func(hd *handler.DefaultStock, id string) (code int, movements []handler.StockMovementJSON) {
+		res := httptest.NewRecorder()
+		hd.History()(res, withID(httptest.NewRequest("GET", "/products/"+id+"/stock-history", nil), id))
+		var body struct {
+			Data []handler.StockMovementJSON `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		return res.Code, body.Data
+	}
+	// quantity returns the quantity of the product id
+	quantity := func(hd *handler.DefaultProducts, id string) int {
+		res := httptest.NewRecorder()
+		hd.GetByID()(res, withID(httptest.NewRequest("GET", "/products/"+id, nil), id))
+		var body struct {
+			Data handler.ProductJSON `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		return body.Data.Quantity
+	}
+
+	t.Run("success 01 - should apply the movements to the quantity and keep them in order", func(t *testing.T) {
+		// arrange
+		hdProducts, hdStock := newHandlers(t)
+
+		// act
+		receipt := move(hdStock, "1", `{"type":"receipt","quantity":5,"reason":"purchase order 7"}`)
+		sale := move(hdStock, "1", `{"type":"sale","quantity":3,"reason":"order 12"}`)
+		adjustment := move(hdStock, "1", `{"type":"adjustment","quantity":-2,"reason":"damaged"}`)
+		code, movements := history(hdStock, "1")
+
+		// assert
+		require.Equal(t, http.StatusCreated, receipt.Code)
+		require.Equal(t, http.StatusCreated, sale.Code)
+		require.Equal(t, http.StatusCreated, adjustment.Code)
+		require.Equal(t, 10, quantity(hdProducts, "1"))
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, movements, 4)
+		// the quantity before the ledger is its opening balance
+		require.Equal(t, "opening balance", movements[0].Reason)
+		deltas, balances := []int{}, []int{}
+		for _, m := range movements {
+			deltas = append(deltas, m.Delta)
+			balances = append(balances, m.Balance)
+		}
+		require.Equal(t, []int{10, 5, -3, -2}, deltas)
+		require.Equal(t, []int{10, 15, 12, 10}, balances)
+	})
+
+	t.Run("success 02 - should record the quantity of a new product as a receipt", func(t *testing.T) {
+		// arrange
+		hdProducts, hdStock := newHandlers(t)
+		product := `{"name":"Product 2","quantity":7,"code_value":"S6612","is_published":true,"expiration":"01/12/2099","price":10}`
+		req := httptest.NewRequest("POST", "/products", strings.NewReader(product))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+
+		// act
+		hdProducts.Create()(res, req)
+		code, movements := history(hdStock, "2")
+
+		// assert
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, movements, 1)
+		require.Equal(t, internal.StockMovementReceipt, movements[0].Type)
+		require.Equal(t, 7, movements[0].Balance)
+	})
+
+	t.Run("success 03 - should derive the quantity from the ledger when the stored copy falls behind", func(t *testing.T) {
+		// arrange
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, Code_value: "S6611", Is_published: true, Expiration: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 1000, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 1))
+		st := service.NewStockDefault(repository.NewStockJSONL(t.TempDir()+"/stock.jsonl"), repository.NewReservationMap(), sv)
+		hdProducts, hdStock := handler.NewDefaultProducts(service.NewProductStock(sv, st)), handler.NewDefaultStock(st)
+		sale := move(hdStock, "1", `{"type":"sale","quantity":4,"reason":"order 12"}`)
+		db[1].Quantity = 99
+
+		// act
+		got := quantity(hdProducts, "1")
+		oversold := move(hdStock, "1", `{"type":"sale","quantity":7,"reason":"order 13"}`)
+
+		// assert
+		require.Equal(t, http.StatusCreated, sale.Code)
+		require.Equal(t, 6, got)
+		require.Equal(t, http.StatusConflict, oversold.Code)
+	})
+
+	t.Run("fail 01 - should reject a sale of more units than in stock", func(t *testing.T) {
+		// arrange
+		hdProducts, hdStock := newHandlers(t)
+
+		// act
+		res := move(hdStock, "1", `{"type":"sale","quantity":11,"reason":"order 12"}`)
+
+		// assert
+		require.Equal(t, http.StatusConflict, res.Code)
+		require.Equal(t, "Insufficient stock", res.Body.String())
+		require.Equal(t, 10, quantity(hdProducts, "1"))
+	})
+
+	t.Run("fail 02 - should reject an invalid movement", func(t *testing.T) {
+		// arrange
+		_, hdStock := newHandlers(t)
+
+		// act
+		unknown := move(hdStock, "1", `{"type":"theft","quantity":1,"reason":"?"}`)
+		negative := move(hdStock, "1", `{"type":"sale","quantity":-1,"reason":"order 12"}`)
+		missing := move(hdStock, "2", `{"type":"receipt","quantity":1,"reason":"purchase order 7"}`)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, unknown.Code)
+		require.Equal(t, http.StatusBadRequest, negative.Code)
+		require.Equal(t, http.StatusNotFound, missing.Code)
+	})
+
+	t.Run("fail 03 - should reject product writes that change the quantity", func(t *testing.T) {
+		// arrange
+		hdProducts, _ := newHandlers(t)
+		product := `{"name":"Product 1","quantity":99,"code_value":"S6611","is_published":true,"expiration":"01/01/2099","price":10}`
+		reqPut := httptest.NewRequest("PUT", "/products/1", strings.NewReader(product))
+		reqPut.Header.Set("Content-Type", "application/json")
+		reqPatch := httptest.NewRequest("PATCH", "/products/1", strings.NewReader(`{"quantity":99}`))
+		reqPatch.Header.Set("Content-Type", "application/json")
+		put, patch := httptest.NewRecorder(), httptest.NewRecorder()
+
+		// act
+		hdProducts.UpdateOrCreate()(put, withID(reqPut, "1"))
+		hdProducts.Update()(patch, withID(reqPatch, "1"))
+
+		// assert
+		require.Equal(t, http.StatusConflict, put.Code)
+		require.Equal(t, http.StatusConflict, patch.Code)
+		require.Equal(t, 10, quantity(hdProducts, "1"))
+	})
+
+	t.Run("fail 04 - should not return the history of an unknown product", func(t *testing.T) {
+		// arrange
+		_, hdStock := newHandlers(t)
+
+		// act
+		code, _ := history(hdStock, "2")
+
+		// assert
+		require.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("fail 05 - should reject product writes that change the quantity of a variant", func(t *testing.T) {
+		// arrange
+		hdProducts, _ := newHandlers(t)
+		put := func(variants string) *httptest.ResponseRecorder {
+			product := `{"name":"Product 1","quantity":10,"code_value":"S6611","is_published":true,"expiration":"01/01/2099","price":10,"variants":` + variants + `}`
+			req := httptest.NewRequest("PUT", "/products/1", strings.NewReader(product))
+			req.Header.Set("Content-Type", "application/json")
+			res := httptest.NewRecorder()
+			hdProducts.UpdateOrCreate()(res, withID(req, "1"))
+			return res
+		}
+
+		// act
+		added := put(`[{"code_value":"S6611L","quantity":3,"price":10}]`)
+		kept := put(`[{"code_value":"S6611L","quantity":3,"price":12}]`)
+		changed := put(`[{"code_value":"S6611L","quantity":30,"price":12}]`)
+
+		// assert
+		require.Equal(t, http.StatusOK, added.Code)
+		require.Equal(t, http.StatusOK, kept.Code)
+		require.Equal(t, http.StatusConflict, changed.Code)
+	})
+}
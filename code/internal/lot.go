@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrLotInvalid is returned when a lot has no code, no units, an expiration in the past,
+	// or the code of a lot of the product with another expiration
+	ErrLotInvalid = errors.New("lot invalid")
+	// ErrLotNotFound is returned when a product has no lot with the code, e.g. one already written off
+	ErrLotNotFound = errors.New("lot not found")
+)
+
+// Lot is a batch of units of a product received together and sharing an expiration.
+// Sales and other outgoing movements take the units of the lots that expire first (FEFO).
+type Lot struct {
+	// Tenant is the tenant whose catalog the product belongs to
+	Tenant string
+	// ProductID is the id of the product
+	ProductID int
+	// Code identifies the lot among those of the product, e.g. the batch number of the supplier
+	Code string
+	// Quantity is the number of units of the lot still in stock
+	Quantity int
+	// Expiration is when the units of the lot can no longer be sold
+	Expiration time.Time
+	// Received is when the first units of the lot were received
+	Received time.Time
+}
+
+// Expired reports whether the units of the lot can no longer be sold at now
+func (l Lot) Expired(now time.Time) bool {
+	return !now.Before(l.Expiration)
+}
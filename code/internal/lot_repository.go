@@ -0,0 +1,17 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type LotRepository interface {
+	// Returns the lots of a product of a tenant with units left, first expiring first
+	Find(ctx context.Context, tenant string, productID int) (lots []Lot, err error)
+
+	// Replaces the lots of a product of a tenant, dropping those without units left
+	Replace(ctx context.Context, tenant string, productID int, lots []Lot) (err error)
+
+	// Returns the lots of a tenant with units left that expired at now, first expiring first
+	Expired(ctx context.Context, tenant string, now time.Time) (lots []Lot, err error)
+}
@@ -0,0 +1,12 @@
+package internal
+
+import "context"
+
+type LotService interface {
+	// Returns the lots of a product with units left, first expiring first
+	GetAll(ctx context.Context, productID int) (lots []Lot, err error)
+
+	// Receives units of a product in a lot, recording them as a receipt in the stock ledger.
+	// Units received again in a lot add to it, returned with its resulting quantity.
+	Receive(ctx context.Context, productID int, lot Lot) (received Lot, err error)
+}
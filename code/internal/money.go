@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// CurrencyDefault is the currency of the prices stored or sent without one
+const CurrencyDefault = "USD"
+
+// Currencies are the ISO 4217 currencies supported, with the number of digits of their minor unit
+var Currencies = map[string]int{
+	"ARS": 2,
+	"AUD": 2,
+	"BHD": 3,
+	"BRL": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CLP": 0,
+	"CNY": 2,
+	"COP": 2,
+	"DKK": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"MXN": 2,
+	"NOK": 2,
+	"PEN": 2,
+	"SEK": 2,
+	"USD": 2,
+	"UYU": 2,
+}
+
+var (
+	// ErrCurrencyUnknown is returned when a currency is not an ISO 4217 code supported
+	ErrCurrencyUnknown = errors.New("currency unknown")
+	// ErrCurrencyMismatch is returned when amounts in different currencies are added up
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrMoneyInvalid is returned when an amount is not a finite number or overflows its minor units
+	ErrMoneyInvalid = errors.New("money invalid")
+)
+
+// Rounding is how an amount is rounded to the minor unit of its currency
+type Rounding string
+
+const (
+	// RoundHalfUp rounds to the nearest minor unit, and halves away from zero
+	RoundHalfUp Rounding = "half_up"
+	// RoundHalfEven rounds to the nearest minor unit, and halves to the even one
+	RoundHalfEven Rounding = "half_even"
+	// RoundDown rounds towards zero
+	RoundDown Rounding = "down"
+	// RoundUp rounds away from zero
+	RoundUp Rounding = "up"
+)
+
+// Valid reports whether the rounding is one of the supported
+func (r Rounding) Valid() bool {
+	switch r {
+	case RoundHalfUp, RoundHalfEven, RoundDown, RoundUp:
+		return true
+	}
+	return false
+}
+
+// Round rounds x to an integer
+func (r Rounding) Round(x *big.Rat) (n int64, err error) {
+	num, den := new(big.Int).Set(x.Num()), x.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		// cmp compares the remainder against half the denominator
+		cmp := new(big.Int).Lsh(rem, 1).Cmp(den)
+		switch {
+		case r == RoundUp,
+			r == RoundHalfUp && cmp >= 0,
+			r == RoundHalfEven && (cmp > 0 || cmp == 0 && q.Bit(0) == 1):
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		err = fmt.Errorf("%w: %s overflows", ErrMoneyInvalid, x.FloatString(4))
+		return
+	}
+	n = q.Int64()
+	if neg {
+		n = -n
+	}
+	return
+}
+
+// Digits returns the number of digits of the minor unit of a currency
+func Digits(currency string) (digits int, err error) {
+	digits, ok := Currencies[currency]
+	if !ok {
+		err = fmt.Errorf("%w: %q", ErrCurrencyUnknown, currency)
+	}
+	return
+}
+
+// Money is an amount of a currency, kept in minor units so that it adds up exactly
+type Money struct {
+	// Amount is the number of minor units, e.g. cents
+	Amount int64
+	// Currency is the ISO 4217 code of the currency
+	Currency string
+}
+
+// NewMoney returns the amount in major units of a currency, e.g. 19.99 USD, rounded half up to the minor unit.
+// The amount is taken as the shortest decimal that prints it, so 0.29 is 29 cents and not 28.
+func NewMoney(major float64, currency string) (m Money, err error) {
+	if math.IsNaN(major) || math.IsInf(major, 0) {
+		err = fmt.Errorf("%w: %v", ErrMoneyInvalid, major)
+		return
+	}
+	x, _ := new(big.Rat).SetString(strconv.FormatFloat(major, 'f', -1, 64))
+	return FromRat(x, currency, RoundHalfUp)
+}
+
+// FromRat returns the amount in major units of a currency, rounded to the minor unit
+func FromRat(major *big.Rat, currency string, rounding Rounding) (m Money, err error) {
+	digits, err := Digits(currency)
+	if err != nil {
+		return
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	amount, err := rounding.Round(new(big.Rat).Mul(major, new(big.Rat).SetInt(scale)))
+	if err != nil {
+		return
+	}
+	m = Money{Amount: amount, Currency: currency}
+	return
+}
+
+// Rat returns the exact amount in major units
+func (m Money) Rat() *big.Rat {
+	digits := Currencies[m.Currency]
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	return new(big.Rat).SetFrac(big.NewInt(m.Amount), scale)
+}
+
+// Major returns the amount in major units, e.g. 19.99 for 1999 cents
+func (m Money) Major() float64 {
+	f, _ := m.Rat().Float64()
+	return f
+}
+
+// Times returns the amount multiplied by n, e.g. the price of n units
+func (m Money) Times(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Add returns the sum of the amounts, which must be in the same currency.
+// A zero amount without currency is taken as in any currency.
+func (m Money) Add(o Money) (sum Money, err error) {
+	switch {
+	case m.Currency == "":
+		sum = Money{Amount: m.Amount + o.Amount, Currency: o.Currency}
+	case o.Currency == "" || o.Currency == m.Currency:
+		sum = Money{Amount: m.Amount + o.Amount, Currency: m.Currency}
+	default:
+		err = fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return
+}
+
+// String returns the amount in major units followed by the currency, e.g. "19.99 USD"
+func (m Money) String() string {
+	return m.Rat().FloatString(Currencies[m.Currency]) + " " + m.Currency
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// OrderStatePending is an order placed and not paid yet; its units are already taken from the stock
+	OrderStatePending = "pending"
+	// OrderStatePaid is an order paid and waiting to be shipped
+	OrderStatePaid = "paid"
+	// OrderStateShipped is an order handed to the carrier, which can no longer change
+	OrderStateShipped = "shipped"
+	// OrderStateCancelled is an order whose units were given back to the stock
+	OrderStateCancelled = "cancelled"
+)
+
+var (
+	// ErrOrderNotFound is returned when an order does not exist
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrOrderInvalid is returned when an order has no lines, a wrong quantity or a product that cannot be sold
+	ErrOrderInvalid = errors.New("order invalid")
+	// ErrOrderTransition is returned when an order cannot move from its state to the requested one
+	ErrOrderTransition = errors.New("order transition not allowed")
+)
+
+// orderTransitions are the states an order can move to from each state
+var orderTransitions = map[string][]string{
+	OrderStatePending: {OrderStatePaid, OrderStateCancelled},
+	OrderStatePaid:    {OrderStateShipped, OrderStateCancelled},
+}
+
+// OrderTransition reports whether an order can move from one state to another
+func OrderTransition(from, to string) bool {
+	for _, v := range orderTransitions[from] {
+		if v == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderLine is a product of an order, with its name and price as they were when the order was placed
+type OrderLine struct {
+	// ProductID is the id of the product
+	ProductID int
+	// Name is the name of the product at order time
+	Name string
+	// Quantity is the number of units
+	Quantity int
+	// UnitPrice is the list price of the product at order time
+	UnitPrice Money
+	// Total is the price of the units at order time, with the promotions; zero in the orders
+	// placed before promotions, which were charged the unit price
+	Total Money
+	// Promotions are the ids of the promotions that lowered the price, in the order they applied
+	Promotions []int
+}
+
+// Subtotal returns the price of the line
+func (l OrderLine) Subtotal() Money {
+	if l.Total.Currency != "" {
+		return l.Total
+	}
+	return l.UnitPrice.Times(l.Quantity)
+}
+
+// Order is a sale of products of the catalog of a tenant
+type Order struct {
+	// ID identifies the order
+	ID int
+	// Tenant is the tenant whose catalog the products belong to
+	Tenant string
+	// Principal is who placed the order, as reported by the auth layer
+	Principal string
+	// State is where the order is in its lifecycle
+	State string
+	// Lines are the products of the order, one line per product, all priced in the same currency
+	Lines []OrderLine
+	// Created is when the order was placed
+	Created time.Time
+	// Updated is when the order last changed state
+	Updated time.Time
+}
+
+// Total returns the price of the order, in the currency of its lines
+func (o Order) Total() (total Money) {
+	for _, l := range o.Lines {
+		total.Amount += l.Subtotal().Amount
+		total.Currency = l.UnitPrice.Currency
+	}
+	return
+}
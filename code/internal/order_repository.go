@@ -0,0 +1,17 @@
+package internal
+
+import "context"
+
+type OrderRepository interface {
+	// Returns the orders of a tenant
+	GetAll(ctx context.Context, tenant string) (orders []Order, err error)
+
+	// Returns an order of a tenant
+	GetByID(ctx context.Context, tenant string, id int) (order Order, err error)
+
+	// Stores a new order, setting its id
+	Create(ctx context.Context, order *Order) (err error)
+
+	// Replaces an order
+	Update(ctx context.Context, order Order) (err error)
+}
@@ -0,0 +1,17 @@
+package internal
+
+import "context"
+
+type OrderService interface {
+	// Returns the orders, in id order
+	GetAll(ctx context.Context) (orders []Order, err error)
+
+	// Returns an order
+	GetByID(ctx context.Context, id int) (order Order, err error)
+
+	// Places an order of the product ids and quantities of the lines, taking the units from the stock
+	Create(ctx context.Context, lines []OrderLine) (order Order, err error)
+
+	// Moves an order to another state; cancelling gives its units back to the stock
+	Transition(ctx context.Context, id int, state string) (order Order, err error)
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPriceScheduleInvalid is returned when a price change is scheduled with a negative price or not in the future
+	ErrPriceScheduleInvalid = errors.New("price schedule invalid")
+	// ErrPriceScheduleNotFound is returned when a scheduled price change does not exist, or was already applied
+	ErrPriceScheduleNotFound = errors.New("price schedule not found")
+	// ErrPriceRecord is returned when a price change cannot be appended to the history
+	ErrPriceRecord = errors.New("price record")
+)
+
+// PriceChange is an immutable entry of the price history of a product
+type PriceChange struct {
+	// Time is the moment the price changed
+	Time time.Time
+	// Tenant is the tenant whose catalog the product belongs to
+	Tenant string
+	// Principal is who changed the price, or who scheduled the change, as reported by the auth layer
+	Principal string
+	// RequestID is the id of the request that changed the price, empty for the scheduled changes
+	RequestID string
+	// ProductID is the id of the product
+	ProductID int
+	// Old is the price before the change, zero when the product was created
+	Old Money
+	// New is the price after the change
+	New Money
+	// ScheduleID is the id of the scheduled change applied, empty for the direct changes
+	ScheduleID string
+}
+
+// PriceSchedule is a change of the price of a product to apply at a future time
+type PriceSchedule struct {
+	// ID identifies the scheduled change
+	ID string
+	// Tenant is the tenant whose catalog the product belongs to
+	Tenant string
+	// Principal is who scheduled the change, as reported by the auth layer
+	Principal string
+	// ProductID is the id of the product
+	ProductID int
+	// Price is the price the product takes, in its currency
+	Price Money
+	// Effective is when the product takes the price
+	Effective time.Time
+	// Created is when the change was scheduled
+	Created time.Time
+}
@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type PriceHistoryRepository interface {
+	// Appends a change to the history
+	Append(ctx context.Context, change PriceChange) (err error)
+
+	// Returns the changes of the price of a product of a tenant, oldest first
+	Find(ctx context.Context, tenant string, productID int) (changes []PriceChange, err error)
+}
+
+type PriceScheduleRepository interface {
+	// Returns the pending changes of a product of a tenant, first effective first
+	Find(ctx context.Context, tenant string, productID int) (schedules []PriceSchedule, err error)
+
+	// Stores a scheduled change
+	Create(ctx context.Context, schedule PriceSchedule) (err error)
+
+	// Removes a scheduled change of a tenant
+	Delete(ctx context.Context, tenant string, id string) (err error)
+
+	// Returns the pending changes of a tenant effective at now, first effective first
+	Due(ctx context.Context, tenant string, now time.Time) (schedules []PriceSchedule, err error)
+}
@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type PriceService interface {
+	// Returns the changes of the price of a product, oldest first
+	History(ctx context.Context, productID int) (changes []PriceChange, err error)
+
+	// Returns the pending changes of the price of a product, first effective first
+	Scheduled(ctx context.Context, productID int) (schedules []PriceSchedule, err error)
+
+	// Schedules a change of the price of a product, in major units of its currency, applied once effective
+	Schedule(ctx context.Context, productID int, price float64, effective time.Time) (schedule PriceSchedule, err error)
+
+	// Cancels a pending change of the price of a product
+	Cancel(ctx context.Context, productID int, id string) (err error)
+
+	// Applies the pending changes of every tenant that are effective, returning the number applied
+	Apply(ctx context.Context) (n int, err error)
+}
@@ -9,5 +9,67 @@ type Product struct {
 	Code_value   string
 	Is_published bool
 	Expiration   time.Time
-	Price        float64
+	// Price is the price of a unit, in the currency the product is sold in
+	Price Money
+	// Reserved is the part of the quantity held by reservations, filled on reads and never stored
+	Reserved int
+	// Attributes are the custom properties of the product, e.g. its brand or volume
+	Attributes []Attribute
+	// Variants are the SKUs the product is sold as, e.g. its sizes or colors
+	Variants []Variant
+}
+
+// Available returns the units that can still be sold or reserved
+func (p Product) Available() int {
+	return max(p.Quantity-p.Reserved, 0)
+}
+
+// Expired reports whether the product can no longer be sold at now
+func (p Product) Expired(now time.Time) bool {
+	return !now.Before(p.Expiration)
+}
+
+// AttributeType is the type of the value of an attribute
+type AttributeType string
+
+const (
+	AttributeString  AttributeType = "string"
+	AttributeNumber  AttributeType = "number"
+	AttributeBoolean AttributeType = "boolean"
+)
+
+// Attribute is a custom, typed property of a product or of a variant
+type Attribute struct {
+	Name string
+	Type AttributeType
+	// Value is a string, a float64 or a bool, as the type says
+	Value any
+}
+
+// Valid reports whether the value of the attribute is of its type
+func (a Attribute) Valid() bool {
+	switch a.Type {
+	case AttributeString:
+		_, ok := a.Value.(string)
+		return ok
+	case AttributeNumber:
+		_, ok := a.Value.(float64)
+		return ok
+	case AttributeBoolean:
+		_, ok := a.Value.(bool)
+		return ok
+	}
+	return false
+}
+
+// Variant is a SKU of a product, with its own code, stock and price.
+// Its quantity is set when the variant is added and cannot change afterwards:
+// the stock movements, reservations and orders apply to the product itself.
+type Variant struct {
+	Code_value string
+	Quantity   int
+	// Price is in the currency of the product
+	Price Money
+	// Attributes tell the variant apart from the others, e.g. {size: "L"}
+	Attributes []Attribute
 }
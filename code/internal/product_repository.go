@@ -1,6 +1,10 @@
 package internal
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 var (
 	ErrProductNotFound = errors.New("product not found")
@@ -8,20 +12,26 @@ var (
 
 type ProductRepository interface {
 	// Returns all products
-	GetAll() (products map[int]*Product, err error)
+	GetAll(ctx context.Context) (products map[int]*Product, err error)
+
+	// Calls fn with every product in id order, one at a time, stopping at the first error
+	Each(ctx context.Context, fn func(product *Product) error) (err error)
 
 	// Returns a product by ID
-	GetByID(id int) (product *Product, err error)
+	GetByID(ctx context.Context, id int) (product *Product, err error)
 
 	// Creates a new product
-	Create(product *Product) (err error)
+	Create(ctx context.Context, product *Product) (err error)
 
 	// Updates a product
-	UpdateOrCreate(product *Product) (prod Product, err error)
+	UpdateOrCreate(ctx context.Context, product *Product) (prod Product, err error)
 
 	// Updates a product
-	Update(product *Product) (err error)
+	Update(ctx context.Context, product *Product) (err error)
 
 	// Deletes a product
-	Delete(id int) (err error)
+	Delete(ctx context.Context, id int) (err error)
+
+	// Returns when the products last changed, zero if unknown
+	LastModified(ctx context.Context) (t time.Time, err error)
 }
@@ -1,31 +1,48 @@
 package internal
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 var (
 	ErrFieldRequired        = errors.New("field required")
 	ErrValidateQualityField = errors.New("validate quality field")
+	// ErrProductAttributeInvalid is returned when an attribute has no name, a repeated name or a value not of its type
+	ErrProductAttributeInvalid = errors.New("product attribute invalid")
+	// ErrProductVariantInvalid is returned when a variant has a malformed or repeated code, or a negative quantity or price
+	ErrProductVariantInvalid = errors.New("product variant invalid")
 )
 
 type ProductService interface {
 	// Returns all products
-	GetAll() (products map[int]*Product, err error)
+	GetAll(ctx context.Context) (products map[int]*Product, err error)
+
+	// Calls fn with every product in id order, one at a time, stopping at the first error
+	Each(ctx context.Context, fn func(product *Product) error) (err error)
 
 	// Returns a product by ID
-	GetByID(id int) (product *Product, err error)
+	GetByID(ctx context.Context, id int) (product *Product, err error)
 
-	// Returns a product by price
-	SearchByPrice(price float64) (products map[int]*Product, err error)
+	// Returns the products priced at or above price, compared in its currency
+	SearchByPrice(ctx context.Context, price Money) (products map[int]*Product, err error)
 
 	// Creates a new product
-	Create(product *Product) (err error)
+	Create(ctx context.Context, product *Product) (err error)
 
 	// Updates or creates a product
-	UpdateOrCreate(product *Product) (prod Product, err error)
+	UpdateOrCreate(ctx context.Context, product *Product) (prod Product, err error)
 
 	// Updates a product
-	Update(product *Product) (err error)
+	Update(ctx context.Context, product *Product) (err error)
 
 	// Deletes a product
-	Delete(id int) (err error)
+	Delete(ctx context.Context, id int) (err error)
+
+	// Unpublishes a product, even an expired one, which could not be updated otherwise
+	Unpublish(ctx context.Context, id int) (err error)
+
+	// Returns when the products last changed, zero if unknown
+	LastModified(ctx context.Context) (t time.Time, err error)
 }
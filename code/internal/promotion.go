@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrPromotionNotFound is returned when a promotion does not exist
+	ErrPromotionNotFound = errors.New("promotion not found")
+	// ErrPromotionInvalid is returned when a promotion has no name, a malformed discount or condition,
+	// or a date window that ends before it starts
+	ErrPromotionInvalid = errors.New("promotion invalid")
+	// ErrPricingQuantity is returned when a product is priced for less than one unit
+	ErrPricingQuantity = errors.New("pricing quantity invalid")
+)
+
+// PromotionKind is the discount a promotion gives
+type PromotionKind string
+
+const (
+	// PromotionPercent takes a percentage off the price of every unit
+	PromotionPercent PromotionKind = "percent"
+	// PromotionAmount takes an amount off the price of every unit, down to zero
+	PromotionAmount PromotionKind = "amount"
+	// PromotionBuyGet gives units for free for every units bought, e.g. buy 2 get 1
+	PromotionBuyGet PromotionKind = "buy_get"
+)
+
+// Promotion is a discount rule of the catalog of a tenant. It applies to the products that meet
+// all its conditions while it is active, in order of priority.
+type Promotion struct {
+	// ID identifies the promotion
+	ID int
+	// Tenant is the tenant whose catalog the promotion belongs to
+	Tenant string
+	// Name is the display name, e.g. "10% off premium wines"
+	Name string
+	// Kind is the discount given, which the fields below configure
+	Kind PromotionKind
+	// Percent is the percentage taken off by a percent promotion, up to 100
+	Percent float64
+	// Amount is the amount taken off by an amount promotion; it only applies to products priced in its currency
+	Amount Money
+	// Buy and Get are the units bought and the units given for free by a buy_get promotion
+	Buy int
+	Get int
+	// Conditions are what a product must meet for the promotion to apply, all of them; none matches every product
+	Conditions []PromotionCondition
+	// Starts is when the promotion becomes active, zero if it always was
+	Starts time.Time
+	// Ends is when the promotion stops being active, zero if it never does
+	Ends time.Time
+	// Priority orders the promotions of a product, the highest first; ties go by id
+	Priority int
+	// Exclusive stops the promotions of lower priority from applying after it
+	Exclusive bool
+}
+
+// Active reports whether the promotion applies at now
+func (p Promotion) Active(now time.Time) bool {
+	if !p.Starts.IsZero() && now.Before(p.Starts) {
+		return false
+	}
+	if !p.Ends.IsZero() && !now.Before(p.Ends) {
+		return false
+	}
+	return true
+}
+
+// the fields of a product a condition can be on; an attribute is named "attributes.<name>"
+const (
+	PromotionFieldPrice       = "price"
+	PromotionFieldQuantity    = "quantity"
+	PromotionFieldName        = "name"
+	PromotionFieldCodeValue   = "code_value"
+	PromotionFieldIsPublished = "is_published"
+	PromotionFieldCategory    = "category"
+	PromotionFieldAttributes  = "attributes."
+)
+
+// PromotionOperator compares the field of a product against the value of a condition
+type PromotionOperator string
+
+const (
+	PromotionEq  PromotionOperator = "eq"
+	PromotionNe  PromotionOperator = "ne"
+	PromotionGt  PromotionOperator = "gt"
+	PromotionGte PromotionOperator = "gte"
+	PromotionLt  PromotionOperator = "lt"
+	PromotionLte PromotionOperator = "lte"
+	// PromotionIn matches if the field equals any of the values of a list
+	PromotionIn PromotionOperator = "in"
+)
+
+// PromotionCondition is a comparison of a field of a product, e.g. {price gt 200 USD}.
+// The price is compared in major units of the currency of the condition, and only the products priced
+// in it meet the condition. The category matches the products in the category with the slug or in any
+// of its descendants.
+type PromotionCondition struct {
+	Field    string
+	Operator PromotionOperator
+	// Value is a string, a float64 or a bool, or a list of them for the in operator
+	Value any
+	// Currency is the currency of the value of a price condition, the default one if empty
+	Currency string
+}
+
+// Valid reports whether the condition compares a known field with a value of its type,
+// and only a price in a known currency
+func (c PromotionCondition) Valid() bool {
+	if c.Currency != "" {
+		if _, err := Digits(c.Currency); err != nil || c.Field != PromotionFieldPrice {
+			return false
+		}
+	}
+	values := []any{c.Value}
+	switch c.Operator {
+	case PromotionEq, PromotionNe:
+	case PromotionGt, PromotionGte, PromotionLt, PromotionLte:
+		if _, ok := c.Value.(float64); !ok {
+			return false
+		}
+	case PromotionIn:
+		list, ok := c.Value.([]any)
+		if !ok || len(list) == 0 {
+			return false
+		}
+		values = list
+	default:
+		return false
+	}
+
+	for _, v := range values {
+		var ok bool
+		switch {
+		case c.Field == PromotionFieldPrice, c.Field == PromotionFieldQuantity:
+			_, ok = v.(float64)
+		case c.Field == PromotionFieldName, c.Field == PromotionFieldCodeValue:
+			_, ok = v.(string)
+		case c.Field == PromotionFieldIsPublished:
+			_, ok = v.(bool)
+		case c.Field == PromotionFieldCategory:
+			// a product is in a category or not: there is no order to compare
+			slug, isString := v.(string)
+			ok = isString && ValidCategorySlug(slug) && (c.Operator == PromotionEq || c.Operator == PromotionNe || c.Operator == PromotionIn)
+		case strings.HasPrefix(c.Field, PromotionFieldAttributes) && len(c.Field) > len(PromotionFieldAttributes):
+			switch v.(type) {
+			case string, float64, bool:
+				ok = true
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Match reports whether a product meets the condition; in tells whether it is in the category with a slug.
+// A product without the attribute of a condition does not meet it.
+func (c PromotionCondition) Match(p Product, in func(slug string) bool) bool {
+	var field any
+	switch {
+	case c.Field == PromotionFieldPrice:
+		currency := c.Currency
+		if currency == "" {
+			currency = CurrencyDefault
+		}
+		if p.Price.Currency != currency {
+			return false
+		}
+		field = p.Price.Major()
+	case c.Field == PromotionFieldQuantity:
+		field = float64(p.Quantity)
+	case c.Field == PromotionFieldName:
+		field = p.Name
+	case c.Field == PromotionFieldCodeValue:
+		field = p.Code_value
+	case c.Field == PromotionFieldIsPublished:
+		field = p.Is_published
+	case c.Field == PromotionFieldCategory:
+		// the product is compared against each category: "ne" holds if it is not in it
+		match := func(v any) bool {
+			slug, _ := v.(string)
+			return in(slug)
+		}
+		switch c.Operator {
+		case PromotionEq:
+			return match(c.Value)
+		case PromotionNe:
+			return !match(c.Value)
+		case PromotionIn:
+			list, _ := c.Value.([]any)
+			for _, v := range list {
+				if match(v) {
+					return true
+				}
+			}
+		}
+		return false
+	default:
+		name := strings.TrimPrefix(c.Field, PromotionFieldAttributes)
+		for _, a := range p.Attributes {
+			if a.Name == name {
+				field = a.Value
+			}
+		}
+		if field == nil {
+			return false
+		}
+	}
+
+	switch c.Operator {
+	case PromotionEq:
+		return field == c.Value
+	case PromotionNe:
+		return field != c.Value
+	case PromotionIn:
+		list, _ := c.Value.([]any)
+		for _, v := range list {
+			if field == v {
+				return true
+			}
+		}
+		return false
+	}
+	f, ok := field.(float64)
+	v, _ := c.Value.(float64)
+	if !ok {
+		return false
+	}
+	switch c.Operator {
+	case PromotionGt:
+		return f > v
+	case PromotionGte:
+		return f >= v
+	case PromotionLt:
+		return f < v
+	case PromotionLte:
+		return f <= v
+	}
+	return false
+}
+
+// Quote is the price of a quantity of units of a product once its promotions apply
+type Quote struct {
+	// Quantity is the number of units priced
+	Quantity int
+	// Unit is the list price of a unit
+	Unit Money
+	// Total is the price of all the units
+	Total Money
+	// Effective is the price of a unit, the total spread over the units
+	Effective Money
+	// Promotions are the ids of the promotions that lowered the price, in the order they applied
+	Promotions []int
+}
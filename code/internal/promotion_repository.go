@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type PromotionRepository interface {
+	// Returns the promotions of a tenant, in id order
+	GetAll(ctx context.Context, tenant string) (promotions []Promotion, err error)
+
+	// Stores a new promotion, setting its id
+	Create(ctx context.Context, promotion *Promotion) (err error)
+
+	// Replaces a promotion
+	Update(ctx context.Context, promotion Promotion) (err error)
+
+	// Removes a promotion of a tenant
+	Delete(ctx context.Context, tenant string, id int) (err error)
+
+	// Returns the last time a promotion was stored, replaced or removed, zero if never
+	LastModified(ctx context.Context) (t time.Time, err error)
+}
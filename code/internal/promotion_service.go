@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type PromotionService interface {
+	// Returns the promotions, in id order
+	GetAll(ctx context.Context) (promotions []Promotion, err error)
+
+	// Returns a promotion
+	GetByID(ctx context.Context, id int) (promotion Promotion, err error)
+
+	// Creates a promotion
+	Create(ctx context.Context, promotion *Promotion) (err error)
+
+	// Replaces a promotion
+	Update(ctx context.Context, promotion Promotion) (err error)
+
+	// Deletes a promotion
+	Delete(ctx context.Context, id int) (err error)
+}
+
+type PricingService interface {
+	// Returns a pricer of the products with the promotions active now, read once
+	// so that it prices a whole listing without reading them again
+	Pricer(ctx context.Context) (pricer Pricer, err error)
+
+	// Returns the last time the effective prices changed: a promotion was written, started or ended,
+	// or a category its conditions refer to changed; zero if never
+	LastModified(ctx context.Context) (t time.Time, err error)
+}
+
+// Pricer prices the products of a tenant with a snapshot of its promotions
+type Pricer interface {
+	// Returns the price of a quantity of units of a product, at least one
+	Quote(product Product, quantity int) (quote Quote, err error)
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets is the number of buckets kept before idle (full) ones are pruned
+const maxIdleBuckets = 10000
+
+// NewTokenBucket returns a new TokenBucket that refills rate tokens per second up to burst
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// TokenBucket is a Limiter holding one token bucket per key
+type TokenBucket struct {
+	// rate is the number of tokens added per second
+	rate float64
+	// burst is the capacity of each bucket
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+// bucket is the state of a single key
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Allow consumes one token for the key and reports whether it is allowed
+func (t *TokenBucket) Allow(key string) (res Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	b, ok := t.buckets[key]
+	if !ok {
+		if len(t.buckets) >= maxIdleBuckets {
+			t.prune(now)
+		}
+		b = &bucket{tokens: float64(t.burst), last: now}
+		t.buckets[key] = b
+	}
+
+	// refill
+	b.tokens = math.Min(float64(t.burst), b.tokens+now.Sub(b.last).Seconds()*t.rate)
+	b.last = now
+
+	res.Limit = t.burst
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = t.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = t.duration(float64(t.burst) - b.tokens)
+	return
+}
+
+// prune drops the buckets that have refilled completely, as they are equivalent to new ones
+func (t *TokenBucket) prune(now time.Time) {
+	for key, b := range t.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*t.rate >= float64(t.burst) {
+			delete(t.buckets, key)
+		}
+	}
+}
+
+// duration returns the time needed to refill n tokens
+func (t *TokenBucket) duration(n float64) time.Duration {
+	if t.rate <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(n / t.rate * float64(time.Second)))
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/ratelimit"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+const (
+	// HeaderPrefixRateLimit is the prefix of the headers reporting the state of a rate limit
+	HeaderPrefixRateLimit = "RateLimit"
+	// HeaderPrefixQuota is the prefix of the headers reporting the state of a quota
+	HeaderPrefixQuota = "X-Quota"
+)
+
+type RateLimiter struct {
+	// lm is the limiter service.
+	lm ratelimit.Limiter
+	// prefix is the prefix of the -Limit, -Remaining and -Reset headers
+	prefix string
+	// key returns the key a request is limited by
+	key func(r *http.Request) string
+}
+
+func NewRateLimiter(lm ratelimit.Limiter, prefix string) *RateLimiter {
+	return &RateLimiter{
+		lm:     lm,
+		prefix: prefix,
+		key:    Key,
+	}
+}
+
+// WithKey limits the requests by the key returned by fn instead of Key
+func (l *RateLimiter) WithKey(fn func(r *http.Request) string) *RateLimiter {
+	l.key = fn
+	return l
+}
+
+// Limit rejects with 429 Too Many Requests the requests exceeding the limit.
+// Requests are keyed by the authenticated principal, falling back to the client IP, unless
+// WithKey says otherwise, so it must run after the authenticator.
+func (l *RateLimiter) Limit(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// before
+		// consume a request
+		res := l.lm.Allow(l.key(r))
+
+		w.Header().Set(l.prefix+"-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set(l.prefix+"-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set(l.prefix+"-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			w.Header().Set("Retry-After", seconds(res.RetryAfter))
+			response.Error(w, http.StatusTooManyRequests, "Too Many Requests")
+			return
+		}
+
+		// call
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Key returns the key a request is limited by: its principal, or its client IP if anonymous
+func Key(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok && principal != "" {
+		return "principal:" + principal
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// KeyAPIKey returns the key a request is limited by: the api key it authenticated with, so keys
+// of the same principal do not share a limit, or Key for the token and client certificates
+func KeyAPIKey(r *http.Request) string {
+	if id, ok := auth.KeyIDFromContext(r.Context()); ok && id != "" {
+		return "key:" + id
+	}
+	return Key(r)
+}
+
+// seconds formats a duration as whole seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,121 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/ratelimit"
+	"github.com/rhinosc/web-market/code/internal/ratelimit/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for RateLimiter.Limit
+func TestRateLimiter_Limit(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("case 1: should reject with 429 once the bucket is empty", func(t *testing.T) {
+		// arrange
+		rl := middleware.NewRateLimiter(ratelimit.NewTokenBucket(0.5, 1), middleware.HeaderPrefixRateLimit)
+		hd := rl.Limit(next)
+
+		// act
+		first := httptest.NewRecorder()
+		hd.ServeHTTP(first, httptest.NewRequest("POST", "/products", nil))
+		second := httptest.NewRecorder()
+		hd.ServeHTTP(second, httptest.NewRequest("POST", "/products", nil))
+
+		// assert
+		require.Equal(t, http.StatusOK, first.Code)
+		require.Equal(t, "1", first.Header().Get("RateLimit-Limit"))
+		require.Equal(t, "0", first.Header().Get("RateLimit-Remaining"))
+		require.Equal(t, http.StatusTooManyRequests, second.Code)
+		require.Equal(t, "2", second.Header().Get("Retry-After"))
+		require.Equal(t, `{"status":"Too Many Requests","message":"Too Many Requests"}`, second.Body.String())
+	})
+
+	t.Run("case 2: should keep a separate bucket per principal", func(t *testing.T) {
+		// arrange
+		rl := middleware.NewRateLimiter(ratelimit.NewTokenBucket(0.5, 1), middleware.HeaderPrefixRateLimit)
+		hd := rl.Limit(next)
+		request := func(principal string) *http.Request {
+			req := httptest.NewRequest("POST", "/products", nil)
+			return req.WithContext(auth.ContextWithPrincipal(req.Context(), principal))
+		}
+
+		// act
+		alice := httptest.NewRecorder()
+		hd.ServeHTTP(alice, request("alice"))
+		bob := httptest.NewRecorder()
+		hd.ServeHTTP(bob, request("bob"))
+
+		// assert
+		require.Equal(t, http.StatusOK, alice.Code)
+		require.Equal(t, http.StatusOK, bob.Code)
+	})
+
+	t.Run("case 3: should reject with 429 once the daily quota is exhausted", func(t *testing.T) {
+		// arrange
+		rl := middleware.NewRateLimiter(ratelimit.NewDailyQuota(1), middleware.HeaderPrefixQuota)
+		hd := rl.Limit(next)
+
+		// act
+		first := httptest.NewRecorder()
+		hd.ServeHTTP(first, httptest.NewRequest("DELETE", "/products/1", nil))
+		second := httptest.NewRecorder()
+		hd.ServeHTTP(second, httptest.NewRequest("DELETE", "/products/1", nil))
+
+		// assert
+		require.Equal(t, http.StatusOK, first.Code)
+		require.Equal(t, "1", first.Header().Get("X-Quota-Limit"))
+		require.Equal(t, http.StatusTooManyRequests, second.Code)
+		require.NotEmpty(t, second.Header().Get("Retry-After"))
+	})
+
+	t.Run("case 4: should count the daily quota per api key, not per principal", func(t *testing.T) {
+		// arrange
+		rl := middleware.NewRateLimiter(ratelimit.NewDailyQuota(1), middleware.HeaderPrefixQuota).WithKey(middleware.KeyAPIKey)
+		hd := rl.Limit(next)
+		request := func(keyID string) *http.Request {
+			req := httptest.NewRequest("POST", "/products", nil)
+			ctx := auth.ContextWithPrincipal(req.Context(), "alice")
+			return req.WithContext(auth.ContextWithKeyID(ctx, keyID))
+		}
+
+		// act
+		first := httptest.NewRecorder()
+		hd.ServeHTTP(first, request("k1"))
+		second := httptest.NewRecorder()
+		hd.ServeHTTP(second, request("k2"))
+		again := httptest.NewRecorder()
+		hd.ServeHTTP(again, request("k1"))
+
+		// assert
+		require.Equal(t, http.StatusOK, first.Code)
+		require.Equal(t, http.StatusOK, second.Code)
+		require.Equal(t, http.StatusTooManyRequests, again.Code)
+	})
+
+	t.Run("case 5: should keep the daily quota used across restarts", func(t *testing.T) {
+		// arrange
+		filePath := filepath.Join(t.TempDir(), "quotas.json")
+		quota, err := ratelimit.LoadDailyQuota(1, filePath)
+		require.NoError(t, err)
+		first := httptest.NewRecorder()
+		middleware.NewRateLimiter(quota, middleware.HeaderPrefixQuota).Limit(next).ServeHTTP(first, httptest.NewRequest("POST", "/products", nil))
+
+		// act
+		restarted, err := ratelimit.LoadDailyQuota(1, filePath)
+		require.NoError(t, err)
+		second := httptest.NewRecorder()
+		middleware.NewRateLimiter(restarted, middleware.HeaderPrefixQuota).Limit(next).ServeHTTP(second, httptest.NewRequest("POST", "/products", nil))
+
+		// assert
+		require.Equal(t, http.StatusOK, first.Code)
+		require.Equal(t, http.StatusTooManyRequests, second.Code)
+	})
+}
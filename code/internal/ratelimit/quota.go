@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// NewDailyQuota returns a new DailyQuota allowing limit requests per key and UTC day
+func NewDailyQuota(limit int) *DailyQuota {
+	return &DailyQuota{
+		limit:  limit,
+		counts: make(map[string]int),
+		now:    time.Now,
+	}
+}
+
+// LoadDailyQuota returns a DailyQuota that keeps the counts of the day in a file, so a restart
+// does not hand out fresh quotas. The counts already in the file are loaded, none if it does not exist.
+func LoadDailyQuota(limit int, filePath string) (d *DailyQuota, err error) {
+	d = NewDailyQuota(limit)
+	d.file = filePath
+
+	b, err := os.ReadFile(filePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return d, nil
+	case err != nil:
+		return nil, err
+	}
+	var state quotaJSON
+	if err = json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+	d.day = state.Day
+	if state.Counts != nil {
+		d.counts = state.Counts
+	}
+	return
+}
+
+// DailyQuota is a Limiter counting requests per key, reset at midnight UTC.
+// The counts are those of this process: servers sharing a quota file overwrite each other's.
+type DailyQuota struct {
+	// limit is the number of requests allowed per day
+	limit int
+	// file is where the counts of the day are kept, empty if only in memory
+	file string
+
+	mu     sync.Mutex
+	day    time.Time
+	counts map[string]int
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+// quotaJSON is the state of a DailyQuota as kept in its file
+type quotaJSON struct {
+	Day    time.Time      `json:"day"`
+	Counts map[string]int `json:"counts"`
+}
+
+// Allow consumes one request of the key's quota for the day and reports whether it is allowed
+func (d *DailyQuota) Allow(key string) (res Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now().UTC()
+	day := now.Truncate(24 * time.Hour)
+	if !day.Equal(d.day) {
+		// a new day starts with fresh quotas
+		d.day = day
+		d.counts = make(map[string]int)
+	}
+
+	res.Limit = d.limit
+	res.Reset = day.Add(24 * time.Hour).Sub(now)
+	if d.counts[key] < d.limit {
+		d.counts[key]++
+		res.Allowed = true
+		d.save()
+	} else {
+		res.RetryAfter = res.Reset
+	}
+	res.Remaining = d.limit - d.counts[key]
+	return
+}
+
+// save replaces the file with the counts of the day. The request is not refused if it fails:
+// the counts in memory still hold until the next restart.
+func (d *DailyQuota) save() {
+	if d.file == "" {
+		return
+	}
+	if err := writeFile(d.file, quotaJSON{Day: d.day, Counts: d.counts}); err != nil {
+		slog.Warn("save daily quota", "file", d.file, "error", err)
+	}
+}
+
+// writeFile replaces the file with v as json, through a temporary file so a failed write keeps the previous content
+func writeFile(filePath string, v any) (err error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(b); err != nil {
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	err = os.Rename(f.Name(), filePath)
+	return
+}
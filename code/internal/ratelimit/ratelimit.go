@@ -0,0 +1,23 @@
+package ratelimit
+
+import "time"
+
+// Result is the outcome of asking a limiter for permission
+type Result struct {
+	// Allowed reports whether the request may proceed
+	Allowed bool
+	// Limit is the maximum number of requests of the window
+	Limit int
+	// Remaining is the number of requests left in the window
+	Remaining int
+	// Reset is the time until the window is fully replenished
+	Reset time.Duration
+	// RetryAfter is the time to wait before retrying a rejected request
+	RetryAfter time.Duration
+}
+
+// Limiter is an interface that contains the methods that a rate limiter must implement
+type Limiter interface {
+	// Allow consumes one request for the key and reports whether it is allowed
+	Allow(key string) (res Result)
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// AuditJSONL is an append-only audit log stored as one JSON object per line
+type AuditJSONL struct {
+	// mu serializes the writes so concurrent entries are never interleaved
+	mu sync.Mutex
+
+	FilePath   string
+	LayoutDate string
+}
+
+func NewAuditJSONL(filePath string, layoutDate string) *AuditJSONL {
+	return &AuditJSONL{
+		FilePath:   filePath,
+		LayoutDate: layoutDate,
+	}
+}
+
+type AuditEntryJSON struct {
+	Time      time.Time    `json:"time"`
+	Tenant    string       `json:"tenant,omitempty"`
+	Principal string       `json:"principal"`
+	RequestID string       `json:"request_id"`
+	Action    string       `json:"action"`
+	ProductID int          `json:"product_id"`
+	Before    *ProductJSON `json:"before"`
+	After     *ProductJSON `json:"after"`
+}
+
+func (a *AuditJSONL) Append(ctx context.Context, entry internal.AuditEntry) (err error) {
+	line, err := json.Marshal(AuditEntryJSON{
+		Time:      entry.Time,
+		Tenant:    entry.Tenant,
+		Principal: entry.Principal,
+		RequestID: entry.RequestID,
+		Action:    entry.Action,
+		ProductID: entry.ProductID,
+		Before:    a.snapshotJSON(entry.Before),
+		After:     a.snapshotJSON(entry.After),
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.FilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if _, err = f.Write(line); err != nil {
+		return
+	}
+	err = f.Sync()
+	return
+}
+
+func (a *AuditJSONL) Find(ctx context.Context, filter internal.AuditFilter) (entries []internal.AuditEntry, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.FilePath)
+	if err != nil {
+		// an audit log that was never written is an empty one
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var v AuditEntryJSON
+		if err = json.Unmarshal(sc.Bytes(), &v); err != nil {
+			return
+		}
+		entry := internal.AuditEntry{
+			Time:      v.Time,
+			Tenant:    v.Tenant,
+			Principal: v.Principal,
+			RequestID: v.RequestID,
+			Action:    v.Action,
+			ProductID: v.ProductID,
+			Before:    a.snapshot(v.Before),
+			After:     a.snapshot(v.After),
+		}
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	err = sc.Err()
+	return
+}
+
+// snapshotJSON serializes a product snapshot, keeping nil as nil
+func (a *AuditJSONL) snapshotJSON(p *internal.Product) *ProductJSON {
+	if p == nil {
+		return nil
+	}
+	return &ProductJSON{
+		Id:           p.Id,
+		Name:         p.Name,
+		Quantity:     p.Quantity,
+		Code_value:   p.Code_value,
+		Is_published: p.Is_published,
+		Expiration:   p.Expiration.Format(a.LayoutDate),
+		PriceMinor:   p.Price.Amount,
+		Currency:     p.Price.Currency,
+		Attributes:   attributesJSON(p.Attributes),
+		Variants:     variantsJSON(p.Variants),
+	}
+}
+
+// snapshot deserializes a product snapshot, keeping nil as nil
+func (a *AuditJSONL) snapshot(p *ProductJSON) *internal.Product {
+	if p == nil {
+		return nil
+	}
+	t, _ := time.Parse(a.LayoutDate, p.Expiration)
+	return &internal.Product{
+		Id:           p.Id,
+		Name:         p.Name,
+		Quantity:     p.Quantity,
+		Code_value:   p.Code_value,
+		Is_published: p.Is_published,
+		Expiration:   t,
+		Price:        money(p.PriceMinor, p.Currency, p.Price),
+		Attributes:   attributes(p.Attributes),
+		Variants:     variants(p.Variants, p.Currency),
+	}
+}
+
+// Check verifies that the log can be appended to
+func (a *AuditJSONL) Check(ctx context.Context) (err error) {
+	return checkWritable(a.FilePath)
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// CartJSON stores the carts of every tenant in a single JSON file, replaced on every write
+type CartJSON struct {
+	// mu serializes the read-modify-write cycles on the file, so concurrent writes are never lost
+	mu sync.RWMutex
+
+	FilePath string
+}
+
+func NewCartJSON(filePath string) *CartJSON {
+	return &CartJSON{
+		FilePath: filePath,
+	}
+}
+
+type CartLineJSON struct {
+	ProductID int    `json:"product_id"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	// UnitPrice and PreviousUnitPrice are the prices of the files written before currencies,
+	// in major units of the default currency. They are read when there is no currency and no longer written.
+	UnitPrice              float64 `json:"unit_price,omitempty"`
+	PreviousUnitPrice      float64 `json:"previous_unit_price,omitempty"`
+	UnitPriceMinor         int64   `json:"unit_price_minor"`
+	PreviousUnitPriceMinor int64   `json:"previous_unit_price_minor,omitempty"`
+	// TotalMinor is the price of the units with the promotions, missing in the carts saved before promotions
+	TotalMinor *int64 `json:"total_minor,omitempty"`
+	Promotions []int  `json:"promotions,omitempty"`
+	Currency   string `json:"currency,omitempty"`
+}
+
+type CartRecordJSON struct {
+	ID        string         `json:"id"`
+	Tenant    string         `json:"tenant,omitempty"`
+	Principal string         `json:"principal"`
+	Lines     []CartLineJSON `json:"lines"`
+	Created   time.Time      `json:"created"`
+	Updated   time.Time      `json:"updated"`
+	Expires   time.Time      `json:"expires"`
+}
+
+func (c *CartJSON) GetByID(ctx context.Context, tenant, id string) (cart internal.Cart, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	carts, err := c.read()
+	if err != nil {
+		return
+	}
+	cart, ok := carts[id]
+	if !ok || cart.Tenant != tenant {
+		cart = internal.Cart{}
+		err = fmt.Errorf("%w: id", internal.ErrCartNotFound)
+		return
+	}
+	return
+}
+
+func (c *CartJSON) Save(ctx context.Context, cart internal.Cart) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	carts, err := c.read()
+	if err != nil {
+		return
+	}
+	carts[cart.ID] = cart
+	return c.write(carts)
+}
+
+func (c *CartJSON) Delete(ctx context.Context, tenant, id string) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	carts, err := c.read()
+	if err != nil {
+		return
+	}
+	if cart, ok := carts[id]; !ok || cart.Tenant != tenant {
+		err = fmt.Errorf("%w: id", internal.ErrCartNotFound)
+		return
+	}
+	delete(carts, id)
+	return c.write(carts)
+}
+
+func (c *CartJSON) DeleteExpired(ctx context.Context, now time.Time) (expired []internal.Cart, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	carts, err := c.read()
+	if err != nil {
+		return
+	}
+	for id, v := range carts {
+		if v.Expired(now) {
+			expired = append(expired, v)
+			delete(carts, id)
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+	if err = c.write(carts); err != nil {
+		expired = nil
+	}
+	return
+}
+
+// Check verifies that the file can be read and that its directory accepts writes
+func (c *CartJSON) Check(ctx context.Context) (err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, err = c.read(); err != nil {
+		return
+	}
+	return checkWritable(c.FilePath)
+}
+
+// read returns every cart by id; a missing file has no carts
+func (c *CartJSON) read() (carts map[string]internal.Cart, err error) {
+	carts = make(map[string]internal.Cart)
+	b, err := os.ReadFile(c.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	var records []CartRecordJSON
+	if err = json.Unmarshal(b, &records); err != nil {
+		err = fmt.Errorf("%w: %s: %v", internal.ErrStorageCartFormat, c.FilePath, err)
+		return
+	}
+	for _, r := range records {
+		cart := internal.Cart{
+			ID:        r.ID,
+			Tenant:    r.Tenant,
+			Principal: r.Principal,
+			Lines:     make([]internal.CartLine, 0, len(r.Lines)),
+			Created:   r.Created,
+			Updated:   r.Updated,
+			Expires:   r.Expires,
+		}
+		for _, l := range r.Lines {
+			line := internal.CartLine{
+				ProductID:  l.ProductID,
+				Name:       l.Name,
+				Quantity:   l.Quantity,
+				UnitPrice:  money(l.UnitPriceMinor, l.Currency, l.UnitPrice),
+				Total:      lineTotal(l.TotalMinor, l.Currency),
+				Promotions: l.Promotions,
+			}
+			if l.PreviousUnitPriceMinor != 0 || l.PreviousUnitPrice != 0 {
+				line.PreviousUnitPrice = money(l.PreviousUnitPriceMinor, l.Currency, l.PreviousUnitPrice)
+			}
+			cart.Lines = append(cart.Lines, line)
+		}
+		carts[r.ID] = cart
+	}
+	return
+}
+
+// write replaces the file with the carts, in creation order so the file is stable between writes
+func (c *CartJSON) write(carts map[string]internal.Cart) (err error) {
+	records := make([]CartRecordJSON, 0, len(carts))
+	for _, v := range carts {
+		r := CartRecordJSON{
+			ID:        v.ID,
+			Tenant:    v.Tenant,
+			Principal: v.Principal,
+			Lines:     make([]CartLineJSON, 0, len(v.Lines)),
+			Created:   v.Created,
+			Updated:   v.Updated,
+			Expires:   v.Expires,
+		}
+		for _, l := range v.Lines {
+			r.Lines = append(r.Lines, CartLineJSON{
+				ProductID:              l.ProductID,
+				Name:                   l.Name,
+				Quantity:               l.Quantity,
+				UnitPriceMinor:         l.UnitPrice.Amount,
+				PreviousUnitPriceMinor: l.PreviousUnitPrice.Amount,
+				TotalMinor:             lineTotalJSON(l.Total),
+				Promotions:             l.Promotions,
+				Currency:               l.UnitPrice.Currency,
+			})
+		}
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Created.Equal(records[j].Created) {
+			return records[i].Created.Before(records[j].Created)
+		}
+		return records[i].ID < records[j].ID
+	})
+	return writeFileJSON(c.FilePath, records)
+}
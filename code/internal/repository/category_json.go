@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// CategoryJSON stores the categories of every tenant and their assignments to the products
+// in a single JSON file, replaced on every write
+type CategoryJSON struct {
+	// mu serializes the read-modify-write cycles on the file, so concurrent writes are never lost
+	mu sync.RWMutex
+
+	FilePath string
+}
+
+func NewCategoryJSON(filePath string) *CategoryJSON {
+	return &CategoryJSON{
+		FilePath: filePath,
+	}
+}
+
+type CategoryRecordJSON struct {
+	ID       int    `json:"id"`
+	Tenant   string `json:"tenant,omitempty"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	ParentID int    `json:"parent_id,omitempty"`
+}
+
+type CategoryAssignmentJSON struct {
+	Tenant      string `json:"tenant,omitempty"`
+	ProductID   int    `json:"product_id"`
+	CategoryIDs []int  `json:"category_ids"`
+}
+
+type CategoryFileJSON struct {
+	Categories  []CategoryRecordJSON     `json:"categories"`
+	Assignments []CategoryAssignmentJSON `json:"assignments"`
+}
+
+func (c *CategoryJSON) GetAll(ctx context.Context, tenant string) (categories []internal.Category, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	file, err := c.read()
+	if err != nil {
+		return
+	}
+	for _, r := range file.Categories {
+		if r.Tenant == tenant {
+			categories = append(categories, internal.Category{ID: r.ID, Tenant: r.Tenant, Slug: r.Slug, Name: r.Name, ParentID: r.ParentID})
+		}
+	}
+	return
+}
+
+func (c *CategoryJSON) Create(ctx context.Context, category *internal.Category) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, err := c.read()
+	if err != nil {
+		return
+	}
+	// ids are shared by the tenants, so an id never designates two categories
+	category.ID = 1
+	if n := len(file.Categories); n > 0 {
+		category.ID = file.Categories[n-1].ID + 1
+	}
+	file.Categories = append(file.Categories, CategoryRecordJSON{
+		ID:       category.ID,
+		Tenant:   category.Tenant,
+		Slug:     category.Slug,
+		Name:     category.Name,
+		ParentID: category.ParentID,
+	})
+	return c.write(file)
+}
+
+func (c *CategoryJSON) Update(ctx context.Context, category internal.Category) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, err := c.read()
+	if err != nil {
+		return
+	}
+	for i, r := range file.Categories {
+		if r.ID == category.ID && r.Tenant == category.Tenant {
+			file.Categories[i] = CategoryRecordJSON{
+				ID:       category.ID,
+				Tenant:   category.Tenant,
+				Slug:     category.Slug,
+				Name:     category.Name,
+				ParentID: category.ParentID,
+			}
+			return c.write(file)
+		}
+	}
+	err = fmt.Errorf("%w: id", internal.ErrCategoryNotFound)
+	return
+}
+
+func (c *CategoryJSON) Delete(ctx context.Context, tenant string, id int) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, err := c.read()
+	if err != nil {
+		return
+	}
+	found := false
+	categories := file.Categories[:0]
+	for _, r := range file.Categories {
+		if r.ID == id && r.Tenant == tenant {
+			found = true
+			continue
+		}
+		categories = append(categories, r)
+	}
+	if !found {
+		err = fmt.Errorf("%w: id", internal.ErrCategoryNotFound)
+		return
+	}
+	file.Categories = categories
+
+	// the products of the category are unassigned from it
+	assignments := file.Assignments[:0]
+	for _, a := range file.Assignments {
+		if a.Tenant == tenant {
+			a.CategoryIDs = without(a.CategoryIDs, id)
+			if len(a.CategoryIDs) == 0 {
+				continue
+			}
+		}
+		assignments = append(assignments, a)
+	}
+	file.Assignments = assignments
+	return c.write(file)
+}
+
+func (c *CategoryJSON) Assignments(ctx context.Context, tenant string) (assignments map[int][]int, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	file, err := c.read()
+	if err != nil {
+		return
+	}
+	assignments = make(map[int][]int)
+	for _, a := range file.Assignments {
+		if a.Tenant == tenant {
+			assignments[a.ProductID] = a.CategoryIDs
+		}
+	}
+	return
+}
+
+func (c *CategoryJSON) Assign(ctx context.Context, tenant string, productID int, categoryIDs []int) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, err := c.read()
+	if err != nil {
+		return
+	}
+	assignments := file.Assignments[:0]
+	for _, a := range file.Assignments {
+		if a.Tenant != tenant || a.ProductID != productID {
+			assignments = append(assignments, a)
+		}
+	}
+	if len(categoryIDs) > 0 {
+		assignments = append(assignments, CategoryAssignmentJSON{Tenant: tenant, ProductID: productID, CategoryIDs: categoryIDs})
+	}
+	file.Assignments = assignments
+	return c.write(file)
+}
+
+// LastModified returns when the file was last written, for the categories and assignments of every tenant
+func (c *CategoryJSON) LastModified(ctx context.Context) (t time.Time, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return modTime(c.FilePath)
+}
+
+// Check verifies that the file can be read and that its directory accepts writes
+func (c *CategoryJSON) Check(ctx context.Context) (err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, err = c.read(); err != nil {
+		return
+	}
+	return checkWritable(c.FilePath)
+}
+
+// read returns the content of the file, the categories in id order; a missing file has no categories
+func (c *CategoryJSON) read() (file CategoryFileJSON, err error) {
+	b, err := os.ReadFile(c.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	if err = json.Unmarshal(b, &file); err != nil {
+		err = fmt.Errorf("%w: %s: %v", internal.ErrStorageCategoryFormat, c.FilePath, err)
+		return
+	}
+	sort.Slice(file.Categories, func(i, j int) bool { return file.Categories[i].ID < file.Categories[j].ID })
+	return
+}
+
+// write replaces the file, the assignments in product order so the file is stable between writes
+func (c *CategoryJSON) write(file CategoryFileJSON) (err error) {
+	sort.Slice(file.Assignments, func(i, j int) bool {
+		if file.Assignments[i].Tenant != file.Assignments[j].Tenant {
+			return file.Assignments[i].Tenant < file.Assignments[j].Tenant
+		}
+		return file.Assignments[i].ProductID < file.Assignments[j].ProductID
+	})
+	if file.Categories == nil {
+		file.Categories = []CategoryRecordJSON{}
+	}
+	if file.Assignments == nil {
+		file.Assignments = []CategoryAssignmentJSON{}
+	}
+	return writeFileJSON(c.FilePath, file)
+}
+
+// without returns the ids other than id
+func without(ids []int, id int) (rest []int) {
+	for _, v := range ids {
+		if v != id {
+			rest = append(rest, v)
+		}
+	}
+	return
+}
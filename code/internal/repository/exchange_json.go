@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// ExchangeRatesJSON is the table of exchange rates as stored in its file, e.g.
+// {"base": "USD", "rates": {"EUR": 0.92, "JPY": 151.3}}
+type ExchangeRatesJSON struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// LoadExchangeRates reads a table of exchange rates from a file.
+// The rates are kept as the decimals written in it, so conversions do not carry float errors.
+func LoadExchangeRates(filePath string) (rates internal.ExchangeRates, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var data ExchangeRatesJSON
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	if err = dec.Decode(&data); err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrExchangeRatesInvalid, err)
+		return
+	}
+
+	if _, err = internal.Digits(data.Base); err != nil {
+		err = fmt.Errorf("%w: base: %v", internal.ErrExchangeRatesInvalid, err)
+		return
+	}
+	rates = internal.ExchangeRates{Base: data.Base, Rates: make(map[string]*big.Rat, len(data.Rates))}
+	for currency, v := range data.Rates {
+		if _, err = internal.Digits(currency); err != nil {
+			err = fmt.Errorf("%w: %v", internal.ErrExchangeRatesInvalid, err)
+			return
+		}
+		rate, ok := new(big.Rat).SetString(v.String())
+		if !ok || rate.Sign() <= 0 {
+			err = fmt.Errorf("%w: rate of %s must be positive", internal.ErrExchangeRatesInvalid, currency)
+			return
+		}
+		rates.Rates[currency] = rate
+	}
+	return
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// LotJSON stores the lots of the products of every tenant in a single JSON file, replaced on every write
+type LotJSON struct {
+	// mu serializes the read-modify-write cycles on the file, so concurrent writes are never lost
+	mu sync.RWMutex
+
+	FilePath string
+}
+
+func NewLotJSON(filePath string) *LotJSON {
+	return &LotJSON{
+		FilePath: filePath,
+	}
+}
+
+type LotRecordJSON struct {
+	Tenant     string    `json:"tenant,omitempty"`
+	ProductID  int       `json:"product_id"`
+	Code       string    `json:"code"`
+	Quantity   int       `json:"quantity"`
+	Expiration time.Time `json:"expiration"`
+	Received   time.Time `json:"received"`
+}
+
+func (l *LotJSON) Find(ctx context.Context, tenant string, productID int) (lots []internal.Lot, err error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	all, err := l.read()
+	if err != nil {
+		return
+	}
+	for _, v := range all {
+		if v.Tenant == tenant && v.ProductID == productID {
+			lots = append(lots, v)
+		}
+	}
+	return
+}
+
+func (l *LotJSON) Replace(ctx context.Context, tenant string, productID int, lots []internal.Lot) (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	all, err := l.read()
+	if err != nil {
+		return
+	}
+	kept := all[:0]
+	for _, v := range all {
+		if v.Tenant != tenant || v.ProductID != productID {
+			kept = append(kept, v)
+		}
+	}
+	for _, v := range lots {
+		if v.Quantity > 0 {
+			v.Tenant, v.ProductID = tenant, productID
+			kept = append(kept, v)
+		}
+	}
+	return l.write(kept)
+}
+
+func (l *LotJSON) Expired(ctx context.Context, tenant string, now time.Time) (lots []internal.Lot, err error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	all, err := l.read()
+	if err != nil {
+		return
+	}
+	for _, v := range all {
+		if v.Tenant == tenant && v.Expired(now) {
+			lots = append(lots, v)
+		}
+	}
+	return
+}
+
+// Check verifies that the file can be read and that its directory accepts writes
+func (l *LotJSON) Check(ctx context.Context) (err error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, err = l.read(); err != nil {
+		return
+	}
+	return checkWritable(l.FilePath)
+}
+
+// read returns every lot, first expiring first; a missing file has no lots
+func (l *LotJSON) read() (lots []internal.Lot, err error) {
+	b, err := os.ReadFile(l.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	var records []LotRecordJSON
+	if err = json.Unmarshal(b, &records); err != nil {
+		err = fmt.Errorf("%w: %s: %v", internal.ErrStorageLotFormat, l.FilePath, err)
+		return
+	}
+	lots = make([]internal.Lot, 0, len(records))
+	for _, r := range records {
+		lots = append(lots, internal.Lot{
+			Tenant:     r.Tenant,
+			ProductID:  r.ProductID,
+			Code:       r.Code,
+			Quantity:   r.Quantity,
+			Expiration: r.Expiration,
+			Received:   r.Received,
+		})
+	}
+	sortLots(lots)
+	return
+}
+
+// write replaces the file, the lots in product order so the file is stable between writes
+func (l *LotJSON) write(lots []internal.Lot) (err error) {
+	sort.SliceStable(lots, func(i, j int) bool {
+		if lots[i].Tenant != lots[j].Tenant {
+			return lots[i].Tenant < lots[j].Tenant
+		}
+		return lots[i].ProductID < lots[j].ProductID
+	})
+	records := make([]LotRecordJSON, 0, len(lots))
+	for _, v := range lots {
+		records = append(records, LotRecordJSON{
+			Tenant:     v.Tenant,
+			ProductID:  v.ProductID,
+			Code:       v.Code,
+			Quantity:   v.Quantity,
+			Expiration: v.Expiration.UTC(),
+			Received:   v.Received.UTC(),
+		})
+	}
+	return writeFileJSON(l.FilePath, records)
+}
+
+// sortLots orders lots first expiring first, then first received first
+func sortLots(lots []internal.Lot) {
+	sort.SliceStable(lots, func(i, j int) bool {
+		if !lots[i].Expiration.Equal(lots[j].Expiration) {
+			return lots[i].Expiration.Before(lots[j].Expiration)
+		}
+		return lots[i].Received.Before(lots[j].Received)
+	})
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// OrderJSON stores the orders of every tenant in a single JSON file, replaced on every write
+type OrderJSON struct {
+	// mu serializes the read-modify-write cycles on the file, so concurrent writes are never lost
+	mu sync.RWMutex
+
+	FilePath string
+}
+
+func NewOrderJSON(filePath string) *OrderJSON {
+	return &OrderJSON{
+		FilePath: filePath,
+	}
+}
+
+type OrderLineJSON struct {
+	ProductID int    `json:"product_id"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	// UnitPrice is the price of the files written before currencies, in major units of the default currency.
+	// It is read when there is no currency and no longer written.
+	UnitPrice      float64 `json:"unit_price,omitempty"`
+	UnitPriceMinor int64   `json:"unit_price_minor"`
+	// TotalMinor is the price of the units with the promotions, missing in the orders placed before promotions
+	TotalMinor *int64 `json:"total_minor,omitempty"`
+	Promotions []int  `json:"promotions,omitempty"`
+	Currency   string `json:"currency,omitempty"`
+}
+
+type OrderRecordJSON struct {
+	ID        int             `json:"id"`
+	Tenant    string          `json:"tenant,omitempty"`
+	Principal string          `json:"principal"`
+	State     string          `json:"state"`
+	Lines     []OrderLineJSON `json:"lines"`
+	Created   time.Time       `json:"created"`
+	Updated   time.Time       `json:"updated"`
+}
+
+func (o *OrderJSON) GetAll(ctx context.Context, tenant string) (orders []internal.Order, err error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	all, err := o.read()
+	if err != nil {
+		return
+	}
+	for _, v := range all {
+		if v.Tenant == tenant {
+			orders = append(orders, v)
+		}
+	}
+	return
+}
+
+func (o *OrderJSON) GetByID(ctx context.Context, tenant string, id int) (order internal.Order, err error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	all, err := o.read()
+	if err != nil {
+		return
+	}
+	for _, v := range all {
+		if v.ID == id && v.Tenant == tenant {
+			order = v
+			return
+		}
+	}
+	err = fmt.Errorf("%w: id", internal.ErrOrderNotFound)
+	return
+}
+
+func (o *OrderJSON) Create(ctx context.Context, order *internal.Order) (err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	all, err := o.read()
+	if err != nil {
+		return
+	}
+	// ids are shared by the tenants, so an id never designates two orders
+	order.ID = 1
+	if len(all) > 0 {
+		order.ID = all[len(all)-1].ID + 1
+	}
+	return o.write(append(all, *order))
+}
+
+func (o *OrderJSON) Update(ctx context.Context, order internal.Order) (err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	all, err := o.read()
+	if err != nil {
+		return
+	}
+	for i, v := range all {
+		if v.ID == order.ID && v.Tenant == order.Tenant {
+			all[i] = order
+			return o.write(all)
+		}
+	}
+	err = fmt.Errorf("%w: id", internal.ErrOrderNotFound)
+	return
+}
+
+// Check verifies that the file can be read and that its directory accepts writes
+func (o *OrderJSON) Check(ctx context.Context) (err error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if _, err = o.read(); err != nil {
+		return
+	}
+	return checkWritable(o.FilePath)
+}
+
+// read returns every order in id order; a missing file has no orders
+func (o *OrderJSON) read() (orders []internal.Order, err error) {
+	b, err := os.ReadFile(o.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	var records []OrderRecordJSON
+	if err = json.Unmarshal(b, &records); err != nil {
+		err = fmt.Errorf("%w: %s: %v", internal.ErrStorageOrderFormat, o.FilePath, err)
+		return
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	orders = make([]internal.Order, 0, len(records))
+	for _, r := range records {
+		order := internal.Order{
+			ID:        r.ID,
+			Tenant:    r.Tenant,
+			Principal: r.Principal,
+			State:     r.State,
+			Lines:     make([]internal.OrderLine, 0, len(r.Lines)),
+			Created:   r.Created,
+			Updated:   r.Updated,
+		}
+		for _, l := range r.Lines {
+			order.Lines = append(order.Lines, internal.OrderLine{
+				ProductID:  l.ProductID,
+				Name:       l.Name,
+				Quantity:   l.Quantity,
+				UnitPrice:  money(l.UnitPriceMinor, l.Currency, l.UnitPrice),
+				Total:      lineTotal(l.TotalMinor, l.Currency),
+				Promotions: l.Promotions,
+			})
+		}
+		orders = append(orders, order)
+	}
+	return
+}
+
+// write replaces the file with the orders through a temporary file, so an interrupted write never truncates it
+func (o *OrderJSON) write(orders []internal.Order) (err error) {
+	records := make([]OrderRecordJSON, 0, len(orders))
+	for _, v := range orders {
+		r := OrderRecordJSON{
+			ID:        v.ID,
+			Tenant:    v.Tenant,
+			Principal: v.Principal,
+			State:     v.State,
+			Lines:     make([]OrderLineJSON, 0, len(v.Lines)),
+			Created:   v.Created,
+			Updated:   v.Updated,
+		}
+		for _, l := range v.Lines {
+			r.Lines = append(r.Lines, OrderLineJSON{
+				ProductID:      l.ProductID,
+				Name:           l.Name,
+				Quantity:       l.Quantity,
+				UnitPriceMinor: l.UnitPrice.Amount,
+				TotalMinor:     lineTotalJSON(l.Total),
+				Promotions:     l.Promotions,
+				Currency:       l.UnitPrice.Currency,
+			})
+		}
+		records = append(records, r)
+	}
+	return writeFileJSON(o.FilePath, records)
+}
+
+// lineTotalJSON serializes the total of a line, nil if the line has none
+func lineTotalJSON(m internal.Money) *int64 {
+	if m.Currency == "" {
+		return nil
+	}
+	return &m.Amount
+}
+
+// lineTotal deserializes the stored total of a line, zero if the line has none
+func lineTotal(minor *int64, currency string) internal.Money {
+	if minor == nil {
+		return internal.Money{}
+	}
+	return internal.Money{Amount: *minor, Currency: currency}
+}
+
+// writeFileJSON replaces a file with the JSON encoding of v through a synced temporary file
+func writeFileJSON(filePath string, v any) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if err = f.Chmod(0644); err != nil {
+		return
+	}
+	if err = json.NewEncoder(f).Encode(v); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	return os.Rename(f.Name(), filePath)
+}
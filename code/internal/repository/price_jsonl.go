@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// PriceJSONL is an append-only price history stored as one JSON object per line
+type PriceJSONL struct {
+	// mu serializes the writes so concurrent changes are never interleaved
+	mu sync.Mutex
+
+	FilePath string
+}
+
+func NewPriceJSONL(filePath string) *PriceJSONL {
+	return &PriceJSONL{
+		FilePath: filePath,
+	}
+}
+
+type PriceChangeJSON struct {
+	Time       time.Time `json:"time"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Principal  string    `json:"principal"`
+	RequestID  string    `json:"request_id"`
+	ProductID  int       `json:"product_id"`
+	Old        MoneyJSON `json:"old"`
+	New        MoneyJSON `json:"new"`
+	ScheduleID string    `json:"schedule_id,omitempty"`
+}
+
+func (p *PriceJSONL) Append(ctx context.Context, change internal.PriceChange) (err error) {
+	line, err := json.Marshal(PriceChangeJSON{
+		Time:       change.Time,
+		Tenant:     change.Tenant,
+		Principal:  change.Principal,
+		RequestID:  change.RequestID,
+		ProductID:  change.ProductID,
+		Old:        moneyJSON(change.Old),
+		New:        moneyJSON(change.New),
+		ScheduleID: change.ScheduleID,
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.FilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if _, err = f.Write(line); err != nil {
+		return
+	}
+	err = f.Sync()
+	return
+}
+
+func (p *PriceJSONL) Find(ctx context.Context, tenant string, productID int) (changes []internal.PriceChange, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.Open(p.FilePath)
+	if err != nil {
+		// a history that was never written is an empty one
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var v PriceChangeJSON
+		if err = json.Unmarshal(sc.Bytes(), &v); err != nil {
+			return
+		}
+		if v.Tenant != tenant || v.ProductID != productID {
+			continue
+		}
+		changes = append(changes, internal.PriceChange{
+			Time:       v.Time,
+			Tenant:     v.Tenant,
+			Principal:  v.Principal,
+			RequestID:  v.RequestID,
+			ProductID:  v.ProductID,
+			Old:        internal.Money{Amount: v.Old.Amount, Currency: v.Old.Currency},
+			New:        internal.Money{Amount: v.New.Amount, Currency: v.New.Currency},
+			ScheduleID: v.ScheduleID,
+		})
+	}
+	err = sc.Err()
+	return
+}
+
+// Check verifies that the history can be appended to
+func (p *PriceJSONL) Check(ctx context.Context) (err error) {
+	return checkWritable(p.FilePath)
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// PriceScheduleJSON stores the pending price changes of every tenant in a single JSON file, replaced on every write
+type PriceScheduleJSON struct {
+	// mu serializes the read-modify-write cycles on the file, so concurrent writes are never lost
+	mu sync.RWMutex
+
+	FilePath string
+}
+
+func NewPriceScheduleJSON(filePath string) *PriceScheduleJSON {
+	return &PriceScheduleJSON{
+		FilePath: filePath,
+	}
+}
+
+type PriceScheduleRecordJSON struct {
+	ID        string    `json:"id"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Principal string    `json:"principal"`
+	ProductID int       `json:"product_id"`
+	Price     MoneyJSON `json:"price"`
+	Effective time.Time `json:"effective"`
+	Created   time.Time `json:"created"`
+}
+
+func (p *PriceScheduleJSON) Find(ctx context.Context, tenant string, productID int) (schedules []internal.PriceSchedule, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	all, err := p.read()
+	if err != nil {
+		return
+	}
+	for _, v := range all {
+		if v.Tenant == tenant && v.ProductID == productID {
+			schedules = append(schedules, v)
+		}
+	}
+	return
+}
+
+func (p *PriceScheduleJSON) Create(ctx context.Context, schedule internal.PriceSchedule) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all, err := p.read()
+	if err != nil {
+		return
+	}
+	return p.write(append(all, schedule))
+}
+
+func (p *PriceScheduleJSON) Delete(ctx context.Context, tenant string, id string) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all, err := p.read()
+	if err != nil {
+		return
+	}
+	for i, v := range all {
+		if v.ID == id && v.Tenant == tenant {
+			return p.write(append(all[:i], all[i+1:]...))
+		}
+	}
+	err = fmt.Errorf("%w: id", internal.ErrPriceScheduleNotFound)
+	return
+}
+
+func (p *PriceScheduleJSON) Due(ctx context.Context, tenant string, now time.Time) (schedules []internal.PriceSchedule, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	all, err := p.read()
+	if err != nil {
+		return
+	}
+	for _, v := range all {
+		if v.Tenant == tenant && !now.Before(v.Effective) {
+			schedules = append(schedules, v)
+		}
+	}
+	return
+}
+
+// Check verifies that the file can be read and that its directory accepts writes
+func (p *PriceScheduleJSON) Check(ctx context.Context) (err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if _, err = p.read(); err != nil {
+		return
+	}
+	return checkWritable(p.FilePath)
+}
+
+// read returns every pending change, first effective first; a missing file has none
+func (p *PriceScheduleJSON) read() (schedules []internal.PriceSchedule, err error) {
+	b, err := os.ReadFile(p.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	var records []PriceScheduleRecordJSON
+	if err = json.Unmarshal(b, &records); err != nil {
+		err = fmt.Errorf("%w: %s: %v", internal.ErrStoragePriceFormat, p.FilePath, err)
+		return
+	}
+	schedules = make([]internal.PriceSchedule, 0, len(records))
+	for _, r := range records {
+		schedules = append(schedules, internal.PriceSchedule{
+			ID:        r.ID,
+			Tenant:    r.Tenant,
+			Principal: r.Principal,
+			ProductID: r.ProductID,
+			Price:     internal.Money{Amount: r.Price.Amount, Currency: r.Price.Currency},
+			Effective: r.Effective,
+			Created:   r.Created,
+		})
+	}
+	// changes effective at the same time apply in the order they were scheduled
+	sort.SliceStable(schedules, func(i, j int) bool {
+		if !schedules[i].Effective.Equal(schedules[j].Effective) {
+			return schedules[i].Effective.Before(schedules[j].Effective)
+		}
+		return schedules[i].Created.Before(schedules[j].Created)
+	})
+	return
+}
+
+// write replaces the file
+func (p *PriceScheduleJSON) write(schedules []internal.PriceSchedule) (err error) {
+	records := make([]PriceScheduleRecordJSON, 0, len(schedules))
+	for _, v := range schedules {
+		records = append(records, PriceScheduleRecordJSON{
+			ID:        v.ID,
+			Tenant:    v.Tenant,
+			Principal: v.Principal,
+			ProductID: v.ProductID,
+			Price:     moneyJSON(v.Price),
+			Effective: v.Effective.UTC(),
+			Created:   v.Created.UTC(),
+		})
+	}
+	return writeFileJSON(p.FilePath, records)
+}
@@ -1,12 +1,18 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/rhinosc/web-market/code/internal"
 )
 
 type ProductStore struct {
+	// mu serializes the read-modify-write cycles on the file, so concurrent writes are never lost
+	mu sync.RWMutex
+
 	st StorageProductJSON
 
 	LastID int
@@ -22,8 +28,11 @@ func NewProductStore(st StorageProductJSON, lastID int, layoutDate string) *Prod
 	}
 }
 
-func (p *ProductStore) GetAll() (products map[int]*internal.Product, err error) {
-	prod, err := p.st.ReadAll()
+func (p *ProductStore) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	prod, err := p.st.ReadAll(ctx)
 	if err != nil {
 		err = fmt.Errorf("%w: id", internal.ErrProductNotFound)
 	}
@@ -31,8 +40,17 @@ func (p *ProductStore) GetAll() (products map[int]*internal.Product, err error)
 	return
 }
 
-func (p *ProductStore) GetByID(id int) (product *internal.Product, err error) {
-	prods, err := p.st.ReadAll()
+// Each streams the products from the file without taking the lock: writes replace the file atomically,
+// so the iteration reads a consistent snapshot and a slow reader never holds back the writers
+func (p *ProductStore) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	return p.st.Each(ctx, fn)
+}
+
+func (p *ProductStore) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	prods, err := p.st.ReadAll(ctx)
 	if err != nil {
 		return
 	}
@@ -44,24 +62,11 @@ func (p *ProductStore) GetByID(id int) (product *internal.Product, err error) {
 	return
 }
 
-func (p *ProductStore) SearchByPrice(price float64) (products map[int]*internal.Product, err error) {
-	prods, err := p.st.ReadAll()
-	if err != nil {
-		return
-	}
-
-	filteredProducts := make(map[int]*internal.Product)
-	for _, v := range prods {
-		if v.Price >= price {
-			filteredProducts[v.Id] = v
-		}
-	}
-	products = filteredProducts
-	return
-}
+func (p *ProductStore) Create(ctx context.Context, product *internal.Product) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-func (p *ProductStore) Create(product *internal.Product) (err error) {
-	prods, err := p.st.ReadAll()
+	prods, err := p.st.ReadAll(ctx)
 	if err != nil {
 		return
 	}
@@ -69,15 +74,18 @@ func (p *ProductStore) Create(product *internal.Product) (err error) {
 	product.Id = p.LastID
 	prods[product.Id] = product
 
-	err = p.st.WriteAll(prods)
+	err = p.st.WriteAll(ctx, prods)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func (p *ProductStore) UpdateOrCreate(product *internal.Product) (prod internal.Product, err error) {
-	prods, err := p.st.ReadAll()
+func (p *ProductStore) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prods, err := p.st.ReadAll(ctx)
 	if err != nil {
 		return
 	}
@@ -93,15 +101,18 @@ func (p *ProductStore) UpdateOrCreate(product *internal.Product) (prod internal.
 		prods[product.Id] = product
 	}
 	prod = *product
-	err = p.st.WriteAll(prods)
+	err = p.st.WriteAll(ctx, prods)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func (p *ProductStore) Update(product *internal.Product) (err error) {
-	prods, err := p.st.ReadAll()
+func (p *ProductStore) Update(ctx context.Context, product *internal.Product) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prods, err := p.st.ReadAll(ctx)
 	if err != nil {
 		return
 	}
@@ -111,15 +122,18 @@ func (p *ProductStore) Update(product *internal.Product) (err error) {
 		return
 	}
 	prods[product.Id] = product
-	err = p.st.WriteAll(prods)
+	err = p.st.WriteAll(ctx, prods)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func (p *ProductStore) Delete(id int) (err error) {
-	prods, err := p.st.ReadAll()
+func (p *ProductStore) Delete(ctx context.Context, id int) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prods, err := p.st.ReadAll(ctx)
 	if err != nil {
 		return
 	}
@@ -129,9 +143,16 @@ func (p *ProductStore) Delete(id int) (err error) {
 		return
 	}
 	delete(prods, id)
-	err = p.st.WriteAll(prods)
+	err = p.st.WriteAll(ctx, prods)
 	if err != nil {
 		return
 	}
 	return
 }
+
+func (p *ProductStore) LastModified(ctx context.Context) (t time.Time, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.st.ModTime(ctx)
+}
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/rhinosc/web-market/code/internal"
@@ -12,22 +15,43 @@ import (
 type ProductMap struct {
 	db     map[int]*internal.Product
 	lastID int
+	// modified is when the products last changed
+	modified time.Time
 }
 
 func NewProductRepository(db map[int]*internal.Product, lastID int) *ProductMap {
 	pMap := &ProductMap{
-		db:     db,
-		lastID: lastID,
+		db:       db,
+		lastID:   lastID,
+		modified: time.Now(),
 	}
 	// pMap.ReadProducts()
 	return pMap
 }
 
-func (p *ProductMap) GetAll() (products map[int]*internal.Product, err error) {
+func (p *ProductMap) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
 	return p.db, nil
 }
 
-func (p *ProductMap) GetByID(id int) (product *internal.Product, err error) {
+func (p *ProductMap) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	ids := make([]int, 0, len(p.db))
+	for id := range p.db {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if err = fn(p.db[id]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (p *ProductMap) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
 	product, ok := p.db[id]
 	if !ok {
 		err = fmt.Errorf("%w: id", internal.ErrProductNotFound)
@@ -37,14 +61,15 @@ func (p *ProductMap) GetByID(id int) (product *internal.Product, err error) {
 	return
 }
 
-func (p *ProductMap) Create(product *internal.Product) (err error) {
+func (p *ProductMap) Create(ctx context.Context, product *internal.Product) (err error) {
 	p.lastID++
 	product.Id = p.lastID
 	p.db[product.Id] = product
+	p.modified = time.Now()
 	return
 }
 
-func (p *ProductMap) UpdateOrCreate(product *internal.Product) (prod internal.Product, err error) {
+func (p *ProductMap) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
 	_, ok := (*p).db[product.Id]
 	switch ok {
 	case true:
@@ -53,61 +78,144 @@ func (p *ProductMap) UpdateOrCreate(product *internal.Product) (prod internal.Pr
 	case false:
 		//create
 		(*p).lastID++
+		product.Id = (*p).lastID
 		(*p).db[(*p).lastID] = product
 	}
+	p.modified = time.Now()
 	prod = *product
 	return
 }
 
-func (p *ProductMap) Update(product *internal.Product) (err error) {
+func (p *ProductMap) Update(ctx context.Context, product *internal.Product) (err error) {
 	_, ok := (*p).db[product.Id]
 	if !ok {
 		err = fmt.Errorf("%w: id", internal.ErrProductNotFound)
 		return
 	}
 	(*p).db[product.Id] = product
+	p.modified = time.Now()
 	return
 }
 
-func (p *ProductMap) Delete(id int) (err error) {
+func (p *ProductMap) Delete(ctx context.Context, id int) (err error) {
 	_, ok := (*p).db[id]
 	if !ok {
 		err = fmt.Errorf("%w: id", internal.ErrProductNotFound)
 		return
 	}
 	delete((*p).db, id)
+	p.modified = time.Now()
 	return
 }
 
+func (p *ProductMap) LastModified(ctx context.Context) (t time.Time, err error) {
+	return p.modified, nil
+}
+
 type ProductJSON struct {
-	Id           int     `json:"id"`
-	Name         string  `json:"name"`
-	Quantity     int     `json:"quantity"`
-	Code_value   string  `json:"code_value"`
-	Is_published bool    `json:"is_published"`
-	Expiration   string  `json:"expiration"`
-	Price        float64 `json:"price"`
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Quantity     int    `json:"quantity"`
+	Code_value   string `json:"code_value"`
+	Is_published bool   `json:"is_published"`
+	Expiration   string `json:"expiration"`
+	// Price is the price of the files written before currencies, in major units of the default currency.
+	// It is read when there is no currency and no longer written.
+	Price      float64 `json:"price,omitempty"`
+	PriceMinor int64   `json:"price_minor"`
+	Currency   string  `json:"currency,omitempty"`
+	// Attributes and Variants are left out of the products that have none, so older files read the same
+	Attributes []AttributeJSON `json:"attributes,omitempty"`
+	Variants   []VariantJSON   `json:"variants,omitempty"`
+}
+
+type AttributeJSON struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type VariantJSON struct {
+	Code_value string          `json:"code_value"`
+	Quantity   int             `json:"quantity"`
+	Price      float64         `json:"price,omitempty"`
+	PriceMinor int64           `json:"price_minor"`
+	Attributes []AttributeJSON `json:"attributes,omitempty"`
+}
+
+// MoneyJSON is an amount in minor units of a currency
+type MoneyJSON struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// moneyJSON serializes an amount
+func moneyJSON(m internal.Money) MoneyJSON {
+	return MoneyJSON{Amount: m.Amount, Currency: m.Currency}
+}
+
+// money deserializes a stored price. Without currency it is a price of the files written before
+// currencies, in major units of the default currency.
+func money(minor int64, currency string, legacy float64) internal.Money {
+	if currency == "" {
+		m, _ := internal.NewMoney(legacy, internal.CurrencyDefault)
+		return m
+	}
+	return internal.Money{Amount: minor, Currency: currency}
+}
+
+// attributesJSON serializes attributes, nil if there are none
+func attributesJSON(attributes []internal.Attribute) (data []AttributeJSON) {
+	for _, a := range attributes {
+		data = append(data, AttributeJSON{Name: a.Name, Type: string(a.Type), Value: a.Value})
+	}
+	return
+}
+
+// attributes deserializes attributes, nil if there are none
+func attributes(data []AttributeJSON) (attributes []internal.Attribute) {
+	for _, a := range data {
+		attributes = append(attributes, internal.Attribute{Name: a.Name, Type: internal.AttributeType(a.Type), Value: a.Value})
+	}
+	return
+}
+
+// variantsJSON serializes variants, nil if there are none
+func variantsJSON(variants []internal.Variant) (data []VariantJSON) {
+	for _, v := range variants {
+		data = append(data, VariantJSON{Code_value: v.Code_value, Quantity: v.Quantity, PriceMinor: v.Price.Amount, Attributes: attributesJSON(v.Attributes)})
+	}
+	return
+}
+
+// variants deserializes the variants of a product stored in a currency, nil if there are none
+func variants(data []VariantJSON, currency string) (variants []internal.Variant) {
+	for _, v := range data {
+		variants = append(variants, internal.Variant{Code_value: v.Code_value, Quantity: v.Quantity, Price: money(v.PriceMinor, currency, v.Price), Attributes: attributes(v.Attributes)})
+	}
+	return
 }
 
 func (p *ProductMap) ReadProducts() {
 	// function to read products from products.json file and create a slice of products and then convert it to a map
 	f, err := os.Open("products.json")
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("error opening file", "file", "products.json", "error", err)
+		return
 	}
 	defer f.Close()
 
 	var products []ProductJSON
 	err = json.NewDecoder(f).Decode(&products)
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("error decoding file", "file", "products.json", "error", err)
 		return
 	}
 
 	for _, v := range products {
 		t, err := time.Parse("02/01/2006", v.Expiration)
 		if err != nil {
-			fmt.Println(err)
+			slog.Warn("error parsing time", "id", v.Id, "expiration", v.Expiration)
 		}
 		(*p).db[v.Id] = &internal.Product{
 			Id:           v.Id,
@@ -116,7 +224,9 @@ func (p *ProductMap) ReadProducts() {
 			Code_value:   v.Code_value,
 			Is_published: v.Is_published,
 			Expiration:   t,
-			Price:        v.Price,
+			Price:        money(v.PriceMinor, v.Currency, v.Price),
+			Attributes:   attributes(v.Attributes),
+			Variants:     variants(v.Variants, v.Currency),
 		}
 		p.lastID = v.Id
 	}
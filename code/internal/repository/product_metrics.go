@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/metrics"
+)
+
+var (
+	repositoryDuration = metrics.Default.NewHistogramVec("repository_operation_duration_seconds",
+		"Latency of product repository operations by tenant and operation.", metrics.DefaultBuckets, "tenant", "operation")
+	repositoryErrors = metrics.Default.NewCounterVec("repository_operation_errors_total",
+		"Number of failed product repository operations by tenant and operation.", "tenant", "operation")
+)
+
+// ProductMetrics is a ProductRepository that times every operation of the wrapped repository
+type ProductMetrics struct {
+	rp internal.ProductRepository
+
+	// tenant is the label the operations are reported under
+	tenant string
+}
+
+func NewProductMetrics(rp internal.ProductRepository, tenant string) *ProductMetrics {
+	return &ProductMetrics{
+		rp:     rp,
+		tenant: tenant,
+	}
+}
+
+func (p *ProductMetrics) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
+	defer p.observe("get_all", time.Now(), &err)
+	return p.rp.GetAll(ctx)
+}
+
+func (p *ProductMetrics) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	defer p.observe("each", time.Now(), &err)
+	return p.rp.Each(ctx, fn)
+}
+
+func (p *ProductMetrics) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
+	defer p.observe("get_by_id", time.Now(), &err)
+	return p.rp.GetByID(ctx, id)
+}
+
+func (p *ProductMetrics) Create(ctx context.Context, product *internal.Product) (err error) {
+	defer p.observe("create", time.Now(), &err)
+	return p.rp.Create(ctx, product)
+}
+
+func (p *ProductMetrics) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
+	defer p.observe("update_or_create", time.Now(), &err)
+	return p.rp.UpdateOrCreate(ctx, product)
+}
+
+func (p *ProductMetrics) Update(ctx context.Context, product *internal.Product) (err error) {
+	defer p.observe("update", time.Now(), &err)
+	return p.rp.Update(ctx, product)
+}
+
+func (p *ProductMetrics) Delete(ctx context.Context, id int) (err error) {
+	defer p.observe("delete", time.Now(), &err)
+	return p.rp.Delete(ctx, id)
+}
+
+func (p *ProductMetrics) LastModified(ctx context.Context) (t time.Time, err error) {
+	defer p.observe("last_modified", time.Now(), &err)
+	return p.rp.LastModified(ctx)
+}
+
+// observe records the duration of an operation started at start, and whether it failed
+func (p *ProductMetrics) observe(operation string, start time.Time, err *error) {
+	repositoryDuration.Observe(time.Since(start).Seconds(), p.tenant, operation)
+	if *err != nil {
+		repositoryErrors.Inc(p.tenant, operation)
+	}
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// PromotionJSON stores the promotions of every tenant in a single JSON file, replaced on every write
+type PromotionJSON struct {
+	// mu serializes the read-modify-write cycles on the file, so concurrent writes are never lost
+	mu sync.RWMutex
+
+	FilePath string
+}
+
+func NewPromotionJSON(filePath string) *PromotionJSON {
+	return &PromotionJSON{
+		FilePath: filePath,
+	}
+}
+
+type PromotionRecordJSON struct {
+	ID         int                      `json:"id"`
+	Tenant     string                   `json:"tenant,omitempty"`
+	Name       string                   `json:"name"`
+	Kind       string                   `json:"kind"`
+	Percent    float64                  `json:"percent,omitempty"`
+	Amount     *MoneyJSON               `json:"amount,omitempty"`
+	Buy        int                      `json:"buy,omitempty"`
+	Get        int                      `json:"get,omitempty"`
+	Conditions []PromotionConditionJSON `json:"conditions,omitempty"`
+	Starts     *time.Time               `json:"starts,omitempty"`
+	Ends       *time.Time               `json:"ends,omitempty"`
+	Priority   int                      `json:"priority,omitempty"`
+	Exclusive  bool                     `json:"exclusive,omitempty"`
+}
+
+type PromotionConditionJSON struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    any    `json:"value"`
+	Currency string `json:"currency,omitempty"`
+}
+
+func (p *PromotionJSON) GetAll(ctx context.Context, tenant string) (promotions []internal.Promotion, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	all, err := p.read()
+	if err != nil {
+		return
+	}
+	for _, v := range all {
+		if v.Tenant == tenant {
+			promotions = append(promotions, v)
+		}
+	}
+	return
+}
+
+func (p *PromotionJSON) Create(ctx context.Context, promotion *internal.Promotion) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all, err := p.read()
+	if err != nil {
+		return
+	}
+	// ids are shared by the tenants, so an id never designates two promotions
+	promotion.ID = 1
+	if n := len(all); n > 0 {
+		promotion.ID = all[n-1].ID + 1
+	}
+	return p.write(append(all, *promotion))
+}
+
+func (p *PromotionJSON) Update(ctx context.Context, promotion internal.Promotion) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all, err := p.read()
+	if err != nil {
+		return
+	}
+	for i, v := range all {
+		if v.ID == promotion.ID && v.Tenant == promotion.Tenant {
+			all[i] = promotion
+			return p.write(all)
+		}
+	}
+	err = fmt.Errorf("%w: id", internal.ErrPromotionNotFound)
+	return
+}
+
+func (p *PromotionJSON) Delete(ctx context.Context, tenant string, id int) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all, err := p.read()
+	if err != nil {
+		return
+	}
+	for i, v := range all {
+		if v.ID == id && v.Tenant == tenant {
+			return p.write(append(all[:i], all[i+1:]...))
+		}
+	}
+	err = fmt.Errorf("%w: id", internal.ErrPromotionNotFound)
+	return
+}
+
+// LastModified returns when the file was last written, for the promotions of every tenant
+func (p *PromotionJSON) LastModified(ctx context.Context) (t time.Time, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return modTime(p.FilePath)
+}
+
+// Check verifies that the file can be read and that its directory accepts writes
+func (p *PromotionJSON) Check(ctx context.Context) (err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if _, err = p.read(); err != nil {
+		return
+	}
+	return checkWritable(p.FilePath)
+}
+
+// read returns every promotion, in id order; a missing file has none
+func (p *PromotionJSON) read() (promotions []internal.Promotion, err error) {
+	b, err := os.ReadFile(p.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	var records []PromotionRecordJSON
+	if err = json.Unmarshal(b, &records); err != nil {
+		err = fmt.Errorf("%w: %s: %v", internal.ErrStoragePromotionFormat, p.FilePath, err)
+		return
+	}
+	promotions = make([]internal.Promotion, 0, len(records))
+	for _, r := range records {
+		v := internal.Promotion{
+			ID:        r.ID,
+			Tenant:    r.Tenant,
+			Name:      r.Name,
+			Kind:      internal.PromotionKind(r.Kind),
+			Percent:   r.Percent,
+			Buy:       r.Buy,
+			Get:       r.Get,
+			Priority:  r.Priority,
+			Exclusive: r.Exclusive,
+		}
+		if r.Amount != nil {
+			v.Amount = internal.Money{Amount: r.Amount.Amount, Currency: r.Amount.Currency}
+		}
+		for _, c := range r.Conditions {
+			v.Conditions = append(v.Conditions, internal.PromotionCondition{Field: c.Field, Operator: internal.PromotionOperator(c.Operator), Value: c.Value, Currency: c.Currency})
+		}
+		if r.Starts != nil {
+			v.Starts = *r.Starts
+		}
+		if r.Ends != nil {
+			v.Ends = *r.Ends
+		}
+		promotions = append(promotions, v)
+	}
+	sort.Slice(promotions, func(i, j int) bool { return promotions[i].ID < promotions[j].ID })
+	return
+}
+
+// write replaces the file
+func (p *PromotionJSON) write(promotions []internal.Promotion) (err error) {
+	records := make([]PromotionRecordJSON, 0, len(promotions))
+	for _, v := range promotions {
+		r := PromotionRecordJSON{
+			ID:        v.ID,
+			Tenant:    v.Tenant,
+			Name:      v.Name,
+			Kind:      string(v.Kind),
+			Percent:   v.Percent,
+			Buy:       v.Buy,
+			Get:       v.Get,
+			Priority:  v.Priority,
+			Exclusive: v.Exclusive,
+		}
+		if v.Amount != (internal.Money{}) {
+			amount := moneyJSON(v.Amount)
+			r.Amount = &amount
+		}
+		for _, c := range v.Conditions {
+			r.Conditions = append(r.Conditions, PromotionConditionJSON{Field: c.Field, Operator: string(c.Operator), Value: c.Value, Currency: c.Currency})
+		}
+		if !v.Starts.IsZero() {
+			starts := v.Starts.UTC()
+			r.Starts = &starts
+		}
+		if !v.Ends.IsZero() {
+			ends := v.Ends.UTC()
+			r.Ends = &ends
+		}
+		records = append(records, r)
+	}
+	return writeFileJSON(p.FilePath, records)
+}
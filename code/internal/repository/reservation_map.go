@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// ReservationMap keeps the reservations in memory: they live for minutes,
+// so on a restart the held units are simply released
+type ReservationMap struct {
+	mu sync.Mutex
+	// db is the reservations by tenant and id
+	db map[string]map[string]internal.Reservation
+	// modified is the last time a reservation of a tenant was created or removed
+	modified map[string]time.Time
+}
+
+func NewReservationMap() *ReservationMap {
+	return &ReservationMap{
+		db:       make(map[string]map[string]internal.Reservation),
+		modified: make(map[string]time.Time),
+	}
+}
+
+func (r *ReservationMap) Create(ctx context.Context, reservation internal.Reservation) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reservations, ok := r.db[reservation.Tenant]
+	if !ok {
+		reservations = make(map[string]internal.Reservation)
+		r.db[reservation.Tenant] = reservations
+	}
+	reservations[reservation.ID] = reservation
+	r.touch(reservation.Tenant, time.Now())
+	return
+}
+
+func (r *ReservationMap) GetByID(ctx context.Context, tenant, id string) (reservation internal.Reservation, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reservation, ok := r.db[tenant][id]
+	if !ok {
+		err = fmt.Errorf("%w: id", internal.ErrReservationNotFound)
+		return
+	}
+	return
+}
+
+func (r *ReservationMap) Delete(ctx context.Context, tenant, id string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.db[tenant][id]; !ok {
+		err = fmt.Errorf("%w: id", internal.ErrReservationNotFound)
+		return
+	}
+	delete(r.db[tenant], id)
+	r.touch(tenant, time.Now())
+	return
+}
+
+func (r *ReservationMap) Reserved(ctx context.Context, tenant string, now time.Time) (reserved map[int]int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reserved = make(map[int]int)
+	for _, v := range r.db[tenant] {
+		if !v.Expired(now) {
+			reserved[v.ProductID] += v.Quantity
+		}
+	}
+	return
+}
+
+func (r *ReservationMap) DeleteExpired(ctx context.Context, now time.Time) (expired []internal.Reservation, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, reservations := range r.db {
+		for id, v := range reservations {
+			if v.Expired(now) {
+				expired = append(expired, v)
+				delete(reservations, id)
+				// the units were released when it expired, not now
+				r.touch(v.Tenant, v.Expires)
+			}
+		}
+	}
+	return
+}
+
+func (r *ReservationMap) Modified(ctx context.Context, tenant string, now time.Time) (t time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t = r.modified[tenant]
+	for _, v := range r.db[tenant] {
+		if v.Expired(now) && v.Expires.After(t) {
+			t = v.Expires
+		}
+	}
+	return
+}
+
+// touch moves the last modification of the reservations of a tenant to t, if later; the caller holds mu
+func (r *ReservationMap) touch(tenant string, t time.Time) {
+	if t.After(r.modified[tenant]) {
+		r.modified[tenant] = t
+	}
+}
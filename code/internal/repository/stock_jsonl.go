@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// StockJSONL is an append-only stock ledger stored as one JSON object per line
+type StockJSONL struct {
+	// mu serializes the writes so concurrent movements are never interleaved
+	mu sync.Mutex
+
+	FilePath string
+}
+
+func NewStockJSONL(filePath string) *StockJSONL {
+	return &StockJSONL{
+		FilePath: filePath,
+	}
+}
+
+type StockMovementJSON struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Principal string    `json:"principal"`
+	RequestID string    `json:"request_id"`
+	ProductID int       `json:"product_id"`
+	Type      string    `json:"type"`
+	Delta     int       `json:"delta"`
+	Balance   int       `json:"balance"`
+	Reason    string    `json:"reason"`
+}
+
+func (s *StockJSONL) Append(ctx context.Context, movement internal.StockMovement) (err error) {
+	line, err := json.Marshal(StockMovementJSON{
+		ID:        movement.ID,
+		Time:      movement.Time,
+		Tenant:    movement.Tenant,
+		Principal: movement.Principal,
+		RequestID: movement.RequestID,
+		ProductID: movement.ProductID,
+		Type:      movement.Type,
+		Delta:     movement.Delta,
+		Balance:   movement.Balance,
+		Reason:    movement.Reason,
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.FilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if _, err = f.Write(line); err != nil {
+		return
+	}
+	err = f.Sync()
+	return
+}
+
+func (s *StockJSONL) Find(ctx context.Context, tenant string, productID int) (movements []internal.StockMovement, err error) {
+	err = s.scan(func(v StockMovementJSON) {
+		if v.Tenant != tenant || v.ProductID != productID {
+			return
+		}
+		movements = append(movements, internal.StockMovement{
+			ID:        v.ID,
+			Time:      v.Time,
+			Tenant:    v.Tenant,
+			Principal: v.Principal,
+			RequestID: v.RequestID,
+			ProductID: v.ProductID,
+			Type:      v.Type,
+			Delta:     v.Delta,
+			Balance:   v.Balance,
+			Reason:    v.Reason,
+		})
+	})
+	return
+}
+
+func (s *StockJSONL) Balances(ctx context.Context, tenant string) (balances map[int]int, err error) {
+	balances = make(map[int]int)
+	err = s.scan(func(v StockMovementJSON) {
+		if v.Tenant != tenant {
+			return
+		}
+		balances[v.ProductID] += v.Delta
+	})
+	return
+}
+
+// scan calls fn with every movement of the ledger, oldest first
+func (s *StockJSONL) scan(fn func(v StockMovementJSON)) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.FilePath)
+	if err != nil {
+		// a ledger that was never written is an empty one
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var v StockMovementJSON
+		if err = json.Unmarshal(sc.Bytes(), &v); err != nil {
+			return
+		}
+		fn(v)
+	}
+	return sc.Err()
+}
+
+// Check verifies that the ledger can be appended to
+func (s *StockJSONL) Check(ctx context.Context) (err error) {
+	return checkWritable(s.FilePath)
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// headerCSV is the first row of a products csv file. The attributes and variants columns hold json,
+// and the price is a decimal in major units of the currency. Files written before the attributes,
+// variants and currency columns existed, without them, are still read, their prices in the default currency.
+var headerCSV = []string{"id", "name", "quantity", "code_value", "is_published", "expiration", "price", "attributes", "variants", "currency"}
+
+const (
+	// columnsCSVLegacy is the number of columns of the files without attributes and variants
+	columnsCSVLegacy = 7
+	// columnsCSVNoCurrency is the number of columns of the files without currency
+	columnsCSVNoCurrency = 9
+)
+
+// StorageProductCSV stores the products as a csv file with a header row, for spreadsheets and bulk edits
+type StorageProductCSV struct {
+	FilePath   string
+	LayoutDate string
+}
+
+func NewStorageProductCSV(filePath string, layoutDate string) *StorageProductCSV {
+	return &StorageProductCSV{
+		FilePath:   filePath,
+		LayoutDate: layoutDate,
+	}
+}
+
+func (s *StorageProductCSV) ReadAll(ctx context.Context) (p map[int]*internal.Product, err error) {
+	f, err := os.Open(s.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			p, err = make(map[int]*internal.Product), nil
+		}
+		return
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	// every record has the columns of the header
+	r.FieldsPerRecord = 0
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			p, err = make(map[int]*internal.Product), nil
+		}
+		return
+	}
+	if header[0] != headerCSV[0] {
+		err = fmt.Errorf("%w: missing csv header", internal.ErrStorageProductFormat)
+		return
+	}
+	if len(header) != len(headerCSV) && len(header) != columnsCSVNoCurrency && len(header) != columnsCSVLegacy {
+		err = fmt.Errorf("%w: %d csv columns", internal.ErrStorageProductFormat, len(header))
+		return
+	}
+
+	p = make(map[int]*internal.Product)
+	for line := 2; ; line++ {
+		var record []string
+		record, err = r.Read()
+		if errors.Is(err, io.EOF) {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		var v internal.Product
+		if v, err = s.parse(record); err != nil {
+			err = fmt.Errorf("%w: line %d: %v", internal.ErrStorageProductFormat, line, err)
+			return
+		}
+		p[v.Id] = &v
+	}
+}
+
+// parse converts a csv record to a product
+func (s *StorageProductCSV) parse(record []string) (v internal.Product, err error) {
+	if v.Id, err = strconv.Atoi(record[0]); err != nil {
+		return
+	}
+	v.Name = record[1]
+	if v.Quantity, err = strconv.Atoi(record[2]); err != nil {
+		return
+	}
+	v.Code_value = record[3]
+	if v.Is_published, err = strconv.ParseBool(record[4]); err != nil {
+		return
+	}
+	if v.Expiration, err = time.Parse(s.LayoutDate, record[5]); err != nil {
+		return
+	}
+	// currency stays empty in the files without it, as the variants of those files are read in major units
+	var currency string
+	if len(record) == len(headerCSV) {
+		currency = record[9]
+	}
+	price, ok := new(big.Rat).SetString(record[6])
+	if !ok {
+		err = fmt.Errorf("invalid price %q", record[6])
+		return
+	}
+	priceCurrency := currency
+	if priceCurrency == "" {
+		priceCurrency = internal.CurrencyDefault
+	}
+	if v.Price, err = internal.FromRat(price, priceCurrency, internal.RoundHalfUp); err != nil {
+		return
+	}
+	if len(record) == columnsCSVLegacy {
+		return
+	}
+
+	var attrs []AttributeJSON
+	if record[7] != "" {
+		if err = json.Unmarshal([]byte(record[7]), &attrs); err != nil {
+			return
+		}
+	}
+	var vars []VariantJSON
+	if record[8] != "" {
+		if err = json.Unmarshal([]byte(record[8]), &vars); err != nil {
+			return
+		}
+	}
+	v.Attributes, v.Variants = attributes(attrs), variants(vars, currency)
+	return
+}
+
+func (s *StorageProductCSV) WriteAll(ctx context.Context, p map[int]*internal.Product) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(s.FilePath), filepath.Base(s.FilePath)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if err = f.Chmod(0644); err != nil {
+		return
+	}
+
+	ids := make([]int, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	w := csv.NewWriter(f)
+	w.Write(headerCSV)
+	for _, id := range ids {
+		v := p[id]
+		var attrs, vars []byte
+		if len(v.Attributes) > 0 {
+			if attrs, err = json.Marshal(attributesJSON(v.Attributes)); err != nil {
+				return
+			}
+		}
+		if len(v.Variants) > 0 {
+			if vars, err = json.Marshal(variantsJSON(v.Variants)); err != nil {
+				return
+			}
+		}
+		w.Write([]string{
+			strconv.Itoa(v.Id),
+			v.Name,
+			strconv.Itoa(v.Quantity),
+			v.Code_value,
+			strconv.FormatBool(v.Is_published),
+			v.Expiration.Format(s.LayoutDate),
+			v.Price.Rat().FloatString(internal.Currencies[v.Price.Currency]),
+			string(attrs),
+			string(vars),
+			v.Price.Currency,
+		})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	err = os.Rename(f.Name(), s.FilePath)
+	return
+}
@@ -1,12 +1,25 @@
 package repository
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/platform/metrics"
+)
+
+var (
+	storageSize = metrics.Default.NewGaugeVec("storage_file_size_bytes",
+		"Size of the products file as of its last read or write.", "storage")
+	storageProducts = metrics.Default.NewGaugeVec("storage_products",
+		"Number of products in the products file as of its last read or write.", "storage")
 )
 
 type StorageProductJSON struct {
@@ -21,61 +34,140 @@ func NewStorageProductJSON(filePath string, layoutDate string) *StorageProductJS
 	}
 }
 
-type ProductAttributesJSON struct {
-	Id           int     `json:"id"`
-	Name         string  `json:"name"`
-	Quantity     int     `json:"quantity"`
-	Code_value   string  `json:"code_value"`
-	Is_published bool    `json:"is_published"`
-	Expiration   string  `json:"expiration"`
-	Price        float64 `json:"price"`
+func (s *StorageProductJSON) ReadAll(ctx context.Context) (p map[int]*internal.Product, err error) {
+	// function to read products from products.json file and create a slice of products and then convert it to a map
+	list, err := s.ReadList(ctx)
+	if err != nil {
+		return
+	}
+
+	p = make(map[int]*internal.Product)
+	for _, v := range list {
+		p[v.Id] = v
+	}
+	return
 }
 
-func (s *StorageProductJSON) ReadAll() (p map[int]*internal.Product, err error) {
-	// function to read products from products.json file and create a slice of products and then convert it to a map
+// ReadList returns the products in file order, keeping the entries that share an id
+func (s *StorageProductJSON) ReadList(ctx context.Context) (p []*internal.Product, err error) {
 	f, err := os.Open(s.FilePath)
 	if err != nil {
-		fmt.Println("error opening file: ", s.FilePath)
+		// a tenant that never wrote a product has an empty catalog
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+			return
+		}
+		slog.ErrorContext(ctx, "error opening file", "file", s.FilePath, "error", err)
+		return
 	}
 	defer f.Close()
 
 	var products []ProductJSON
 	err = json.NewDecoder(f).Decode(&products)
 	if err != nil {
-		fmt.Println("error decoding file: ", s.FilePath)
+		slog.ErrorContext(ctx, "error decoding file", "file", s.FilePath, "error", err)
 		return
 	}
 
-	p = make(map[int]*internal.Product)
+	s.observe(f, len(products))
+
+	p = make([]*internal.Product, 0, len(products))
 	for _, v := range products {
-		t, err := time.Parse(s.LayoutDate, v.Expiration)
-		if err != nil {
-			fmt.Println("error parsing time: ", v.Expiration)
+		p = append(p, s.product(ctx, v))
+	}
+	return
+}
+
+// Each calls fn with the products in file order, decoding them one at a time so memory stays flat
+// whatever the size of the catalog. It stops at the first error of fn or of the context.
+// Writes replace the file with a rename, so the iteration reads the snapshot it opened.
+func (s *StorageProductJSON) Each(ctx context.Context, fn func(p *internal.Product) error) (err error) {
+	f, err := os.Open(s.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+			return
 		}
-		p[v.Id] = &internal.Product{
-			Id:           v.Id,
-			Name:         v.Name,
-			Quantity:     v.Quantity,
-			Code_value:   v.Code_value,
-			Is_published: v.Is_published,
-			Expiration:   t,
-			Price:        v.Price,
+		slog.ErrorContext(ctx, "error opening file", "file", s.FilePath, "error", err)
+		return
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	if tok, errTok := dec.Token(); errTok != nil || tok != json.Delim('[') {
+		err = fmt.Errorf("%w: %s is not a json array", internal.ErrStorageProductFormat, s.FilePath)
+		return
+	}
+
+	count := 0
+	for dec.More() {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		var v ProductJSON
+		if err = dec.Decode(&v); err != nil {
+			slog.ErrorContext(ctx, "error decoding file", "file", s.FilePath, "error", err)
+			return
+		}
+		count++
+		if err = fn(s.product(ctx, v)); err != nil {
+			return
 		}
 	}
+
+	s.observe(f, count)
 	return
 }
 
-func (s *StorageProductJSON) WriteAll(p map[int]*internal.Product) (err error) {
+// product converts a stored product, leaving the expiration zero if it cannot be parsed
+func (s *StorageProductJSON) product(ctx context.Context, v ProductJSON) *internal.Product {
+	t, err := time.Parse(s.LayoutDate, v.Expiration)
+	if err != nil {
+		slog.WarnContext(ctx, "error parsing time", "file", s.FilePath, "id", v.Id, "expiration", v.Expiration)
+	}
+	return &internal.Product{
+		Id:           v.Id,
+		Name:         v.Name,
+		Quantity:     v.Quantity,
+		Code_value:   v.Code_value,
+		Is_published: v.Is_published,
+		Expiration:   t,
+		Price:        money(v.PriceMinor, v.Currency, v.Price),
+		Attributes:   attributes(v.Attributes),
+		Variants:     variants(v.Variants, v.Currency),
+	}
+}
+
+func (s *StorageProductJSON) WriteAll(ctx context.Context, p map[int]*internal.Product) (err error) {
 	// function to write products to products.json file
-	f, err := os.OpenFile(s.FilePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	// - products are written to a temporary file that replaces the original once synced,
+	//   so an interrupted write never leaves a truncated file behind
+	f, err := os.CreateTemp(filepath.Dir(s.FilePath), filepath.Base(s.FilePath)+".*.tmp")
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "error opening file", "file", s.FilePath, "error", err)
+		return
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if err = f.Chmod(0644); err != nil {
 		return
 	}
-	defer f.Close()
 
-	var products []ProductJSON
-	for _, v := range p {
+	// products are written in id order, so the file is stable between writes
+	ids := make([]int, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	products := make([]ProductJSON, 0, len(p))
+	for _, id := range ids {
+		v := p[id]
 		products = append(products, ProductJSON{
 			Id:           v.Id,
 			Name:         v.Name,
@@ -83,14 +175,95 @@ func (s *StorageProductJSON) WriteAll(p map[int]*internal.Product) (err error) {
 			Code_value:   v.Code_value,
 			Is_published: v.Is_published,
 			Expiration:   v.Expiration.Format(s.LayoutDate),
-			Price:        v.Price,
+			PriceMinor:   v.Price.Amount,
+			Currency:     v.Price.Currency,
+			Attributes:   attributesJSON(v.Attributes),
+			Variants:     variantsJSON(v.Variants),
 		})
 	}
 
 	err = json.NewEncoder(f).Encode(products)
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "error encoding file", "file", s.FilePath, "error", err)
+		return
+	}
+	if err = f.Sync(); err != nil {
+		slog.ErrorContext(ctx, "error syncing file", "file", s.FilePath, "error", err)
+		return
+	}
+	s.observe(f, len(products))
+	if err = os.Rename(f.Name(), s.FilePath); err != nil {
+		slog.ErrorContext(ctx, "error replacing file", "file", s.FilePath, "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "products written", "file", s.FilePath, "count", len(products))
+	return
+}
+
+// observe updates the storage gauges from the open file and its number of products
+func (s *StorageProductJSON) observe(f *os.File, count int) {
+	if info, err := f.Stat(); err == nil {
+		storageSize.Set(float64(info.Size()), s.FilePath)
+	}
+	storageProducts.Set(float64(count), s.FilePath)
+}
+
+// LastID returns the greatest product id in the file, so new ids continue its sequence
+func (s *StorageProductJSON) LastID(ctx context.Context) (id int, err error) {
+	prods, err := s.ReadAll(ctx)
+	if err != nil {
 		return
 	}
+	for k := range prods {
+		if k > id {
+			id = k
+		}
+	}
 	return
 }
+
+// ModTime returns when the file was last written, zero if it does not exist yet.
+// Writes replace the whole file, so it is the time of the latest product change.
+func (s *StorageProductJSON) ModTime(ctx context.Context) (t time.Time, err error) {
+	return modTime(s.FilePath)
+}
+
+// modTime returns when a file was last written, zero if it does not exist yet
+func modTime(filePath string) (t time.Time, err error) {
+	info, err := os.Stat(filePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		err = nil
+	case err == nil:
+		t = info.ModTime()
+	}
+	return
+}
+
+// Check verifies that the file can be read and that its directory accepts writes,
+// without modifying the products
+func (s *StorageProductJSON) Check(ctx context.Context) (err error) {
+	if _, err = s.ReadAll(ctx); err != nil {
+		return
+	}
+	return checkWritable(s.FilePath)
+}
+
+// checkWritable verifies that the file, if it exists, can be opened for writing
+// and that a file can be created next to it
+func checkWritable(filePath string) (err error) {
+	f, err := os.OpenFile(filePath, os.O_WRONLY, 0)
+	switch {
+	case err == nil:
+		f.Close()
+	case !errors.Is(err, os.ErrNotExist):
+		return
+	}
+
+	f, err = os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.check")
+	if err != nil {
+		return
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
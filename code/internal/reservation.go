@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrReservationNotFound is returned when a reservation does not exist, or was committed or released
+	ErrReservationNotFound = errors.New("reservation not found")
+	// ErrReservationExpired is returned when a reservation is committed after its expiry
+	ErrReservationExpired = errors.New("reservation expired")
+	// ErrReservationInvalid is returned when a reservation has a wrong quantity or ttl
+	ErrReservationInvalid = errors.New("reservation invalid")
+)
+
+// Reservation holds units of a product for a while, e.g. during a checkout, so that no one else can buy them.
+// The held units stay in the quantity of the product until the reservation is committed as a sale.
+type Reservation struct {
+	// ID identifies the reservation
+	ID string
+	// Tenant is the tenant whose catalog the product belongs to
+	Tenant string
+	// Principal is who made the reservation, as reported by the auth layer
+	Principal string
+	// ProductID is the id of the product
+	ProductID int
+	// Quantity is the number of units held
+	Quantity int
+	// Created is when the reservation was made
+	Created time.Time
+	// Expires is when the units are released if the reservation is not committed
+	Expires time.Time
+}
+
+// Expired reports whether the reservation no longer holds its units at now
+func (r Reservation) Expired(now time.Time) bool {
+	return !now.Before(r.Expires)
+}
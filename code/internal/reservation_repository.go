@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type ReservationRepository interface {
+	// Stores a new reservation
+	Create(ctx context.Context, reservation Reservation) (err error)
+
+	// Returns a reservation of a tenant, expired or not
+	GetByID(ctx context.Context, tenant, id string) (reservation Reservation, err error)
+
+	// Removes a reservation of a tenant
+	Delete(ctx context.Context, tenant, id string) (err error)
+
+	// Returns the units held per product by the reservations of a tenant not expired at now
+	Reserved(ctx context.Context, tenant string, now time.Time) (reserved map[int]int, err error)
+
+	// Removes and returns the reservations of every tenant expired at now
+	DeleteExpired(ctx context.Context, now time.Time) (expired []Reservation, err error)
+
+	// Returns the last time the units held by the reservations of a tenant changed as seen at now:
+	// a reservation was created or removed, or expired
+	Modified(ctx context.Context, tenant string, now time.Time) (t time.Time, err error)
+}
@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+type ReservationService interface {
+	// Holds quantity units of a product for ttl, or the default ttl when it is zero
+	Reserve(ctx context.Context, productID, quantity int, ttl time.Duration) (reservation Reservation, err error)
+
+	// Returns a reservation
+	GetByID(ctx context.Context, id string) (reservation Reservation, err error)
+
+	// Turns a reservation into a sale of its units, returning the recorded movement
+	Commit(ctx context.Context, id string) (movement StockMovement, err error)
+
+	// Releases the units of a reservation
+	Release(ctx context.Context, id string) (err error)
+
+	// Releases the expired reservations of every tenant, returning how many were released
+	Sweep(ctx context.Context) (released int, err error)
+}
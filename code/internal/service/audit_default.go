@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+type AuditDefault struct {
+	rp internal.AuditRepository
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+func NewAuditDefault(rp internal.AuditRepository) *AuditDefault {
+	return &AuditDefault{
+		rp:  rp,
+		now: time.Now,
+	}
+}
+
+func (a *AuditDefault) Record(ctx context.Context, entry internal.AuditEntry) (err error) {
+	entry.Time = a.now().UTC()
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		entry.Principal = principal
+	}
+	entry.Tenant, _ = tenant.TenantFromContext(ctx)
+	entry.RequestID = internal.RequestIDFromContext(ctx)
+
+	if err = a.rp.Append(ctx, entry); err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrAuditRecord, err)
+	}
+	return
+}
+
+func (a *AuditDefault) Find(ctx context.Context, filter internal.AuditFilter) (entries []internal.AuditEntry, err error) {
+	// a tenant only sees the entries of its own catalog
+	if tenantID, ok := tenant.TenantFromContext(ctx); ok {
+		filter.Tenant = tenantID
+	}
+	return a.rp.Find(ctx, filter)
+}
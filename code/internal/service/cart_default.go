@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// CartDefault keeps the carts in step with the catalog: the lines are checked against the products
+// when they change and repriced whenever the cart is read
+type CartDefault struct {
+	// mu serializes the changes of the carts, so that concurrent changes of a cart are never lost
+	mu sync.Mutex
+
+	rp internal.CartRepository
+	// sv is the catalog, whose products report the units available
+	sv internal.ProductService
+	// orders places the orders of the carts checked out
+	orders internal.OrderService
+	// pr prices the lines with the promotions, nil if the lines are priced at the list prices
+	pr internal.PricingService
+
+	// ttl is the time a cart is kept after its last change
+	ttl time.Duration
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+func NewCartDefault(rp internal.CartRepository, sv internal.ProductService, orders internal.OrderService, ttl time.Duration) *CartDefault {
+	return &CartDefault{
+		rp:     rp,
+		sv:     sv,
+		orders: orders,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// WithPricing makes the carts price the lines with the promotions active when they are read
+func (c *CartDefault) WithPricing(pr internal.PricingService) *CartDefault {
+	c.pr = pr
+	return c
+}
+
+func (c *CartDefault) Create(ctx context.Context) (cart internal.Cart, err error) {
+	id, err := newID()
+	if err != nil {
+		return
+	}
+	now := c.now().UTC()
+	cart = internal.Cart{
+		ID:      id,
+		Lines:   []internal.CartLine{},
+		Created: now,
+		Updated: now,
+		Expires: now.Add(c.ttl),
+	}
+	cart.Tenant, _ = tenant.TenantFromContext(ctx)
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		cart.Principal = principal
+	}
+	if err = c.rp.Save(ctx, cart); err != nil {
+		cart = internal.Cart{}
+	}
+	return
+}
+
+func (c *CartDefault) GetByID(ctx context.Context, id string) (cart internal.Cart, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cart, err = c.get(ctx, id)
+	if err != nil {
+		return
+	}
+	changed, err := c.reprice(ctx, &cart)
+	if err != nil || !changed {
+		return
+	}
+	// the new prices are kept, so the previous ones are reported until the lines change
+	err = c.rp.Save(ctx, cart)
+	return
+}
+
+func (c *CartDefault) SetLine(ctx context.Context, id string, productID, quantity int) (cart internal.Cart, err error) {
+	if quantity <= 0 {
+		err = fmt.Errorf("%w: quantity", internal.ErrCartInvalid)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cart, err = c.get(ctx, id)
+	if err != nil {
+		return
+	}
+	product, err := c.sv.GetByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, internal.ErrProductNotFound) {
+			err = fmt.Errorf("%w: product %d: %w", internal.ErrCartInvalid, productID, err)
+		}
+		return
+	}
+	if !product.Is_published {
+		err = fmt.Errorf("%w: product %d is not published", internal.ErrCartInvalid, productID)
+		return
+	}
+	if available := product.Available(); quantity > available {
+		err = fmt.Errorf("%w: product %d: %d available, %d requested", internal.ErrStockInsufficient, productID, available, quantity)
+		return
+	}
+
+	// the line is priced with the promotions when the cart is saved
+	line := internal.CartLine{ProductID: productID, Name: product.Name, Quantity: quantity, UnitPrice: product.Price}
+	i := c.line(cart, productID)
+	// the lines of a cart add up to a total in a single currency
+	for j, l := range cart.Lines {
+		if j != i && l.UnitPrice.Currency != product.Price.Currency {
+			err = fmt.Errorf("%w: product %d is priced in %s, the cart in %s", internal.ErrCartInvalid, productID, product.Price.Currency, l.UnitPrice.Currency)
+			return
+		}
+	}
+	if i < 0 {
+		cart.Lines = append(cart.Lines, line)
+	} else {
+		cart.Lines[i] = line
+	}
+	return c.save(ctx, cart)
+}
+
+// RemoveLine removes the line of a product from a cart; removing a product the cart does not have changes nothing
+func (c *CartDefault) RemoveLine(ctx context.Context, id string, productID int) (cart internal.Cart, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cart, err = c.get(ctx, id)
+	if err != nil {
+		return
+	}
+	if i := c.line(cart, productID); i >= 0 {
+		cart.Lines = append(cart.Lines[:i], cart.Lines[i+1:]...)
+	}
+	return c.save(ctx, cart)
+}
+
+func (c *CartDefault) Delete(ctx context.Context, id string) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	return c.rp.Delete(ctx, tenantID, id)
+}
+
+func (c *CartDefault) Checkout(ctx context.Context, id string) (order internal.Order, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cart, err := c.get(ctx, id)
+	if err != nil {
+		return
+	}
+	if _, err = c.reprice(ctx, &cart); err != nil {
+		return
+	}
+	if len(cart.Lines) == 0 {
+		err = fmt.Errorf("%w: no lines", internal.ErrCartInvalid)
+		return
+	}
+
+	// the order takes the prices of the catalog and the promotions at once, which are the ones of the repriced cart
+	lines := make([]internal.OrderLine, 0, len(cart.Lines))
+	for _, l := range cart.Lines {
+		lines = append(lines, internal.OrderLine{ProductID: l.ProductID, Quantity: l.Quantity})
+	}
+	if order, err = c.orders.Create(ctx, lines); err != nil {
+		return
+	}
+	if errDelete := c.rp.Delete(ctx, cart.Tenant, cart.ID); errDelete != nil {
+		// the order is placed: the cart will be swept once expired
+		slog.ErrorContext(ctx, "checked out cart not deleted", "cart", cart.ID, "error", errDelete)
+	}
+	slog.InfoContext(ctx, "cart checked out", "cart", cart.ID, "order", order.ID)
+	return
+}
+
+func (c *CartDefault) Sweep(ctx context.Context) (deleted int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expired, err := c.rp.DeleteExpired(ctx, c.now())
+	if err != nil {
+		return
+	}
+	for _, v := range expired {
+		slog.InfoContext(ctx, "cart expired", "cart", v.ID, "tenant", v.Tenant, "lines", len(v.Lines))
+	}
+	deleted = len(expired)
+	return
+}
+
+// get returns a cart of the tenant that has not expired
+func (c *CartDefault) get(ctx context.Context, id string) (cart internal.Cart, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	cart, err = c.rp.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return
+	}
+	if cart.Expired(c.now()) {
+		err = fmt.Errorf("%w: at %s", internal.ErrCartExpired, cart.Expires.Format(time.RFC3339))
+		cart = internal.Cart{}
+	}
+	return
+}
+
+// save reprices a cart whose lines changed, extends its expiry and stores it
+func (c *CartDefault) save(ctx context.Context, cart internal.Cart) (saved internal.Cart, err error) {
+	if _, err = c.reprice(ctx, &cart); err != nil {
+		return
+	}
+	now := c.now().UTC()
+	cart.Updated = now
+	cart.Expires = now.Add(c.ttl)
+	if err = c.rp.Save(ctx, cart); err != nil {
+		return
+	}
+	saved = cart
+	return
+}
+
+// reprice sets the current name and price of the products on the lines, keeping the price the line had
+// when it changes, prices the lines with the active promotions and drops the lines of the products
+// deleted since. It reports whether the cart changed.
+func (c *CartDefault) reprice(ctx context.Context, cart *internal.Cart) (changed bool, err error) {
+	pricer, err := pricerOf(ctx, c.pr)
+	if err != nil {
+		return
+	}
+	lines := cart.Lines[:0]
+	for _, l := range cart.Lines {
+		product, errProduct := c.sv.GetByID(ctx, l.ProductID)
+		switch {
+		case errors.Is(errProduct, internal.ErrProductNotFound):
+			changed = true
+			continue
+		case errProduct != nil:
+			err = errProduct
+			return
+		}
+		quote, errQuote := pricer.Quote(*product, l.Quantity)
+		if errQuote != nil {
+			err = errQuote
+			return
+		}
+		if quote.Unit != l.UnitPrice {
+			l.PreviousUnitPrice, l.UnitPrice = l.UnitPrice, quote.Unit
+			changed = true
+		}
+		if quote.Total != l.Total || !slices.Equal(quote.Promotions, l.Promotions) {
+			l.Total, l.Promotions = quote.Total, quote.Promotions
+			changed = true
+		}
+		if product.Name != l.Name {
+			l.Name = product.Name
+			changed = true
+		}
+		lines = append(lines, l)
+	}
+	cart.Lines = lines
+	return
+}
+
+// line returns the index of the line of a product in a cart, -1 if the cart does not have it
+func (c *CartDefault) line(cart internal.Cart, productID int) int {
+	for i, l := range cart.Lines {
+		if l.ProductID == productID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// CategoryDefault keeps the categories of each tenant a tree: every parent exists and no category
+// is its own ancestor
+type CategoryDefault struct {
+	// mu serializes the changes of the categories, so that two moves never build a cycle together
+	mu sync.Mutex
+
+	rp internal.CategoryRepository
+	// sv is the catalog the assigned products must exist in
+	sv internal.ProductService
+}
+
+func NewCategoryDefault(rp internal.CategoryRepository, sv internal.ProductService) *CategoryDefault {
+	return &CategoryDefault{
+		rp: rp,
+		sv: sv,
+	}
+}
+
+func (c *CategoryDefault) GetAll(ctx context.Context) (categories []internal.Category, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	return c.rp.GetAll(ctx, tenantID)
+}
+
+func (c *CategoryDefault) GetByID(ctx context.Context, id int) (category internal.Category, err error) {
+	categories, err := c.tree(ctx)
+	if err != nil {
+		return
+	}
+	category, ok := categories[id]
+	if !ok {
+		err = fmt.Errorf("%w: id", internal.ErrCategoryNotFound)
+	}
+	return
+}
+
+func (c *CategoryDefault) Create(ctx context.Context, category *internal.Category) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	category.Tenant, _ = tenant.TenantFromContext(ctx)
+	categories, err := c.tree(ctx)
+	if err != nil {
+		return
+	}
+	if err = c.validate(categories, *category); err != nil {
+		return
+	}
+	if err = c.rp.Create(ctx, category); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "category created", "category", category.ID, "slug", category.Slug)
+	return
+}
+
+func (c *CategoryDefault) Update(ctx context.Context, category internal.Category) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	category.Tenant, _ = tenant.TenantFromContext(ctx)
+	categories, err := c.tree(ctx)
+	if err != nil {
+		return
+	}
+	if _, ok := categories[category.ID]; !ok {
+		err = fmt.Errorf("%w: id", internal.ErrCategoryNotFound)
+		return
+	}
+	if err = c.validate(categories, category); err != nil {
+		return
+	}
+	// a category cannot move under itself or any of its descendants
+	for id := category.ParentID; id != 0; id = categories[id].ParentID {
+		if id == category.ID {
+			err = fmt.Errorf("%w: %d under %d", internal.ErrCategoryCycle, category.ID, category.ParentID)
+			return
+		}
+	}
+	if err = c.rp.Update(ctx, category); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "category updated", "category", category.ID, "slug", category.Slug, "parent", category.ParentID)
+	return
+}
+
+func (c *CategoryDefault) Delete(ctx context.Context, id int) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	categories, err := c.tree(ctx)
+	if err != nil {
+		return
+	}
+	if _, ok := categories[id]; !ok {
+		err = fmt.Errorf("%w: id", internal.ErrCategoryNotFound)
+		return
+	}
+	for _, v := range categories {
+		if v.ParentID == id {
+			err = fmt.Errorf("%w: %d is the parent of %d", internal.ErrCategoryHasChildren, id, v.ID)
+			return
+		}
+	}
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	if err = c.rp.Delete(ctx, tenantID, id); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "category deleted", "category", id)
+	return
+}
+
+func (c *CategoryDefault) Path(ctx context.Context, id int) (path []string, err error) {
+	categories, err := c.tree(ctx)
+	if err != nil {
+		return
+	}
+	if _, ok := categories[id]; !ok {
+		err = fmt.Errorf("%w: id", internal.ErrCategoryNotFound)
+		return
+	}
+	return c.path(categories, id), nil
+}
+
+func (c *CategoryDefault) ProductCategories(ctx context.Context, productID int) (categories []internal.Category, err error) {
+	if _, err = c.sv.GetByID(ctx, productID); err != nil {
+		return
+	}
+	all, err := c.tree(ctx)
+	if err != nil {
+		return
+	}
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	assignments, err := c.rp.Assignments(ctx, tenantID)
+	if err != nil {
+		return
+	}
+	categories = []internal.Category{}
+	for _, id := range assignments[productID] {
+		if v, ok := all[id]; ok {
+			categories = append(categories, v)
+		}
+	}
+	return
+}
+
+func (c *CategoryDefault) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) (categories []internal.Category, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err = c.sv.GetByID(ctx, productID); err != nil {
+		return
+	}
+	all, err := c.tree(ctx)
+	if err != nil {
+		return
+	}
+	// the ids are kept sorted and once each, so the assignment reads the same however it was given
+	seen := make(map[int]bool)
+	ids := make([]int, 0, len(categoryIDs))
+	for _, id := range categoryIDs {
+		if _, ok := all[id]; !ok {
+			err = fmt.Errorf("%w: %d", internal.ErrCategoryNotFound, id)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	if err = c.rp.Assign(ctx, tenantID, productID, ids); err != nil {
+		return
+	}
+	categories = make([]internal.Category, 0, len(ids))
+	for _, id := range ids {
+		categories = append(categories, all[id])
+	}
+	slog.InfoContext(ctx, "product categorized", "id", productID, "categories", ids)
+	return
+}
+
+func (c *CategoryDefault) LastModified(ctx context.Context) (t time.Time, err error) {
+	return c.rp.LastModified(ctx)
+}
+
+func (c *CategoryDefault) ProductIDs(ctx context.Context, slug string) (ids map[int]bool, err error) {
+	categories, err := c.tree(ctx)
+	if err != nil {
+		return
+	}
+	root := 0
+	for _, v := range categories {
+		if v.Slug == slug {
+			root = v.ID
+			break
+		}
+	}
+	if root == 0 {
+		err = fmt.Errorf("%w: %s", internal.ErrCategoryNotFound, slug)
+		return
+	}
+
+	// a category is under the root if the root is one of its ancestors or itself
+	under := func(id int) bool {
+		for ; id != 0; id = categories[id].ParentID {
+			if id == root {
+				return true
+			}
+		}
+		return false
+	}
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	assignments, err := c.rp.Assignments(ctx, tenantID)
+	if err != nil {
+		return
+	}
+	ids = make(map[int]bool)
+	for productID, categoryIDs := range assignments {
+		for _, id := range categoryIDs {
+			if under(id) {
+				ids[productID] = true
+				break
+			}
+		}
+	}
+	return
+}
+
+// tree returns the categories of the tenant by id
+func (c *CategoryDefault) tree(ctx context.Context) (categories map[int]internal.Category, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	list, err := c.rp.GetAll(ctx, tenantID)
+	if err != nil {
+		return
+	}
+	categories = make(map[int]internal.Category, len(list))
+	for _, v := range list {
+		categories[v.ID] = v
+	}
+	return
+}
+
+// validate checks the fields of a category and that its slug is free and its parent exists
+func (c *CategoryDefault) validate(categories map[int]internal.Category, category internal.Category) (err error) {
+	if strings.TrimSpace(category.Name) == "" {
+		err = fmt.Errorf("%w: name", internal.ErrCategoryInvalid)
+		return
+	}
+	if !internal.ValidCategorySlug(category.Slug) {
+		err = fmt.Errorf("%w: slug %q", internal.ErrCategoryInvalid, category.Slug)
+		return
+	}
+	for _, v := range categories {
+		if v.Slug == category.Slug && v.ID != category.ID {
+			err = fmt.Errorf("%w: %s", internal.ErrCategorySlugTaken, category.Slug)
+			return
+		}
+	}
+	if _, ok := categories[category.ParentID]; category.ParentID != 0 && !ok {
+		err = fmt.Errorf("%w: parent %d", internal.ErrCategoryInvalid, category.ParentID)
+		return
+	}
+	return
+}
+
+// path returns the slugs from the root to a category
+func (c *CategoryDefault) path(categories map[int]internal.Category, id int) (path []string) {
+	for ; id != 0; id = categories[id].ParentID {
+		path = append([]string{categories[id].Slug}, path...)
+	}
+	return
+}
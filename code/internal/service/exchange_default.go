@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// NewExchangeDefault returns a new instance of ExchangeDefault
+func NewExchangeDefault(rates internal.ExchangeRates, rounding internal.Rounding) *ExchangeDefault {
+	return &ExchangeDefault{
+		rates:    rates,
+		rounding: rounding,
+	}
+}
+
+// ExchangeDefault converts amounts with a local table of exchange rates
+type ExchangeDefault struct {
+	rates    internal.ExchangeRates
+	rounding internal.Rounding
+}
+
+// Convert returns the amount in a currency, rounded to its minor unit
+func (e *ExchangeDefault) Convert(ctx context.Context, m internal.Money, currency string) (converted internal.Money, err error) {
+	if _, err = internal.Digits(currency); err != nil {
+		return
+	}
+	if m.Currency == currency {
+		converted = m
+		return
+	}
+	rate, ok := e.rates.Rate(m.Currency, currency)
+	if !ok {
+		err = fmt.Errorf("%w: %s to %s", internal.ErrExchangeRateMissing, m.Currency, currency)
+		return
+	}
+	converted, err = internal.FromRat(new(big.Rat).Mul(m.Rat(), rate), currency, e.rounding)
+	return
+}
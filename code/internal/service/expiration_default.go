@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// ExpirationDefault withdraws what expired from the catalogs of the tenants and alerts about it
+type ExpirationDefault struct {
+	// sv is the product service the products are read and unpublished through
+	sv internal.ProductService
+	// st is the stock the expired lots are written off from
+	st *StockDefault
+	// lots is the lots of the products
+	lots internal.LotRepository
+	// al is where the alerts are sent
+	al internal.AlertSender
+	// tenants is the ids of the tenants swept
+	tenants []string
+	// alertWithin is how long before their expiration the products are alerted
+	alertWithin time.Duration
+
+	// alerted is the keys of the alerts delivered, so each is sent once;
+	// kept in memory, so a restart may send an alert again
+	mu      sync.Mutex
+	alerted map[string]bool
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+func NewExpirationDefault(sv internal.ProductService, st *StockDefault, lots internal.LotRepository, al internal.AlertSender, tenants []string, alertWithin time.Duration) *ExpirationDefault {
+	return &ExpirationDefault{
+		sv:          sv,
+		st:          st,
+		lots:        lots,
+		al:          al,
+		tenants:     tenants,
+		alertWithin: alertWithin,
+		alerted:     make(map[string]bool),
+		now:         time.Now,
+	}
+}
+
+func (e *ExpirationDefault) Expiring(ctx context.Context, within time.Duration) (products []internal.Product, err error) {
+	all, err := e.sv.GetAll(ctx)
+	if err != nil {
+		return
+	}
+	now := e.now()
+	for _, v := range all {
+		if !v.Expired(now) && v.Expiration.Before(now.Add(within)) {
+			products = append(products, *v)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool {
+		if !products[i].Expiration.Equal(products[j].Expiration) {
+			return products[i].Expiration.Before(products[j].Expiration)
+		}
+		return products[i].Id < products[j].Id
+	})
+	return
+}
+
+func (e *ExpirationDefault) Sweep(ctx context.Context) (n int, err error) {
+	var errs []error
+	for _, id := range e.tenants {
+		swept, errTenant := e.sweep(tenant.ContextWithTenant(ctx, id), id)
+		n += swept
+		if errTenant != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", id, errTenant))
+		}
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "expired stock withdrawn", "count", n)
+	}
+	err = errors.Join(errs...)
+	return
+}
+
+// sweep withdraws what expired from the catalog of a tenant
+func (e *ExpirationDefault) sweep(ctx context.Context, tenantID string) (n int, err error) {
+	now := e.now()
+	all, err := e.sv.GetAll(ctx)
+	if err != nil {
+		return
+	}
+	ids := make([]int, 0, len(all))
+	for id := range all {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	// products
+	var errs []error
+	for _, id := range ids {
+		p := all[id]
+		switch {
+		case !p.Is_published:
+			continue
+		case p.Expired(now):
+			if err = e.sv.Unpublish(ctx, id); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n++
+			e.alert(ctx, internal.Alert{Event: internal.AlertProductExpired, Tenant: tenantID, ProductID: id, Name: p.Name, Quantity: p.Quantity, Expiration: p.Expiration})
+		case p.Expiration.Before(now.Add(e.alertWithin)):
+			e.alert(ctx, internal.Alert{Event: internal.AlertProductExpiring, Tenant: tenantID, ProductID: id, Name: p.Name, Quantity: p.Quantity, Expiration: p.Expiration})
+		}
+	}
+
+	// lots
+	// - the expired lots are written off, whether or not their product expired and was unpublished with them;
+	//   those of a deleted product are dropped
+	lots, err := e.lots.Expired(ctx, tenantID, now)
+	if err != nil {
+		errs = append(errs, err)
+		err = errors.Join(errs...)
+		return
+	}
+	for _, l := range lots {
+		p, ok := all[l.ProductID]
+		if !ok {
+			if err = e.lots.Replace(ctx, tenantID, l.ProductID, nil); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		// the lot is dropped with its write-off, so a lot written off since it was listed is skipped
+		lot := l
+		if _, lot, err = e.st.WriteOff(ctx, l.ProductID, l.Code); err != nil {
+			if !errors.Is(err, internal.ErrLotNotFound) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		n++
+		e.alert(ctx, internal.Alert{Event: internal.AlertLotExpired, Tenant: tenantID, ProductID: l.ProductID, Name: p.Name, Lot: lot.Code, Quantity: lot.Quantity, Expiration: lot.Expiration})
+	}
+	err = errors.Join(errs...)
+	return
+}
+
+// alert sends an alert unless it was already delivered; an alert not delivered is sent again
+// on the next sweep if it is still due
+func (e *ExpirationDefault) alert(ctx context.Context, alert internal.Alert) {
+	key := fmt.Sprintf("%s|%s|%d|%s|%d", alert.Event, alert.Tenant, alert.ProductID, alert.Lot, alert.Expiration.Unix())
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.alerted[key] {
+		return
+	}
+
+	alert.Key = key
+	alert.Time = e.now().UTC()
+	if err := e.al.Send(ctx, alert); err != nil {
+		slog.ErrorContext(ctx, "alert not delivered", "event", alert.Event, "id", alert.ProductID, "error", err)
+		return
+	}
+	e.alerted[key] = true
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// LotDefault receives the units of the products in lots, through the stock ledger,
+// so that the outgoing movements can take them first expiring first
+type LotDefault struct {
+	rp internal.LotRepository
+	// st is the stock the lots are received in, whose lock also guards the lots
+	st *StockDefault
+}
+
+func NewLotDefault(rp internal.LotRepository, st *StockDefault) *LotDefault {
+	return &LotDefault{
+		rp: rp,
+		st: st,
+	}
+}
+
+func (l *LotDefault) GetAll(ctx context.Context, productID int) (lots []internal.Lot, err error) {
+	if _, err = l.st.sv.GetByID(ctx, productID); err != nil {
+		return
+	}
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	return l.rp.Find(ctx, tenantID, productID)
+}
+
+func (l *LotDefault) Receive(ctx context.Context, productID int, lot internal.Lot) (received internal.Lot, err error) {
+	now := l.st.now()
+	switch {
+	case lot.Code == "":
+		err = fmt.Errorf("%w: code", internal.ErrLotInvalid)
+		return
+	case lot.Quantity <= 0:
+		err = fmt.Errorf("%w: quantity", internal.ErrLotInvalid)
+		return
+	case lot.Expired(now):
+		err = fmt.Errorf("%w: expiration", internal.ErrLotInvalid)
+		return
+	}
+
+	l.st.mu.Lock()
+	defer l.st.mu.Unlock()
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	lots, err := l.rp.Find(ctx, tenantID, productID)
+	if err != nil {
+		return
+	}
+	i := -1
+	for j, v := range lots {
+		if v.Code == lot.Code {
+			i = j
+			break
+		}
+	}
+	if i >= 0 && !lots[i].Expiration.Equal(lot.Expiration) {
+		err = fmt.Errorf("%w: lot %s expires on %s", internal.ErrLotInvalid, lot.Code, lots[i].Expiration.Format("2006-01-02"))
+		return
+	}
+
+	if _, err = l.st.move(ctx, productID, internal.StockMovementReceipt, lot.Quantity, "lot "+lot.Code, 0); err != nil {
+		return
+	}
+
+	if i >= 0 {
+		lots[i].Quantity += lot.Quantity
+		received = lots[i]
+	} else {
+		received = internal.Lot{
+			Tenant:     tenantID,
+			ProductID:  productID,
+			Code:       lot.Code,
+			Quantity:   lot.Quantity,
+			Expiration: lot.Expiration,
+			Received:   now.UTC(),
+		}
+		lots = append(lots, received)
+	}
+	if err = l.rp.Replace(ctx, tenantID, productID, lots); err != nil {
+		// the units are received all the same, only not tracked in the lot
+		slog.ErrorContext(ctx, "lot not recorded", "id", productID, "lot", lot.Code, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "lot received", "id", productID, "lot", lot.Code, "quantity", lot.Quantity)
+	return
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// OrderDefault places the orders and moves them through their states. It takes the lock of the stock,
+// so that the units of every line of an order are checked and taken at once.
+type OrderDefault struct {
+	rp internal.OrderRepository
+	st *StockDefault
+	// pr prices the lines with the promotions, nil if the lines are charged the list prices
+	pr internal.PricingService
+}
+
+func NewOrderDefault(rp internal.OrderRepository, st *StockDefault) *OrderDefault {
+	return &OrderDefault{
+		rp: rp,
+		st: st,
+	}
+}
+
+// WithPricing makes the orders charge the lines the prices of the promotions active when they are placed
+func (o *OrderDefault) WithPricing(pr internal.PricingService) *OrderDefault {
+	o.pr = pr
+	return o
+}
+
+func (o *OrderDefault) GetAll(ctx context.Context) (orders []internal.Order, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	return o.rp.GetAll(ctx, tenantID)
+}
+
+func (o *OrderDefault) GetByID(ctx context.Context, id int) (order internal.Order, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	return o.rp.GetByID(ctx, tenantID, id)
+}
+
+func (o *OrderDefault) Create(ctx context.Context, lines []internal.OrderLine) (order internal.Order, err error) {
+	if len(lines) == 0 {
+		err = fmt.Errorf("%w: no lines", internal.ErrOrderInvalid)
+		return
+	}
+	// one line per product, in the order they were first given
+	var merged []internal.OrderLine
+	index := make(map[int]int)
+	for _, l := range lines {
+		if l.Quantity <= 0 {
+			err = fmt.Errorf("%w: quantity of product %d", internal.ErrOrderInvalid, l.ProductID)
+			return
+		}
+		if i, ok := index[l.ProductID]; ok {
+			merged[i].Quantity += l.Quantity
+			continue
+		}
+		index[l.ProductID] = len(merged)
+		merged = append(merged, internal.OrderLine{ProductID: l.ProductID, Quantity: l.Quantity})
+	}
+
+	pricer, err := pricerOf(ctx, o.pr)
+	if err != nil {
+		return
+	}
+
+	o.st.mu.Lock()
+	defer o.st.mu.Unlock()
+
+	// every line is checked before any unit is taken
+	for i, l := range merged {
+		var product *internal.Product
+		product, err = o.st.product(ctx, l.ProductID)
+		if err != nil {
+			if errors.Is(err, internal.ErrProductNotFound) {
+				err = fmt.Errorf("%w: product %d: %w", internal.ErrOrderInvalid, l.ProductID, err)
+			}
+			return
+		}
+		if !product.Is_published {
+			err = fmt.Errorf("%w: product %d is not published", internal.ErrOrderInvalid, l.ProductID)
+			return
+		}
+		var reserved int
+		if reserved, err = o.st.reserved(ctx, l.ProductID); err != nil {
+			return
+		}
+		if available := product.Quantity - reserved; l.Quantity > available {
+			err = fmt.Errorf("%w: product %d: %d available, %d requested", internal.ErrStockInsufficient, l.ProductID, max(available, 0), l.Quantity)
+			return
+		}
+		// the lines of an order add up to a total in a single currency
+		if i > 0 && product.Price.Currency != merged[0].UnitPrice.Currency {
+			err = fmt.Errorf("%w: product %d is priced in %s, the order in %s", internal.ErrOrderInvalid, l.ProductID, product.Price.Currency, merged[0].UnitPrice.Currency)
+			return
+		}
+		// the price is the one at order time, with the promotions then, whatever they become later
+		var quote internal.Quote
+		if quote, err = pricer.Quote(*product, l.Quantity); err != nil {
+			return
+		}
+		merged[i].Name = product.Name
+		merged[i].UnitPrice = quote.Unit
+		merged[i].Total = quote.Total
+		merged[i].Promotions = quote.Promotions
+	}
+
+	now := o.st.now().UTC()
+	order = internal.Order{
+		State:   internal.OrderStatePending,
+		Lines:   merged,
+		Created: now,
+		Updated: now,
+	}
+	order.Tenant, _ = tenant.TenantFromContext(ctx)
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		order.Principal = principal
+	}
+	if err = o.rp.Create(ctx, &order); err != nil {
+		return
+	}
+
+	reason := "order " + strconv.Itoa(order.ID)
+	for i, l := range order.Lines {
+		if _, err = o.st.move(ctx, l.ProductID, internal.StockMovementSale, -l.Quantity, reason, 0); err != nil {
+			// the units already taken are given back, and the order is left cancelled
+			o.restock(ctx, order.Lines[:i], reason+" failed")
+			order.State = internal.OrderStateCancelled
+			if errUpdate := o.rp.Update(ctx, order); errUpdate != nil {
+				slog.ErrorContext(ctx, "failed order not cancelled", "order", order.ID, "error", errUpdate)
+			}
+			order = internal.Order{}
+			return
+		}
+	}
+	slog.InfoContext(ctx, "order placed", "order", order.ID, "lines", len(order.Lines), "total", order.Total().String())
+	return
+}
+
+func (o *OrderDefault) Transition(ctx context.Context, id int, state string) (order internal.Order, err error) {
+	o.st.mu.Lock()
+	defer o.st.mu.Unlock()
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	order, err = o.rp.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return
+	}
+	if !internal.OrderTransition(order.State, state) {
+		err = fmt.Errorf("%w: from %s to %s", internal.ErrOrderTransition, order.State, state)
+		return
+	}
+
+	from := order.State
+	order.State = state
+	order.Updated = o.st.now().UTC()
+	if err = o.rp.Update(ctx, order); err != nil {
+		return
+	}
+	if state == internal.OrderStateCancelled {
+		o.restock(ctx, order.Lines, "order "+strconv.Itoa(order.ID)+" cancelled")
+	}
+	slog.InfoContext(ctx, "order transitioned", "order", order.ID, "from", from, "to", state)
+	return
+}
+
+// restock gives the units of the lines back to the stock. A line whose product was deleted
+// since cannot be given back, which is logged rather than failing the whole cancellation.
+func (o *OrderDefault) restock(ctx context.Context, lines []internal.OrderLine, reason string) {
+	for _, l := range lines {
+		if _, err := o.st.move(ctx, l.ProductID, internal.StockMovementReturn, l.Quantity, reason, 0); err != nil {
+			slog.ErrorContext(ctx, "order units not restocked", "id", l.ProductID, "quantity", l.Quantity, "reason", reason, "error", err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// PriceDefault keeps the price history of the products and applies their scheduled price changes
+type PriceDefault struct {
+	rp      internal.PriceScheduleRepository
+	history internal.PriceHistoryRepository
+	// sv is the product service the scheduled prices are written through, which records them in the history
+	sv internal.ProductService
+	// tenants is the ids of the tenants whose scheduled changes are applied
+	tenants []string
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+func NewPriceDefault(rp internal.PriceScheduleRepository, history internal.PriceHistoryRepository, sv internal.ProductService, tenants []string) *PriceDefault {
+	return &PriceDefault{
+		rp:      rp,
+		history: history,
+		sv:      sv,
+		tenants: tenants,
+		now:     time.Now,
+	}
+}
+
+func (p *PriceDefault) History(ctx context.Context, productID int) (changes []internal.PriceChange, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	changes, err = p.history.Find(ctx, tenantID, productID)
+	if err != nil {
+		return
+	}
+
+	// the history outlives a deleted product, but a product that never existed has none
+	if len(changes) == 0 {
+		_, err = p.sv.GetByID(ctx, productID)
+	}
+	return
+}
+
+func (p *PriceDefault) Scheduled(ctx context.Context, productID int) (schedules []internal.PriceSchedule, err error) {
+	if _, err = p.sv.GetByID(ctx, productID); err != nil {
+		return
+	}
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	return p.rp.Find(ctx, tenantID, productID)
+}
+
+func (p *PriceDefault) Schedule(ctx context.Context, productID int, price float64, effective time.Time) (schedule internal.PriceSchedule, err error) {
+	now := p.now()
+	switch {
+	case price < 0:
+		err = fmt.Errorf("%w: price", internal.ErrPriceScheduleInvalid)
+		return
+	case !effective.After(now):
+		err = fmt.Errorf("%w: effective", internal.ErrPriceScheduleInvalid)
+		return
+	}
+	product, err := p.sv.GetByID(ctx, productID)
+	if err != nil {
+		return
+	}
+	amount, err := internal.NewMoney(price, product.Price.Currency)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", internal.ErrPriceScheduleInvalid, err)
+		return
+	}
+
+	schedule = internal.PriceSchedule{
+		ProductID: productID,
+		Price:     amount,
+		Effective: effective.UTC(),
+		Created:   now.UTC(),
+	}
+	if schedule.ID, err = newID(); err != nil {
+		return
+	}
+	schedule.Tenant, _ = tenant.TenantFromContext(ctx)
+	schedule.Principal, _ = auth.PrincipalFromContext(ctx)
+	if err = p.rp.Create(ctx, schedule); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "price scheduled", "id", productID, "schedule", schedule.ID, "price", schedule.Price.String(), "effective", schedule.Effective)
+	return
+}
+
+func (p *PriceDefault) Cancel(ctx context.Context, productID int, id string) (err error) {
+	schedules, err := p.Scheduled(ctx, productID)
+	if err != nil {
+		return
+	}
+	for _, v := range schedules {
+		if v.ID == id {
+			if err = p.rp.Delete(ctx, v.Tenant, id); err != nil {
+				return
+			}
+			slog.InfoContext(ctx, "price schedule cancelled", "id", productID, "schedule", id)
+			return
+		}
+	}
+	err = fmt.Errorf("%w: id", internal.ErrPriceScheduleNotFound)
+	return
+}
+
+func (p *PriceDefault) Apply(ctx context.Context) (n int, err error) {
+	now := p.now()
+	var errs []error
+	for _, tenantID := range p.tenants {
+		ctxTenant := tenant.ContextWithTenant(ctx, tenantID)
+		schedules, errDue := p.rp.Due(ctxTenant, tenantID, now)
+		if errDue != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenantID, errDue))
+			continue
+		}
+		for _, s := range schedules {
+			if err = p.apply(ctxTenant, s); err != nil {
+				errs = append(errs, fmt.Errorf("tenant %s: schedule %s: %w", tenantID, s.ID, err))
+				continue
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "scheduled prices applied", "count", n)
+	}
+	err = errors.Join(errs...)
+	return
+}
+
+// apply sets the price of a scheduled change on its product, on behalf of whom scheduled it.
+// A change that can never apply, e.g. to a deleted or expired product or to one priced in another currency since,
+// is dropped; any other failure is retried.
+func (p *PriceDefault) apply(ctx context.Context, s internal.PriceSchedule) (err error) {
+	ctx = context.WithValue(auth.ContextWithPrincipal(ctx, s.Principal), scheduleKey{}, s.ID)
+
+	product, err := p.sv.GetByID(ctx, s.ProductID)
+	switch {
+	case err != nil:
+	case product.Price.Currency != s.Price.Currency:
+		// the product changed currency since the change was scheduled, whose amount means nothing in the new one
+		err = fmt.Errorf("%w: priced in %s, the product in %s", internal.ErrPriceScheduleInvalid, s.Price.Currency, product.Price.Currency)
+	default:
+		prod := *product
+		prod.Price = s.Price
+		err = p.sv.Update(ctx, &prod)
+	}
+	switch {
+	case err == nil:
+		slog.InfoContext(ctx, "scheduled price applied", "id", s.ProductID, "schedule", s.ID, "price", s.Price.String())
+	case errors.Is(err, internal.ErrProductNotFound), errors.Is(err, internal.ErrFieldRequired), errors.Is(err, internal.ErrValidateQualityField),
+		errors.Is(err, internal.ErrProductVariantInvalid), errors.Is(err, internal.ErrPriceScheduleInvalid):
+		slog.WarnContext(ctx, "scheduled price dropped", "id", s.ProductID, "schedule", s.ID, "error", err)
+		err = nil
+	default:
+		return
+	}
+	return p.rp.Delete(ctx, s.Tenant, s.ID)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// PricingDefault prices the products with the promotions of their tenant
+type PricingDefault struct {
+	rp internal.PromotionRepository
+	// ct resolves the category conditions, nil if the products are not categorized
+	ct internal.CategoryService
+	// rounding rounds the discounted prices to the minor unit of their currency
+	rounding internal.Rounding
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+func NewPricingDefault(rp internal.PromotionRepository, ct internal.CategoryService, rounding internal.Rounding) *PricingDefault {
+	return &PricingDefault{
+		rp:       rp,
+		ct:       ct,
+		rounding: rounding,
+		now:      time.Now,
+	}
+}
+
+func (p *PricingDefault) Pricer(ctx context.Context) (pricer internal.Pricer, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	all, err := p.rp.GetAll(ctx, tenantID)
+	if err != nil {
+		return
+	}
+
+	// the active promotions are kept in the order they apply: the highest priority first, then by id
+	now := p.now()
+	var promotions []internal.Promotion
+	for _, v := range all {
+		if v.Active(now) {
+			promotions = append(promotions, v)
+		}
+	}
+	sort.SliceStable(promotions, func(i, j int) bool { return promotions[i].Priority > promotions[j].Priority })
+
+	// the products of the categories of the conditions are looked up once for all the products
+	categories := make(map[string]map[int]bool)
+	for _, v := range promotions {
+		for _, c := range v.Conditions {
+			if c.Field != internal.PromotionFieldCategory {
+				continue
+			}
+			slugs := []any{c.Value}
+			if list, ok := c.Value.([]any); ok {
+				slugs = list
+			}
+			for _, s := range slugs {
+				slug, _ := s.(string)
+				if _, ok := categories[slug]; ok || p.ct == nil {
+					continue
+				}
+				var ids map[int]bool
+				ids, err = p.ct.ProductIDs(ctx, slug)
+				switch {
+				case errors.Is(err, internal.ErrCategoryNotFound):
+					// a deleted category has no products
+					ids, err = nil, nil
+				case err != nil:
+					return
+				}
+				categories[slug] = ids
+			}
+		}
+	}
+
+	pricer = &pricerDefault{promotions: promotions, categories: categories, rounding: p.rounding}
+	return
+}
+
+// LastModified is the latest of the writes of the promotions and of the categories, and of the starts and
+// ends of the promotions of the tenant up to now, which change the effective prices without any write
+func (p *PricingDefault) LastModified(ctx context.Context) (t time.Time, err error) {
+	if t, err = p.rp.LastModified(ctx); err != nil {
+		return
+	}
+	if p.ct != nil {
+		var categories time.Time
+		if categories, err = p.ct.LastModified(ctx); err != nil {
+			return
+		}
+		t = later(t, categories)
+	}
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	promotions, err := p.rp.GetAll(ctx, tenantID)
+	if err != nil {
+		return
+	}
+	now := p.now()
+	for _, v := range promotions {
+		for _, boundary := range []time.Time{v.Starts, v.Ends} {
+			if !boundary.After(now) {
+				t = later(t, boundary)
+			}
+		}
+	}
+	return
+}
+
+// later returns the later of two times
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// pricerOf returns a pricer with the promotions of pr, or one at the list prices if pr is nil
+func pricerOf(ctx context.Context, pr internal.PricingService) (p internal.Pricer, err error) {
+	if pr == nil {
+		// without promotions the total is the list price of the units, which needs no rounding
+		p = &pricerDefault{rounding: internal.RoundHalfUp}
+		return
+	}
+	return pr.Pricer(ctx)
+}
+
+// pricerDefault applies a snapshot of the active promotions of a tenant
+type pricerDefault struct {
+	// promotions are the active promotions, in the order they apply
+	promotions []internal.Promotion
+	// categories are the ids of the products of each category of the conditions, by slug
+	categories map[string]map[int]bool
+	rounding   internal.Rounding
+}
+
+// Quote applies the promotions the product meets in turn: the percent and amount ones lower the price
+// of the units, the buy_get ones the number of units paid. A promotion that lowers nothing is not applied,
+// so it does not stop the others even if it is exclusive.
+func (p *pricerDefault) Quote(product internal.Product, quantity int) (quote internal.Quote, err error) {
+	if quantity < 1 {
+		err = fmt.Errorf("%w: quantity %d", internal.ErrPricingQuantity, quantity)
+		return
+	}
+	in := func(slug string) bool {
+		return p.categories[slug][product.Id]
+	}
+
+	unit, paid := product.Price, quantity
+	for _, v := range p.promotions {
+		if !p.match(v, product, in) {
+			continue
+		}
+		applied := false
+		switch v.Kind {
+		case internal.PromotionPercent:
+			percent, _ := new(big.Rat).SetString(strconv.FormatFloat(v.Percent, 'f', -1, 64))
+			rest := new(big.Rat).Sub(big.NewRat(100, 1), percent)
+			var discounted internal.Money
+			discounted, err = internal.FromRat(new(big.Rat).Mul(unit.Rat(), rest.Quo(rest, big.NewRat(100, 1))), unit.Currency, p.rounding)
+			if err != nil {
+				return
+			}
+			applied, unit = discounted != unit, discounted
+		case internal.PromotionAmount:
+			discounted := internal.Money{Amount: max(unit.Amount-v.Amount.Amount, 0), Currency: unit.Currency}
+			applied, unit = discounted != unit, discounted
+		case internal.PromotionBuyGet:
+			free := paid / (v.Buy + v.Get) * v.Get
+			applied, paid = free > 0, paid-free
+		}
+		if !applied {
+			continue
+		}
+		quote.Promotions = append(quote.Promotions, v.ID)
+		if v.Exclusive {
+			break
+		}
+	}
+
+	quote.Quantity = quantity
+	quote.Unit = product.Price
+	quote.Total = unit.Times(paid)
+	quote.Effective, err = internal.FromRat(new(big.Rat).Quo(quote.Total.Rat(), big.NewRat(int64(quantity), 1)), unit.Currency, p.rounding)
+	return
+}
+
+// match reports whether a product meets every condition of a promotion; an amount promotion
+// only applies to the products priced in its currency
+func (p *pricerDefault) match(promotion internal.Promotion, product internal.Product, in func(slug string) bool) bool {
+	if promotion.Kind == internal.PromotionAmount && promotion.Amount.Currency != product.Price.Currency {
+		return false
+	}
+	for _, c := range promotion.Conditions {
+		if !c.Match(product, in) {
+			return false
+		}
+	}
+	return true
+}
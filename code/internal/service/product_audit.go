@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// ProductAudit is a ProductService that records every successful mutation in the audit log
+// before handing the result back to the caller. The mutation is committed by then, so an entry
+// that cannot be recorded is logged rather than failing a change the client would retry.
+type ProductAudit struct {
+	sv internal.ProductService
+	au internal.AuditService
+}
+
+func NewProductAudit(sv internal.ProductService, au internal.AuditService) *ProductAudit {
+	return &ProductAudit{
+		sv: sv,
+		au: au,
+	}
+}
+
+func (p *ProductAudit) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
+	return p.sv.GetAll(ctx)
+}
+
+func (p *ProductAudit) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	return p.sv.Each(ctx, fn)
+}
+
+func (p *ProductAudit) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
+	return p.sv.GetByID(ctx, id)
+}
+
+func (p *ProductAudit) SearchByPrice(ctx context.Context, price internal.Money) (products map[int]*internal.Product, err error) {
+	return p.sv.SearchByPrice(ctx, price)
+}
+
+func (p *ProductAudit) Create(ctx context.Context, product *internal.Product) (err error) {
+	if err = p.sv.Create(ctx, product); err != nil {
+		return
+	}
+
+	p.record(ctx, internal.AuditEntry{
+		Action:    internal.AuditActionCreate,
+		ProductID: product.Id,
+		After:     snapshot(product),
+	})
+	return
+}
+
+func (p *ProductAudit) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
+	before, err := p.before(ctx, product.Id)
+	if err != nil {
+		return
+	}
+
+	prod, err = p.sv.UpdateOrCreate(ctx, product)
+	if err != nil {
+		return
+	}
+
+	p.record(ctx, internal.AuditEntry{
+		Action:    internal.AuditActionUpdateOrCreate,
+		ProductID: prod.Id,
+		Before:    before,
+		After:     snapshot(&prod),
+	})
+	return
+}
+
+func (p *ProductAudit) Update(ctx context.Context, product *internal.Product) (err error) {
+	before, err := p.before(ctx, product.Id)
+	if err != nil {
+		return
+	}
+
+	if err = p.sv.Update(ctx, product); err != nil {
+		return
+	}
+
+	p.record(ctx, internal.AuditEntry{
+		Action:    internal.AuditActionUpdate,
+		ProductID: product.Id,
+		Before:    before,
+		After:     snapshot(product),
+	})
+	return
+}
+
+func (p *ProductAudit) Delete(ctx context.Context, id int) (err error) {
+	before, err := p.before(ctx, id)
+	if err != nil {
+		return
+	}
+
+	if err = p.sv.Delete(ctx, id); err != nil {
+		return
+	}
+
+	p.record(ctx, internal.AuditEntry{
+		Action:    internal.AuditActionDelete,
+		ProductID: id,
+		Before:    before,
+	})
+	return
+}
+
+func (p *ProductAudit) Unpublish(ctx context.Context, id int) (err error) {
+	before, err := p.before(ctx, id)
+	if err != nil {
+		return
+	}
+	if err = p.sv.Unpublish(ctx, id); err != nil || before == nil || !before.Is_published {
+		return
+	}
+
+	after := snapshot(before)
+	after.Is_published = false
+	p.record(ctx, internal.AuditEntry{
+		Action:    internal.AuditActionUnpublish,
+		ProductID: id,
+		Before:    before,
+		After:     after,
+	})
+	return
+}
+
+func (p *ProductAudit) LastModified(ctx context.Context) (t time.Time, err error) {
+	return p.sv.LastModified(ctx)
+}
+
+// record appends an entry to the audit log, logging it if it cannot be recorded
+func (p *ProductAudit) record(ctx context.Context, entry internal.AuditEntry) {
+	if err := p.au.Record(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "audit product", "action", entry.Action, "id", entry.ProductID, "error", err)
+	}
+}
+
+// before returns a snapshot of the product as it is before a mutation, or nil if it does not exist yet
+func (p *ProductAudit) before(ctx context.Context, id int) (product *internal.Product, err error) {
+	current, err := p.sv.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, internal.ErrProductNotFound) {
+			err = nil
+		}
+		return
+	}
+	product = snapshot(current)
+	return
+}
+
+// snapshot returns a copy of the product, so later mutations do not alter the recorded state
+func snapshot(product *internal.Product) *internal.Product {
+	if product == nil {
+		return nil
+	}
+	cp := *product
+	cp.Attributes = slices.Clone(product.Attributes)
+	cp.Variants = slices.Clone(product.Variants)
+	return &cp
+}
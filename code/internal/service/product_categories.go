@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// ProductCategories is a ProductService that unassigns the categories of the products deleted, and of the
+// products created with the id of a deleted one, so that a product never inherits the categories of another
+type ProductCategories struct {
+	sv internal.ProductService
+	rp internal.CategoryRepository
+}
+
+func NewProductCategories(sv internal.ProductService, rp internal.CategoryRepository) *ProductCategories {
+	return &ProductCategories{
+		sv: sv,
+		rp: rp,
+	}
+}
+
+func (p *ProductCategories) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
+	return p.sv.GetAll(ctx)
+}
+
+func (p *ProductCategories) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	return p.sv.Each(ctx, fn)
+}
+
+func (p *ProductCategories) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
+	return p.sv.GetByID(ctx, id)
+}
+
+func (p *ProductCategories) SearchByPrice(ctx context.Context, price internal.Money) (products map[int]*internal.Product, err error) {
+	return p.sv.SearchByPrice(ctx, price)
+}
+
+func (p *ProductCategories) Create(ctx context.Context, product *internal.Product) (err error) {
+	if err = p.sv.Create(ctx, product); err != nil {
+		return
+	}
+	// the ids are reused once the catalog is reloaded, and a product deleted offline kept its categories
+	p.unassign(ctx, product.Id)
+	return
+}
+
+func (p *ProductCategories) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
+	_, err = p.sv.GetByID(ctx, product.Id)
+	existed := err == nil
+	if err != nil && !errors.Is(err, internal.ErrProductNotFound) {
+		return
+	}
+
+	if prod, err = p.sv.UpdateOrCreate(ctx, product); err != nil {
+		return
+	}
+	if !existed {
+		p.unassign(ctx, prod.Id)
+	}
+	return
+}
+
+func (p *ProductCategories) Update(ctx context.Context, product *internal.Product) (err error) {
+	return p.sv.Update(ctx, product)
+}
+
+func (p *ProductCategories) Delete(ctx context.Context, id int) (err error) {
+	if err = p.sv.Delete(ctx, id); err != nil {
+		return
+	}
+	p.unassign(ctx, id)
+	return
+}
+
+func (p *ProductCategories) Unpublish(ctx context.Context, id int) (err error) {
+	return p.sv.Unpublish(ctx, id)
+}
+
+func (p *ProductCategories) LastModified(ctx context.Context) (t time.Time, err error) {
+	return p.sv.LastModified(ctx)
+}
+
+// unassign removes the categories of a product, if it has any. The product has changed by then, so
+// a failure is logged rather than failing a write the client would retry.
+func (p *ProductCategories) unassign(ctx context.Context, id int) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	assignments, err := p.rp.Assignments(ctx, tenantID)
+	if err == nil && len(assignments[id]) > 0 {
+		err = p.rp.Assign(ctx, tenantID, id, nil)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "unassign product categories", "id", id, "error", err)
+		return
+	}
+	if len(assignments[id]) > 0 {
+		slog.InfoContext(ctx, "product uncategorized", "id", id, "categories", assignments[id])
+	}
+}
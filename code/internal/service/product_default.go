@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"regexp"
 	"time"
 
@@ -11,6 +13,8 @@ import (
 
 type ProductDefault struct {
 	rp internal.ProductRepository
+	// ex converts the prices searched in another currency, nil if they are only compared in their own
+	ex internal.ExchangeService
 }
 
 func NewProductDefault(rp internal.ProductRepository) *ProductDefault {
@@ -19,12 +23,22 @@ func NewProductDefault(rp internal.ProductRepository) *ProductDefault {
 	}
 }
 
-func (p *ProductDefault) GetAll() (products map[int]*internal.Product, err error) {
-	return p.rp.GetAll()
+// WithExchange makes the price searches convert the prices of the products into the currency searched
+func (p *ProductDefault) WithExchange(ex internal.ExchangeService) *ProductDefault {
+	p.ex = ex
+	return p
 }
 
-func (p *ProductDefault) GetByID(id int) (product *internal.Product, err error) {
-	product, err = p.rp.GetByID(id)
+func (p *ProductDefault) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
+	return p.rp.GetAll(ctx)
+}
+
+func (p *ProductDefault) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	return p.rp.Each(ctx, fn)
+}
+
+func (p *ProductDefault) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
+	product, err = p.rp.GetByID(ctx, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, internal.ErrProductNotFound):
@@ -36,74 +50,125 @@ func (p *ProductDefault) GetByID(id int) (product *internal.Product, err error)
 	return
 }
 
-func (p *ProductDefault) SearchByPrice(price float64) (products map[int]*internal.Product, err error) {
-	allProducts, err := (*p).GetAll()
+// SearchByPrice compares the prices in minor units of the currency searched. The prices in other currencies
+// are converted into it, and left out if there is no exchange service or no rate to convert them.
+func (p *ProductDefault) SearchByPrice(ctx context.Context, price internal.Money) (products map[int]*internal.Product, err error) {
+	allProducts, err := (*p).GetAll(ctx)
 	products = make(map[int]*internal.Product)
 	if err != nil {
 		return
 	}
+	var skipped int
+	var errConvert error
 	for _, product := range allProducts {
-		if product.Price >= price {
+		current := product.Price
+		if current.Currency != price.Currency {
+			if p.ex == nil {
+				continue
+			}
+			// a product whose price cannot be converted is left out, not the whole search
+			if current, errConvert = p.ex.Convert(ctx, current, price.Currency); errConvert != nil {
+				skipped++
+				continue
+			}
+		}
+		if current.Amount >= price.Amount {
 			products[product.Id] = product
 		}
 	}
+	if skipped > 0 {
+		slog.WarnContext(ctx, "products left out of the price search", "currency", price.Currency, "count", skipped, "error", errConvert)
+	}
 	if len(products) == 0 {
 		err = fmt.Errorf("%w: price", internal.ErrProductNotFound)
 	}
 	return
 }
 
-func (p *ProductDefault) Create(product *internal.Product) (err error) {
+func (p *ProductDefault) Create(ctx context.Context, product *internal.Product) (err error) {
 
 	if err = Validate(product); err != nil {
+		slog.InfoContext(ctx, "product rejected", "error", err)
 		return
 	}
 
-	err = p.rp.Create(product)
+	err = p.rp.Create(ctx, product)
+	if err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "product created", "id", product.Id)
 	return
 }
 
-func (p *ProductDefault) UpdateOrCreate(product *internal.Product) (prod internal.Product, err error) {
+func (p *ProductDefault) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
 	if err = Validate(product); err != nil {
+		slog.InfoContext(ctx, "product rejected", "id", product.Id, "error", err)
 		return
 	}
 
-	prod, err = p.rp.UpdateOrCreate(product)
+	prod, err = p.rp.UpdateOrCreate(ctx, product)
 	if err != nil {
 		switch {
 		case errors.Is(err, internal.ErrProductNotFound):
 			err = fmt.Errorf("%w: id", internal.ErrProductNotFound)
 		}
+		return
 	}
+	slog.InfoContext(ctx, "product updated or created", "id", prod.Id)
 	return
 }
 
-func (p *ProductDefault) Update(product *internal.Product) (err error) {
+func (p *ProductDefault) Update(ctx context.Context, product *internal.Product) (err error) {
 	if err = Validate(product); err != nil {
+		slog.InfoContext(ctx, "product rejected", "id", product.Id, "error", err)
 		return
 	}
 
-	err = p.rp.Update(product)
+	err = p.rp.Update(ctx, product)
 	if err != nil {
 		switch {
 		case errors.Is(err, internal.ErrProductNotFound):
 			err = fmt.Errorf("%w: id", internal.ErrProductNotFound)
 		}
+		return
 	}
+	slog.InfoContext(ctx, "product updated", "id", product.Id)
 	return
 }
 
-func (p *ProductDefault) Delete(id int) (err error) {
-	err = p.rp.Delete(id)
+func (p *ProductDefault) Delete(ctx context.Context, id int) (err error) {
+	err = p.rp.Delete(ctx, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, internal.ErrProductNotFound):
 			err = fmt.Errorf("%w: id", internal.ErrProductNotFound)
 		}
+		return
+	}
+	slog.InfoContext(ctx, "product deleted", "id", id)
+	return
+}
+
+func (p *ProductDefault) Unpublish(ctx context.Context, id int) (err error) {
+	product, err := p.GetByID(ctx, id)
+	if err != nil || !product.Is_published {
+		return
+	}
+
+	// not validated, so that an expired product can still be withdrawn
+	prod := *product
+	prod.Is_published = false
+	if err = p.rp.Update(ctx, &prod); err != nil {
+		return
 	}
+	slog.InfoContext(ctx, "product unpublished", "id", id)
 	return
 }
 
+func (p *ProductDefault) LastModified(ctx context.Context) (t time.Time, err error) {
+	return p.rp.LastModified(ctx)
+}
+
 func Validate(p *internal.Product) (err error) {
 	// required fields
 	if (*p).Name == "" {
@@ -135,10 +200,66 @@ func Validate(p *internal.Product) (err error) {
 		err = fmt.Errorf("%w: expiration", internal.ErrValidateQualityField)
 		return
 	}
-	if p.Price < 0 {
+	if p.Price.Amount < 0 {
 		err = fmt.Errorf("%w: price", internal.ErrValidateQualityField)
 		return
 	}
+	if _, ok := internal.Currencies[p.Price.Currency]; !ok {
+		err = fmt.Errorf("%w: currency", internal.ErrValidateQualityField)
+		return
+	}
+
+	// attributes and variants
+	if err = validateAttributes(p.Attributes); err != nil {
+		return
+	}
+	codes := map[string]bool{p.Code_value: true}
+	for i, v := range p.Variants {
+		if !rx.MatchString(v.Code_value) {
+			err = fmt.Errorf("%w: %d: code_value", internal.ErrProductVariantInvalid, i)
+			return
+		}
+		// a code designates one sku: the product or one of its variants
+		if codes[v.Code_value] {
+			err = fmt.Errorf("%w: %d: code_value %s repeated", internal.ErrProductVariantInvalid, i, v.Code_value)
+			return
+		}
+		codes[v.Code_value] = true
+		if v.Quantity < 0 {
+			err = fmt.Errorf("%w: %d: quantity", internal.ErrProductVariantInvalid, i)
+			return
+		}
+		// a product is sold in a single currency, so its variants add up with it in carts and orders
+		if v.Price.Amount < 0 || v.Price.Currency != p.Price.Currency {
+			err = fmt.Errorf("%w: %d: price", internal.ErrProductVariantInvalid, i)
+			return
+		}
+		if err = validateAttributes(v.Attributes); err != nil {
+			err = fmt.Errorf("%w: %d: %w", internal.ErrProductVariantInvalid, i, err)
+			return
+		}
+	}
+
+	return
+}
 
+// validateAttributes checks that the attributes have distinct names and values of their types
+func validateAttributes(attributes []internal.Attribute) (err error) {
+	names := make(map[string]bool, len(attributes))
+	for _, a := range attributes {
+		if a.Name == "" {
+			err = fmt.Errorf("%w: name", internal.ErrProductAttributeInvalid)
+			return
+		}
+		if names[a.Name] {
+			err = fmt.Errorf("%w: %s repeated", internal.ErrProductAttributeInvalid, a.Name)
+			return
+		}
+		names[a.Name] = true
+		if !a.Valid() {
+			err = fmt.Errorf("%w: %s is not a %s", internal.ErrProductAttributeInvalid, a.Name, a.Type)
+			return
+		}
+	}
 	return
 }
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// scheduleKey is the context key under which the id of the scheduled price change being applied is stored
+type scheduleKey struct{}
+
+// ProductPrice is a ProductService that records every change of the price of a product in its price history
+type ProductPrice struct {
+	sv internal.ProductService
+	rp internal.PriceHistoryRepository
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+func NewProductPrice(sv internal.ProductService, rp internal.PriceHistoryRepository) *ProductPrice {
+	return &ProductPrice{
+		sv:  sv,
+		rp:  rp,
+		now: time.Now,
+	}
+}
+
+func (p *ProductPrice) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
+	return p.sv.GetAll(ctx)
+}
+
+func (p *ProductPrice) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	return p.sv.Each(ctx, fn)
+}
+
+func (p *ProductPrice) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
+	return p.sv.GetByID(ctx, id)
+}
+
+func (p *ProductPrice) SearchByPrice(ctx context.Context, price internal.Money) (products map[int]*internal.Product, err error) {
+	return p.sv.SearchByPrice(ctx, price)
+}
+
+func (p *ProductPrice) Create(ctx context.Context, product *internal.Product) (err error) {
+	if err = p.sv.Create(ctx, product); err != nil {
+		return
+	}
+	p.record(ctx, product.Id, internal.Money{Currency: product.Price.Currency}, product.Price)
+	return
+}
+
+func (p *ProductPrice) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
+	old, existed, err := p.price(ctx, product.Id)
+	if err != nil {
+		return
+	}
+
+	if prod, err = p.sv.UpdateOrCreate(ctx, product); err != nil {
+		return
+	}
+	if !existed || old != prod.Price {
+		p.record(ctx, prod.Id, old, prod.Price)
+	}
+	return
+}
+
+func (p *ProductPrice) Update(ctx context.Context, product *internal.Product) (err error) {
+	old, _, err := p.price(ctx, product.Id)
+	if err != nil {
+		return
+	}
+
+	if err = p.sv.Update(ctx, product); err != nil {
+		return
+	}
+	if old != product.Price {
+		p.record(ctx, product.Id, old, product.Price)
+	}
+	return
+}
+
+func (p *ProductPrice) Delete(ctx context.Context, id int) (err error) {
+	return p.sv.Delete(ctx, id)
+}
+
+func (p *ProductPrice) Unpublish(ctx context.Context, id int) (err error) {
+	return p.sv.Unpublish(ctx, id)
+}
+
+func (p *ProductPrice) LastModified(ctx context.Context) (t time.Time, err error) {
+	return p.sv.LastModified(ctx)
+}
+
+// price returns the price of a product before a change, and whether the product exists
+func (p *ProductPrice) price(ctx context.Context, id int) (price internal.Money, ok bool, err error) {
+	current, err := p.sv.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, internal.ErrProductNotFound) {
+			err = nil
+		}
+		return
+	}
+	return current.Price, true, nil
+}
+
+// record appends a change of the price of a product to the history, with its time and origin.
+// The price has changed by then, so a change that cannot be appended is logged rather than failing
+// a write the client would retry.
+func (p *ProductPrice) record(ctx context.Context, id int, old, price internal.Money) {
+	change := internal.PriceChange{
+		Time:      p.now().UTC(),
+		RequestID: internal.RequestIDFromContext(ctx),
+		ProductID: id,
+		Old:       old,
+		New:       price,
+	}
+	change.Tenant, _ = tenant.TenantFromContext(ctx)
+	change.Principal, _ = auth.PrincipalFromContext(ctx)
+	change.ScheduleID, _ = ctx.Value(scheduleKey{}).(string)
+
+	if err := p.rp.Append(ctx, change); err != nil {
+		slog.ErrorContext(ctx, "record price change", "id", id, "error", fmt.Errorf("%w: %v", internal.ErrPriceRecord, err))
+	}
+}
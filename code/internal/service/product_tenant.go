@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// ProductTenant is a ProductService that routes every call to the catalog of the tenant in the context,
+// so each tenant only ever sees its own products and id sequence
+type ProductTenant struct {
+	// sv maps a tenant id to the service of its catalog
+	sv map[string]internal.ProductService
+}
+
+func NewProductTenant(sv map[string]internal.ProductService) *ProductTenant {
+	return &ProductTenant{
+		sv: sv,
+	}
+}
+
+func (p *ProductTenant) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.GetAll(ctx)
+}
+
+func (p *ProductTenant) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.Each(ctx, fn)
+}
+
+func (p *ProductTenant) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.GetByID(ctx, id)
+}
+
+func (p *ProductTenant) SearchByPrice(ctx context.Context, price internal.Money) (products map[int]*internal.Product, err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.SearchByPrice(ctx, price)
+}
+
+func (p *ProductTenant) Create(ctx context.Context, product *internal.Product) (err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.Create(ctx, product)
+}
+
+func (p *ProductTenant) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.UpdateOrCreate(ctx, product)
+}
+
+func (p *ProductTenant) Update(ctx context.Context, product *internal.Product) (err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.Update(ctx, product)
+}
+
+func (p *ProductTenant) Delete(ctx context.Context, id int) (err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.Delete(ctx, id)
+}
+
+func (p *ProductTenant) Unpublish(ctx context.Context, id int) (err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.Unpublish(ctx, id)
+}
+
+func (p *ProductTenant) LastModified(ctx context.Context) (t time.Time, err error) {
+	sv, err := p.service(ctx)
+	if err != nil {
+		return
+	}
+	return sv.LastModified(ctx)
+}
+
+// service returns the service of the tenant in the context
+func (p *ProductTenant) service(ctx context.Context) (sv internal.ProductService, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	sv, ok := p.sv[tenantID]
+	if !ok {
+		err = fmt.Errorf("%w: %s", tenant.ErrTenantNotFound, tenantID)
+		return
+	}
+	return
+}
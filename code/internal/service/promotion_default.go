@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// PromotionDefault keeps the promotions of each tenant well formed
+type PromotionDefault struct {
+	rp internal.PromotionRepository
+}
+
+func NewPromotionDefault(rp internal.PromotionRepository) *PromotionDefault {
+	return &PromotionDefault{
+		rp: rp,
+	}
+}
+
+func (p *PromotionDefault) GetAll(ctx context.Context) (promotions []internal.Promotion, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	return p.rp.GetAll(ctx, tenantID)
+}
+
+func (p *PromotionDefault) GetByID(ctx context.Context, id int) (promotion internal.Promotion, err error) {
+	promotions, err := p.GetAll(ctx)
+	if err != nil {
+		return
+	}
+	for _, v := range promotions {
+		if v.ID == id {
+			promotion = v
+			return
+		}
+	}
+	err = fmt.Errorf("%w: id", internal.ErrPromotionNotFound)
+	return
+}
+
+func (p *PromotionDefault) Create(ctx context.Context, promotion *internal.Promotion) (err error) {
+	promotion.Tenant, _ = tenant.TenantFromContext(ctx)
+	if err = p.validate(*promotion); err != nil {
+		return
+	}
+	if err = p.rp.Create(ctx, promotion); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "promotion created", "promotion", promotion.ID, "kind", promotion.Kind, "priority", promotion.Priority)
+	return
+}
+
+func (p *PromotionDefault) Update(ctx context.Context, promotion internal.Promotion) (err error) {
+	promotion.Tenant, _ = tenant.TenantFromContext(ctx)
+	if err = p.validate(promotion); err != nil {
+		return
+	}
+	if err = p.rp.Update(ctx, promotion); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "promotion updated", "promotion", promotion.ID, "kind", promotion.Kind, "priority", promotion.Priority)
+	return
+}
+
+func (p *PromotionDefault) Delete(ctx context.Context, id int) (err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	if err = p.rp.Delete(ctx, tenantID, id); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "promotion deleted", "promotion", id)
+	return
+}
+
+// validate checks the name, the discount of the kind, the conditions and the date window of a promotion
+func (p *PromotionDefault) validate(promotion internal.Promotion) (err error) {
+	if strings.TrimSpace(promotion.Name) == "" {
+		err = fmt.Errorf("%w: name", internal.ErrPromotionInvalid)
+		return
+	}
+	switch promotion.Kind {
+	case internal.PromotionPercent:
+		if promotion.Percent <= 0 || promotion.Percent > 100 {
+			err = fmt.Errorf("%w: percent %v must be above 0 and up to 100", internal.ErrPromotionInvalid, promotion.Percent)
+			return
+		}
+	case internal.PromotionAmount:
+		if _, errCurrency := internal.Digits(promotion.Amount.Currency); errCurrency != nil || promotion.Amount.Amount <= 0 {
+			err = fmt.Errorf("%w: amount %s must be positive in a supported currency", internal.ErrPromotionInvalid, promotion.Amount)
+			return
+		}
+	case internal.PromotionBuyGet:
+		if promotion.Buy < 1 || promotion.Get < 1 {
+			err = fmt.Errorf("%w: buy %d get %d must be at least 1 each", internal.ErrPromotionInvalid, promotion.Buy, promotion.Get)
+			return
+		}
+	default:
+		err = fmt.Errorf("%w: kind %q", internal.ErrPromotionInvalid, promotion.Kind)
+		return
+	}
+	for i, c := range promotion.Conditions {
+		if !c.Valid() {
+			err = fmt.Errorf("%w: condition %d: %s %s %v", internal.ErrPromotionInvalid, i, c.Field, c.Operator, c.Value)
+			return
+		}
+	}
+	if !promotion.Starts.IsZero() && !promotion.Ends.IsZero() && !promotion.Ends.After(promotion.Starts) {
+		err = fmt.Errorf("%w: ends before it starts", internal.ErrPromotionInvalid)
+		return
+	}
+	return
+}
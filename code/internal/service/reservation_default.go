@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// ReservationDefault holds units of the products for a while. It takes the lock of the stock,
+// so that a reservation is never made on units that a concurrent sale is taking.
+type ReservationDefault struct {
+	rp internal.ReservationRepository
+	st *StockDefault
+
+	// ttl is the time a reservation is held when the client does not ask for one
+	ttl time.Duration
+	// maxTTL is the longest time a reservation can be held
+	maxTTL time.Duration
+}
+
+func NewReservationDefault(rp internal.ReservationRepository, st *StockDefault, ttl, maxTTL time.Duration) *ReservationDefault {
+	return &ReservationDefault{
+		rp:     rp,
+		st:     st,
+		ttl:    ttl,
+		maxTTL: maxTTL,
+	}
+}
+
+func (r *ReservationDefault) Reserve(ctx context.Context, productID, quantity int, ttl time.Duration) (reservation internal.Reservation, err error) {
+	if quantity <= 0 {
+		err = fmt.Errorf("%w: quantity", internal.ErrReservationInvalid)
+		return
+	}
+	if ttl == 0 {
+		ttl = r.ttl
+	}
+	if ttl < 0 || ttl > r.maxTTL {
+		err = fmt.Errorf("%w: ttl must be up to %s", internal.ErrReservationInvalid, r.maxTTL)
+		return
+	}
+
+	r.st.mu.Lock()
+	defer r.st.mu.Unlock()
+
+	product, err := r.st.product(ctx, productID)
+	if err != nil {
+		return
+	}
+	reserved, err := r.st.reserved(ctx, productID)
+	if err != nil {
+		return
+	}
+	if available := product.Quantity - reserved; quantity > available {
+		err = fmt.Errorf("%w: %d available, %d requested", internal.ErrStockInsufficient, max(available, 0), quantity)
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		return
+	}
+	now := r.st.now().UTC()
+	reservation = internal.Reservation{
+		ID:        id,
+		ProductID: productID,
+		Quantity:  quantity,
+		Created:   now,
+		Expires:   now.Add(ttl),
+	}
+	reservation.Tenant, _ = tenant.TenantFromContext(ctx)
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		reservation.Principal = principal
+	}
+	if err = r.rp.Create(ctx, reservation); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "stock reserved", "reservation", id, "id", productID, "quantity", quantity, "expires", reservation.Expires)
+	return
+}
+
+func (r *ReservationDefault) GetByID(ctx context.Context, id string) (reservation internal.Reservation, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	return r.rp.GetByID(ctx, tenantID, id)
+}
+
+func (r *ReservationDefault) Commit(ctx context.Context, id string) (movement internal.StockMovement, err error) {
+	r.st.mu.Lock()
+	defer r.st.mu.Unlock()
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	reservation, err := r.rp.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return
+	}
+	if reservation.Expired(r.st.now()) {
+		// the units may already be held or sold by someone else
+		err = fmt.Errorf("%w: at %s", internal.ErrReservationExpired, reservation.Expires.Format(time.RFC3339))
+		return
+	}
+
+	movement, err = r.st.move(ctx, reservation.ProductID, internal.StockMovementSale, -reservation.Quantity, "reservation "+id, reservation.Quantity)
+	if err != nil {
+		return
+	}
+	if err = r.rp.Delete(ctx, tenantID, id); err != nil {
+		// the sale is recorded: the reservation will be swept once expired
+		slog.ErrorContext(ctx, "committed reservation not deleted", "reservation", id, "error", err)
+		err = nil
+	}
+	return
+}
+
+func (r *ReservationDefault) Release(ctx context.Context, id string) (err error) {
+	r.st.mu.Lock()
+	defer r.st.mu.Unlock()
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	if err = r.rp.Delete(ctx, tenantID, id); err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "reservation released", "reservation", id)
+	return
+}
+
+func (r *ReservationDefault) Sweep(ctx context.Context) (released int, err error) {
+	r.st.mu.Lock()
+	defer r.st.mu.Unlock()
+
+	expired, err := r.rp.DeleteExpired(ctx, r.st.now())
+	if err != nil {
+		return
+	}
+	for _, v := range expired {
+		slog.InfoContext(ctx, "reservation expired", "reservation", v.ID, "tenant", v.Tenant, "id", v.ProductID, "quantity", v.Quantity)
+	}
+	released = len(expired)
+	return
+}
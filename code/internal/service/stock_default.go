@@ -0,0 +1,569 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+)
+
+// StockDefault keeps the stock ledger, from which the quantity of the products is derived.
+// The ledger is the source of truth: the quantity of a product is the sum of its movements, kept as
+// a running balance per tenant that is rebuilt from the ledger on first use. The quantity stored
+// with the product is a copy written after each movement, for the other readers of the catalog
+// file; it may fall behind if that write fails, and the reads through ProductStock replace it.
+// A product that predates the ledger has its stored quantity recorded as its opening balance.
+type StockDefault struct {
+	// mu serializes the changes of quantity, so that no movement is applied on a stale quantity.
+	// ProductStock takes it too for the product writes.
+	mu sync.Mutex
+	// bmu guards the balances, which the reads take without waiting on mu
+	bmu sync.Mutex
+	// balances is the sum of the movements of each product, by tenant, loaded from the ledger on first use
+	balances map[string]map[int]int
+	// recorded is the time of the last movement recorded for each tenant since the start
+	recorded map[string]time.Time
+
+	rp internal.StockRepository
+	// rs is the reservations, whose units are not available to sales
+	rs internal.ReservationRepository
+	// sv is the product service the quantities are written through
+	sv internal.ProductService
+	// lots is the lots the outgoing movements take their units from, if they are tracked
+	lots internal.LotRepository
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+func NewStockDefault(rp internal.StockRepository, rs internal.ReservationRepository, sv internal.ProductService) *StockDefault {
+	return &StockDefault{
+		rp:       rp,
+		rs:       rs,
+		sv:       sv,
+		balances: make(map[string]map[int]int),
+		recorded: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// WithLots makes the outgoing movements take their units from the lots of the products, first expiring first
+func (s *StockDefault) WithLots(rp internal.LotRepository) *StockDefault {
+	s.lots = rp
+	return s
+}
+
+func (s *StockDefault) Move(ctx context.Context, productID int, typ string, quantity int, reason string) (movement internal.StockMovement, err error) {
+	delta, err := internal.StockDelta(typ, quantity)
+	if err != nil {
+		err = fmt.Errorf("%w: quantity %d for %q", internal.ErrStockMovementInvalid, quantity, typ)
+		return
+	}
+	if reason == "" {
+		err = fmt.Errorf("%w: reason", internal.ErrStockMovementInvalid)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.move(ctx, productID, typ, delta, reason, 0)
+}
+
+// WriteOff takes the units left in an expired lot of a product out of the stock and drops the lot.
+// The lot is dropped before the movement is recorded, and put back if it cannot be, so that a lot is
+// never written off twice; a lot already dropped is not found.
+func (s *StockDefault) WriteOff(ctx context.Context, productID int, code string) (movement internal.StockMovement, lot internal.Lot, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	lots, err := s.lots.Find(ctx, tenantID, productID)
+	if err != nil {
+		return
+	}
+	i := slices.IndexFunc(lots, func(l internal.Lot) bool { return l.Code == code })
+	if i < 0 {
+		err = fmt.Errorf("%w: lot %s", internal.ErrLotNotFound, code)
+		return
+	}
+	lot = lots[i]
+	if err = s.lots.Replace(ctx, tenantID, productID, slices.Delete(slices.Clone(lots), i, i+1)); err != nil {
+		return
+	}
+
+	product, err := s.product(ctx, productID)
+	if err == nil {
+		// the lots may have fallen behind the ledger, which never goes negative
+		if n := min(lot.Quantity, product.Quantity); n > 0 {
+			movement, err = s.apply(ctx, product, internal.StockMovementAdjustment, -n, "lot "+code+" expired", 0)
+		}
+	}
+	if err != nil {
+		if errRestore := s.lots.Replace(ctx, tenantID, productID, lots); errRestore != nil {
+			slog.ErrorContext(ctx, "expired lot not restored", "id", productID, "lot", code, "error", errRestore)
+		}
+	}
+	return
+}
+
+// move applies a delta to a product and records it, taking the units out of its lots; the caller holds mu
+func (s *StockDefault) move(ctx context.Context, productID int, typ string, delta int, reason string, held int) (movement internal.StockMovement, err error) {
+	product, err := s.product(ctx, productID)
+	if err != nil {
+		return
+	}
+	if movement, err = s.apply(ctx, product, typ, delta, reason, held); err != nil {
+		return
+	}
+
+	if delta < 0 && s.lots != nil {
+		// the movement stands even if its lots fall behind, which only overstates the untracked units
+		if errLots := s.consume(ctx, product, -delta); errLots != nil {
+			slog.ErrorContext(ctx, "stock lots not consumed", "id", productID, "error", errLots)
+		}
+	}
+	return
+}
+
+// apply applies a delta to a product, as derived from the ledger, and records it; the caller holds mu.
+// Sales cannot take the units held by reservations, except the held units of the reservation being committed.
+func (s *StockDefault) apply(ctx context.Context, product *internal.Product, typ string, delta int, reason string, held int) (movement internal.StockMovement, err error) {
+	productID := product.Id
+	available := product.Quantity
+	if typ == internal.StockMovementSale {
+		var reserved int
+		if reserved, err = s.reserved(ctx, productID); err != nil {
+			return
+		}
+		available -= reserved - held
+	}
+	if available+delta < 0 {
+		err = fmt.Errorf("%w: %d available, %d requested", internal.ErrStockInsufficient, max(available, 0), -delta)
+		return
+	}
+	balance := product.Quantity + delta
+
+	// the movement is the change: once recorded, the quantity is the new balance
+	movement = internal.StockMovement{
+		ProductID: productID,
+		Type:      typ,
+		Delta:     delta,
+		Balance:   balance,
+		Reason:    reason,
+	}
+	if err = s.record(ctx, &movement); err != nil {
+		return
+	}
+	updated := *product
+	updated.Quantity = balance
+	if errStore := s.sv.Update(ctx, &updated); errStore != nil {
+		slog.ErrorContext(ctx, "stock quantity not stored", "id", productID, "balance", balance, "error", errStore)
+	}
+	slog.InfoContext(ctx, "stock moved", "id", productID, "type", typ, "delta", delta, "balance", balance)
+	return
+}
+
+// consume takes n units out of the lots of a product, first expiring first; the caller holds mu.
+// The units not in any lot are taken as expiring with the product.
+func (s *StockDefault) consume(ctx context.Context, product *internal.Product, n int) (err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	lots, err := s.lots.Find(ctx, tenantID, product.Id)
+	if err != nil || len(lots) == 0 {
+		return
+	}
+
+	untracked := product.Quantity
+	for _, v := range lots {
+		untracked -= v.Quantity
+	}
+	untracked = max(untracked, 0)
+
+	for i := range lots {
+		if n == 0 {
+			break
+		}
+		if untracked > 0 && product.Expiration.Before(lots[i].Expiration) {
+			taken := min(untracked, n)
+			untracked -= taken
+			n -= taken
+		}
+		taken := min(lots[i].Quantity, n)
+		lots[i].Quantity -= taken
+		n -= taken
+	}
+	return s.lots.Replace(ctx, tenantID, product.Id, lots)
+}
+
+func (s *StockDefault) Record(ctx context.Context, movement internal.StockMovement) (err error) {
+	return s.record(ctx, &movement)
+}
+
+func (s *StockDefault) History(ctx context.Context, productID int) (movements []internal.StockMovement, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	movements, err = s.rp.Find(ctx, tenantID, productID)
+	if err != nil {
+		return
+	}
+
+	// the ledger outlives a deleted product, but a product that never existed has no history
+	if len(movements) == 0 {
+		_, err = s.sv.GetByID(ctx, productID)
+	}
+	return
+}
+
+// reserved returns the units of a product held by the reservations of the tenant
+func (s *StockDefault) reserved(ctx context.Context, productID int) (n int, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	reserved, err := s.rs.Reserved(ctx, tenantID, s.now())
+	if err != nil {
+		return
+	}
+	n = reserved[productID]
+	return
+}
+
+// record fills the movement with its id, time and origin, appends it to the ledger and adds it to the balance
+func (s *StockDefault) record(ctx context.Context, movement *internal.StockMovement) (err error) {
+	if movement.ID, err = newID(); err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrStockRecord, err)
+		return
+	}
+	movement.Time = s.now().UTC()
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		movement.Principal = principal
+	}
+	movement.Tenant, _ = tenant.TenantFromContext(ctx)
+	movement.RequestID = internal.RequestIDFromContext(ctx)
+
+	s.bmu.Lock()
+	defer s.bmu.Unlock()
+
+	// the balances are loaded before the append, so that they never count the movement twice
+	balances, err := s.ledger(ctx, movement.Tenant)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrStockRecord, err)
+		return
+	}
+	if err = s.rp.Append(ctx, *movement); err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrStockRecord, err)
+		return
+	}
+	balances[movement.ProductID] += movement.Delta
+	s.recorded[movement.Tenant] = movement.Time
+	return
+}
+
+// ledger returns the balances of a tenant, loading them from the ledger the first time; the caller holds bmu
+func (s *StockDefault) ledger(ctx context.Context, tenantID string) (balances map[int]int, err error) {
+	balances, ok := s.balances[tenantID]
+	if ok {
+		return
+	}
+	if balances, err = s.rp.Balances(ctx, tenantID); err != nil {
+		return
+	}
+	s.balances[tenantID] = balances
+	return
+}
+
+// balance returns the sum of the movements of a product, and whether it has any
+func (s *StockDefault) balance(ctx context.Context, productID int) (n int, ok bool, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+
+	s.bmu.Lock()
+	defer s.bmu.Unlock()
+
+	balances, err := s.ledger(ctx, tenantID)
+	if err != nil {
+		return
+	}
+	n, ok = balances[productID]
+	return
+}
+
+// quantities returns a copy of the balances of the tenant, for the reads of many products
+func (s *StockDefault) quantities(ctx context.Context) (balances map[int]int, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+
+	s.bmu.Lock()
+	defer s.bmu.Unlock()
+
+	current, err := s.ledger(ctx, tenantID)
+	if err != nil {
+		return
+	}
+	balances = make(map[int]int, len(current))
+	for id, n := range current {
+		balances[id] = n
+	}
+	return
+}
+
+// product returns a copy of a product with its quantity derived from the ledger; the caller holds mu.
+// The quantity of a product that predates the ledger is recorded as its opening balance.
+func (s *StockDefault) product(ctx context.Context, productID int) (product *internal.Product, err error) {
+	prod, err := s.sv.GetByID(ctx, productID)
+	if err != nil {
+		return
+	}
+	product = new(internal.Product)
+	*product = *prod
+
+	balance, ok, err := s.balance(ctx, productID)
+	switch {
+	case err != nil:
+		return
+	case ok:
+		product.Quantity = balance
+	case product.Quantity != 0:
+		err = s.record(ctx, &internal.StockMovement{
+			ProductID: productID,
+			Type:      internal.StockMovementAdjustment,
+			Delta:     product.Quantity,
+			Balance:   product.Quantity,
+			Reason:    "opening balance",
+		})
+	}
+	return
+}
+
+// ProductStock is a ProductService that keeps the quantity of the products derived from the stock ledger:
+// the quantity of a new product is recorded as its first receipt, afterwards only movements change it,
+// and the reads return the sum of the movements rather than the stored copy
+type ProductStock struct {
+	sv internal.ProductService
+	st *StockDefault
+}
+
+func NewProductStock(sv internal.ProductService, st *StockDefault) *ProductStock {
+	return &ProductStock{
+		sv: sv,
+		st: st,
+	}
+}
+
+func (p *ProductStock) GetAll(ctx context.Context) (products map[int]*internal.Product, err error) {
+	prods, err := p.sv.GetAll(ctx)
+	if err != nil {
+		return
+	}
+	return p.withStock(ctx, prods)
+}
+
+func (p *ProductStock) Each(ctx context.Context, fn func(product *internal.Product) error) (err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	reserved, err := p.st.rs.Reserved(ctx, tenantID, p.st.now())
+	if err != nil {
+		return
+	}
+	balances, err := p.st.quantities(ctx)
+	if err != nil {
+		return
+	}
+	return p.sv.Each(ctx, func(product *internal.Product) error {
+		prod := *product
+		if n, ok := balances[prod.Id]; ok {
+			prod.Quantity = n
+		}
+		prod.Reserved = reserved[prod.Id]
+		return fn(&prod)
+	})
+}
+
+func (p *ProductStock) GetByID(ctx context.Context, id int) (product *internal.Product, err error) {
+	prod, err := p.sv.GetByID(ctx, id)
+	if err != nil {
+		return
+	}
+	balance, ok, err := p.st.balance(ctx, id)
+	if err != nil {
+		return
+	}
+	reserved, err := p.st.reserved(ctx, id)
+	if err != nil {
+		return
+	}
+	product = new(internal.Product)
+	*product = *prod
+	if ok {
+		product.Quantity = balance
+	}
+	product.Reserved = reserved
+	return
+}
+
+func (p *ProductStock) SearchByPrice(ctx context.Context, price internal.Money) (products map[int]*internal.Product, err error) {
+	prods, err := p.sv.SearchByPrice(ctx, price)
+	if err != nil {
+		return
+	}
+	return p.withStock(ctx, prods)
+}
+
+func (p *ProductStock) Create(ctx context.Context, product *internal.Product) (err error) {
+	p.st.mu.Lock()
+	defer p.st.mu.Unlock()
+
+	product.Reserved = 0
+	if err = p.sv.Create(ctx, product); err != nil {
+		return
+	}
+	return p.receipt(ctx, product.Id, product.Quantity)
+}
+
+func (p *ProductStock) UpdateOrCreate(ctx context.Context, product *internal.Product) (prod internal.Product, err error) {
+	p.st.mu.Lock()
+	defer p.st.mu.Unlock()
+
+	product.Reserved = 0
+	current, err := p.st.product(ctx, product.Id)
+	switch {
+	case err == nil:
+		if err = derived(current, product); err != nil {
+			return
+		}
+		if prod, err = p.sv.UpdateOrCreate(ctx, product); err != nil {
+			return
+		}
+		prod.Reserved, err = p.st.reserved(ctx, prod.Id)
+		return
+	case errors.Is(err, internal.ErrProductNotFound):
+		if prod, err = p.sv.UpdateOrCreate(ctx, product); err != nil {
+			return
+		}
+		err = p.receipt(ctx, prod.Id, prod.Quantity)
+		return
+	}
+	return
+}
+
+func (p *ProductStock) Update(ctx context.Context, product *internal.Product) (err error) {
+	p.st.mu.Lock()
+	defer p.st.mu.Unlock()
+
+	current, err := p.st.product(ctx, product.Id)
+	if err != nil {
+		return
+	}
+	if err = derived(current, product); err != nil {
+		return
+	}
+	// the reserved units of the product, as read, are not stored
+	prod := *product
+	prod.Reserved = 0
+	return p.sv.Update(ctx, &prod)
+}
+
+func (p *ProductStock) Delete(ctx context.Context, id int) (err error) {
+	return p.sv.Delete(ctx, id)
+}
+
+func (p *ProductStock) Unpublish(ctx context.Context, id int) (err error) {
+	p.st.mu.Lock()
+	defer p.st.mu.Unlock()
+
+	return p.sv.Unpublish(ctx, id)
+}
+
+// LastModified is the latest of the changes of the catalog, of the ledger and of the reservations,
+// since the quantity and the reserved units read are derived from the last two
+func (p *ProductStock) LastModified(ctx context.Context) (t time.Time, err error) {
+	if t, err = p.sv.LastModified(ctx); err != nil {
+		return
+	}
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	reserved, err := p.st.rs.Modified(ctx, tenantID, p.st.now())
+	if err != nil {
+		return
+	}
+
+	p.st.bmu.Lock()
+	recorded := p.st.recorded[tenantID]
+	p.st.bmu.Unlock()
+
+	t = later(later(t, reserved), recorded)
+	return
+}
+
+// withStock returns copies of the products with their quantity from the ledger and their reserved units,
+// leaving the stored products untouched
+func (p *ProductStock) withStock(ctx context.Context, prods map[int]*internal.Product) (products map[int]*internal.Product, err error) {
+	tenantID, _ := tenant.TenantFromContext(ctx)
+	reserved, err := p.st.rs.Reserved(ctx, tenantID, p.st.now())
+	if err != nil {
+		return
+	}
+	balances, err := p.st.quantities(ctx)
+	if err != nil {
+		return
+	}
+	products = make(map[int]*internal.Product, len(prods))
+	for id, v := range prods {
+		prod := *v
+		if n, ok := balances[id]; ok {
+			prod.Quantity = n
+		}
+		prod.Reserved = reserved[id]
+		products[id] = &prod
+	}
+	return
+}
+
+// derived returns ErrStockQuantityDerived if a write changes the quantity of a product or of one of its variants.
+// The ledger only tracks the product itself, so the quantity of a variant is the one it was added with.
+func derived(current, product *internal.Product) (err error) {
+	if current.Quantity != product.Quantity {
+		return fmt.Errorf("%w: quantity", internal.ErrStockQuantityDerived)
+	}
+	quantities := make(map[string]int, len(current.Variants))
+	for _, v := range current.Variants {
+		quantities[v.Code_value] = v.Quantity
+	}
+	for _, v := range product.Variants {
+		if n, ok := quantities[v.Code_value]; ok && n != v.Quantity {
+			return fmt.Errorf("%w: quantity of variant %s", internal.ErrStockQuantityDerived, v.Code_value)
+		}
+	}
+	return
+}
+
+// receipt records the initial quantity of a new product. The ledger outlives a deleted product,
+// so a new product under its id starts from the balance the deleted one left.
+func (p *ProductStock) receipt(ctx context.Context, id, quantity int) (err error) {
+	balance, _, err := p.st.balance(ctx, id)
+	if err != nil || balance == quantity {
+		return
+	}
+	movement := internal.StockMovement{
+		ProductID: id,
+		Type:      internal.StockMovementReceipt,
+		Delta:     quantity - balance,
+		Balance:   quantity,
+		Reason:    "initial stock",
+	}
+	if movement.Delta < 0 {
+		movement.Type = internal.StockMovementAdjustment
+	}
+	return p.st.record(ctx, &movement)
+}
+
+// newID returns a random identifier for the ledger and the reservations
+func newID() (id string, err error) {
+	b := make([]byte, 8)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	id = hex.EncodeToString(b)
+	return
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/repository"
+	"github.com/rhinosc/web-market/code/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockDefault_Concurrency(t *testing.T) {
+	// newServices returns the stock and reservation services over a catalog with the last unit of the product 1
+	newServices := func(t *testing.T) (*service.StockDefault, *service.ReservationDefault) {
+		db := map[int]*internal.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 1, Code_value: "S6611", Is_published: true, Expiration: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC), Price: internal.Money{Amount: 1000, Currency: "USD"}},
+		}
+		sv := service.NewProductDefault(repository.NewProductRepository(db, 1))
+		rp := repository.NewReservationMap()
+		st := service.NewStockDefault(repository.NewStockJSONL(t.TempDir()+"/stock.jsonl"), rp, sv)
+		return st, service.NewReservationDefault(rp, st, time.Minute, time.Hour)
+	}
+	// race runs every fn at once and returns their errors
+	race := func(fns ...func() error) (errs []error) {
+		errs = make([]error, len(fns))
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i, fn := range fns {
+			wg.Add(1)
+			go func(i int, fn func() error) {
+				defer wg.Done()
+				<-start
+				errs[i] = fn()
+			}(i, fn)
+		}
+		close(start)
+		wg.Wait()
+		return
+	}
+	// succeeded counts the nil errors and requires the others to be ErrStockInsufficient
+	succeeded := func(t *testing.T, errs []error) (n int) {
+		for _, err := range errs {
+			if err == nil {
+				n++
+				continue
+			}
+			require.ErrorIs(t, err, internal.ErrStockInsufficient)
+		}
+		return
+	}
+
+	t.Run("case 1: should let only one of the concurrent reservations and sales take the last unit", func(t *testing.T) {
+		// arrange
+		ctx := context.Background()
+		st, rs := newServices(t)
+		var fns []func() error
+		for i := 0; i < 8; i++ {
+			fns = append(fns, func() error {
+				_, err := rs.Reserve(ctx, 1, 1, 0)
+				return err
+			}, func() error {
+				_, err := st.Move(ctx, 1, internal.StockMovementSale, 1, "order")
+				return err
+			})
+		}
+
+		// act
+		errs := race(fns...)
+
+		// assert
+		require.Equal(t, 1, succeeded(t, errs))
+	})
+
+	t.Run("case 2: should let only one of the concurrent sales take the last unit", func(t *testing.T) {
+		// arrange
+		ctx := context.Background()
+		st, _ := newServices(t)
+		var fns []func() error
+		for i := 0; i < 16; i++ {
+			fns = append(fns, func() error {
+				_, err := st.Move(ctx, 1, internal.StockMovementSale, 1, "order")
+				return err
+			})
+		}
+
+		// act
+		errs := race(fns...)
+		movements, err := st.History(ctx, 1)
+
+		// assert
+		require.Equal(t, 1, succeeded(t, errs))
+		require.NoError(t, err)
+		// the opening balance and the one sale
+		require.Len(t, movements, 2)
+		require.Equal(t, 0, movements[1].Balance)
+	})
+}
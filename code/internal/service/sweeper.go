@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Sweeper calls sweep every interval until ctx is done, e.g. to discard what expired.
+// The name identifies what is swept in the logs.
+func Sweeper(ctx context.Context, name string, interval time.Duration, sweep func(ctx context.Context) (n int, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := sweep(ctx); err != nil {
+				slog.ErrorContext(ctx, "sweep", "name", name, "error", err)
+			}
+		}
+	}
+}
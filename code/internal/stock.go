@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// StockMovementReceipt is stock received from a supplier
+	StockMovementReceipt = "receipt"
+	// StockMovementSale is stock sold to a customer
+	StockMovementSale = "sale"
+	// StockMovementAdjustment is a correction of the stock, e.g. after a count, in either direction
+	StockMovementAdjustment = "adjustment"
+	// StockMovementReturn is stock returned by a customer
+	StockMovementReturn = "return"
+)
+
+var (
+	// ErrStockMovementInvalid is returned when a movement has an unknown type, a wrong quantity or no reason
+	ErrStockMovementInvalid = errors.New("stock movement invalid")
+	// ErrStockInsufficient is returned when a movement would leave the stock negative
+	ErrStockInsufficient = errors.New("stock insufficient")
+	// ErrStockQuantityDerived is returned when a product write tries to set the quantity, which only movements change
+	ErrStockQuantityDerived = errors.New("stock quantity is derived from the movements")
+	// ErrStockRecord is returned when a movement cannot be appended to the ledger
+	ErrStockRecord = errors.New("stock record")
+)
+
+// StockMovement is an immutable entry of the stock ledger of a product.
+// The quantity of a product is the sum of the deltas of its movements: the quantity stored with
+// the product is only a copy, which the reads replace with the sum.
+type StockMovement struct {
+	// ID identifies the movement within the ledger
+	ID string
+	// Time is the moment the movement was recorded
+	Time time.Time
+	// Tenant is the tenant whose catalog the product belongs to
+	Tenant string
+	// Principal is who recorded the movement, as reported by the auth layer
+	Principal string
+	// RequestID is the id of the request that recorded the movement
+	RequestID string
+	// ProductID is the id of the product
+	ProductID int
+	// Type is the kind of movement
+	Type string
+	// Delta is the signed change of the quantity: positive for receipts and returns, negative for sales
+	Delta int
+	// Balance is the quantity of the product after the movement
+	Balance int
+	// Reason explains the movement
+	Reason string
+}
+
+// StockDelta returns the signed change of a movement of type typ and quantity units,
+// or ErrStockMovementInvalid if the quantity does not suit the type
+func StockDelta(typ string, quantity int) (delta int, err error) {
+	switch typ {
+	case StockMovementReceipt, StockMovementReturn:
+		if quantity <= 0 {
+			err = ErrStockMovementInvalid
+		}
+		delta = quantity
+	case StockMovementSale:
+		if quantity <= 0 {
+			err = ErrStockMovementInvalid
+		}
+		delta = -quantity
+	case StockMovementAdjustment:
+		if quantity == 0 {
+			err = ErrStockMovementInvalid
+		}
+		delta = quantity
+	default:
+		err = ErrStockMovementInvalid
+	}
+	return
+}
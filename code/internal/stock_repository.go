@@ -0,0 +1,14 @@
+package internal
+
+import "context"
+
+type StockRepository interface {
+	// Appends a movement to the ledger
+	Append(ctx context.Context, movement StockMovement) (err error)
+
+	// Returns the movements of a product of a tenant, oldest first
+	Find(ctx context.Context, tenant string, productID int) (movements []StockMovement, err error)
+
+	// Returns the sum of the deltas of the movements of every product of a tenant that has any
+	Balances(ctx context.Context, tenant string) (balances map[int]int, err error)
+}
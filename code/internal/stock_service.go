@@ -0,0 +1,14 @@
+package internal
+
+import "context"
+
+type StockService interface {
+	// Applies a movement of quantity units to a product and records it, returning the recorded movement
+	Move(ctx context.Context, productID int, typ string, quantity int, reason string) (movement StockMovement, err error)
+
+	// Records a movement whose change the product already has, e.g. the initial stock of a new product
+	Record(ctx context.Context, movement StockMovement) (err error)
+
+	// Returns the movements of a product, oldest first
+	History(ctx context.Context, productID int) (movements []StockMovement, err error)
+}
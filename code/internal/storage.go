@@ -1,19 +1,41 @@
 package internal
 
 import (
+	"context"
 	"errors"
 )
 
 var (
 	// ErrStorageProductTimeLayout is an error that returns when the time layout is invalid
 	ErrStorageProductTimeLayout = errors.New("storage: time layout invalid")
+
+	// ErrStorageProductFormat is an error that returns when a stored product is malformed
+	ErrStorageProductFormat = errors.New("storage: product format invalid")
+
+	// ErrStorageOrderFormat is an error that returns when the stored orders are malformed
+	ErrStorageOrderFormat = errors.New("storage: order format invalid")
+
+	// ErrStorageCartFormat is an error that returns when the stored carts are malformed
+	ErrStorageCartFormat = errors.New("storage: cart format invalid")
+
+	// ErrStorageCategoryFormat is an error that returns when the stored categories are malformed
+	ErrStorageCategoryFormat = errors.New("storage: category format invalid")
+
+	// ErrStorageLotFormat is an error that returns when the stored lots are malformed
+	ErrStorageLotFormat = errors.New("storage: lot format invalid")
+
+	// ErrStoragePriceFormat is an error that returns when the stored price schedules are malformed
+	ErrStoragePriceFormat = errors.New("storage: price format invalid")
+
+	// ErrStoragePromotionFormat is an error that returns when the stored promotions are malformed
+	ErrStoragePromotionFormat = errors.New("storage: promotion format invalid")
 )
 
 // StorageProduct is an interface that contains the methods that a storage product must implement
 type StorageProduct interface {
-	// ReadAll is a method that returns all products
-	ReadAll() (p []Product, err error)
+	// ReadAll is a method that returns all products by id
+	ReadAll(ctx context.Context) (p map[int]*Product, err error)
 
 	// WriteAll is a method that writes all products
-	WriteAll(p []Product) (err error)
+	WriteAll(ctx context.Context, p map[int]*Product) (err error)
 }
@@ -0,0 +1,17 @@
+package tenant
+
+import "context"
+
+// tenantKey is the context key under which the tenant id is stored
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the tenant id
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant id stored in ctx, if any
+func TenantFromContext(ctx context.Context) (tenantID string, ok bool) {
+	tenantID, ok = ctx.Value(tenantKey{}).(string)
+	return
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/rhinosc/web-market/code/internal/auth"
+	"github.com/rhinosc/web-market/code/internal/tenant"
+	"github.com/rhinosc/web-market/code/platform/logger"
+	"github.com/rhinosc/web-market/code/platform/web/response"
+)
+
+type Tenancy struct {
+	// rs is the tenant resolver service.
+	rs tenant.Resolver
+}
+
+func NewTenancy(rs tenant.Resolver) *Tenancy {
+	return &Tenancy{
+		rs: rs,
+	}
+}
+
+// Tenant resolves the tenant of the authenticated principal, so it must run after the authenticator
+func (t *Tenancy) Tenant(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// before
+		// get principal
+		principal, _ := auth.PrincipalFromContext(r.Context())
+
+		// resolve tenant
+		tenantID, err := t.rs.Resolve(principal)
+		if err != nil {
+			slog.WarnContext(r.Context(), "tenant not resolved", "error", err)
+			response.Error(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		// call
+		logger.AddAttrs(r.Context(), slog.String("tenant", tenantID))
+		r = r.WithContext(tenant.ContextWithTenant(r.Context(), tenantID))
+		handler.ServeHTTP(w, r)
+	})
+}
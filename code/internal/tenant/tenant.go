@@ -0,0 +1,27 @@
+package tenant
+
+import "errors"
+
+var (
+	// ErrTenantNotFound is an error that returns when a principal does not belong to any tenant
+	ErrTenantNotFound = errors.New("tenant: not found")
+
+	// ErrTenantConfigInvalid is an error that returns when the tenants configuration is invalid
+	ErrTenantConfigInvalid = errors.New("tenant: config invalid")
+)
+
+// Tenant is a store sharing the service, with its own isolated catalog
+type Tenant struct {
+	// ID is the unique id of the tenant
+	ID string `json:"id"`
+	// Principals are the principals that belong to the tenant
+	Principals []string `json:"principals"`
+	// Storage is the location of the tenant's products file
+	Storage string `json:"storage"`
+}
+
+// Resolver is an interface that contains the methods that a tenant resolver must implement
+type Resolver interface {
+	// Resolve returns the id of the tenant a principal belongs to
+	Resolve(principal string) (tenantID string, err error)
+}
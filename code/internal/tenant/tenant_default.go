@@ -0,0 +1,101 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadTenants reads the tenants stored as a JSON array in the file and validates them
+func LoadTenants(filePath string) (tenants []Tenant, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&tenants); err != nil {
+		err = fmt.Errorf("%w: %v", ErrTenantConfigInvalid, err)
+		return
+	}
+	err = Validate(tenants)
+	return
+}
+
+// Validate checks that tenant ids are unique and set, that every tenant has a storage
+// and that no principal belongs to more than one tenant
+func Validate(tenants []Tenant) (err error) {
+	ids := make(map[string]bool)
+	storages := make(map[string]string)
+	principals := make(map[string]string)
+	for _, t := range tenants {
+		if t.ID == "" {
+			return fmt.Errorf("%w: id required", ErrTenantConfigInvalid)
+		}
+		if ids[t.ID] {
+			return fmt.Errorf("%w: duplicated id %s", ErrTenantConfigInvalid, t.ID)
+		}
+		ids[t.ID] = true
+
+		if t.Storage == "" {
+			return fmt.Errorf("%w: storage required for %s", ErrTenantConfigInvalid, t.ID)
+		}
+		if other, ok := storages[t.Storage]; ok {
+			return fmt.Errorf("%w: storage %s shared by %s and %s", ErrTenantConfigInvalid, t.Storage, other, t.ID)
+		}
+		storages[t.Storage] = t.ID
+
+		for _, p := range t.Principals {
+			if other, ok := principals[p]; ok {
+				return fmt.Errorf("%w: principal %s in %s and %s", ErrTenantConfigInvalid, p, other, t.ID)
+			}
+			principals[p] = t.ID
+		}
+	}
+	return
+}
+
+// NewDirectory returns a new Directory. Principals not listed by any tenant resolve to the tenant
+// with their same id, or to fallback if it is not empty.
+func NewDirectory(tenants []Tenant, fallback string) *Directory {
+	d := &Directory{
+		ids:        make(map[string]bool),
+		principals: make(map[string]string),
+		fallback:   fallback,
+	}
+	for _, t := range tenants {
+		d.ids[t.ID] = true
+		for _, p := range t.Principals {
+			d.principals[p] = t.ID
+		}
+	}
+	return d
+}
+
+// Directory is a Resolver backed by the tenants configuration
+type Directory struct {
+	// ids is the set of tenant ids
+	ids map[string]bool
+	// principals maps a principal to its tenant id
+	principals map[string]string
+	// fallback is the tenant id of unlisted principals
+	fallback string
+}
+
+// Resolve returns the id of the tenant a principal belongs to
+func (d *Directory) Resolve(principal string) (tenantID string, err error) {
+	if id, ok := d.principals[principal]; ok {
+		tenantID = id
+		return
+	}
+	if d.ids[principal] {
+		tenantID = principal
+		return
+	}
+	if d.fallback != "" {
+		tenantID = d.fallback
+		return
+	}
+	err = fmt.Errorf("%w: principal %s", ErrTenantNotFound, principal)
+	return
+}
@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+)
+
+// SignatureHeader is the header carrying the hex HMAC-SHA256 of the body, keyed with the secret
+const SignatureHeader = "X-Market-Signature"
+
+// NewSender returns a new Sender posting the alerts to urls, each given timeout to answer.
+// The bodies are signed when secret is not empty.
+func NewSender(urls []string, secret string, timeout time.Duration) *Sender {
+	return &Sender{
+		urls:   urls,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: timeout},
+		sent:   make(map[string]map[string]bool),
+	}
+}
+
+// Sender is an internal.AlertSender posting the alerts as JSON to webhooks.
+// Without urls the alerts are only logged.
+//
+// An alert sent again after a failure is only posted to the urls that did not accept it yet.
+// That is tracked in memory, so a restart may post it twice: the delivery is at least once,
+// and the receivers drop the duplicates by the id of the alert.
+type Sender struct {
+	urls   []string
+	secret []byte
+	client *http.Client
+	// mu guards sent
+	mu sync.Mutex
+	// sent is the urls that accepted an alert not yet delivered to all of them, by alert key
+	sent map[string]map[string]bool
+}
+
+// AlertJSON is the body posted for an alert
+type AlertJSON struct {
+	// ID is the key of the alert, the same on every attempt to deliver it
+	ID         string `json:"id,omitempty"`
+	Event      string `json:"event"`
+	Time       string `json:"time"`
+	Tenant     string `json:"tenant,omitempty"`
+	ProductID  int    `json:"product_id"`
+	Name       string `json:"name"`
+	Lot        string `json:"lot,omitempty"`
+	Quantity   int    `json:"quantity"`
+	Expiration string `json:"expiration"`
+}
+
+// Send posts the alert to every url that did not accept it yet, all of which must answer with a 2xx status
+func (s *Sender) Send(ctx context.Context, alert internal.Alert) (err error) {
+	slog.InfoContext(ctx, "alert", "event", alert.Event, "tenant", alert.Tenant, "id", alert.ProductID, "lot", alert.Lot)
+	if len(s.urls) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(AlertJSON{
+		ID:         alert.Key,
+		Event:      alert.Event,
+		Time:       alert.Time.UTC().Format(time.RFC3339),
+		Tenant:     alert.Tenant,
+		ProductID:  alert.ProductID,
+		Name:       alert.Name,
+		Lot:        alert.Lot,
+		Quantity:   alert.Quantity,
+		Expiration: alert.Expiration.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := s.sent[alert.Key]
+	if sent == nil {
+		sent = make(map[string]bool)
+	}
+
+	var errs []error
+	for _, url := range s.urls {
+		if sent[url] {
+			continue
+		}
+		if errPost := s.post(ctx, url, body); errPost != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", internal.ErrAlertNotDelivered, url, errPost))
+			continue
+		}
+		sent[url] = true
+	}
+
+	// without a key the alerts cannot be told apart, so each is posted to every url
+	switch {
+	case len(errs) == 0 || alert.Key == "":
+		delete(s.sent, alert.Key)
+	default:
+		s.sent[alert.Key] = sent
+	}
+	return errors.Join(errs...)
+}
+
+// post sends the body to a url
+func (s *Sender) post(ctx context.Context, url string, body []byte) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err = fmt.Errorf("status %d", res.StatusCode)
+	}
+	return
+}
+
+// Sign returns the hex HMAC-SHA256 of body keyed with secret, as sent in the SignatureHeader
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
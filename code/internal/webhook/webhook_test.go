@@ -0,0 +1,127 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rhinosc/web-market/code/internal"
+	"github.com/rhinosc/web-market/code/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSender_Send(t *testing.T) {
+	alert := internal.Alert{
+		Event:      internal.AlertLotExpired,
+		Time:       time.Date(2030, time.March, 1, 8, 0, 0, 0, time.UTC),
+		Tenant:     "acme",
+		ProductID:  1,
+		Name:       "Milk",
+		Lot:        "L1",
+		Quantity:   4,
+		Expiration: time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("success 01 - should post the alert signed with the secret", func(t *testing.T) {
+		// arrange
+		var body []byte
+		var signature string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = io.ReadAll(r.Body)
+			signature = r.Header.Get(webhook.SignatureHeader)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+		sd := webhook.NewSender([]string{srv.URL}, "secret", time.Second)
+
+		// act
+		err := sd.Send(context.Background(), alert)
+
+		// assert
+		require.NoError(t, err)
+		var got webhook.AlertJSON
+		require.NoError(t, json.Unmarshal(body, &got))
+		require.Equal(t, webhook.AlertJSON{
+			Event:      "lot.expired",
+			Time:       "2030-03-01T08:00:00Z",
+			Tenant:     "acme",
+			ProductID:  1,
+			Name:       "Milk",
+			Lot:        "L1",
+			Quantity:   4,
+			Expiration: "2030-03-01T00:00:00Z",
+		}, got)
+		require.Equal(t, webhook.Sign([]byte("secret"), body), signature)
+	})
+
+	t.Run("success 02 - should only log the alert without urls", func(t *testing.T) {
+		// arrange
+		sd := webhook.NewSender(nil, "", time.Second)
+
+		// act
+		err := sd.Send(context.Background(), alert)
+
+		// assert
+		require.NoError(t, err)
+	})
+
+	t.Run("success 03 - should post an alert sent again only to the urls that did not accept it", func(t *testing.T) {
+		// arrange
+		var okCalls, failCalls int
+		var body []byte
+		ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			okCalls++
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ok.Close()
+		fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			failCalls++
+			body, _ = io.ReadAll(r.Body)
+			if failCalls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer fail.Close()
+		sd := webhook.NewSender([]string{ok.URL, fail.URL}, "", time.Second)
+		keyed := alert
+		keyed.Key = "lot.expired|acme|1|L1|1900800000"
+
+		// act
+		errFirst := sd.Send(context.Background(), keyed)
+		errAgain := sd.Send(context.Background(), keyed)
+
+		// assert
+		require.ErrorIs(t, errFirst, internal.ErrAlertNotDelivered)
+		require.NoError(t, errAgain)
+		require.Equal(t, 1, okCalls)
+		require.Equal(t, 2, failCalls)
+		var got webhook.AlertJSON
+		require.NoError(t, json.Unmarshal(body, &got))
+		require.Equal(t, keyed.Key, got.ID)
+	})
+
+	t.Run("fail 01 - should report the urls that did not accept the alert", func(t *testing.T) {
+		// arrange
+		var calls int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			require.Empty(t, r.Header.Get(webhook.SignatureHeader))
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+		sd := webhook.NewSender([]string{srv.URL, srv.URL}, "", time.Second)
+
+		// act
+		err := sd.Send(context.Background(), alert)
+
+		// assert
+		require.ErrorIs(t, err, internal.ErrAlertNotDelivered)
+		require.Equal(t, 2, calls)
+	})
+}
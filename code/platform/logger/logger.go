@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// attrsKey is the context key under which the request attributes are stored
+type attrsKey struct{}
+
+// attrs is the set of attributes added to every record logged within a request.
+// It is shared by pointer so inner middlewares can enrich what outer ones log.
+type attrs struct {
+	mu   sync.Mutex
+	list []slog.Attr
+}
+
+// ContextWithAttrs returns a copy of ctx carrying a new set of request attributes
+func ContextWithAttrs(ctx context.Context, list ...slog.Attr) context.Context {
+	return context.WithValue(ctx, attrsKey{}, &attrs{list: list})
+}
+
+// AddAttrs adds attributes to the set carried by ctx. It does nothing if ctx carries no set.
+func AddAttrs(ctx context.Context, list ...slog.Attr) {
+	a, ok := ctx.Value(attrsKey{}).(*attrs)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	a.list = append(a.list, list...)
+	a.mu.Unlock()
+}
+
+// Attrs returns a copy of the attributes carried by ctx
+func Attrs(ctx context.Context) (list []slog.Attr) {
+	a, ok := ctx.Value(attrsKey{}).(*attrs)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	list = append(list, a.list...)
+	a.mu.Unlock()
+	return
+}
+
+// NewContextHandler returns a new ContextHandler wrapping h
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	return &ContextHandler{
+		Handler: h,
+	}
+}
+
+// ContextHandler is a slog.Handler that adds the request attributes carried by the context to every record
+type ContextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request attributes to the record and passes it to the wrapped handler
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(Attrs(ctx)...)
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a ContextHandler whose wrapped handler has the attributes
+func (h *ContextHandler) WithAttrs(list []slog.Attr) slog.Handler {
+	return NewContextHandler(h.Handler.WithAttrs(list))
+}
+
+// WithGroup returns a ContextHandler whose wrapped handler has the group
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return NewContextHandler(h.Handler.WithGroup(name))
+}
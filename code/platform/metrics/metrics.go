@@ -0,0 +1,308 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default histogram buckets, in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the application metrics are registered in
+var Default = NewRegistry()
+
+// collector is a metric family that can write itself in the text exposition format
+type collector interface {
+	write(w io.Writer)
+}
+
+// NewRegistry returns a new Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// Registry is a set of metric families exposed together
+type Registry struct {
+	mu         sync.Mutex
+	names      []string
+	collectors map[string]collector
+}
+
+// register adds a collector, panicking on duplicated names as it is a programming error
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[name]; ok {
+		panic("metrics: duplicated metric " + name)
+	}
+	r.names = append(r.names, name)
+	r.collectors[name] = c
+}
+
+// NewCounterVec registers and returns a new counter family with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: newFamily(name, help, "counter", labels)}
+	r.register(name, c)
+	return c
+}
+
+// NewHistogramVec registers and returns a new histogram family with the given buckets and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{family: newFamily(name, help, "histogram", labels), buckets: buckets}
+	r.register(name, h)
+	return h
+}
+
+// NewGaugeVec registers and returns a new gauge family with the given label names
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{family: newFamily(name, help, "gauge", labels)}
+	r.register(name, g)
+	return g
+}
+
+// WriteText writes every metric family in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) (err error) {
+	r.mu.Lock()
+	names := append([]string(nil), r.names...)
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		r.mu.Lock()
+		c := r.collectors[name]
+		r.mu.Unlock()
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler returns a handler serving the registry in the Prometheus text exposition format
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		r.WriteText(w)
+	}
+}
+
+// family holds the metadata shared by every kind of metric
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func newFamily(name, help, kind string, labels []string) family {
+	return family{name: name, help: help, kind: kind, labels: labels}
+}
+
+// header writes the HELP and TYPE lines
+func (f family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+}
+
+// key joins label values into a map key
+func (f family) key(labelValues []string) string {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// labelPairs formats label values, plus optional extra pairs, as {a="x",b="y"}
+func (f family) labelPairs(labelValues []string, extra ...string) string {
+	if len(f.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, l := range f.labels {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(l + `="` + escapeLabel(labelValues[i]) + `"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if sb.Len() > 1 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extra[i] + `="` + escapeLabel(extra[i+1]) + `"`)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// CounterVec is a family of monotonically increasing counters
+type CounterVec struct {
+	family
+
+	mu     sync.Mutex
+	values map[string]*counterSample
+}
+
+// counterSample is the value of a counter or gauge for a set of label values
+type counterSample struct {
+	labelValues []string
+	value       float64
+}
+
+// Inc adds one to the counter with the label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter with the label values
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	k := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]*counterSample)
+	}
+	s, ok := c.values[k]
+	if !ok {
+		s = &counterSample{labelValues: append([]string(nil), labelValues...)}
+		c.values[k] = s
+	}
+	s.value += v
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.header(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range sortedKeys(c.values) {
+		s := c.values[k]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(s.labelValues), formatFloat(s.value))
+	}
+}
+
+// HistogramVec is a family of histograms sharing the same buckets
+type HistogramVec struct {
+	family
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramSample
+}
+
+type histogramSample struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// Observe adds an observation to the histogram with the label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	k := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.values == nil {
+		h.values = make(map[string]*histogramSample)
+	}
+	s, ok := h.values[k]
+	if !ok {
+		s = &histogramSample{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[k] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.header(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range sortedKeys(h.values) {
+		s := h.values[k]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(s.labelValues, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(s.labelValues), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(s.labelValues), s.count)
+	}
+}
+
+// GaugeVec is a family of values that can go up and down
+type GaugeVec struct {
+	family
+
+	mu     sync.Mutex
+	values map[string]*counterSample
+}
+
+// Set sets the gauge with the label values to v
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	k := g.key(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.values == nil {
+		g.values = make(map[string]*counterSample)
+	}
+	s, ok := g.values[k]
+	if !ok {
+		s = &counterSample{labelValues: append([]string(nil), labelValues...)}
+		g.values[k] = s
+	}
+	s.value = v
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.header(w)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, k := range sortedKeys(g.values) {
+		s := g.values[k]
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(s.labelValues), formatFloat(s.value))
+	}
+}
+
+// sortedKeys returns the keys of m in order, so the output is stable between scrapes
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat formats a sample value as expected by the exposition format
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
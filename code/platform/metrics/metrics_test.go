@@ -0,0 +1,61 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/platform/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Registry.Handler
+func TestRegistry_Handler(t *testing.T) {
+	t.Run("case 1: should expose counters, gauges and histograms in the text format", func(t *testing.T) {
+		// arrange
+		reg := metrics.NewRegistry()
+		requests := reg.NewCounterVec("requests_total", "Number of requests.", "route")
+		size := reg.NewGaugeVec("size_bytes", "Size of the file.")
+		latency := reg.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+
+		requests.Inc("/products/{id}")
+		requests.Add(2, `a"b`)
+		size.Set(1024)
+		latency.Observe(0.05, "/products")
+		latency.Observe(0.5, "/products")
+
+		// act
+		rr := httptest.NewRecorder()
+		reg.Handler()(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+		// assert
+		expectedCode := http.StatusOK
+		expectedBody := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/products",le="0.1"} 1
+latency_seconds_bucket{route="/products",le="1"} 2
+latency_seconds_bucket{route="/products",le="+Inf"} 2
+latency_seconds_sum{route="/products"} 0.55
+latency_seconds_count{route="/products"} 2
+# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{route="/products/{id}"} 1
+requests_total{route="a\"b"} 2
+# HELP size_bytes Size of the file.
+# TYPE size_bytes gauge
+size_bytes 1024
+`
+		require.Equal(t, expectedCode, rr.Code)
+		require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+		require.Equal(t, expectedBody, rr.Body.String())
+	})
+
+	t.Run("case 2: should panic when a metric is registered twice", func(t *testing.T) {
+		// arrange
+		reg := metrics.NewRegistry()
+		reg.NewCounterVec("requests_total", "Number of requests.")
+
+		// act & assert
+		require.Panics(t, func() { reg.NewCounterVec("requests_total", "Number of requests.") })
+	})
+}
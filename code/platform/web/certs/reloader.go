@@ -0,0 +1,118 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCertsInvalid is an error that returns when a certificate or CA file cannot be used
+	ErrCertsInvalid = errors.New("certs: invalid")
+)
+
+// NewReloader returns a new Reloader, loading the key pair once to fail fast on bad files
+func NewReloader(certFile, keyFile string, interval time.Duration) (r *Reloader, err error) {
+	r = &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		now:      time.Now,
+	}
+	if err = r.load(); err != nil {
+		r = nil
+	}
+	return
+}
+
+// Reloader serves a certificate key pair from disk, reloading it when the files change,
+// so certificates can be renewed without restarting the server
+type Reloader struct {
+	certFile string
+	keyFile  string
+	// interval is the minimum time between two checks of the files
+	interval time.Duration
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+}
+
+// GetCertificate returns the current certificate, as expected by tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := r.now(); now.Sub(r.checked) >= r.interval {
+		r.checked = now
+		if modTime, err := r.lastModified(); err == nil && !modTime.Equal(r.modTime) {
+			if err := r.loadLocked(); err != nil {
+				// keep serving the previous certificate until the files are fixed
+				slog.Error("reloading certificate", "cert_file", r.certFile, "error", err)
+			} else {
+				slog.Info("certificate reloaded", "cert_file", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// load reads the key pair
+func (r *Reloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loadLocked()
+}
+
+func (r *Reloader) loadLocked() (err error) {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrCertsInvalid, err)
+		return
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = r.now()
+	return
+}
+
+// lastModified returns the latest modification time of the key pair files
+func (r *Reloader) lastModified() (modTime time.Time, err error) {
+	for _, f := range []string{r.certFile, r.keyFile} {
+		var info os.FileInfo
+		info, err = os.Stat(f)
+		if err != nil {
+			return
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return
+}
+
+// LoadCertPool reads the PEM encoded certificates of a CA file
+func LoadCertPool(caFile string) (pool *x509.CertPool, err error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return
+	}
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		pool, err = nil, fmt.Errorf("%w: no certificates in %s", ErrCertsInvalid, caFile)
+	}
+	return
+}
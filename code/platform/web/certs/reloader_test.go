@@ -0,0 +1,100 @@
+package certs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rhinosc/web-market/code/platform/web/certs"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair writes a self-signed certificate for cn and its key, with the given modification time
+func writeKeyPair(t *testing.T, certFile, keyFile, cn string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+// commonName returns the common name of the leaf certificate served by the reloader
+func commonName(t *testing.T, r *certs.Reloader) string {
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+// Tests for Reloader
+func TestReloader(t *testing.T) {
+	t.Run("case 1: should serve the new certificate once the files change", func(t *testing.T) {
+		// arrange
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		writeKeyPair(t, certFile, keyFile, "first", time.Now().Add(-time.Minute))
+		r, err := certs.NewReloader(certFile, keyFile, 0)
+		require.NoError(t, err)
+
+		// act
+		before := commonName(t, r)
+		writeKeyPair(t, certFile, keyFile, "second", time.Now())
+		after := commonName(t, r)
+
+		// assert
+		require.Equal(t, "first", before)
+		require.Equal(t, "second", after)
+	})
+
+	t.Run("case 2: should keep the previous certificate when the new files are invalid", func(t *testing.T) {
+		// arrange
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		writeKeyPair(t, certFile, keyFile, "first", time.Now().Add(-time.Minute))
+		r, err := certs.NewReloader(certFile, keyFile, 0)
+		require.NoError(t, err)
+
+		// act
+		require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0644))
+		cn := commonName(t, r)
+
+		// assert
+		require.Equal(t, "first", cn)
+	})
+
+	t.Run("case 3: should fail on start when the files are invalid", func(t *testing.T) {
+		// arrange
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0644))
+		require.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0600))
+
+		// act
+		r, err := certs.NewReloader(certFile, keyFile, time.Minute)
+
+		// assert
+		require.ErrorIs(t, err, certs.ErrCertsInvalid)
+		require.Nil(t, r)
+	})
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import "net/http"
+
+// MaxBodyBytes limits the size of request bodies to n bytes.
+// Reading past the limit fails, which the handlers report as an invalid body.
+func MaxBodyBytes(n int64) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// before
+			if r.ContentLength > n {
+				w.Header().Set("Connection", "close")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+
+			// call
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import "net/http"
+
+// CacheControl sets the Cache-Control header of the responses that do not set their own
+func CacheControl(value string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// before: the handler may still override it
+			w.Header().Set("Cache-Control", value)
+
+			// call
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
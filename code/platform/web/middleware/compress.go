@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encoding is a content coding the responses can be compressed with
+type Encoding struct {
+	// Name is the token of the coding in Accept-Encoding and Content-Encoding
+	Name string
+	// NewWriter returns a writer compressing into w
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	// EncodingZstd is the zstd coding (RFC 8878), with a single encoding goroutine per response
+	EncodingZstd = Encoding{Name: "zstd", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+	}}
+	// EncodingBrotli is the br coding (RFC 7932), at a level fast enough for responses built on every request
+	EncodingBrotli = Encoding{Name: "br", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriterLevel(w, 5), nil
+	}}
+	// EncodingGzip is the gzip coding
+	EncodingGzip = Encoding{Name: "gzip", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	}}
+	// EncodingDeflate is the deflate coding, which in http is the zlib format (RFC 9110)
+	EncodingDeflate = Encoding{Name: "deflate", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriterLevel(w, zlib.DefaultCompression)
+	}}
+)
+
+// DefaultCompressMinSize is the size below which responses are not worth compressing
+const DefaultCompressMinSize = 1024
+
+// Compress compresses the responses of at least minSize bytes with the coding the client prefers
+// among encodings, which are in the order of preference of the server (default zstd, br, gzip, deflate).
+// Responses that already have a Content-Encoding or a compressed content type are left as they are.
+func Compress(minSize int, encodings ...Encoding) func(http.Handler) http.Handler {
+	if len(encodings) == 0 {
+		encodings = []Encoding{EncodingZstd, EncodingBrotli, EncodingGzip, EncodingDeflate}
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the response depends on Accept-Encoding, whether it is compressed or not
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			enc, ok := negotiate(r.Header.Get("Accept-Encoding"), encodings)
+			if !ok || r.Method == http.MethodHead {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, enc: enc, minSize: minSize}
+			defer cw.close()
+			handler.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiate returns the encoding with the highest q-value in Accept-Encoding,
+// the server's order breaking ties
+func negotiate(accept string, encodings []Encoding) (enc Encoding, ok bool) {
+	if accept == "" {
+		return
+	}
+
+	q := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	best := 0.0
+	for _, e := range encodings {
+		weight, listed := q[e.Name]
+		if !listed {
+			// the wildcard stands for every coding not listed
+			weight = q["*"]
+		}
+		if weight > best {
+			enc, ok, best = e, true, weight
+		}
+	}
+	return
+}
+
+// compressWriter buffers the beginning of the response until it knows whether to compress it
+type compressWriter struct {
+	http.ResponseWriter
+
+	enc     Encoding
+	minSize int
+
+	// status is the code of the response, written once decided
+	status int
+	// buf holds the body until minSize bytes are written
+	buf []byte
+	// decided is set once the headers are written, zw is the compressor if compressing
+	decided bool
+	zw      io.WriteCloser
+}
+
+// WriteHeader records the status code; bodiless statuses are written right away
+func (c *compressWriter) WriteHeader(code int) {
+	if c.decided || c.status != 0 {
+		return
+	}
+	c.status = code
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		c.decide(false)
+	}
+}
+
+// Write buffers the body until it is large enough to be compressed
+func (c *compressWriter) Write(b []byte) (n int, err error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if !c.decided {
+		c.buf = append(c.buf, b...)
+		if len(c.buf) < c.minSize {
+			return len(b), nil
+		}
+		if err = c.decide(true); err != nil {
+			return
+		}
+		return len(b), nil
+	}
+
+	if c.zw != nil {
+		return c.zw.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush compresses and sends what has been written so far, for streamed responses
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		if c.status == 0 {
+			c.status = http.StatusOK
+		}
+		if err := c.decide(true); err != nil {
+			return
+		}
+	}
+	if f, ok := c.zw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// decide writes the headers, compressing the body if compress is set and the response allows it,
+// and then the buffered body
+func (c *compressWriter) decide(compress bool) (err error) {
+	c.decided = true
+
+	h := c.ResponseWriter.Header()
+	if h.Get("Content-Type") == "" && len(c.buf) > 0 {
+		// net/http would otherwise sniff the compressed bytes
+		h.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+	if compress && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", c.enc.Name)
+		h.Del("Content-Length")
+		if c.zw, err = c.enc.NewWriter(c.ResponseWriter); err != nil {
+			c.zw = nil
+			h.Del("Content-Encoding")
+		}
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return
+	}
+	if c.zw != nil {
+		_, err = c.zw.Write(buf)
+		return
+	}
+	_, err = c.ResponseWriter.Write(buf)
+	return
+}
+
+// close sends a response too small to be compressed, or ends the compressed one
+func (c *compressWriter) close() {
+	if !c.decided {
+		if c.status == 0 {
+			// the handler wrote nothing
+			return
+		}
+		c.decide(false)
+	}
+	if c.zw != nil {
+		c.zw.Close()
+	}
+}
+
+// compressible reports whether a content type benefits from compression
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/x-ndjson", mediaType == "application/xml", mediaType == "application/javascript":
+		return true
+	}
+	// images, archives, pprof profiles (already gzipped)...
+	return false
+}
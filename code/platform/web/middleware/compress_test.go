@@ -0,0 +1,120 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rhinosc/web-market/code/platform/web/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Compress
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"name":"Product"}`, 100)
+	handler := func(contentType, body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			w.Write([]byte(body))
+		})
+	}
+	serve := func(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		res := httptest.NewRecorder()
+		middleware.Compress(middleware.DefaultCompressMinSize)(h).ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("case 1: should gzip a large response", func(t *testing.T) {
+		// act
+		res := serve(handler("application/json", body), "gzip, deflate")
+
+		// assert
+		require.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+		require.Equal(t, "Accept-Encoding", res.Header().Get("Vary"))
+		zr, err := gzip.NewReader(res.Body)
+		require.NoError(t, err)
+		b, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, body, string(b))
+	})
+
+	t.Run("case 2: should honor the q-values of the client", func(t *testing.T) {
+		// act
+		res := serve(handler("application/json", body), "gzip;q=0.5, deflate")
+
+		// assert
+		require.Equal(t, "deflate", res.Header().Get("Content-Encoding"))
+		zr, err := zlib.NewReader(res.Body)
+		require.NoError(t, err)
+		b, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, body, string(b))
+	})
+
+	t.Run("case 3: should not compress unsupported or refused codings", func(t *testing.T) {
+		for _, acceptEncoding := range []string{"", "compress, xz", "zstd;q=0, br;q=0, gzip;q=0, deflate;q=0", "identity"} {
+			// act
+			res := serve(handler("application/json", body), acceptEncoding)
+
+			// assert
+			require.Empty(t, res.Header().Get("Content-Encoding"), acceptEncoding)
+			require.Equal(t, body, res.Body.String())
+		}
+	})
+
+	t.Run("case 4: should not compress small or already compressed responses", func(t *testing.T) {
+		// act
+		small := serve(handler("application/json", `{"name":"Product"}`), "gzip")
+		binary := serve(handler("application/octet-stream", body), "gzip")
+
+		// assert
+		require.Empty(t, small.Header().Get("Content-Encoding"))
+		require.Equal(t, `{"name":"Product"}`, small.Body.String())
+		require.Empty(t, binary.Header().Get("Content-Encoding"))
+	})
+
+	t.Run("case 5: should keep the sniffed content type of the uncompressed body", func(t *testing.T) {
+		// act
+		res := serve(handler("", strings.Repeat("pong ", 300)), "gzip")
+
+		// assert
+		require.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+		require.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	})
+
+	t.Run("case 6: should negotiate br and zstd", func(t *testing.T) {
+		// act
+		br := serve(handler("application/json", body), "gzip, br")
+		zst := serve(handler("application/json", body), "gzip, deflate, br;q=0.8, zstd")
+		preferred := serve(handler("application/json", body), "gzip, deflate, br, zstd")
+
+		// assert
+		require.Equal(t, "br", br.Header().Get("Content-Encoding"))
+		b, err := io.ReadAll(brotli.NewReader(br.Body))
+		require.NoError(t, err)
+		require.Equal(t, body, string(b))
+
+		require.Equal(t, "zstd", zst.Header().Get("Content-Encoding"))
+		zr, err := zstd.NewReader(zst.Body)
+		require.NoError(t, err)
+		defer zr.Close()
+		b, err = io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, body, string(b))
+
+		// every coding at the same q-value: the order of the server decides
+		require.Equal(t, "zstd", preferred.Header().Get("Content-Encoding"))
+	})
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Conditional gives the successful GET responses a strong ETag, hashed from the body unless the handler
+// set one, and answers 304 Not Modified when the request's If-None-Match, or else its If-Modified-Since
+// against the Last-Modified set by the handler, shows the client already has the response.
+// The body is buffered to be hashed, except for responses the handler flushes, which are streamed untagged.
+// It must wrap Compress, so that every content coding of a response has its own ETag.
+func Conditional(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &conditionalWriter{ResponseWriter: w}
+		handler.ServeHTTP(cw, r)
+		if cw.streaming {
+			return
+		}
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+
+		h := w.Header()
+		if cw.status == http.StatusOK {
+			if h.Get("ETag") == "" {
+				sum := sha256.Sum256(cw.buf.Bytes())
+				h.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+			}
+
+			if notModified(r, h) {
+				h.Del("Content-Type")
+				h.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		w.WriteHeader(cw.status)
+		w.Write(cw.buf.Bytes())
+	})
+}
+
+// notModified reports whether the client's cached response, as described by the request preconditions,
+// is still the current one (RFC 9110, 13.2.2)
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(h.Get("ETag"), "W/")
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		// If-Modified-Since is ignored when If-None-Match is present
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.Truncate(time.Second).After(ims)
+}
+
+// conditionalWriter buffers the response until it is known whether it is modified
+type conditionalWriter struct {
+	http.ResponseWriter
+
+	status int
+	buf    bytes.Buffer
+	// streaming is set once the handler flushes, from then on it writes through
+	streaming bool
+}
+
+// WriteHeader records the status code
+func (c *conditionalWriter) WriteHeader(code int) {
+	if c.streaming {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if c.status == 0 {
+		c.status = code
+	}
+}
+
+// Write buffers the body
+func (c *conditionalWriter) Write(b []byte) (n int, err error) {
+	if c.streaming {
+		return c.ResponseWriter.Write(b)
+	}
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.buf.Write(b)
+}
+
+// Flush gives up on the conditional response: what is buffered is sent and the rest streamed
+func (c *conditionalWriter) Flush() {
+	if !c.streaming {
+		c.streaming = true
+		if c.status == 0 {
+			c.status = http.StatusOK
+		}
+		c.ResponseWriter.WriteHeader(c.status)
+		c.ResponseWriter.Write(c.buf.Bytes())
+		c.buf.Reset()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (c *conditionalWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,114 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rhinosc/web-market/code/platform/web/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Conditional
+func TestConditional(t *testing.T) {
+	modified := time.Date(2030, time.January, 2, 15, 4, 5, 0, time.UTC)
+	hd := middleware.Conditional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write([]byte(`{"name":"Product"}`))
+	}))
+	serve := func(h http.Handler, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header = header
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("case 1: should tag the response with a strong etag", func(t *testing.T) {
+		// act
+		res := serve(hd, http.Header{})
+		again := serve(hd, http.Header{})
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Regexp(t, `^"[0-9a-f]{32}"$`, res.Header().Get("ETag"))
+		require.Equal(t, res.Header().Get("ETag"), again.Header().Get("ETag"))
+		require.Equal(t, `{"name":"Product"}`, res.Body.String())
+	})
+
+	t.Run("case 2: should answer not modified to a matching If-None-Match", func(t *testing.T) {
+		// arrange
+		etag := serve(hd, http.Header{}).Header().Get("ETag")
+
+		// act
+		res := serve(hd, http.Header{"If-None-Match": {`"other", ` + etag}})
+
+		// assert
+		require.Equal(t, http.StatusNotModified, res.Code)
+		require.Equal(t, etag, res.Header().Get("ETag"))
+		require.Empty(t, res.Body.String())
+	})
+
+	t.Run("case 3: should answer with the body to a stale If-None-Match, ignoring If-Modified-Since", func(t *testing.T) {
+		// act
+		res := serve(hd, http.Header{
+			"If-None-Match":     {`"stale"`},
+			"If-Modified-Since": {modified.Format(http.TimeFormat)},
+		})
+
+		// assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, `{"name":"Product"}`, res.Body.String())
+	})
+
+	t.Run("case 4: should compare If-Modified-Since with Last-Modified", func(t *testing.T) {
+		// act
+		current := serve(hd, http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}})
+		stale := serve(hd, http.Header{"If-Modified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)}})
+
+		// assert
+		require.Equal(t, http.StatusNotModified, current.Code)
+		require.Equal(t, http.StatusOK, stale.Code)
+	})
+
+	t.Run("case 5: should give each content coding its own etag", func(t *testing.T) {
+		// arrange
+		large := middleware.Conditional(middleware.Compress(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name":"Product"}`))
+		})))
+
+		// act
+		identity := serve(large, http.Header{})
+		gzipped := serve(large, http.Header{"Accept-Encoding": {"gzip"}})
+
+		// assert
+		require.Equal(t, "gzip", gzipped.Header().Get("Content-Encoding"))
+		require.NotEqual(t, identity.Header().Get("ETag"), gzipped.Header().Get("ETag"))
+	})
+
+	t.Run("case 6: should not tag errors nor buffer streamed responses", func(t *testing.T) {
+		// arrange
+		failing := middleware.Conditional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "not found", http.StatusNotFound)
+		}))
+		streaming := middleware.Conditional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("line 1\n"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("line 2\n"))
+		}))
+
+		// act
+		notFound := serve(failing, http.Header{})
+		streamed := serve(streaming, http.Header{})
+
+		// assert
+		require.Equal(t, http.StatusNotFound, notFound.Code)
+		require.Empty(t, notFound.Header().Get("ETag"))
+		require.True(t, streamed.Flushed)
+		require.Empty(t, streamed.Header().Get("ETag"))
+		require.Equal(t, "line 1\nline 2\n", streamed.Body.String())
+	})
+}
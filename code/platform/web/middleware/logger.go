@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/rhinosc/web-market/code/platform/logger"
+)
+
+// Logger logs one record per request with its method, path, status and latency.
+// It must run after RequestID; the attributes added by inner middlewares through
+// logger.AddAttrs (e.g. the principal) are included in this and every other record of the request.
+func Logger(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// before
+		start := time.Now()
+		ctx := logger.ContextWithAttrs(r.Context(), slog.String("request_id", RequestIDFromContext(r.Context())))
+		rw := NewResponseRecorder(w)
+
+		// call
+		handler.ServeHTTP(rw, r.WithContext(ctx))
+
+		// after
+		level := slog.LevelInfo
+		if rw.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rw.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", rw.Bytes()),
+		)
+	})
+}
@@ -0,0 +1,50 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/platform/logger"
+	"github.com/rhinosc/web-market/code/platform/web/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Logger
+func TestLogger(t *testing.T) {
+	t.Run("case 1: should log the request with its id and the attributes added downstream", func(t *testing.T) {
+		// arrange
+		var buf bytes.Buffer
+		defaultLogger := slog.Default()
+		slog.SetDefault(slog.New(logger.NewContextHandler(slog.NewJSONHandler(&buf, nil))))
+		defer slog.SetDefault(defaultLogger)
+
+		hd := middleware.RequestID(middleware.Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.AddAttrs(r.Context(), slog.String("principal", "alice"))
+			w.WriteHeader(http.StatusTeapot)
+		})))
+
+		// act
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.Header.Set(middleware.HeaderRequestID, "abc")
+		res := httptest.NewRecorder()
+		hd.ServeHTTP(res, req)
+
+		var record map[string]any
+		err := json.Unmarshal(buf.Bytes(), &record)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, "abc", res.Header().Get(middleware.HeaderRequestID))
+		require.Equal(t, "request", record["msg"])
+		require.Equal(t, "GET", record["method"])
+		require.Equal(t, "/products", record["path"])
+		require.Equal(t, float64(http.StatusTeapot), record["status"])
+		require.Equal(t, "abc", record["request_id"])
+		require.Equal(t, "alice", record["principal"])
+		require.Contains(t, record, "latency")
+	})
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rhinosc/web-market/code/platform/metrics"
+)
+
+var (
+	httpRequests = metrics.Default.NewCounterVec("http_requests_total",
+		"Number of HTTP requests by route pattern, method and status code.", "route", "method", "code")
+	httpDuration = metrics.Default.NewHistogramVec("http_request_duration_seconds",
+		"Latency of HTTP requests by route pattern and method.", metrics.DefaultBuckets, "route", "method")
+)
+
+// Metrics counts the requests and observes their latency per chi route pattern.
+// Requests that match no route are reported under the "unmatched" route to bound cardinality.
+func Metrics(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// before
+		start := time.Now()
+		rw := NewResponseRecorder(w)
+
+		// call
+		handler.ServeHTTP(rw, r)
+
+		// after
+		// the route pattern is only known once chi has routed the request
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		httpRequests.Inc(route, r.Method, strconv.Itoa(rw.Status()))
+		httpDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import "net/http"
+
+// NewResponseRecorder returns a new ResponseRecorder wrapping w
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{
+		ResponseWriter: w,
+	}
+}
+
+// ResponseRecorder is a http.ResponseWriter that remembers the status code and size of the response
+type ResponseRecorder struct {
+	http.ResponseWriter
+
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status code and writes it
+func (r *ResponseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the number of bytes written and writes them
+func (r *ResponseRecorder) Write(b []byte) (n int, err error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err = r.ResponseWriter.Write(b)
+	r.bytes += n
+	return
+}
+
+// Status returns the status code of the response, 200 if none was written explicitly
+func (r *ResponseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Bytes returns the number of bytes of the body written so far
+func (r *ResponseRecorder) Bytes() int {
+	return r.bytes
+}
+
+// Flush flushes the wrapped writer if it supports it
+func (r *ResponseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (r *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// HeaderRequestID is the header used to propagate the request id
+const HeaderRequestID = "X-Request-ID"
+
+// requestIDKey is the context key under which the request id is stored
+type requestIDKey struct{}
+
+// RequestID assigns a request id to every request, reusing the one sent by the client if present.
+// The id is echoed back in the response headers and stored in the request context.
+func RequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// before
+		// get or generate the request id
+		id := r.Header.Get(HeaderRequestID)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+
+		// call
+		r = r.WithContext(ContextWithRequestID(r.Context(), id))
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the request id
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id stored in ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) (id string) {
+	id, _ = ctx.Value(requestIDKey{}).(string)
+	return
+}
+
+// newRequestID returns a random 128-bit hex encoded id
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -1,34 +1,123 @@
 package request
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
 )
 
-// JSON decodes json from request body to ptr
+// DefaultMaxBytes is the maximum size of a json body when none is configured
+const DefaultMaxBytes int64 = 1 << 20
+
 var (
 	// ErrRequestContentTypeNotJSON is used when the request content type is not application/json.
 	ErrRequestContentTypeNotJSON = errors.New("request content type is not application/json")
 	// ErrRequestJSONInvalid is used when the request json is invalid.
 	ErrRequestJSONInvalid = errors.New("request json invalid")
+	// ErrRequestBodyTooLarge is used when the request body exceeds the maximum size.
+	ErrRequestBodyTooLarge = errors.New("request body too large")
 )
 
-// JSON decodes json from request body to ptr
+// JSONOptions configures how JSONWith reads a body
+type JSONOptions struct {
+	// MaxBytes is the maximum size of the body (default DefaultMaxBytes)
+	MaxBytes int64
+	// AllowUnknownFields accepts fields that ptr has no field for
+	AllowUnknownFields bool
+	// Schema, if not nil, validates the body before it is decoded into ptr
+	Schema *Schema
+}
+
+// JSON decodes json from request body to ptr, with the default options
 func JSON(r *http.Request, ptr any) (err error) {
+	return JSONWith(r, ptr, JSONOptions{})
+}
+
+// JSONWith decodes json from request body to ptr.
+// The content type must be application/json (or a +json type) in utf-8, the body a single json value
+// no larger than the maximum size, without unknown fields and, if any, valid against the schema.
+// Fields missing in the body keep the value they have in ptr.
+func JSONWith(r *http.Request, ptr any, opts JSONOptions) (err error) {
 	// check content type
-	if r.Header.Get("Content-Type") != "application/json" {
+	if !isJSON(r.Header.Get("Content-Type")) {
 		err = ErrRequestContentTypeNotJSON
 		return
 	}
 
 	// get body
-	err = json.NewDecoder(r.Body).Decode(ptr)
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
 	if err != nil {
+		// the server may already limit the body with http.MaxBytesReader
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			err = ErrRequestBodyTooLarge
+			return
+		}
 		err = fmt.Errorf("%w. %v", ErrRequestJSONInvalid, err)
 		return
 	}
+	if int64(len(body)) > maxBytes {
+		err = ErrRequestBodyTooLarge
+		return
+	}
+
+	// validate against the schema
+	if opts.Schema != nil {
+		// numbers are kept as json.Number so that integers are told apart from floats
+		var v any
+		if err = decode(body, &v, true, true); err != nil {
+			return
+		}
+		if err = opts.Schema.Validate(v); err != nil {
+			return
+		}
+	}
+
+	err = decode(body, ptr, opts.AllowUnknownFields, false)
+	return
+}
+
+// decode decodes a single json value from body to ptr
+func decode(body []byte, ptr any, allowUnknownFields, useNumber bool) (err error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if useNumber {
+		dec.UseNumber()
+	}
+	if !allowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
 
+	if err = dec.Decode(ptr); err != nil {
+		err = fmt.Errorf("%w. %v", ErrRequestJSONInvalid, err)
+		return
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		err = fmt.Errorf("%w. %v", ErrRequestJSONInvalid, "unexpected data after the json value")
+		return
+	}
+	err = nil
 	return
 }
+
+// isJSON reports whether a content type is json, e.g. "application/json; charset=utf-8"
+func isJSON(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mediaType != "application/json" && !(strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")) {
+		return false
+	}
+	// json is always utf-8 (RFC 8259)
+	charset, ok := params["charset"]
+	return !ok || strings.EqualFold(charset, "utf-8")
+}
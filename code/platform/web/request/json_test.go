@@ -74,3 +74,93 @@ func TestRequestJSON(t *testing.T) {
 		require.Equal(t, expectedSchema, inputSchema)
 	})
 }
+
+// Tests for JSONWith function
+func TestRequestJSONWith(t *testing.T) {
+	type schema struct {
+		Name string `json:"name"`
+	}
+	newRequest := func(contentType, body string) *http.Request {
+		return &http.Request{
+			Header: http.Header{"Content-Type": []string{contentType}},
+			Body:   io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	t.Run("success - media type parameters", func(t *testing.T) {
+		for _, contentType := range []string{"application/json; charset=utf-8", "application/json;charset=UTF-8", "application/merge-patch+json"} {
+			// act
+			var inputSchema schema
+			err := request.JSONWith(newRequest(contentType, `{"name":"test"}`), &inputSchema, request.JSONOptions{})
+
+			// assert
+			require.NoError(t, err, contentType)
+			require.Equal(t, schema{Name: "test"}, inputSchema)
+		}
+	})
+
+	t.Run("error - charset", func(t *testing.T) {
+		// act
+		var inputSchema schema
+		err := request.JSONWith(newRequest("application/json; charset=latin1", `{"name":"test"}`), &inputSchema, request.JSONOptions{})
+
+		// assert
+		require.ErrorIs(t, err, request.ErrRequestContentTypeNotJSON)
+	})
+
+	t.Run("error - unknown field", func(t *testing.T) {
+		// act
+		var inputSchema schema
+		err := request.JSONWith(newRequest("application/json", `{"name":"test","price":1}`), &inputSchema, request.JSONOptions{})
+
+		// assert
+		require.ErrorIs(t, err, request.ErrRequestJSONInvalid)
+		require.EqualError(t, err, `request json invalid. json: unknown field "price"`)
+	})
+
+	t.Run("success - unknown field allowed", func(t *testing.T) {
+		// act
+		var inputSchema schema
+		err := request.JSONWith(newRequest("application/json", `{"name":"test","price":1}`), &inputSchema, request.JSONOptions{AllowUnknownFields: true})
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, schema{Name: "test"}, inputSchema)
+	})
+
+	t.Run("error - trailing data", func(t *testing.T) {
+		// act
+		var inputSchema schema
+		err := request.JSONWith(newRequest("application/json", `{"name":"test"}{"name":"other"}`), &inputSchema, request.JSONOptions{})
+
+		// assert
+		require.ErrorIs(t, err, request.ErrRequestJSONInvalid)
+	})
+
+	t.Run("error - body too large", func(t *testing.T) {
+		// act
+		var inputSchema schema
+		err := request.JSONWith(newRequest("application/json", `{"name":"test"}`), &inputSchema, request.JSONOptions{MaxBytes: 10})
+
+		// assert
+		require.ErrorIs(t, err, request.ErrRequestBodyTooLarge)
+		require.Equal(t, schema{}, inputSchema)
+	})
+
+	t.Run("error - schema", func(t *testing.T) {
+		// arrange
+		sc, err := request.NewSchema([]byte(`{"type":"object","required":["name"],"properties":{"name":{"type":"string","minLength":1}}}`), "")
+		require.NoError(t, err)
+
+		// act
+		var inputSchema schema
+		err = request.JSONWith(newRequest("application/json", `{"name":""}`), &inputSchema, request.JSONOptions{Schema: sc})
+
+		// assert
+		var schemaErr *request.SchemaError
+		require.ErrorIs(t, err, request.ErrRequestJSONSchema)
+		require.ErrorAs(t, err, &schemaErr)
+		require.Equal(t, "/name", schemaErr.Path)
+		require.Equal(t, schema{}, inputSchema)
+	})
+}
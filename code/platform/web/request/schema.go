@@ -0,0 +1,378 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrRequestJSONSchema is used when the request json does not match the schema.
+	ErrRequestJSONSchema = errors.New("request json does not match the schema")
+	// ErrSchemaInvalid is used when a schema cannot be compiled.
+	ErrSchemaInvalid = errors.New("schema invalid")
+)
+
+// SchemaError is a violation of the schema, at the json pointer of the offending value
+type SchemaError struct {
+	// Path is the json pointer of the value, e.g. "/price"
+	Path string
+	// Reason describes the violation
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s. %s: %s", ErrRequestJSONSchema, e.Path, e.Reason)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrRequestJSONSchema
+}
+
+// Schema is a JSON Schema. It supports the validation keywords used to describe request bodies:
+// type, enum, const, properties, required, additionalProperties, items, minItems, maxItems,
+// minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum,
+// allOf, anyOf, oneOf and local $ref. Other keywords (description, format, examples...) are ignored.
+type Schema struct {
+	// root is the whole document, to resolve $ref
+	root any
+	// node is the schema within the document
+	node map[string]any
+	// patterns caches the compiled patterns
+	patterns map[string]*regexp.Regexp
+}
+
+// NewSchema compiles the schema at the json pointer ref (e.g. "#/components/schemas/Product")
+// of a json document, or the whole document if ref is empty.
+func NewSchema(doc []byte, ref string) (s *Schema, err error) {
+	var root any
+	if err = json.Unmarshal(doc, &root); err != nil {
+		err = fmt.Errorf("%w: %v", ErrSchemaInvalid, err)
+		return
+	}
+
+	s = &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	if s.node, err = s.resolve(ref); err != nil {
+		s = nil
+		return
+	}
+	// compile the patterns upfront, so an invalid one fails here rather than on a request
+	if err = s.compile(s.node, make(map[string]bool)); err != nil {
+		s = nil
+	}
+	return
+}
+
+// Validate checks a value decoded with json.Decoder.UseNumber against the schema
+func (s *Schema) Validate(v any) (err error) {
+	return s.validate(s.node, v, "")
+}
+
+// resolve returns the schema at a local json pointer
+func (s *Schema) resolve(ref string) (node map[string]any, err error) {
+	if ref != "" && !strings.HasPrefix(ref, "#") {
+		err = fmt.Errorf("%w: only local $ref are supported: %s", ErrSchemaInvalid, ref)
+		return
+	}
+
+	cur := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(ref, "#"), "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch c := cur.(type) {
+		case map[string]any:
+			cur = c[token]
+		case []any:
+			i, _ := strconv.Atoi(token)
+			if i < 0 || i >= len(c) {
+				cur = nil
+				break
+			}
+			cur = c[i]
+		default:
+			cur = nil
+		}
+	}
+
+	node, ok := cur.(map[string]any)
+	if !ok {
+		err = fmt.Errorf("%w: %q is not a schema", ErrSchemaInvalid, ref)
+	}
+	return
+}
+
+// compile resolves the references and compiles the patterns reachable from node
+func (s *Schema) compile(node map[string]any, seen map[string]bool) (err error) {
+	if ref, ok := node["$ref"].(string); ok {
+		if seen[ref] {
+			return
+		}
+		seen[ref] = true
+		var target map[string]any
+		if target, err = s.resolve(ref); err != nil {
+			return
+		}
+		return s.compile(target, seen)
+	}
+
+	if p, ok := node["pattern"].(string); ok {
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(p); err != nil {
+			return fmt.Errorf("%w: pattern %q: %v", ErrSchemaInvalid, p, err)
+		}
+		s.patterns[p] = re
+	}
+
+	var children []any
+	for _, key := range []string{"items", "additionalProperties"} {
+		children = append(children, node[key])
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := node[key].([]any)
+		children = append(children, list...)
+	}
+	properties, _ := node["properties"].(map[string]any)
+	for _, p := range properties {
+		children = append(children, p)
+	}
+	for _, child := range children {
+		if c, ok := child.(map[string]any); ok {
+			if err = s.compile(c, seen); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// validate checks v against the schema node, at the json pointer path
+func (s *Schema) validate(node map[string]any, v any, path string) (err error) {
+	fail := func(format string, args ...any) error {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		return &SchemaError{Path: p, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if ref, ok := node["$ref"].(string); ok {
+		var target map[string]any
+		if target, err = s.resolve(ref); err != nil {
+			return
+		}
+		return s.validate(target, v, path)
+	}
+
+	// type
+	if t, ok := node["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []any:
+			for _, tt := range t {
+				if ts, ok := tt.(string); ok {
+					types = append(types, ts)
+				}
+			}
+		}
+		match := false
+		for _, t := range types {
+			if isType(v, t) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return fail("must be of type %s", strings.Join(types, " or "))
+		}
+	}
+
+	// enum and const
+	if enum, ok := node["enum"].([]any); ok {
+		match := false
+		for _, e := range enum {
+			if equal(v, e) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return fail("must be one of %v", enum)
+		}
+	}
+	if c, ok := node["const"]; ok && !equal(v, c) {
+		return fail("must be %v", c)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if err = s.validateObject(node, v, path, fail); err != nil {
+			return
+		}
+	case []any:
+		if n, ok := number(node["minItems"]); ok && float64(len(v)) < n {
+			return fail("must have at least %v items", n)
+		}
+		if n, ok := number(node["maxItems"]); ok && float64(len(v)) > n {
+			return fail("must have at most %v items", n)
+		}
+		if items, ok := node["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err = s.validate(items, item, path+"/"+strconv.Itoa(i)); err != nil {
+					return
+				}
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := number(node["minLength"]); ok && length < n {
+			return fail("must be at least %v characters long", n)
+		}
+		if n, ok := number(node["maxLength"]); ok && length > n {
+			return fail("must be at most %v characters long", n)
+		}
+		if p, ok := node["pattern"].(string); ok && !s.patterns[p].MatchString(v) {
+			return fail("must match the pattern %s", p)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if n, ok := number(node["minimum"]); ok && f < n {
+			return fail("must be greater than or equal to %v", n)
+		}
+		if n, ok := number(node["maximum"]); ok && f > n {
+			return fail("must be less than or equal to %v", n)
+		}
+		if n, ok := number(node["exclusiveMinimum"]); ok && f <= n {
+			return fail("must be greater than %v", n)
+		}
+		if n, ok := number(node["exclusiveMaximum"]); ok && f >= n {
+			return fail("must be less than %v", n)
+		}
+	}
+
+	// combinators
+	if all, ok := node["allOf"].([]any); ok {
+		for _, sub := range all {
+			if sub, ok := sub.(map[string]any); ok {
+				if err = s.validate(sub, v, path); err != nil {
+					return
+				}
+			}
+		}
+	}
+	if anyOf, ok := node["anyOf"].([]any); ok {
+		if s.matches(anyOf, v, path) == 0 {
+			return fail("must match at least one of the schemas")
+		}
+	}
+	if oneOf, ok := node["oneOf"].([]any); ok {
+		if s.matches(oneOf, v, path) != 1 {
+			return fail("must match exactly one of the schemas")
+		}
+	}
+	return
+}
+
+// validateObject checks the properties of an object
+func (s *Schema) validateObject(node map[string]any, v map[string]any, path string, fail func(string, ...any) error) (err error) {
+	required, _ := node["required"].([]any)
+	for _, r := range required {
+		if name, ok := r.(string); ok {
+			if _, ok := v[name]; !ok {
+				return fail("property %q is required", name)
+			}
+		}
+	}
+
+	// sorted, so the first violation reported is stable
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	properties, _ := node["properties"].(map[string]any)
+	for _, name := range names {
+		propPath := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+		if prop, ok := properties[name].(map[string]any); ok {
+			if err = s.validate(prop, v[name], propPath); err != nil {
+				return
+			}
+			continue
+		}
+		switch additional := node["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fail("property %q is not allowed", name)
+			}
+		case map[string]any:
+			if err = s.validate(additional, v[name], propPath); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// matches returns how many of the schemas v is valid against
+func (s *Schema) matches(schemas []any, v any, path string) (n int) {
+	for _, sub := range schemas {
+		if sub, ok := sub.(map[string]any); ok && s.validate(sub, v, path) == nil {
+			n++
+		}
+	}
+	return
+}
+
+// isType reports whether v is of the json schema type t
+func isType(v any, t string) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		if t != "integer" {
+			return false
+		}
+		// 1.0 is an integer too
+		r, ok := new(big.Rat).SetString(v.String())
+		return ok && r.IsInt()
+	}
+	return false
+}
+
+// number returns a numeric keyword of the schema
+func number(v any) (n float64, ok bool) {
+	n, ok = v.(float64)
+	return
+}
+
+// equal compares a value decoded with UseNumber against one of the schema
+func equal(v, schema any) bool {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		s, isNumber := schema.(float64)
+		return err == nil && isNumber && f == s
+	}
+	return reflect.DeepEqual(v, schema)
+}
@@ -0,0 +1,88 @@
+package request_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/platform/web/request"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Schema
+func TestSchema_Validate(t *testing.T) {
+	doc := []byte(`{
+		"components": {"schemas": {
+			"Product": {
+				"type": "object",
+				"required": ["name", "quantity"],
+				"additionalProperties": false,
+				"properties": {
+					"name": {"type": "string", "maxLength": 5, "pattern": "^[a-z]+$"},
+					"quantity": {"type": "integer", "minimum": 0},
+					"price": {"type": "number", "exclusiveMinimum": 0},
+					"tags": {"type": "array", "maxItems": 2, "items": {"$ref": "#/components/schemas/Tag"}},
+					"status": {"enum": ["draft", "published"]},
+					"note": {"type": ["string", "null"]}
+				}
+			},
+			"Tag": {"type": "string", "minLength": 1}
+		}}
+	}`)
+	sc, err := request.NewSchema(doc, "#/components/schemas/Product")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name string
+		body string
+		path string
+	}{
+		{name: "valid", body: `{"name":"abc","quantity":1,"price":1.5,"tags":["a"],"status":"draft","note":null}`},
+		{name: "integer written as float", body: `{"name":"abc","quantity":2.0}`},
+		{name: "required", body: `{"name":"abc"}`, path: "/"},
+		{name: "type", body: `{"name":"abc","quantity":"1"}`, path: "/quantity"},
+		{name: "integer", body: `{"name":"abc","quantity":1.5}`, path: "/quantity"},
+		{name: "minimum", body: `{"name":"abc","quantity":-1}`, path: "/quantity"},
+		{name: "exclusive minimum", body: `{"name":"abc","quantity":1,"price":0}`, path: "/price"},
+		{name: "max length", body: `{"name":"abcdef","quantity":1}`, path: "/name"},
+		{name: "pattern", body: `{"name":"ABC","quantity":1}`, path: "/name"},
+		{name: "additional properties", body: `{"name":"abc","quantity":1,"color":"red"}`, path: "/"},
+		{name: "ref", body: `{"name":"abc","quantity":1,"tags":[""]}`, path: "/tags/0"},
+		{name: "max items", body: `{"name":"abc","quantity":1,"tags":["a","b","c"]}`, path: "/tags"},
+		{name: "enum", body: `{"name":"abc","quantity":1,"status":"gone"}`, path: "/status"},
+		{name: "nullable", body: `{"name":"abc","quantity":1,"note":1}`, path: "/note"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			dec := json.NewDecoder(bytes.NewReader([]byte(c.body)))
+			dec.UseNumber()
+			var v any
+			require.NoError(t, dec.Decode(&v))
+
+			// act
+			err := sc.Validate(v)
+
+			// assert
+			if c.path == "" {
+				require.NoError(t, err)
+				return
+			}
+			var schemaErr *request.SchemaError
+			require.ErrorAs(t, err, &schemaErr)
+			require.Equal(t, c.path, schemaErr.Path)
+		})
+	}
+}
+
+func TestNewSchema(t *testing.T) {
+	t.Run("error - missing ref", func(t *testing.T) {
+		_, err := request.NewSchema([]byte(`{"type":"object"}`), "#/components/schemas/Missing")
+		require.ErrorIs(t, err, request.ErrSchemaInvalid)
+	})
+
+	t.Run("error - invalid pattern", func(t *testing.T) {
+		_, err := request.NewSchema([]byte(`{"properties":{"name":{"pattern":"("}}}`), "")
+		require.ErrorIs(t, err, request.ErrSchemaInvalid)
+	})
+}
@@ -0,0 +1,73 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentTypeNDJSON is the media type of newline delimited json
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// NDJSON streams newline delimited json values, one per line, flushing them to the client
+// every flushEvery values so it can start processing before the response ends
+type NDJSON struct {
+	w          http.ResponseWriter
+	rc         *http.ResponseController
+	enc        *json.Encoder
+	flushEvery int
+	// count is the number of values written
+	count int
+}
+
+// NewNDJSON returns a new NDJSON writing to w. The status and headers are written with the first value.
+func NewNDJSON(w http.ResponseWriter, flushEvery int) *NDJSON {
+	if flushEvery <= 0 {
+		flushEvery = 1
+	}
+	return &NDJSON{
+		w:          w,
+		rc:         http.NewResponseController(w),
+		enc:        json.NewEncoder(w),
+		flushEvery: flushEvery,
+	}
+}
+
+// Encode writes a value on its own line
+func (n *NDJSON) Encode(v any) (err error) {
+	if n.count == 0 {
+		n.w.Header().Set("Content-Type", ContentTypeNDJSON)
+		n.w.WriteHeader(http.StatusOK)
+	}
+
+	if err = n.enc.Encode(v); err != nil {
+		return
+	}
+	n.count++
+	if n.count%n.flushEvery == 0 {
+		err = n.flush()
+	}
+	return
+}
+
+// Count returns the number of values written; once it is not zero the status can no longer change
+func (n *NDJSON) Count() int {
+	return n.count
+}
+
+// Close ends the stream, writing the headers of an empty one and flushing the pending values
+func (n *NDJSON) Close() (err error) {
+	if n.count == 0 {
+		n.w.Header().Set("Content-Type", ContentTypeNDJSON)
+		n.w.WriteHeader(http.StatusOK)
+		return
+	}
+	return n.flush()
+}
+
+// flush sends the values written so far, if the writer supports it
+func (n *NDJSON) flush() (err error) {
+	if err = n.rc.Flush(); err == http.ErrNotSupported {
+		err = nil
+	}
+	return
+}
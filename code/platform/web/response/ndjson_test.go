@@ -0,0 +1,48 @@
+package response_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rhinosc/web-market/code/platform/web/response"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for NDJSON
+func TestNDJSON(t *testing.T) {
+	t.Run("200 - one value per line, flushed", func(t *testing.T) {
+		// arrange
+		rr := httptest.NewRecorder()
+		nd := response.NewNDJSON(rr, 2)
+
+		// act
+		require.NoError(t, nd.Encode(map[string]int{"id": 1}))
+		require.False(t, rr.Flushed)
+		require.NoError(t, nd.Encode(map[string]int{"id": 2}))
+		require.True(t, rr.Flushed)
+		require.NoError(t, nd.Encode(map[string]int{"id": 3}))
+		require.NoError(t, nd.Close())
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+		require.Equal(t, "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", rr.Body.String())
+		require.Equal(t, 3, nd.Count())
+	})
+
+	t.Run("200 - empty stream", func(t *testing.T) {
+		// arrange
+		rr := httptest.NewRecorder()
+		nd := response.NewNDJSON(rr, 10)
+
+		// act
+		require.NoError(t, nd.Close())
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+		require.Empty(t, rr.Body.String())
+	})
+}
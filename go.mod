@@ -3,7 +3,9 @@ module github.com/rhinosc/web-market
 go 1.21.4
 
 require (
+	github.com/andybalholm/brotli v1.0.6
 	github.com/go-chi/chi/v5 v5.0.11
+	github.com/klauspost/compress v1.17.4
 	github.com/stretchr/testify v1.8.4
 )
 